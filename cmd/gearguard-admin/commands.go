@@ -0,0 +1,407 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// findUser resolves a user by ID or email
+func findUser(ref string) (models.User, error) {
+	var user models.User
+	query := database.DB.Where("email = ?", ref)
+	if id, err := strconv.Atoi(ref); err == nil {
+		query = database.DB.Where("id = ?", id)
+	}
+	if err := query.First(&user).Error; err != nil {
+		return user, fmt.Errorf("user %q not found", ref)
+	}
+	return user, nil
+}
+
+// findTeam resolves a team by ID or name
+func findTeam(ref string) (models.MaintenanceTeam, error) {
+	var team models.MaintenanceTeam
+	query := database.DB.Where("name = ?", ref)
+	if id, err := strconv.Atoi(ref); err == nil {
+		query = database.DB.Where("id = ?", id)
+	}
+	if err := query.First(&team).Error; err != nil {
+		return team, fmt.Errorf("team %q not found", ref)
+	}
+	return team, nil
+}
+
+// optionalUserID resolves a user reference, where "none" clears the assignment
+func optionalUserID(ref string) (*uint, error) {
+	if ref == "none" {
+		return nil, nil
+	}
+	user, err := findUser(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &user.ID, nil
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
+}
+
+func printUsers(users []models.User) {
+	teams := map[uint]string{}
+	var all []models.MaintenanceTeam
+	database.DB.Find(&all)
+	for _, t := range all {
+		teams[t.ID] = t.Name
+	}
+
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		team := "-"
+		if u.TeamID != nil {
+			team = teams[*u.TeamID]
+		}
+		status := "active"
+		if u.Disabled {
+			status = "disabled"
+		}
+		rows = append(rows, []string{strconv.Itoa(int(u.ID)), u.Name, u.Email, string(u.Role), team, status})
+	}
+	printResult(users, []string{"ID", "NAME", "EMAIL", "ROLE", "TEAM", "STATUS"}, rows)
+}
+
+func printTeams(teams []models.MaintenanceTeam) {
+	rows := make([][]string, 0, len(teams))
+	for _, t := range teams {
+		rows = append(rows, []string{strconv.Itoa(int(t.ID)), t.Name, strconv.Itoa(len(t.Members)), strconv.FormatFloat(t.HourlyRate, 'f', 2, 64)})
+	}
+	printResult(teams, []string{"ID", "NAME", "MEMBERS", "HOURLY RATE"}, rows)
+}
+
+func runUser(action string, args []string) error {
+	switch action {
+	case "create":
+		fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+		name := fs.String("name", "", "full name")
+		email := fs.String("email", "", "email address")
+		role := fs.String("role", "Employee", "Employee, Technician or Manager")
+		teamRef := fs.String("team", "", "team ID or name")
+		password := fs.String("password", "", "initial password (generated when empty)")
+		if _, err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		if *name == "" || *email == "" {
+			return fmt.Errorf("-name and -email are required")
+		}
+		parsedRole, err := models.ParseRole(*role)
+		if err != nil {
+			return err
+		}
+
+		user := models.User{Name: *name, Email: *email, Role: parsedRole}
+		if *teamRef != "" {
+			team, err := findTeam(*teamRef)
+			if err != nil {
+				return err
+			}
+			user.TeamID = &team.ID
+		}
+
+		generated := *password == ""
+		if generated {
+			b := make([]byte, 9)
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+			*password = hex.EncodeToString(b)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.Password = string(hash)
+
+		if err := database.DB.Create(&user).Error; err != nil {
+			return err
+		}
+		services.RecordAudit(0, services.EntityUser, user.ID, models.AuditCreate, nil, user)
+
+		printUsers([]models.User{user})
+		if generated {
+			fmt.Fprintln(os.Stderr, "Generated password:", *password)
+		}
+		return nil
+
+	case "list":
+		fs := flag.NewFlagSet("user list", flag.ContinueOnError)
+		role := fs.String("role", "", "only users with this role")
+		teamRef := fs.String("team", "", "only members of this team")
+		disabled := fs.Bool("disabled", false, "only disabled users")
+		if _, err := parseFlags(fs, args); err != nil {
+			return err
+		}
+
+		query := database.DB.Order("id")
+		if *role != "" {
+			parsedRole, err := models.ParseRole(*role)
+			if err != nil {
+				return err
+			}
+			query = query.Where("role = ?", parsedRole)
+		}
+		if *teamRef != "" {
+			team, err := findTeam(*teamRef)
+			if err != nil {
+				return err
+			}
+			query = query.Where("team_id = ?", team.ID)
+		}
+		if *disabled {
+			query = query.Where("disabled = ?", true)
+		}
+
+		var users []models.User
+		if err := query.Find(&users).Error; err != nil {
+			return err
+		}
+		printUsers(users)
+		return nil
+
+	case "disable", "enable":
+		if len(args) != 1 {
+			return fmt.Errorf("user %s needs a user", action)
+		}
+		return updateUser(args[0], func(u *models.User) error {
+			u.Disabled = action == "disable"
+			return nil
+		})
+
+	case "set-role":
+		if len(args) != 2 {
+			return fmt.Errorf("user set-role needs a user and a role")
+		}
+		role, err := models.ParseRole(args[1])
+		if err != nil {
+			return err
+		}
+		return updateUser(args[0], func(u *models.User) error {
+			u.Role = role
+			return nil
+		})
+
+	case "reset-mfa":
+		if len(args) != 1 {
+			return fmt.Errorf("user reset-mfa needs a user")
+		}
+		user, err := findUser(args[0])
+		if err != nil {
+			return err
+		}
+		// Recovery codes and sessions tied to the old second factor go with it
+		if err := database.DB.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		err = database.DB.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return updateUser(args[0], func(u *models.User) error {
+			u.TOTPSecret = ""
+			u.TOTPEnabled = false
+			u.TOTPLastStep = 0
+			return nil
+		})
+
+	case "unlock":
+		if len(args) != 1 {
+			return fmt.Errorf("user unlock needs a user")
+		}
+		user, err := findUser(args[0])
+		if err != nil {
+			return err
+		}
+		// Same key handlers.Login counts failed sign-ins under
+		key := "login:account:" + strings.ToLower(user.Email)
+		if err := database.DB.Delete(&models.Throttle{}, "key = ?", key).Error; err != nil {
+			return err
+		}
+		printMessage(fmt.Sprintf("Cleared failed sign-ins for %s", user.Email), map[string]interface{}{"user_id": user.ID})
+		return nil
+
+	case "set-team":
+		if len(args) != 2 {
+			return fmt.Errorf("user set-team needs a user and a team (or none)")
+		}
+		return updateUser(args[0], func(u *models.User) error {
+			if args[1] == "none" {
+				u.TeamID = nil
+				return nil
+			}
+			team, err := findTeam(args[1])
+			if err != nil {
+				return err
+			}
+			u.TeamID = &team.ID
+			return nil
+		})
+	}
+	return fmt.Errorf("unknown user command %q", action)
+}
+
+// updateUser loads a user, applies change, saves and audits it
+func updateUser(ref string, change func(*models.User) error) error {
+	user, err := findUser(ref)
+	if err != nil {
+		return err
+	}
+	before := user
+	if err := change(&user); err != nil {
+		return err
+	}
+	if err := database.DB.Save(&user).Error; err != nil {
+		return err
+	}
+	services.RecordAudit(0, services.EntityUser, user.ID, models.AuditUpdate, before, user)
+
+	printUsers([]models.User{user})
+	return nil
+}
+
+func runTeam(action string, args []string) error {
+	switch action {
+	case "create":
+		fs := flag.NewFlagSet("team create", flag.ContinueOnError)
+		name := fs.String("name", "", "team name")
+		rate := fs.Float64("rate", 0, "default hourly labor rate")
+		if _, err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if *rate < 0 {
+			return fmt.Errorf("-rate cannot be negative")
+		}
+
+		team := models.MaintenanceTeam{Name: *name, HourlyRate: *rate}
+		if err := database.DB.Create(&team).Error; err != nil {
+			return err
+		}
+		services.RecordAudit(0, services.EntityTeam, team.ID, models.AuditCreate, nil, team)
+
+		printTeams([]models.MaintenanceTeam{team})
+		return nil
+
+	case "list":
+		var teams []models.MaintenanceTeam
+		if err := database.DB.Preload("Members").Order("id").Find(&teams).Error; err != nil {
+			return err
+		}
+		printTeams(teams)
+		return nil
+	}
+	return fmt.Errorf("unknown team command %q", action)
+}
+
+func runEquipment(action string, args []string) error {
+	if action != "reassign" {
+		return fmt.Errorf("unknown equipment command %q", action)
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("equipment reassign needs an equipment ID")
+	}
+
+	fs := flag.NewFlagSet("equipment reassign", flag.ContinueOnError)
+	owner := fs.String("owner", "", "new owner (user or none)")
+	technician := fs.String("technician", "", "new default technician (user or none)")
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *owner == "" && *technician == "" {
+		return fmt.Errorf("give -owner and/or -technician")
+	}
+
+	var equipment models.Equipment
+	if err := database.DB.First(&equipment, args[0]).Error; err != nil {
+		return fmt.Errorf("equipment %q not found", args[0])
+	}
+	before := equipment
+
+	if *owner != "" {
+		id, err := optionalUserID(*owner)
+		if err != nil {
+			return err
+		}
+		equipment.EmployeeID = id
+	}
+	if *technician != "" {
+		id, err := optionalUserID(*technician)
+		if err != nil {
+			return err
+		}
+		equipment.DefaultTechnicianID = id
+	}
+
+	if err := database.DB.Model(&equipment).Select("employee_id", "default_technician_id").Updates(&equipment).Error; err != nil {
+		return err
+	}
+	services.RecordAudit(0, services.EntityEquipment, equipment.ID, models.AuditUpdate, before, equipment)
+
+	ref := func(id *uint) string {
+		if id == nil {
+			return "-"
+		}
+		return strconv.Itoa(int(*id))
+	}
+	printResult(equipment, []string{"ID", "NAME", "OWNER", "TECHNICIAN"}, [][]string{
+		{strconv.Itoa(int(equipment.ID)), equipment.Name, ref(equipment.EmployeeID), ref(equipment.DefaultTechnicianID)},
+	})
+	return nil
+}
+
+func runTokens(action string, args []string) error {
+	if action != "purge" {
+		return fmt.Errorf("unknown tokens command %q", action)
+	}
+
+	result := database.DB.Model(&models.User{}).
+		Where("password_reset_token <> '' AND password_reset_at <= ?", time.Now()).
+		Updates(map[string]interface{}{"password_reset_token": "", "password_reset_at": time.Time{}})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Revoked refresh tokens are kept until they expire so reuse can still be detected
+	sessions := database.DB.Where("expires_at <= ?", time.Now()).Delete(&models.RefreshToken{})
+	if sessions.Error != nil {
+		return sessions.Error
+	}
+
+	// Throttle windows last at most an hour, so day-old unblocked rows are dead
+	throttles := database.DB.Where("window_start <= ? AND blocked_until <= ?", time.Now().Add(-24*time.Hour), time.Now()).Delete(&models.Throttle{})
+	if throttles.Error != nil {
+		return throttles.Error
+	}
+
+	printMessage(fmt.Sprintf("Purged %d expired password reset token(s), %d expired refresh token(s) and %d stale throttle(s)", result.RowsAffected, sessions.RowsAffected, throttles.RowsAffected),
+		map[string]interface{}{"purged": result.RowsAffected, "refresh_tokens_purged": sessions.RowsAffected, "throttles_purged": throttles.RowsAffected})
+	return nil
+}
@@ -0,0 +1,120 @@
+// Command gearguard-admin manages users, teams and equipment assignments
+// directly against the database, e.g. to create the first Manager.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gearguard/internal/database"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: gearguard-admin [-o table|json] <command> [flags]
+
+Users:
+  user create -name N -email E -role R [-team T] [-password P]
+  user list [-role R] [-team T] [-disabled]
+  user disable <user>
+  user enable <user>
+  user set-role <user> <role>
+  user set-team <user> <team|none>
+  user reset-mfa <user>       clear two-factor enrollment and recovery codes
+  user unlock <user>          clear failed sign-ins and lift a lockout
+
+Teams:
+  team create -name N [-rate R]
+  team list
+
+Equipment:
+  equipment reassign <equipment-id> [-owner <user|none>] [-technician <user|none>]
+
+Maintenance:
+  tokens purge      clear expired password reset and refresh tokens, stale throttles
+
+<user> is an ID or email address, <team> an ID or name.`
+
+// output is the format selected with -o
+var output = "table"
+
+func main() {
+	log.SetFlags(0)
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	global := flag.NewFlagSet("gearguard-admin", flag.ExitOnError)
+	global.StringVar(&output, "o", "table", "output format: table or json")
+	global.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	global.Parse(os.Args[1:])
+	if output != "table" && output != "json" {
+		fail(fmt.Errorf("-o must be table or json"))
+	}
+
+	args := global.Args()
+	if len(args) < 2 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	database.ConnectDB()
+	database.CheckMigrations()
+
+	var err error
+	switch args[0] {
+	case "user":
+		err = runUser(args[1], args[2:])
+	case "team":
+		err = runTeam(args[1], args[2:])
+	case "equipment":
+		err = runEquipment(args[1], args[2:])
+	case "tokens":
+		err = runTokens(args[1], args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+// printResult writes v as indented JSON, or as a table with the given columns
+func printResult(v interface{}, header []string, rows [][]string) {
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// printMessage reports the outcome of a command that has no records to show
+func printMessage(message string, fields map[string]interface{}) {
+	if output == "json" {
+		if fields == nil {
+			fields = map[string]interface{}{}
+		}
+		fields["message"] = message
+		printResult(fields, nil, nil)
+		return
+	}
+	fmt.Println(message)
+}
@@ -0,0 +1,53 @@
+// Command mock-idp runs a throwaway OpenID Connect provider for trying single
+// sign-on locally. Point the server at it with
+//
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=gearguard OIDC_CLIENT_SECRET=dev-secret
+//	OIDC_ROLE_GROUPS=gg-managers:Manager,gg-techs:Technician
+//	OIDC_TEAM_GROUPS="gg-mechanical:Mechanical Team"
+//
+// Signing in shows a list of users to pick from. -users replaces the built-in
+// ones with a JSON array of {"sub", "email", "name", "groups"} objects.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"gearguard/internal/oidc/oidctest"
+)
+
+var defaultUsers = []oidctest.User{
+	{Subject: "mock-manager", Email: "manager@example.com", Name: "Morgan Manager", Groups: []string{"gg-managers"}},
+	{Subject: "mock-tech", Email: "tech@example.com", Name: "Taylor Tech", Groups: []string{"gg-techs", "gg-mechanical"}},
+	{Subject: "mock-employee", Email: "employee@example.com", Name: "Emery Employee", Groups: []string{}},
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, as the server reaches it")
+	clientID := flag.String("client-id", "gearguard", "accepted client ID")
+	clientSecret := flag.String("client-secret", "dev-secret", "accepted client secret (empty for a public client)")
+	usersFile := flag.String("users", "", "JSON file of users")
+	flag.Parse()
+
+	users := defaultUsers
+	if *usersFile != "" {
+		data, err := os.ReadFile(*usersFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &users); err != nil {
+			log.Fatalf("%s: %v", *usersFile, err)
+		}
+	}
+
+	provider, err := oidctest.New(*issuer, *clientID, *clientSecret, users...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Mock identity provider %s on %s with %d users", provider.Issuer, *addr, len(users))
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
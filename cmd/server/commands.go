@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"gearguard/internal/database"
+)
+
+const commandUsage = `Usage:
+  gearguard                        start the API server
+  gearguard migrate up [N]         apply pending migrations (all, or the next N)
+  gearguard migrate down [N]       roll back the last N applied migrations (default 1)
+  gearguard migrate status         list migrations and when they were applied
+  gearguard migrate create <name>  add an empty up/down migration pair
+  gearguard seed                   insert default reference data (teams)`
+
+// runCommand executes a maintenance subcommand instead of starting the server
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate needs up, down, status or create")
+		}
+		return runMigrate(args[1], args[2:])
+	case "seed":
+		database.ConnectDB()
+		return database.Seed(database.DB)
+	case "help", "-h", "--help":
+		fmt.Println(commandUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func runMigrate(action string, args []string) error {
+	if action == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("migrate create needs a name")
+		}
+		paths, err := database.CreateMigration(database.MigrationsDir, args[0])
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return err
+	}
+
+	steps := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("step count must be a positive number")
+		}
+		steps = n
+	}
+
+	database.ConnectDB()
+	switch action {
+	case "up":
+		applied, err := database.MigrateUp(database.DB, steps)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return err
+	case "down":
+		reverted, err := database.MigrateDown(database.DB, steps)
+		for _, m := range reverted {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+		return err
+	case "status":
+		statuses, err := database.MigrationStatuses(database.DB)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate action %q", action)
+}
+
+func exitOnCommandError(err error) {
+	if err != nil {
+		log.Println(err)
+		fmt.Fprintln(os.Stderr, commandUsage)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -9,6 +9,12 @@ import (
 	"gearguard/internal/database"
 	"gearguard/internal/handlers"
 	"gearguard/internal/middleware"
+	"gearguard/internal/oidc"
+	"gearguard/internal/policy"
+	"gearguard/internal/services"
+	"gearguard/internal/storage"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -21,8 +27,44 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// Maintenance subcommands (migrate, seed) run instead of the server
+	if len(os.Args) > 1 {
+		exitOnCommandError(runCommand(os.Args[1:]))
+	}
+
+	// Token Signing Keys
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatal("Invalid JWT key configuration: ", err)
+	}
+	if err := policy.LoadMFARoles(); err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize Database
 	database.ConnectDB()
+	database.CheckMigrations()
+	db := store.NewGorm(database.DB)
+	h := handlers.New(db)
+
+	// Single Sign-On
+	sso, err := oidc.LoadConfig()
+	if err != nil {
+		log.Fatal("Invalid OIDC configuration: ", err)
+	}
+	if sso != nil {
+		h.OIDC = oidc.NewProvider(*sso)
+		log.Printf("Single sign-on enabled with %s", sso.Issuer)
+	}
+
+	// Load Request Workflow
+	services.LoadWorkflow()
+
+	// Initialize Attachment Storage
+	storage.Init()
+
+	// Background Jobs
+	services.StartScheduleGenerator(h.AnnounceRequest)
+	h.Webhooks.Start(time.Minute)
 
 	                // Initialize Router
 
@@ -46,15 +88,37 @@ func main() {
 
 	                // Public Routes
 
-	                api.HandleFunc("/register", handlers.Register).Methods("POST", "OPTIONS")
+	                api.HandleFunc("/register", h.Register).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/login", h.Login).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/refresh", h.Refresh).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/login/mfa", h.VerifyLoginMFA).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/login/mfa/enroll", h.StartLoginEnrollment).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/login/mfa/enroll/confirm", h.ConfirmLoginEnrollment).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/oidc/config", h.GetOIDCConfig).Methods("GET", "OPTIONS")
+
+	                api.HandleFunc("/oidc/login", h.StartOIDCLogin).Methods("GET", "OPTIONS")
+
+	                api.HandleFunc("/oidc/callback", h.FinishOIDCLogin).Methods("POST", "OPTIONS")
+
+	                api.HandleFunc("/forgot-password", h.ForgotPassword).Methods("POST", "OPTIONS")
 
-	                api.HandleFunc("/login", handlers.Login).Methods("POST", "OPTIONS")
+	                api.HandleFunc("/reset-password", h.ResetPassword).Methods("POST", "OPTIONS")
 
-	                api.HandleFunc("/forgot-password", handlers.ForgotPassword).Methods("POST", "OPTIONS")
+	                api.HandleFunc("/invitations/accept", h.GetInvitationByToken).Methods("GET", "OPTIONS")
 
-	                api.HandleFunc("/reset-password", handlers.ResetPassword).Methods("POST", "OPTIONS")
+	                api.HandleFunc("/invitations/accept", h.AcceptInvitation).Methods("POST", "OPTIONS")
 
-	                api.HandleFunc("/teams", handlers.GetTeams).Methods("GET", "OPTIONS")
+	                api.HandleFunc("/teams", h.GetTeams).Methods("GET", "OPTIONS")
+
+	                api.HandleFunc("/attachments/{id}/download", handlers.DownloadAttachment).Methods("GET", "OPTIONS")
+
+	                api.HandleFunc("/calendar/feeds/{token:[0-9a-f]{64}}.ics", h.GetCalendarFeedICS).Methods("GET", "OPTIONS")
 
 	        
 
@@ -62,43 +126,276 @@ func main() {
 
 	                protected := api.PathPrefix("/").Subrouter()
 
-	                protected.Use(middleware.AuthMiddleware)
+	                protected.Use(middleware.Auth(db))
+
+	        
+
+	                // Session Routes
+
+	                protected.Handle("/logout", policy.Require(policy.Delete, policy.Session, h.Logout)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/logout-all", policy.Require(policy.Delete, policy.Session, h.LogoutAll)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/api-keys", policy.Require(policy.Read, policy.APIKey, h.GetAPIKeys)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/api-keys", policy.Require(policy.Create, policy.APIKey, h.CreateAPIKey)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/api-keys/{id}", policy.Require(policy.Delete, policy.APIKey, h.RevokeAPIKey)).Methods("DELETE", "OPTIONS")
+
+	        
+
+	                // Two-Factor Authentication
+
+	                protected.Handle("/mfa/totp", policy.Require(policy.Create, policy.MFA, h.StartTOTPEnrollment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/mfa/totp/confirm", policy.Require(policy.Create, policy.MFA, h.ConfirmTOTPEnrollment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/mfa/totp", policy.Require(policy.Delete, policy.MFA, h.DisableTOTP)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/mfa/recovery-codes", policy.Require(policy.Create, policy.MFA, h.RegenerateRecoveryCodes)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/users/{id}/mfa/reset", policy.Require(policy.Update, policy.User, h.ResetUserMFA)).Methods("POST", "OPTIONS")
 
 	        
 
 	                // Team & User Routes
 
-	                protected.HandleFunc("/teams", handlers.CreateTeam).Methods("POST", "OPTIONS")
+	                protected.Handle("/teams", policy.Require(policy.Create, policy.Team, h.CreateTeam)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/users/employees", policy.Require(policy.Read, policy.User, h.GetEmployees)).Methods("GET", "OPTIONS")
 
-	                protected.HandleFunc("/users/employees", handlers.GetEmployees).Methods("GET", "OPTIONS")
+	                protected.Handle("/users/technicians", policy.Require(policy.Read, policy.User, h.GetTechnicians)).Methods("GET", "OPTIONS")
 
-	                protected.HandleFunc("/users/technicians", handlers.GetTechnicians).Methods("GET", "OPTIONS")
+	                protected.Handle("/invitations", policy.Require(policy.Read, policy.Invitation, h.GetInvitations)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/invitations", policy.Require(policy.Create, policy.Invitation, h.CreateInvitation)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/invitations/{id}/resend", policy.Require(policy.Update, policy.Invitation, h.ResendInvitation)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/invitations/{id}", policy.Require(policy.Delete, policy.Invitation, h.RevokeInvitation)).Methods("DELETE", "OPTIONS")
 
 	        
 
 	                // Equipment Routes
 
-	                protected.HandleFunc("/equipment", handlers.CreateEquipment).Methods("POST", "OPTIONS")
+	                protected.Handle("/equipment", policy.Require(policy.Create, policy.Equipment, h.CreateEquipment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/equipment", policy.Require(policy.Read, policy.Equipment, h.GetEquipment)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}", policy.Require(policy.Read, policy.Equipment, h.GetEquipmentByID)).Methods("GET", "OPTIONS")
 
-	                protected.HandleFunc("/equipment", handlers.GetEquipment).Methods("GET", "OPTIONS")
+	                protected.Handle("/equipment/{id}", policy.Require(policy.Update, policy.Equipment, h.UpdateEquipment)).Methods("PATCH", "OPTIONS")
 
-	                protected.HandleFunc("/equipment/{id}/requests", handlers.GetEquipmentRequests).Methods("GET", "OPTIONS")
+	                protected.Handle("/equipment/{id}", policy.Require(policy.Delete, policy.Equipment, h.ArchiveEquipment)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/restore", policy.Require(policy.Delete, policy.Equipment, h.RestoreEquipment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/transfer", policy.Require(policy.Create, policy.Transfer, h.TransferEquipment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/transfers", policy.Require(policy.Read, policy.Transfer, h.GetEquipmentTransfers)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/requests", policy.Require(policy.Read, policy.Request, h.GetEquipmentRequests)).Methods("GET", "OPTIONS")
 
 	        
 
 	                // Maintenance Request Routes
 
-	                protected.HandleFunc("/requests", handlers.CreateRequest).Methods("POST", "OPTIONS")
+	                protected.Handle("/requests", policy.Require(policy.Create, policy.Request, h.CreateRequest)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/requests", policy.Require(policy.Read, policy.Request, h.GetRequests)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/requests/{id}", policy.Require(policy.Update, policy.Request, h.UpdateRequest)).Methods("PUT", "OPTIONS")
+
+	        
+
+	                // Calendar Routes
+
+	                protected.Handle("/calendar", policy.Require(policy.Read, policy.Calendar, h.GetCalendar)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/calendar/feeds", policy.Require(policy.Read, policy.CalendarFeed, h.GetCalendarFeeds)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/calendar/feeds", policy.Require(policy.Create, policy.CalendarFeed, h.CreateCalendarFeed)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/calendar/feeds/{id}", policy.Require(policy.Delete, policy.CalendarFeed, h.RevokeCalendarFeed)).Methods("DELETE", "OPTIONS")
+
+	        
+
+	                // Workflow
+
+	                protected.Handle("/workflow", policy.Require(policy.Read, policy.Workflow, handlers.GetWorkflow)).Methods("GET", "OPTIONS")
+
+	        
 
-	                protected.HandleFunc("/requests", handlers.GetRequests).Methods("GET", "OPTIONS")
+	                // History (Audit Trail)
 
-	                protected.HandleFunc("/requests/{id}", handlers.UpdateRequest).Methods("PUT", "OPTIONS")
+	                protected.Handle("/requests/{id}/history", policy.Require(policy.Read, policy.History, handlers.GetRequestHistory)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/history", policy.Require(policy.Read, policy.History, handlers.GetEquipmentHistory)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Comment Routes
+
+	                protected.Handle("/requests/{id}/comments", policy.Require(policy.Read, policy.Comment, h.GetComments)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/requests/{id}/comments", policy.Require(policy.Create, policy.Comment, h.CreateComment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/comments/{id}", policy.Require(policy.Update, policy.Comment, h.UpdateComment)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/comments/{id}", policy.Require(policy.Delete, policy.Comment, h.DeleteComment)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/comments/{id}/history", policy.Require(policy.Read, policy.History, h.GetCommentHistory)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Attachment Routes
+
+	                protected.Handle("/equipment/{id}/attachments", policy.Require(policy.Create, policy.Attachment, handlers.UploadEquipmentAttachment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/attachments", policy.Require(policy.Read, policy.Attachment, handlers.GetEquipmentAttachments)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/requests/{id}/attachments", policy.Require(policy.Create, policy.Attachment, handlers.UploadRequestAttachment)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/requests/{id}/attachments", policy.Require(policy.Read, policy.Attachment, handlers.GetRequestAttachments)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/attachments/{id}", policy.Require(policy.Read, policy.Attachment, handlers.GetAttachment)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/attachments/{id}", policy.Require(policy.Delete, policy.Attachment, handlers.DeleteAttachment)).Methods("DELETE", "OPTIONS")
+
+	        
+
+	                // Inventory Routes
+
+	                protected.Handle("/parts", policy.Require(policy.Create, policy.Part, handlers.CreatePart)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/parts", policy.Require(policy.Read, policy.Part, handlers.GetParts)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/parts/{id}", policy.Require(policy.Read, policy.Part, handlers.GetPart)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/parts/{id}", policy.Require(policy.Update, policy.Part, handlers.UpdatePart)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/parts/{id}/receive", policy.Require(policy.Update, policy.Stock, handlers.ReceiveStock)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/parts/{id}/adjust", policy.Require(policy.Update, policy.Stock, handlers.AdjustStock)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/parts/{id}/issue", policy.Require(policy.Update, policy.Stock, handlers.IssueStock)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/parts/{id}/movements", policy.Require(policy.Read, policy.Stock, handlers.GetStockMovements)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/requests/{id}/parts", policy.Require(policy.Read, policy.Part, handlers.GetRequestParts)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Cost Routes
+
+	                protected.Handle("/requests/{id}/vendor-costs", policy.Require(policy.Create, policy.Cost, handlers.AddVendorCost)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/requests/{id}/vendor-costs", policy.Require(policy.Read, policy.Cost, handlers.GetVendorCosts)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/vendor-costs/{id}", policy.Require(policy.Delete, policy.Cost, handlers.DeleteVendorCost)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/costs", policy.Require(policy.Read, policy.Cost, handlers.GetEquipmentCosts)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/users/{id}/labor-rate", policy.Require(policy.Update, policy.User, handlers.SetUserLaborRate)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/teams/{id}/labor-rate", policy.Require(policy.Update, policy.Team, handlers.SetTeamLaborRate)).Methods("PUT", "OPTIONS")
+
+	        
+
+	                // Analytics
+
+	                protected.Handle("/analytics/reliability", policy.Require(policy.Read, policy.Analytics, handlers.GetReliability)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Location & Assembly Routes
+
+	                protected.Handle("/locations", policy.Require(policy.Create, policy.Location, h.CreateLocation)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/locations", policy.Require(policy.Read, policy.Location, h.GetLocations)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/locations/import-legacy", policy.Require(policy.Create, policy.Location, h.ImportLegacyLocations)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/locations/{id}", policy.Require(policy.Read, policy.Location, h.GetLocation)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/locations/{id}", policy.Require(policy.Update, policy.Location, h.UpdateLocation)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/locations/{id}", policy.Require(policy.Delete, policy.Location, h.DeleteLocation)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/locations/{id}/rollup", policy.Require(policy.Read, policy.Location, h.GetLocationRollup)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/parent", policy.Require(policy.Update, policy.Equipment, h.SetEquipmentParent)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/children", policy.Require(policy.Read, policy.Equipment, h.GetEquipmentChildren)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/rollup", policy.Require(policy.Read, policy.Equipment, h.GetEquipmentRollup)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Preventive Schedule Routes
+
+	                protected.Handle("/schedules", policy.Require(policy.Create, policy.Schedule, h.CreateSchedule)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/schedules", policy.Require(policy.Read, policy.Schedule, h.GetSchedules)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/schedules/{id}", policy.Require(policy.Update, policy.Schedule, h.UpdateSchedule)).Methods("PUT", "OPTIONS")
+
+	                protected.Handle("/schedules/{id}", policy.Require(policy.Delete, policy.Schedule, h.DeleteSchedule)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/schedules/{id}/occurrences", policy.Require(policy.Read, policy.Schedule, h.GetScheduleOccurrences)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Meter Routes
+
+	                protected.Handle("/equipment/{id}/meters", policy.Require(policy.Create, policy.Meter, h.CreateMeter)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/equipment/{id}/meters", policy.Require(policy.Read, policy.Meter, h.GetEquipmentMeters)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/meters/readings", policy.Require(policy.Create, policy.MeterReading, h.PostMeterReadings)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/meters/{id}/readings", policy.Require(policy.Create, policy.MeterReading, h.PostMeterReading)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/meters/{id}/readings", policy.Require(policy.Read, policy.MeterReading, h.GetMeterReadings)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/meters/{id}/rules", policy.Require(policy.Create, policy.MeterRule, h.CreateMeterRule)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/meter-rules/{id}", policy.Require(policy.Delete, policy.MeterRule, h.DeleteMeterRule)).Methods("DELETE", "OPTIONS")
 
 	        
 
 	                // Dashboard
 
-	                protected.HandleFunc("/dashboard/stats", handlers.GetDashboardStats).Methods("GET", "OPTIONS")
+	                protected.Handle("/dashboard/stats", policy.Require(policy.Read, policy.Dashboard, h.GetDashboardStats)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Real-time Updates
+
+	                protected.Handle("/events", policy.Require(policy.Read, policy.Event, h.StreamEvents)).Methods("GET", "OPTIONS")
+
+	        
+
+	                // Webhooks
+
+	                protected.Handle("/webhooks", policy.Require(policy.Read, policy.Webhook, h.GetWebhooks)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/webhooks", policy.Require(policy.Create, policy.Webhook, h.CreateWebhook)).Methods("POST", "OPTIONS")
+
+	                protected.Handle("/webhooks/{id}", policy.Require(policy.Read, policy.Webhook, h.GetWebhook)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/webhooks/{id}", policy.Require(policy.Update, policy.Webhook, h.UpdateWebhook)).Methods("PATCH", "OPTIONS")
+
+	                protected.Handle("/webhooks/{id}", policy.Require(policy.Delete, policy.Webhook, h.DeleteWebhook)).Methods("DELETE", "OPTIONS")
+
+	                protected.Handle("/webhooks/{id}/deliveries", policy.Require(policy.Read, policy.Webhook, h.GetWebhookDeliveries)).Methods("GET", "OPTIONS")
+
+	                protected.Handle("/webhooks/{id}/deliveries/{delivery}/redeliver", policy.Require(policy.Update, policy.Webhook, h.RedeliverWebhookDelivery)).Methods("POST", "OPTIONS")
+
+	                // Every protected route must declare who may call it
+	                if err := policy.VerifyRoutes(protected); err != nil {
+	                        log.Fatal(err)
+	                }
 
 	        // CORS Setup
 	        allowedOrigins := []string{
@@ -111,8 +408,8 @@ func main() {
 
 	        c := cors.New(cors.Options{
 	                AllowedOrigins:   allowedOrigins,
-	                AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-	                AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept"},
+	                AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+	                AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept", "X-API-Key", "Last-Event-ID"},
 	                AllowCredentials: true,
 	                Debug:            true,
 	        })
@@ -12,6 +12,8 @@ import Login from './pages/Login';
 import Signup from './pages/Signup';
 import ForgotPassword from './pages/ForgotPassword';
 import ResetPassword from './pages/ResetPassword';
+import AcceptInvite from './pages/AcceptInvite';
+import OIDCCallback from './pages/OIDCCallback';
 import Teams from './pages/Teams';
 import { AuthProvider, useAuth } from './services/AuthContext';
 
@@ -31,6 +33,8 @@ function App() {
           <Route path="/signup" element={<Signup />} />
           <Route path="/forgot-password" element={<ForgotPassword />} />
           <Route path="/reset-password/:token" element={<ResetPassword />} />
+          <Route path="/invite/:token" element={<AcceptInvite />} />
+          <Route path="/oidc/callback" element={<OIDCCallback />} />
           
           <Route path="/" element={<ProtectedRoute><Dashboard /></ProtectedRoute>} />
           <Route path="/equipment" element={<ProtectedRoute><EquipmentList /></ProtectedRoute>} />
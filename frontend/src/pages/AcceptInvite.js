@@ -0,0 +1,68 @@
+import React, { useState, useEffect } from 'react';
+import { Container, Form, Button, Card, Alert } from 'react-bootstrap';
+import { useParams, useNavigate } from 'react-router-dom';
+import api from '../services/api';
+
+const AcceptInvite = () => {
+    const { token } = useParams();
+    const navigate = useNavigate();
+    const [invitation, setInvitation] = useState(null);
+    const [name, setName] = useState('');
+    const [password, setPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+    const [error, setError] = useState('');
+
+    useEffect(() => {
+        api.get('/invitations/accept', { params: { token } })
+            .then(res => {
+                setInvitation(res.data);
+                setName(res.data.name || '');
+            })
+            .catch(err => setError(err.response?.data?.error || 'Invalid or expired invitation'));
+    }, [token]);
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        if (password !== confirmPassword) {
+            return setError('Passwords do not match');
+        }
+        try {
+            await api.post('/invitations/accept', { token, name, password });
+            alert('Your account is ready. Please log in.');
+            navigate('/login');
+        } catch (err) {
+            setError(err.response?.data?.error || 'Could not accept invitation');
+        }
+    };
+
+    return (
+        <Container className="d-flex justify-content-center align-items-center" style={{ minHeight: '80vh' }}>
+            <Card style={{ width: '400px' }}>
+                <Card.Body>
+                    <h2 className="text-center mb-4">Join GearGuard</h2>
+                    {error && <Alert variant="danger">{error}</Alert>}
+                    {invitation && (
+                        <Form onSubmit={handleSubmit}>
+                            <p>You were invited as a <strong>{invitation.role}</strong> with <strong>{invitation.email}</strong>.</p>
+                            <Form.Group className="mb-3">
+                                <Form.Label>Full Name</Form.Label>
+                                <Form.Control type="text" required value={name} onChange={(e) => setName(e.target.value)} />
+                            </Form.Group>
+                            <Form.Group className="mb-3">
+                                <Form.Label>Password</Form.Label>
+                                <Form.Control type="password" required minLength="6" onChange={(e) => setPassword(e.target.value)} />
+                            </Form.Group>
+                            <Form.Group className="mb-3">
+                                <Form.Label>Confirm Password</Form.Label>
+                                <Form.Control type="password" required onChange={(e) => setConfirmPassword(e.target.value)} />
+                            </Form.Group>
+                            <Button className="w-100" type="submit">Create Account</Button>
+                        </Form>
+                    )}
+                </Card.Body>
+            </Card>
+        </Container>
+    );
+};
+
+export default AcceptInvite;
@@ -1,11 +1,11 @@
-import React, { useState, useEffect } from 'react';
-import { Container, Card, Badge, Button, Modal, Dropdown } from 'react-bootstrap';
+import React, { useState, useEffect, useCallback } from 'react';
+import { Container, Card, Badge, Button, Modal, Dropdown, Form, Alert } from 'react-bootstrap';
 import { Calendar, momentLocalizer } from 'react-big-calendar';
 import moment from 'moment';
 import api from '../services/api';
 import { useNavigate } from 'react-router-dom';
 import 'react-big-calendar/lib/css/react-big-calendar.css';
-import { FaPlus, FaChevronLeft, FaChevronRight } from 'react-icons/fa';
+import { FaPlus, FaChevronLeft, FaChevronRight, FaRss } from 'react-icons/fa';
 
 // Setup the localizer by providing the moment (or globalize, or Date) Object
 const localizer = momentLocalizer(moment);
@@ -17,35 +17,59 @@ const CalendarView = () => {
     const [showModal, setShowModal] = useState(false);
     const [selectedRequest, setSelectedRequest] = useState(null);
 
-    useEffect(() => {
-        fetchRequests();
-    }, []);
+    const [teams, setTeams] = useState([]);
+    const [showSubscribe, setShowSubscribe] = useState(false);
+    const [feedTeam, setFeedTeam] = useState('');
+    const [feed, setFeed] = useState(null);
+    const [feedError, setFeedError] = useState('');
 
-    const fetchRequests = async () => {
+    // The month view also shows the end of the previous month and the start of the next
+    const fetchRequests = useCallback(async () => {
+        const from = moment(date).startOf('month').startOf('week').format('YYYY-MM-DD');
+        const to = moment(date).endOf('month').endOf('week').format('YYYY-MM-DD');
         try {
-            const res = await api.get('/requests');
-            // Filter requests that have a scheduled date
-            const calendarEvents = res.data
-                .filter(req => req.scheduled_date && req.status !== 'Scrap')
-                .map(req => {
-                    const startDate = new Date(req.scheduled_date);
-                    // Default duration 1 hour if not specified, or use duration_hours
-                    const endDate = new Date(startDate.getTime() + (req.duration_hours || 1) * 60 * 60 * 1000);
-                    
-                    return {
-                        id: req.id,
-                        title: `${req.subject} (${req.equipment?.name})`,
-                        start: startDate,
-                        end: endDate,
-                        resource: req,
-                        type: req.type,
-                        status: req.status
-                    };
-                });
+            const res = await api.get('/calendar', { params: { from, to } });
+            const calendarEvents = res.data.map(event => ({
+                id: event.request.id,
+                title: `${event.request.subject} (${event.request.equipment?.name})`,
+                start: new Date(event.start),
+                end: new Date(event.end),
+                resource: event.request,
+                type: event.request.type,
+                status: event.request.status
+            }));
             setEvents(calendarEvents);
         } catch (error) {
             console.error("Error loading calendar events", error);
         }
+    }, [date]);
+
+    useEffect(() => {
+        fetchRequests();
+    }, [fetchRequests]);
+
+    const openSubscribe = async () => {
+        setFeed(null);
+        setFeedError('');
+        setFeedTeam('');
+        setShowSubscribe(true);
+        try {
+            const res = await api.get('/teams');
+            setTeams(res.data);
+        } catch (error) {
+            console.error("Error loading teams", error);
+        }
+    };
+
+    const createFeed = async () => {
+        setFeedError('');
+        try {
+            const body = feedTeam ? { team_id: Number(feedTeam) } : {};
+            const res = await api.post('/calendar/feeds', body);
+            setFeed(res.data);
+        } catch (error) {
+            setFeedError(error.response?.data?.error || 'Could not create the subscription link');
+        }
     };
 
     // Custom styling for events
@@ -97,6 +121,9 @@ const CalendarView = () => {
                     <p className="text-muted mb-0">Track upcoming preventive and corrective tasks</p>
                 </div>
                 <div className="d-flex gap-2">
+                    <Button variant="outline-secondary" onClick={openSubscribe} className="d-flex align-items-center gap-2 shadow-sm">
+                        <FaRss size={12} /> Subscribe
+                    </Button>
                     <Button variant="primary" onClick={() => navigate('/requests/new')} className="d-flex align-items-center gap-2 shadow-sm">
                         <FaPlus size={12} /> Schedule New
                     </Button>
@@ -190,6 +217,47 @@ const CalendarView = () => {
                     </Button>
                 </Modal.Footer>
             </Modal>
+
+            {/* Calendar Subscription Modal */}
+            <Modal show={showSubscribe} onHide={() => setShowSubscribe(false)} centered>
+                <Modal.Header closeButton className="border-0 pb-0">
+                    <Modal.Title className="fw-bold">Subscribe in your calendar app</Modal.Title>
+                </Modal.Header>
+                <Modal.Body className="pt-2">
+                    {feedError && <Alert variant="danger">{feedError}</Alert>}
+                    {feed ? (
+                        <div>
+                            <p className="text-muted small">
+                                Add this URL to Google Calendar, Outlook or Apple Calendar. Anyone with the link can see
+                                these jobs, and it is only shown once; create a new one if you lose it.
+                            </p>
+                            <Form.Control readOnly value={feed.url} onFocus={e => e.target.select()} className="mb-2" />
+                            <a href={feed.webcal_url} className="small">Open in calendar app</a>
+                        </div>
+                    ) : (
+                        <Form.Group>
+                            <Form.Label>Calendar</Form.Label>
+                            <Form.Text className="d-block mb-2">Technicians and employees can subscribe to their own team's jobs.</Form.Text>
+                            <Form.Select value={feedTeam} onChange={e => setFeedTeam(e.target.value)}>
+                                <option value="">My maintenance jobs</option>
+                                {teams.map(team => (
+                                    <option key={team.id} value={team.id}>{team.name}</option>
+                                ))}
+                            </Form.Select>
+                        </Form.Group>
+                    )}
+                </Modal.Body>
+                <Modal.Footer className="border-0 pt-0">
+                    <Button variant="secondary" onClick={() => setShowSubscribe(false)}>
+                        Close
+                    </Button>
+                    {!feed && (
+                        <Button variant="primary" onClick={createFeed}>
+                            Create link
+                        </Button>
+                    )}
+                </Modal.Footer>
+            </Modal>
         </Container>
     );
 };
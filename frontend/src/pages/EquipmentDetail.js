@@ -1,40 +1,63 @@
 import React, { useState, useEffect } from 'react';
 import { useParams } from 'react-router-dom';
-import { Container, Card, Button, Badge, Row, Col, ListGroup } from 'react-bootstrap';
+import { Container, Card, Button, Badge, Row, Col, ListGroup, Alert } from 'react-bootstrap';
 import api from '../services/api';
+import { useAuth } from '../services/AuthContext';
 
 const EquipmentDetail = () => {
     const { id } = useParams();
+    const { user } = useAuth();
     const [equipment, setEquipment] = useState(null);
     const [requests, setRequests] = useState([]);
     const [showRequests, setShowRequests] = useState(false);
+    const [error, setError] = useState('');
+
+    const isManager = user?.role === 'Manager';
 
     useEffect(() => {
         const fetchData = async () => {
             try {
-                // Note: The generic get returns list, we filter manually for prototype or assume ID match if API supports /equipment/{id}
-                // To be safe with current API:
-                const allEq = await api.get('/equipment');
-                const match = allEq.data.find(e => e.id === parseInt(id));
-                setEquipment(match);
+                const eqRes = await api.get(`/equipment/${id}`);
+                setEquipment(eqRes.data);
 
                 const reqRes = await api.get(`/equipment/${id}/requests`);
                 setRequests(reqRes.data);
             } catch (err) {
                 console.error(err);
+                setError(err.response?.status === 404 ? 'Equipment not found.' : 'Could not load equipment.');
             }
         };
         fetchData();
     }, [id]);
 
+    const toggleArchived = async () => {
+        setError('');
+        try {
+            const res = equipment.archived_at
+                ? await api.post(`/equipment/${id}/restore`)
+                : await api.delete(`/equipment/${id}`);
+            setEquipment(res.data);
+        } catch (err) {
+            setError(err.response?.data?.error || 'Could not update equipment.');
+        }
+    };
+
+    if (error && !equipment) return <Container className="mt-4"><Alert variant="danger">{error}</Alert></Container>;
     if (!equipment) return <Container className="mt-4">Loading...</Container>;
 
     const openRequestsCount = requests.filter(r => r.status === 'New' || r.status === 'In Progress').length;
 
     return (
         <Container className="mt-5" style={{ maxWidth: '1000px' }}>
+            {error && <Alert variant="danger" dismissible onClose={() => setError('')}>{error}</Alert>}
+
             {/* Odoo-style Smart Button Row */}
-            <div className="d-flex justify-content-end mb-4">
+            <div className="d-flex justify-content-end align-items-center gap-3 mb-4">
+                {isManager && (
+                    <Button variant={equipment.archived_at ? 'outline-success' : 'outline-secondary'} onClick={toggleArchived}>
+                        {equipment.archived_at ? 'Restore' : 'Archive'}
+                    </Button>
+                )}
                 <Button 
                     variant="primary" 
                     className="px-4 py-3 shadow border-0 d-flex align-items-center gap-3"
@@ -64,7 +87,9 @@ const EquipmentDetail = () => {
             <Card className="border-0 shadow-sm" style={{ borderRadius: '15px' }}>
                 <Card.Header className="bg-white border-0 pt-4 px-4 pb-0 d-flex justify-content-between align-items-center">
                     <h2 className="fw-bold text-dark mb-0">{equipment.name}</h2>
-                    {equipment.is_usable ? (
+                    {equipment.archived_at ? (
+                        <Badge bg="secondary" className="px-3 py-2 rounded-pill fw-normal">Archived</Badge>
+                    ) : equipment.is_usable ? (
                         <Badge bg="success-subtle" text="success" className="px-3 py-2 rounded-pill fw-normal">Active / Usable</Badge>
                     ) : (
                         <Badge bg="danger-subtle" text="danger" className="px-3 py-2 rounded-pill fw-normal">Scrapped / Unusable</Badge>
@@ -99,6 +124,10 @@ const EquipmentDetail = () => {
                                         <span className="text-secondary">Maintenance Team</span>
                                         <span className="fw-medium">{equipment.maintenance_team?.name}</span>
                                     </div>
+                                    <div className="d-flex justify-content-between border-bottom pb-2">
+                                        <span className="text-secondary">Owner</span>
+                                        <span className="fw-medium">{equipment.employee?.name || 'Unassigned'}</span>
+                                    </div>
                                     <div className="d-flex justify-content-between border-bottom pb-2">
                                         <span className="text-secondary">Assigned Technician</span>
                                         <span className="fw-medium">{equipment.default_technician?.name || 'Unassigned'}</span>
@@ -19,7 +19,7 @@ const ForgotPassword = () => {
             const response = await api.post('/forgot-password', { email });
             setMessage(response.data.message);
         } catch (err) {
-            setError(err.response?.data?.message || 'Something went wrong');
+            setError(err.response?.data?.error || err.response?.data?.message || 'Something went wrong');
         } finally {
             setLoading(false);
         }
@@ -2,6 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { Container, Row, Col, Card, Badge, Button, Modal, Form } from 'react-bootstrap';
 import { DragDropContext, Droppable, Draggable } from '@hello-pangea/dnd';
 import api from '../services/api';
+import { subscribeEvents } from '../services/events';
 import { FaUserCircle, FaClock } from 'react-icons/fa';
 
 const KanbanBoard = () => {
@@ -17,26 +18,55 @@ const KanbanBoard = () => {
     const [completingReq, setCompletingReq] = useState(null);
     const [duration, setDuration] = useState('');
 
+    // Workflow (columns come from the server-side workflow definition)
+    const [workflow, setWorkflow] = useState({ initial: 'New', states: [], next_states: {} });
+
     useEffect(() => {
-        fetchRequests();
+        let unsubscribe = () => {};
+        let cancelled = false;
+        let pending = null;
+        const init = async () => {
+            let wf = workflow;
+            try {
+                const res = await api.get('/workflow');
+                wf = res.data;
+                setWorkflow(wf);
+            } catch (error) {
+                console.error("Kanban - Error fetching workflow:", error);
+            }
+            fetchRequests(wf);
+            if (cancelled) return;
+            // Other people's changes show up live; bursts of events share one reload
+            unsubscribe = subscribeEvents((type) => {
+                if (type.startsWith('request.') || type === 'reset') {
+                    clearTimeout(pending);
+                    pending = setTimeout(() => fetchRequests(wf), 300);
+                }
+            });
+        };
+        init();
+        return () => {
+            cancelled = true;
+            clearTimeout(pending);
+            unsubscribe();
+        };
+        // eslint-disable-next-line react-hooks/exhaustive-deps
     }, []);
 
-    const fetchRequests = async () => {
+    const fetchRequests = async (wf = workflow) => {
         try {
             const res = await api.get('/requests');
             console.log("Kanban - Fetched Requests:", res.data);
             
-            const grouped = {
-                'New': [],
-                'In Progress': [],
-                'Repaired': [],
-                'Scrap': []
-            };
+            const grouped = {};
+            (wf.states.length ? wf.states.map(s => s.name) : Object.keys(columns)).forEach(name => {
+                grouped[name] = [];
+            });
             res.data.forEach(req => {
                 if (grouped[req.status]) {
                     grouped[req.status].push(req);
-                } else {
-                    grouped['New'].push(req);
+                } else if (grouped[wf.initial]) {
+                    grouped[wf.initial].push(req);
                 }
             });
             setColumns(grouped);
@@ -50,6 +80,13 @@ const KanbanBoard = () => {
         if (!destination) return;
         if (source.droppableId === destination.droppableId && source.index === destination.index) return;
 
+        // Only allow moves the workflow permits for this user
+        const allowed = workflow.next_states[source.droppableId];
+        if (source.droppableId !== destination.droppableId && allowed && !allowed.includes(destination.droppableId)) {
+            alert(`Cannot move a request from ${source.droppableId} to ${destination.droppableId}`);
+            return;
+        }
+
         const sourceCol = [...columns[source.droppableId]];
         const destCol = [...columns[destination.droppableId]];
         const [movedReq] = sourceCol.splice(source.index, 1);
@@ -76,6 +113,9 @@ const KanbanBoard = () => {
             fetchRequests();
         } catch (error) {
             console.error("Failed to move card", error);
+            if (error.response?.data?.error) {
+                alert(error.response.data.error);
+            }
             fetchRequests();
         }
     };
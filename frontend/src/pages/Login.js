@@ -1,42 +1,138 @@
-import React, { useState } from 'react';
+import React, { useState, useEffect } from 'react';
 import { Container, Form, Button, Card, Alert } from 'react-bootstrap';
-import { useNavigate, Link } from 'react-router-dom';
+import { useNavigate, useLocation, Link } from 'react-router-dom';
 import { useAuth } from '../services/AuthContext';
+import api from '../services/api';
 
 const Login = () => {
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
     const [error, setError] = useState('');
-    const { login } = useAuth();
+    // Two-factor step: { token, enroll } once the password was accepted
+    const [challenge, setChallenge] = useState(null);
+    const [code, setCode] = useState('');
+    const [useRecovery, setUseRecovery] = useState(false);
+    const [enrollment, setEnrollment] = useState(null);
+    const [recoveryCodes, setRecoveryCodes] = useState(null);
+    const [sso, setSso] = useState(null);
+    const { login, verifyMfa, startMfaEnrollment, confirmMfaEnrollment } = useAuth();
     const navigate = useNavigate();
+    const location = useLocation();
+
+    const beginChallenge = async (data) => {
+        setError('');
+        setChallenge({ token: data.challenge_token, enroll: data.mfa_enrollment_required });
+        if (data.mfa_enrollment_required) {
+            setEnrollment(await startMfaEnrollment(data.challenge_token));
+        }
+    };
+
+    useEffect(() => {
+        api.get('/oidc/config').then(res => setSso(res.data.enabled ? res.data : null)).catch(() => setSso(null));
+        // Single sign-on hands over here when a second factor is still needed
+        if (location.state?.challenge) beginChallenge(location.state.challenge);
+        if (location.state?.error) setError(location.state.error);
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, []);
+
+    const handleSso = async () => {
+        try {
+            const res = await api.get('/oidc/login');
+            sessionStorage.setItem('oidc_flow', res.data.flow_token);
+            window.location.assign(res.data.authorization_url);
+        } catch (err) {
+            setError(err.response?.data?.error || 'Single sign-on is unavailable');
+        }
+    };
 
     const handleSubmit = async (e) => {
         e.preventDefault();
         try {
-            await login(email, password);
+            const data = await login(email, password);
+            if (data.challenge_token) {
+                await beginChallenge(data);
+                return;
+            }
             navigate('/');
         } catch (err) {
-            setError('Invalid credentials');
+            setError(err.response?.status === 429 ? err.response.data.error : 'Invalid credentials');
         }
     };
 
+    const handleCode = async (e) => {
+        e.preventDefault();
+        try {
+            if (challenge.enroll) {
+                const data = await confirmMfaEnrollment(challenge.token, code);
+                setRecoveryCodes(data.recovery_codes);
+                return;
+            }
+            await verifyMfa(challenge.token, useRecovery ? { recovery_code: code } : { code });
+            navigate('/');
+        } catch (err) {
+            setError(err.response?.data?.error || 'Invalid verification code');
+        }
+    };
+
+    const renderSecondStep = () => {
+        if (recoveryCodes) {
+            return (
+                <>
+                    <p>Two-factor authentication is on. Store these recovery codes somewhere safe; each works once.</p>
+                    <pre>{recoveryCodes.join('\n')}</pre>
+                    <Button className="w-100" onClick={() => navigate('/')}>Continue</Button>
+                </>
+            );
+        }
+        return (
+            <Form onSubmit={handleCode}>
+                {challenge.enroll && enrollment && (
+                    <>
+                        <p>Your role requires two-factor authentication. Add this account to your authenticator app, then enter the code it shows.</p>
+                        <p className="small text-break"><a href={enrollment.provisioning_uri}>{enrollment.provisioning_uri}</a></p>
+                        <p className="small">Secret: <code>{enrollment.secret}</code></p>
+                    </>
+                )}
+                <Form.Group className="mb-3">
+                    <Form.Label>{useRecovery ? 'Recovery code' : 'Authentication code'}</Form.Label>
+                    <Form.Control type="text" autoComplete="one-time-code" required value={code} onChange={(e) => setCode(e.target.value)} />
+                </Form.Group>
+                <Button className="w-100" type="submit">Verify</Button>
+                {!challenge.enroll && (
+                    <div className="text-center mt-3">
+                        <Button variant="link" onClick={() => { setUseRecovery(!useRecovery); setCode(''); }}>
+                            {useRecovery ? 'Use authenticator code' : 'Use a recovery code'}
+                        </Button>
+                    </div>
+                )}
+            </Form>
+        );
+    };
+
     return (
         <Container className="d-flex justify-content-center align-items-center" style={{ minHeight: '80vh' }}>
             <Card style={{ width: '400px' }}>
                 <Card.Body>
                     <h2 className="text-center mb-4">Login</h2>
                     {error && <Alert variant="danger">{error}</Alert>}
-                    <Form onSubmit={handleSubmit}>
-                        <Form.Group className="mb-3">
-                            <Form.Label>Email</Form.Label>
-                            <Form.Control type="email" required onChange={(e) => setEmail(e.target.value)} />
-                        </Form.Group>
-                        <Form.Group className="mb-3">
-                            <Form.Label>Password</Form.Label>
-                            <Form.Control type="password" required onChange={(e) => setPassword(e.target.value)} />
-                        </Form.Group>
-                        <Button className="w-100" type="submit">Log In</Button>
-                    </Form>
+                    {challenge ? renderSecondStep() : (
+                        <Form onSubmit={handleSubmit}>
+                            <Form.Group className="mb-3">
+                                <Form.Label>Email</Form.Label>
+                                <Form.Control type="email" required onChange={(e) => setEmail(e.target.value)} />
+                            </Form.Group>
+                            <Form.Group className="mb-3">
+                                <Form.Label>Password</Form.Label>
+                                <Form.Control type="password" required onChange={(e) => setPassword(e.target.value)} />
+                            </Form.Group>
+                            <Button className="w-100" type="submit">Log In</Button>
+                            {sso && (
+                                <Button className="w-100 mt-2" variant="outline-secondary" onClick={handleSso}>
+                                    Sign in with {sso.name}
+                                </Button>
+                            )}
+                        </Form>
+                    )}
                     <div className="text-center mt-3">
                         <Link to="/forgot-password">Forgot Password?</Link>
                     </div>
@@ -0,0 +1,42 @@
+import React, { useEffect, useRef } from 'react';
+import { Container, Spinner } from 'react-bootstrap';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { useAuth } from '../services/AuthContext';
+
+// The identity provider redirects here with ?code&state after sign-in
+const OIDCCallback = () => {
+    const [params] = useSearchParams();
+    const { finishSso } = useAuth();
+    const navigate = useNavigate();
+    const done = useRef(false);
+
+    useEffect(() => {
+        // Codes are single use; don't redeem twice under StrictMode
+        if (done.current) return;
+        done.current = true;
+
+        const flow = sessionStorage.getItem('oidc_flow');
+        sessionStorage.removeItem('oidc_flow');
+        if (params.get('error') || !flow) {
+            navigate('/login', { state: { error: params.get('error_description') || 'Single sign-on was cancelled' } });
+            return;
+        }
+        finishSso(params.get('code'), params.get('state'), flow)
+            .then(data => {
+                if (data.challenge_token) {
+                    navigate('/login', { state: { challenge: data } });
+                } else {
+                    navigate('/');
+                }
+            })
+            .catch(err => navigate('/login', { state: { error: err.response?.data?.error || 'Single sign-on failed' } }));
+    }, [params, finishSso, navigate]);
+
+    return (
+        <Container className="d-flex justify-content-center align-items-center" style={{ minHeight: '80vh' }}>
+            <Spinner animation="border" />
+        </Container>
+    );
+};
+
+export default OIDCCallback;
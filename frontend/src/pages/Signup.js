@@ -1,30 +1,19 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState } from 'react';
 import { Container, Form, Button, Card, Alert } from 'react-bootstrap';
 import { useNavigate } from 'react-router-dom';
 import api from '../services/api';
 
 const Signup = () => {
     const [formData, setFormData] = useState({
-        name: '', email: '', password: '', role: 'Technician', team_id: ''
+        name: '', email: '', password: ''
     });
-    const [teams, setTeams] = useState([]);
     const [error, setError] = useState('');
     const navigate = useNavigate();
 
-    useEffect(() => {
-        api.get('/teams').then(res => setTeams(res.data)).catch(console.error);
-    }, []);
-
     const handleSubmit = async (e) => {
         e.preventDefault();
         try {
-            const payload = { ...formData };
-            if (payload.role === 'Technician' && payload.team_id) {
-                payload.team_id = parseInt(payload.team_id);
-            } else {
-                payload.team_id = null;
-            }
-            await api.post('/register', payload);
+            await api.post('/register', formData);
             navigate('/login');
         } catch (err) {
             // Display server error if available
@@ -52,23 +41,7 @@ const Signup = () => {
                             <Form.Label>Password</Form.Label>
                             <Form.Control type="password" required onChange={(e) => setFormData({...formData, password: e.target.value})} />
                         </Form.Group>
-                        <Form.Group className="mb-3">
-                            <Form.Label>Role</Form.Label>
-                            <Form.Select onChange={(e) => setFormData({...formData, role: e.target.value})}>
-                                <option value="Technician">Technician</option>
-                                <option value="Manager">Manager</option>
-                                <option value="Employee">Employee (Standard User)</option>
-                            </Form.Select>
-                        </Form.Group>
-                        {formData.role === 'Technician' && (
-                            <Form.Group className="mb-3">
-                                <Form.Label>Team</Form.Label>
-                                <Form.Select required onChange={(e) => setFormData({...formData, team_id: e.target.value})}>
-                                    <option value="">Select Team...</option>
-                                    {teams.map(t => <option key={t.id} value={t.id}>{t.name}</option>)}
-                                </Form.Select>
-                            </Form.Group>
-                        )}
+                        <Form.Text as="p" muted>Self sign-up creates an Employee account. Technicians and managers join through an invitation from a manager.</Form.Text>
                         <Button className="w-100" type="submit">Sign Up</Button>
                     </Form>
                     <div className="text-center mt-3">
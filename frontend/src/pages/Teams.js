@@ -1,15 +1,64 @@
 import React, { useState, useEffect } from 'react';
-import { Container, Table, Button, Form, Modal, Card, Row, Col } from 'react-bootstrap';
+import { Container, Table, Button, Form, Modal, Card, Row, Col, Badge } from 'react-bootstrap';
 import api from '../services/api';
+import { useAuth } from '../services/AuthContext';
+
+const emptyInvite = { email: '', name: '', role: 'Technician', team_id: '' };
 
 const Teams = () => {
     const [teams, setTeams] = useState([]);
     const [showModal, setShowModal] = useState(false);
     const [newTeamName, setNewTeamName] = useState('');
+    const [invitations, setInvitations] = useState([]);
+    const [showInvite, setShowInvite] = useState(false);
+    const [invite, setInvite] = useState(emptyInvite);
+    const { user } = useAuth();
+    const isManager = user?.role === 'Manager';
 
     useEffect(() => {
         fetchTeams();
-    }, []);
+        if (isManager) fetchInvitations();
+    }, [isManager]);
+
+    const fetchInvitations = async () => {
+        try {
+            const res = await api.get('/invitations');
+            setInvitations(res.data);
+        } catch (error) {
+            console.error("Error fetching invitations", error);
+        }
+    };
+
+    const handleInvite = async (e) => {
+        e.preventDefault();
+        try {
+            await api.post('/invitations', { ...invite, team_id: invite.team_id ? parseInt(invite.team_id) : null });
+            setShowInvite(false);
+            setInvite(emptyInvite);
+            fetchInvitations();
+        } catch (error) {
+            alert(error.response?.data?.error || 'Failed to send invitation');
+        }
+    };
+
+    const handleResend = async (id) => {
+        try {
+            await api.post(`/invitations/${id}/resend`);
+            fetchInvitations();
+        } catch (error) {
+            alert(error.response?.data?.error || 'Failed to resend invitation');
+        }
+    };
+
+    const handleRevoke = async (id) => {
+        if (!window.confirm('Revoke this invitation?')) return;
+        try {
+            await api.delete(`/invitations/${id}`);
+            fetchInvitations();
+        } catch (error) {
+            alert(error.response?.data?.error || 'Failed to revoke invitation');
+        }
+    };
 
     const fetchTeams = async () => {
         try {
@@ -36,7 +85,10 @@ const Teams = () => {
         <Container className="mt-4">
             <div className="d-flex justify-content-between align-items-center mb-4">
                 <h2>Maintenance Teams</h2>
-                <Button variant="primary" onClick={() => setShowModal(true)}>+ New Team</Button>
+                <div>
+                    {isManager && <Button variant="outline-primary" className="me-2" onClick={() => setShowInvite(true)}>Invite Member</Button>}
+                    <Button variant="primary" onClick={() => setShowModal(true)}>+ New Team</Button>
+                </div>
             </div>
 
             <Row>
@@ -63,6 +115,67 @@ const Teams = () => {
                 ))}
             </Row>
 
+            {isManager && invitations.length > 0 && (
+                <>
+                    <h4 className="mt-2">Pending Invitations</h4>
+                    <Table striped bordered hover size="sm">
+                        <thead>
+                            <tr><th>Email</th><th>Role</th><th>Team</th><th>Expires</th><th>Status</th><th></th></tr>
+                        </thead>
+                        <tbody>
+                            {invitations.map(inv => (
+                                <tr key={inv.id}>
+                                    <td>{inv.email}</td>
+                                    <td>{inv.role}</td>
+                                    <td>{teams.find(t => t.id === inv.team_id)?.name || '-'}</td>
+                                    <td>{new Date(inv.expires_at).toLocaleString()}</td>
+                                    <td><Badge bg={inv.status === 'expired' ? 'secondary' : 'info'}>{inv.status}</Badge></td>
+                                    <td>
+                                        <Button size="sm" variant="link" onClick={() => handleResend(inv.id)}>Resend</Button>
+                                        <Button size="sm" variant="link" className="text-danger" onClick={() => handleRevoke(inv.id)}>Revoke</Button>
+                                    </td>
+                                </tr>
+                            ))}
+                        </tbody>
+                    </Table>
+                </>
+            )}
+
+            {/* Invite Member Modal */}
+            <Modal show={showInvite} onHide={() => setShowInvite(false)}>
+                <Modal.Header closeButton>
+                    <Modal.Title>Invite Member</Modal.Title>
+                </Modal.Header>
+                <Modal.Body>
+                    <Form onSubmit={handleInvite}>
+                        <Form.Group className="mb-3">
+                            <Form.Label>Email</Form.Label>
+                            <Form.Control type="email" required value={invite.email} onChange={(e) => setInvite({...invite, email: e.target.value})} />
+                        </Form.Group>
+                        <Form.Group className="mb-3">
+                            <Form.Label>Name</Form.Label>
+                            <Form.Control type="text" value={invite.name} onChange={(e) => setInvite({...invite, name: e.target.value})} />
+                        </Form.Group>
+                        <Form.Group className="mb-3">
+                            <Form.Label>Role</Form.Label>
+                            <Form.Select value={invite.role} onChange={(e) => setInvite({...invite, role: e.target.value})}>
+                                <option value="Employee">Employee</option>
+                                <option value="Technician">Technician</option>
+                                <option value="Manager">Manager</option>
+                            </Form.Select>
+                        </Form.Group>
+                        <Form.Group className="mb-3">
+                            <Form.Label>Team</Form.Label>
+                            <Form.Select value={invite.team_id} onChange={(e) => setInvite({...invite, team_id: e.target.value})}>
+                                <option value="">No team</option>
+                                {teams.map(t => <option key={t.id} value={t.id}>{t.name}</option>)}
+                            </Form.Select>
+                        </Form.Group>
+                        <Button variant="primary" type="submit">Send Invitation</Button>
+                    </Form>
+                </Modal.Body>
+            </Modal>
+
             {/* Create Team Modal */}
             <Modal show={showModal} onHide={() => setShowModal(false)}>
                 <Modal.Header closeButton>
@@ -18,32 +18,66 @@ export const AuthProvider = ({ children }) => {
         setLoading(false);
     }, []);
 
-    const login = async (email, password) => {
-        const res = await api.post('/login', { email, password });
-        // Assuming backend returns user_id in the response map or we decode it
-        // The backend Login handler returns: { token, name, role } but NOT id.
-        // I will fix the backend to return ID as well.
-        // For now, let's assume I fix the backend next.
-        const { token, name, role, user_id } = res.data; 
-        
+    // Stores the tokens of a completed login
+    const startSession = (data) => {
+        const { token, refresh_token, name, role, user_id } = data;
+
         localStorage.setItem('token', token);
+        localStorage.setItem('refresh_token', refresh_token);
         localStorage.setItem('user_name', name);
         localStorage.setItem('user_role', role);
         if (user_id) localStorage.setItem('user_id', user_id);
-        
+
         setUser({ name, role, id: user_id, token });
+    };
+
+    // Returns the response; when it carries a challenge_token the user must
+    // complete two-factor authentication before they are signed in
+    const login = async (email, password) => {
+        const res = await api.post('/login', { email, password });
+        if (!res.data.challenge_token) startSession(res.data);
+        return res.data;
+    };
+
+    // Completes a single sign-on redirect; may also return a challenge_token
+    const finishSso = async (code, state, flow_token) => {
+        const res = await api.post('/oidc/callback', { code, state, flow_token });
+        if (!res.data.challenge_token) startSession(res.data);
         return res.data;
     };
 
-    const logout = () => {
+    const verifyMfa = async (challenge_token, { code, recovery_code }) => {
+        const res = await api.post('/login/mfa', { challenge_token, code, recovery_code });
+        startSession(res.data);
+        return res.data;
+    };
+
+    const startMfaEnrollment = async (challenge_token) => {
+        const res = await api.post('/login/mfa/enroll', { challenge_token });
+        return res.data;
+    };
+
+    const confirmMfaEnrollment = async (challenge_token, code) => {
+        const res = await api.post('/login/mfa/enroll/confirm', { challenge_token, code });
+        startSession(res.data);
+        return res.data;
+    };
+
+    const logout = async (everywhere = false) => {
+        try {
+            await api.post(everywhere ? '/logout-all' : '/logout');
+        } catch (err) {
+            // The session may already be gone; clear local state regardless
+        }
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
         localStorage.removeItem('user_name');
         localStorage.removeItem('user_role');
         setUser(null);
     };
 
     return (
-        <AuthContext.Provider value={{ user, login, logout, loading }}>
+        <AuthContext.Provider value={{ user, login, finishSso, verifyMfa, startMfaEnrollment, confirmMfaEnrollment, logout, loading }}>
             {children}
         </AuthContext.Provider>
     );
@@ -21,4 +21,46 @@ api.interceptors.request.use(
     }
 );
 
+// Access tokens are short-lived: on a 401, exchange the refresh token once and retry.
+// Concurrent failures share a single refresh, since each refresh token works only once.
+let refreshing = null;
+
+export const refreshTokens = () => {
+    if (!refreshing) {
+        const refreshToken = localStorage.getItem('refresh_token');
+        refreshing = (refreshToken
+            ? axios.post(`${api.defaults.baseURL}/refresh`, { refresh_token: refreshToken })
+            : Promise.reject(new Error('No refresh token')))
+            .then((res) => {
+                localStorage.setItem('token', res.data.token);
+                localStorage.setItem('refresh_token', res.data.refresh_token);
+                localStorage.setItem('user_role', res.data.role);
+                return res.data.token;
+            })
+            .finally(() => { refreshing = null; });
+    }
+    return refreshing;
+};
+
+api.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+        const original = error.config;
+        const isAuthCall = ['/login', '/refresh', '/register', '/oidc'].some((path) => original?.url?.startsWith(path));
+        if (error.response?.status !== 401 || !original || original._retried || isAuthCall) {
+            return Promise.reject(error);
+        }
+        original._retried = true;
+        try {
+            const token = await refreshTokens();
+            original.headers.Authorization = `Bearer ${token}`;
+            return api(original);
+        } catch (refreshError) {
+            ['token', 'refresh_token', 'user_name', 'user_role', 'user_id'].forEach((k) => localStorage.removeItem(k));
+            window.location.assign('/login');
+            return Promise.reject(error);
+        }
+    }
+);
+
 export default api;
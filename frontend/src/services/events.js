@@ -0,0 +1,69 @@
+import api, { refreshTokens } from './api';
+
+// subscribeEvents streams server events (request.create, equipment.update, ...)
+// to onEvent(type, data) until the returned function is called. It uses fetch
+// rather than EventSource so the access token travels in the Authorization
+// header; after a drop it reconnects with Last-Event-ID to replay what was missed.
+// A "reset" event means some changes were lost: reload instead.
+export const subscribeEvents = (onEvent) => {
+    let stopped = false;
+    let controller = null;
+    let lastEventId = '';
+    let retry = 3000;
+
+    const dispatch = (block) => {
+        let type = 'message';
+        let data = '';
+        let id = null;
+        block.split('\n').forEach((line) => {
+            if (line.startsWith(':')) return; // Heartbeat
+            const colon = line.indexOf(':');
+            const field = colon === -1 ? line : line.slice(0, colon);
+            const value = colon === -1 ? '' : line.slice(colon + 1).replace(/^ /, '');
+            if (field === 'event') type = value;
+            else if (field === 'data') data += value;
+            else if (field === 'id') id = value;
+            else if (field === 'retry' && /^\d+$/.test(value)) retry = Number(value);
+        });
+        if (id !== null) lastEventId = id;
+        if (data) onEvent(type, JSON.parse(data));
+    };
+
+    const connect = async (refreshed = false) => {
+        controller = new AbortController();
+        const headers = { Authorization: `Bearer ${localStorage.getItem('token')}` };
+        if (lastEventId) headers['Last-Event-ID'] = lastEventId;
+        try {
+            const res = await fetch(`${api.defaults.baseURL}/events`, { headers, signal: controller.signal });
+            if (res.status === 401 && !refreshed) {
+                await refreshTokens();
+                return connect(true);
+            }
+            if (!res.ok) throw new Error(`Event stream failed: ${res.status}`);
+
+            const reader = res.body.getReader();
+            const decoder = new TextDecoder();
+            let buffer = '';
+            for (;;) {
+                const { value, done } = await reader.read();
+                if (done) break;
+                buffer += decoder.decode(value, { stream: true }).replace(/\r\n?/g, '\n');
+                let end;
+                while ((end = buffer.indexOf('\n\n')) !== -1) {
+                    dispatch(buffer.slice(0, end));
+                    buffer = buffer.slice(end + 2);
+                }
+            }
+        } catch (error) {
+            if (stopped) return;
+            console.error('Event stream interrupted', error);
+        }
+        if (!stopped) setTimeout(() => connect(), retry);
+    };
+
+    connect();
+    return () => {
+        stopped = true;
+        if (controller) controller.abort();
+    };
+};
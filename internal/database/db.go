@@ -5,8 +5,6 @@ import (
 	"log"
 	"os"
 
-	"gearguard/internal/models"
-
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -45,32 +43,28 @@ func ConnectDB() {
 	}
 
 	log.Println("Connected to Database")
+}
 
-	// Migrate the schema
-	err = DB.AutoMigrate(
-		&models.MaintenanceTeam{},
-		&models.User{},
-		&models.Equipment{},
-		&models.MaintenanceRequest{},
-	)
+// CheckMigrations makes sure the schema is current before serving. Pending
+// migrations are applied when AUTO_MIGRATE=true (safe with several replicas, as
+// migrations run under an advisory lock); otherwise the server refuses to start.
+func CheckMigrations() {
+	pending, err := PendingMigrations(DB)
 	if err != nil {
-		log.Fatal("Failed to migrate database schema: ", err)
+		log.Fatal("Failed to read migration status: ", err)
+	}
+	if pending == 0 {
+		return
 	}
-	log.Println("Database Migration Completed")
 
-	// Seed Default Teams if empty
-	var count int64
-	DB.Model(&models.MaintenanceTeam{}).Count(&count)
-	if count == 0 {
-		defaultTeams := []models.MaintenanceTeam{
-			{Name: "Mechanical Team"},
-			{Name: "Electrical Team"},
-			{Name: "IT Support"},
-			{Name: "General Maintenance"},
-		}
-		for _, team := range defaultTeams {
-			DB.Create(&team)
-		}
-		log.Println("Default teams seeded")
+	if os.Getenv("AUTO_MIGRATE") != "true" {
+		log.Fatalf("Database schema is %d migration(s) behind; run `gearguard migrate up` or set AUTO_MIGRATE=true", pending)
+	}
+	applied, err := MigrateUp(DB, 0)
+	if err != nil {
+		log.Fatal("Failed to migrate database schema: ", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
 	}
 }
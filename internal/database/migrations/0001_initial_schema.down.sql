@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS
+    vendor_costs,
+    part_usages,
+    stock_movements,
+    stocks,
+    parts,
+    attachments,
+    request_comments,
+    audit_logs,
+    meter_rules,
+    meter_readings,
+    meters,
+    maintenance_schedules,
+    maintenance_requests,
+    equipment,
+    users,
+    maintenance_teams,
+    locations;
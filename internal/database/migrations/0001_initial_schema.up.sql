@@ -0,0 +1,247 @@
+-- Baseline schema, equivalent to what AutoMigrate created before versioned
+-- migrations. IF NOT EXISTS lets databases created by AutoMigrate adopt it as-is.
+
+CREATE TABLE IF NOT EXISTS locations (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name       text,
+    kind       text,
+    parent_id  bigint REFERENCES locations (id),
+    path       text
+);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_locations_parent_id ON locations (parent_id);
+CREATE INDEX IF NOT EXISTS idx_locations_path ON locations (path);
+
+CREATE TABLE IF NOT EXISTS maintenance_teams (
+    id          bigserial PRIMARY KEY,
+    name        text,
+    hourly_rate decimal
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id                   bigserial PRIMARY KEY,
+    name                 text,
+    email                text CONSTRAINT uni_users_email UNIQUE,
+    password             text,
+    role                 text,
+    team_id              bigint REFERENCES maintenance_teams (id),
+    password_reset_token text,
+    password_reset_at    timestamptz,
+    hourly_rate          decimal
+);
+
+CREATE TABLE IF NOT EXISTS equipment (
+    id                    bigserial PRIMARY KEY,
+    name                  text,
+    category              text,
+    department            text,
+    serial_number         text,
+    purchase_date         timestamptz,
+    warranty_info         text,
+    location              text,
+    location_id           bigint REFERENCES locations (id),
+    parent_id             bigint REFERENCES equipment (id),
+    maintenance_team_id   bigint REFERENCES maintenance_teams (id),
+    default_technician_id bigint REFERENCES users (id),
+    employee_id           bigint REFERENCES users (id),
+    is_usable             boolean DEFAULT true
+);
+CREATE INDEX IF NOT EXISTS idx_equipment_location_id ON equipment (location_id);
+CREATE INDEX IF NOT EXISTS idx_equipment_parent_id ON equipment (parent_id);
+
+CREATE TABLE IF NOT EXISTS maintenance_requests (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    updated_at     timestamptz,
+    deleted_at     timestamptz,
+    subject        text,
+    type           text,
+    status         text DEFAULT 'New',
+    equipment_id   bigint REFERENCES equipment (id),
+    team_id        bigint REFERENCES maintenance_teams (id),
+    technician_id  bigint REFERENCES users (id),
+    created_by_id  bigint REFERENCES users (id),
+    scheduled_date timestamptz,
+    duration_hours decimal,
+    repaired_at    timestamptz,
+    schedule_id    bigint,
+    meter_rule_id  bigint,
+    labor_rate     decimal,
+    labor_cost     decimal,
+    parts_cost     decimal,
+    vendor_cost    decimal,
+    total_cost     decimal
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_requests_deleted_at ON maintenance_requests (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_maintenance_requests_schedule_id ON maintenance_requests (schedule_id);
+CREATE INDEX IF NOT EXISTS idx_maintenance_requests_meter_rule_id ON maintenance_requests (meter_rule_id);
+
+CREATE TABLE IF NOT EXISTS maintenance_schedules (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz,
+    subject         text,
+    duration_hours  decimal,
+    equipment_id    bigint REFERENCES equipment (id),
+    frequency       text,
+    "interval"      bigint DEFAULT 1,
+    weekdays        text,
+    start_date      timestamptz,
+    end_date        timestamptz,
+    "count"         bigint,
+    active          boolean DEFAULT true,
+    generated_count bigint,
+    last_occurrence timestamptz,
+    created_by_id   bigint
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_schedules_deleted_at ON maintenance_schedules (deleted_at);
+
+CREATE TABLE IF NOT EXISTS meters (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    deleted_at      timestamptz,
+    equipment_id    bigint,
+    name            text,
+    unit            text,
+    current_reading decimal,
+    last_reading_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_meters_deleted_at ON meters (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_meters_equipment_id ON meters (equipment_id);
+
+CREATE TABLE IF NOT EXISTS meter_readings (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    meter_id       bigint,
+    value          decimal,
+    read_at        timestamptz,
+    recorded_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_meter_readings_meter_id ON meter_readings (meter_id);
+
+CREATE TABLE IF NOT EXISTS meter_rules (
+    id               bigserial PRIMARY KEY,
+    created_at       timestamptz,
+    deleted_at       timestamptz,
+    meter_id         bigint REFERENCES meters (id),
+    kind             text,
+    subject          text,
+    duration_hours   decimal,
+    every            decimal,
+    baseline_reading decimal,
+    threshold        decimal,
+    tripped          boolean,
+    active           boolean DEFAULT true,
+    created_by_id    bigint
+);
+CREATE INDEX IF NOT EXISTS idx_meter_rules_deleted_at ON meter_rules (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_meter_rules_meter_id ON meter_rules (meter_id);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    actor_id    bigint REFERENCES users (id),
+    entity_type text,
+    entity_id   bigint,
+    action      text,
+    changes     jsonb
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_entity ON audit_logs (entity_type, entity_id);
+
+CREATE TABLE IF NOT EXISTS request_comments (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    request_id bigint,
+    author_id  bigint REFERENCES users (id),
+    body       text,
+    internal   boolean,
+    edited     boolean
+);
+CREATE INDEX IF NOT EXISTS idx_request_comments_deleted_at ON request_comments (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_request_comments_request_id ON request_comments (request_id);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    deleted_at     timestamptz,
+    entity_type    text,
+    entity_id      bigint,
+    file_name      text,
+    content_type   text,
+    size           bigint,
+    storage_key    text,
+    thumbnail_key  text,
+    has_thumbnail  boolean,
+    uploaded_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_deleted_at ON attachments (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_attachment_entity ON attachments (entity_type, entity_id);
+
+CREATE TABLE IF NOT EXISTS parts (
+    id                bigserial PRIMARY KEY,
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    deleted_at        timestamptz,
+    part_number       text,
+    name              text,
+    description       text,
+    unit              text,
+    unit_cost         decimal,
+    reorder_point     decimal,
+    low_stock_alerted boolean
+);
+CREATE INDEX IF NOT EXISTS idx_parts_deleted_at ON parts (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_parts_part_number ON parts (part_number);
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id         bigserial PRIMARY KEY,
+    updated_at timestamptz,
+    part_id    bigint REFERENCES parts (id),
+    location   text,
+    quantity   decimal
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_part_location ON stocks (part_id, location);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    part_id        bigint,
+    location       text,
+    kind           text,
+    quantity       decimal,
+    request_id     bigint,
+    note           text,
+    recorded_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_part_id ON stock_movements (part_id);
+
+CREATE TABLE IF NOT EXISTS part_usages (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    request_id     bigint REFERENCES maintenance_requests (id),
+    part_id        bigint REFERENCES parts (id),
+    location       text,
+    quantity       decimal,
+    unit_cost      decimal,
+    recorded_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_part_usages_request_id ON part_usages (request_id);
+
+CREATE TABLE IF NOT EXISTS vendor_costs (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    request_id     bigint,
+    vendor         text,
+    description    text,
+    invoice_ref    text,
+    amount         decimal,
+    recorded_by_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_vendor_costs_request_id ON vendor_costs (request_id);
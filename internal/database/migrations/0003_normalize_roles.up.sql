@@ -0,0 +1,10 @@
+-- Roles used to be free text and were compared case-insensitively; store the
+-- canonical spelling and demote anything unrecognised to Employee.
+UPDATE users SET role = 'Employee' WHERE lower(role) = 'employee';
+UPDATE users SET role = 'Technician' WHERE lower(role) = 'technician';
+UPDATE users SET role = 'Manager' WHERE lower(role) = 'manager';
+UPDATE users SET role = 'Employee' WHERE role IS NULL OR role NOT IN ('Employee', 'Technician', 'Manager');
+
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'Employee';
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('Employee', 'Technician', 'Manager'));
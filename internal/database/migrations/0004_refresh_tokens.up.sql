@@ -0,0 +1,14 @@
+-- Server-side refresh tokens. A session is the chain of tokens rotated from
+-- one login; only SHA-256 hashes of the tokens are stored.
+CREATE TABLE refresh_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id text NOT NULL,
+    token_hash text NOT NULL CONSTRAINT uni_refresh_tokens_token_hash UNIQUE,
+    created_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    user_agent text NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens (session_id);
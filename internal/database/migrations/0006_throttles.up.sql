@@ -0,0 +1,8 @@
+-- Failed login and password reset attempts by account and client IP
+CREATE TABLE throttles (
+    key           text PRIMARY KEY,
+    count         bigint NOT NULL DEFAULT 0,
+    window_start  timestamptz NOT NULL,
+    blocked_until timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'
+);
+CREATE INDEX idx_throttles_window_start ON throttles (window_start);
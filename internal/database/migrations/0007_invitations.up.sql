@@ -0,0 +1,17 @@
+-- Manager-issued invitations carrying a pre-assigned role and team
+CREATE TABLE invitations (
+    id            bigserial PRIMARY KEY,
+    email         text NOT NULL,
+    name          text NOT NULL DEFAULT '',
+    role          text NOT NULL CHECK (role IN ('Employee', 'Technician', 'Manager')),
+    team_id       bigint REFERENCES maintenance_teams (id) ON DELETE SET NULL,
+    nonce         text NOT NULL,
+    invited_by_id bigint NOT NULL REFERENCES users (id),
+    created_at    timestamptz,
+    sent_at       timestamptz,
+    expires_at    timestamptz NOT NULL,
+    accepted_at   timestamptz,
+    revoked_at    timestamptz,
+    user_id       bigint REFERENCES users (id) ON DELETE SET NULL
+);
+CREATE INDEX idx_invitations_email ON invitations (email);
@@ -0,0 +1,3 @@
+-- Link users to their single sign-on identity
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject text NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_users_oidc_subject ON users (oidc_subject) WHERE oidc_subject <> '';
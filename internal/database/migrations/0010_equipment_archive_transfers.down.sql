@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS equipment_transfers;
+DROP INDEX IF EXISTS idx_equipment_archived_at;
+ALTER TABLE equipment DROP COLUMN IF EXISTS archived_at;
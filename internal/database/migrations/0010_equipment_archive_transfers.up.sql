@@ -0,0 +1,16 @@
+-- Archiving (soft delete) for equipment, and a history of ownership transfers
+ALTER TABLE equipment ADD COLUMN IF NOT EXISTS archived_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_equipment_archived_at ON equipment (archived_at);
+
+CREATE TABLE equipment_transfers (
+    id                 bigserial PRIMARY KEY,
+    equipment_id       bigint NOT NULL REFERENCES equipment (id) ON DELETE CASCADE,
+    from_employee_id   bigint REFERENCES users (id) ON DELETE SET NULL,
+    to_employee_id     bigint REFERENCES users (id) ON DELETE SET NULL,
+    from_technician_id bigint REFERENCES users (id) ON DELETE SET NULL,
+    to_technician_id   bigint REFERENCES users (id) ON DELETE SET NULL,
+    transferred_by_id  bigint NOT NULL REFERENCES users (id),
+    note               text NOT NULL DEFAULT '',
+    created_at         timestamptz
+);
+CREATE INDEX idx_equipment_transfers_equipment_id ON equipment_transfers (equipment_id);
@@ -0,0 +1,12 @@
+-- iCalendar subscription feeds; only the hash of each feed token is stored
+CREATE TABLE calendar_feeds (
+    id              bigserial PRIMARY KEY,
+    user_id         bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_id         bigint REFERENCES maintenance_teams (id) ON DELETE CASCADE,
+    token_hash      text NOT NULL,
+    created_at      timestamptz,
+    last_fetched_at timestamptz,
+    revoked_at      timestamptz
+);
+CREATE UNIQUE INDEX idx_calendar_feeds_token_hash ON calendar_feeds (token_hash);
+CREATE INDEX idx_calendar_feeds_user_id ON calendar_feeds (user_id);
@@ -0,0 +1,32 @@
+-- Outgoing webhooks and the log of their deliveries
+CREATE TABLE webhooks (
+    id            bigserial PRIMARY KEY,
+    url           text NOT NULL,
+    secret        text NOT NULL,
+    events        text NOT NULL DEFAULT '',
+    active        boolean NOT NULL DEFAULT true,
+    created_by_id bigint NOT NULL REFERENCES users (id),
+    created_at    timestamptz,
+    updated_at    timestamptz
+);
+
+CREATE TABLE webhook_deliveries (
+    id              bigserial PRIMARY KEY,
+    webhook_id      bigint NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        text NOT NULL,
+    event_type      text NOT NULL,
+    payload         text NOT NULL,
+    status          text NOT NULL,
+    attempts        integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz,
+    last_attempt_at timestamptz,
+    response_status integer NOT NULL DEFAULT 0,
+    response_body   text NOT NULL DEFAULT '',
+    error           text NOT NULL DEFAULT '',
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries (status);
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
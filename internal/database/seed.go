@@ -0,0 +1,35 @@
+package database
+
+import (
+	"log"
+
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTeams are created by the seed step on an empty database
+var DefaultTeams = []string{"Mechanical Team", "Electrical Team", "IT Support", "General Maintenance"}
+
+// Seed inserts the default reference data. It only adds teams when there are
+// none, so it is safe to run repeatedly.
+func Seed(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.MaintenanceTeam{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Println("Teams already present, skipping team seed")
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range DefaultTeams {
+			if err := tx.Create(&models.MaintenanceTeam{Name: name}).Error; err != nil {
+				return err
+			}
+		}
+		log.Println("Default teams seeded")
+		return nil
+	})
+}
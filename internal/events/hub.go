@@ -0,0 +1,144 @@
+// Package events fans changes to records out to the clients streaming them,
+// each receiving only what its user may see. Recent events are kept so a
+// client that reconnects can pick up where it left off.
+//
+// A hub lives in the memory of one server process and only sees what that
+// process publishes: with several replicas, a client streaming from one would
+// miss changes made through the others. Run a single replica while clients
+// rely on the event stream.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gearguard/internal/models"
+)
+
+// subscriberBuffer is how many events may wait for a subscriber before it is
+// considered stalled and dropped; it can reconnect and resume
+const subscriberBuffer = 64
+
+// Event is a change published to subscribers
+type Event struct {
+	ID   string      // "<epoch>-<sequence>", the hub's epoch telling restarts apart
+	Type string      // "<entity>.<action>", e.g. "request.update"
+	Data interface{} // The record as changed, sent as JSON
+
+	seq     uint64
+	visible func(models.User) bool
+}
+
+// Visible reports whether user may receive e
+func (e Event) Visible(user models.User) bool {
+	return e.visible == nil || e.visible(user)
+}
+
+// Hub delivers published events to subscribers and keeps the most recent
+// ones for replay
+type Hub struct {
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	backlog []Event // Oldest first
+	size    int
+	subs    map[*Subscription]bool
+}
+
+// NewHub returns a hub keeping the last size events for replay
+func NewHub(size int) *Hub {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return &Hub{epoch: hex.EncodeToString(b), size: size, subs: map[*Subscription]bool{}}
+}
+
+// Publish sends an event to every subscriber whose user visible accepts (all
+// of them when visible is nil)
+func (h *Hub) Publish(eventType string, data interface{}, visible func(models.User) bool) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e := Event{
+		ID:      h.epoch + "-" + strconv.FormatUint(h.seq, 10),
+		Type:    eventType,
+		Data:    data,
+		seq:     h.seq,
+		visible: visible,
+	}
+	h.backlog = append(h.backlog, e)
+	if len(h.backlog) > h.size {
+		h.backlog = h.backlog[len(h.backlog)-h.size:]
+	}
+	for sub := range h.subs {
+		if !e.Visible(sub.user) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+	return e
+}
+
+// Subscription receives the events its user may see
+type Subscription struct {
+	// Events is closed when the subscription is, or when it falls too far behind
+	Events <-chan Event
+
+	events chan Event
+	user   models.User
+	hub    *Hub
+}
+
+// Subscribe registers user for events published from now on. Given the ID of
+// the last event a client received, it also returns the ones it missed since;
+// complete is false when some of those are no longer kept or the ID isn't
+// from this hub (the server restarted), and the client should reload instead.
+func (h *Hub) Subscribe(user models.User, lastEventID string) (sub *Subscription, missed []Event, complete bool) {
+	events := make(chan Event, subscriberBuffer)
+	sub = &Subscription{Events: events, events: events, user: user, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[sub] = true
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	epoch, rawSeq, _ := strings.Cut(lastEventID, "-")
+	last, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil || epoch != h.epoch || last > h.seq {
+		return sub, nil, false
+	}
+	oldest := h.seq + 1 - uint64(len(h.backlog))
+	if last+1 < oldest {
+		return sub, nil, false
+	}
+	for _, e := range h.backlog {
+		if e.seq > last && e.Visible(user) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, true
+}
+
+// Close stops the subscription and closes its Events channel
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove unregisters sub; h.mu must be held
+func (h *Hub) remove(sub *Subscription) {
+	if h.subs[sub] {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/middleware"
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// apiKeyView adds the scopes and derived state to an API key in responses
+type apiKeyView struct {
+	models.APIKey
+	Scopes []string `json:"scopes"`
+	Active bool     `json:"active"`
+}
+
+func viewAPIKey(key models.APIKey, now time.Time) apiKeyView {
+	return apiKeyView{APIKey: key, Scopes: key.ScopeList(), Active: key.Active(now)}
+}
+
+// CreateAPIKey issues a key acting as the caller, limited to the requested
+// scopes. The key itself is only ever returned here.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // Never expires when 0
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if input.ExpiresInDays < 0 {
+		utils.RespondError(w, http.StatusBadRequest, "expires_in_days cannot be negative")
+		return
+	}
+	scopes := make([]string, 0, len(input.Scopes))
+	for _, scope := range input.Scopes {
+		scopes = append(scopes, strings.TrimSpace(scope))
+	}
+	if err := policy.ValidateScopes(user.Role, scopes); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	raw, prefix, err := middleware.NewAPIKey()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate key")
+		return
+	}
+	now := time.Now()
+	key := models.APIKey{
+		UserID:  user.ID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: middleware.HashAPIKey(raw),
+		Scopes:  strings.Join(scopes, " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expires := now.AddDate(0, 0, input.ExpiresInDays)
+		key.ExpiresAt = &expires
+	}
+	if err := h.APIKeys.CreateAPIKey(&key); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityAPIKey, key.ID, models.AuditCreate, nil, key)
+
+	utils.RespondJSON(w, http.StatusCreated, map[string]interface{}{"api_key": viewAPIKey(key, now), "key": raw})
+}
+
+// GetAPIKeys lists the caller's API keys, including revoked and expired ones
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	keys, err := h.APIKeys.ListAPIKeys(user.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now()
+	views := make([]apiKeyView, 0, len(keys))
+	for _, key := range keys {
+		views = append(views, viewAPIKey(key, now))
+	}
+	utils.RespondJSON(w, http.StatusOK, views)
+}
+
+// RevokeAPIKey stops a key from working. Users revoke their own keys; Managers
+// can revoke anyone's.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+	key, err := h.APIKeys.GetAPIKey(uint(id))
+	if err != nil || (key.UserID != user.ID && user.Role != models.RoleManager) {
+		utils.RespondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if key.RevokedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "API key was already revoked")
+		return
+	}
+
+	before := key
+	now := time.Now()
+	if err := h.APIKeys.RevokeAPIKey(key.ID, now); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	key.RevokedAt = &now
+	h.Audit(user.ID, services.EntityAPIKey, key.ID, models.AuditUpdate, before, key)
+
+	utils.RespondJSON(w, http.StatusOK, viewAPIKey(key, now))
+}
@@ -0,0 +1,112 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gearguard/internal/middleware"
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+)
+
+type createdKey struct {
+	APIKey struct {
+		ID     uint     `json:"id"`
+		Prefix string   `json:"prefix"`
+		Scopes []string `json:"scopes"`
+	} `json:"api_key"`
+	Key string `json:"key"`
+}
+
+func (f *fixture) createKey(as models.User, scopes ...string) createdKey {
+	f.t.Helper()
+	w := f.call(f.h.CreateAPIKey, http.MethodPost, "/api/api-keys", as,
+		map[string]interface{}{"name": "ci", "scopes": scopes}, nil)
+	expectStatus(f.t, w, http.StatusCreated)
+	return decode[createdKey](f.t, w)
+}
+
+// withKey calls handler behind middleware.Auth and the route's policy, the way
+// the server mounts it, authenticated by an API key
+func (f *fixture) withKey(key string, action policy.Action, resource policy.Resource, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	f.t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+	r.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	middleware.Auth(f.store)(policy.Require(action, resource, handler)).ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	f := newFixture(t)
+	created := f.createKey(f.tech1, "equipment:read")
+
+	stored, err := f.store.GetAPIKey(created.APIKey.ID)
+	f.must(err)
+	if stored.KeyHash == "" || stored.KeyHash == created.Key || stored.Prefix != created.APIKey.Prefix {
+		t.Fatalf("key should be stored hashed under its prefix: %+v", stored)
+	}
+
+	expectStatus(t, f.withKey(created.Key, policy.Read, policy.Equipment, f.h.GetEquipment), http.StatusOK)
+	expectStatus(t, f.withKey(created.Key, policy.Create, policy.Equipment, f.h.CreateEquipment), http.StatusForbidden)
+	expectStatus(t, f.withKey(created.Key, policy.Read, policy.Request, f.h.GetRequests), http.StatusForbidden)
+
+	// Even a wildcard key can't manage keys or sessions
+	wildcard := f.createKey(f.manager, "*:*")
+	expectStatus(t, f.withKey(wildcard.Key, policy.Read, policy.Team, f.h.GetTeams), http.StatusOK)
+	expectStatus(t, f.withKey(wildcard.Key, policy.Create, policy.APIKey, f.h.CreateAPIKey), http.StatusForbidden)
+	expectStatus(t, f.withKey(wildcard.Key, policy.Delete, policy.Session, f.h.LogoutAll), http.StatusForbidden)
+
+	stored, err = f.store.GetAPIKey(created.APIKey.ID)
+	f.must(err)
+	if stored.LastUsedAt == nil {
+		t.Fatal("expected last use to be recorded")
+	}
+
+	// A tampered secret with a known prefix is refused
+	expectStatus(t, f.withKey(created.Key+"x", policy.Read, policy.Equipment, f.h.GetEquipment), http.StatusUnauthorized)
+}
+
+func TestAPIKeyScopesAreValidated(t *testing.T) {
+	f := newFixture(t)
+	for _, scopes := range [][]string{nil, {"equipment"}, {"gadget:read"}, {"equipment:sell"}, {"session:delete"}, {"team:create"}} {
+		w := f.call(f.h.CreateAPIKey, http.MethodPost, "/api/api-keys", f.employee1,
+			map[string]interface{}{"name": "bad", "scopes": scopes}, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("scopes %v: expected 400, got %d", scopes, w.Code)
+		}
+	}
+}
+
+func TestAPIKeyRevocationAndExpiry(t *testing.T) {
+	f := newFixture(t)
+	created := f.createKey(f.tech1, "equipment:*")
+
+	// Other users can't see or revoke it, but a manager can
+	id := fmt.Sprint(created.APIKey.ID)
+	vars := map[string]string{"id": id}
+	expectStatus(t, f.call(f.h.RevokeAPIKey, http.MethodDelete, "/api/api-keys/"+id, f.tech2, nil, vars), http.StatusNotFound)
+	if keys := decode[[]map[string]interface{}](t, f.call(f.h.GetAPIKeys, http.MethodGet, "/api/api-keys", f.tech2, nil, nil)); len(keys) != 0 {
+		t.Fatalf("expected tech2 to have no keys, got %v", keys)
+	}
+	expectStatus(t, f.call(f.h.RevokeAPIKey, http.MethodDelete, "/api/api-keys/"+id, f.manager, nil, vars), http.StatusOK)
+	expectStatus(t, f.withKey(created.Key, policy.Read, policy.Equipment, f.h.GetEquipment), http.StatusUnauthorized)
+	expectStatus(t, f.call(f.h.RevokeAPIKey, http.MethodDelete, "/api/api-keys/"+id, f.tech1, nil, vars), http.StatusConflict)
+
+	raw, prefix, err := middleware.NewAPIKey()
+	f.must(err)
+	past := time.Now().Add(-time.Minute)
+	f.must(f.store.CreateAPIKey(&models.APIKey{UserID: f.tech1.ID, Name: "old", Prefix: prefix,
+		KeyHash: middleware.HashAPIKey(raw), Scopes: "equipment:read", ExpiresAt: &past}))
+	expectStatus(t, f.withKey(raw, policy.Read, policy.Equipment, f.h.GetEquipment), http.StatusUnauthorized)
+
+	// Disabling the owner stops their keys
+	live := f.createKey(f.tech1, "equipment:read")
+	disabled := f.tech1
+	disabled.Disabled = true
+	f.must(f.store.SaveUser(&disabled))
+	expectStatus(t, f.withKey(live.Key, policy.Read, policy.Equipment, f.h.GetEquipment), http.StatusUnauthorized)
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/storage"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Download links handed to clients stay valid for this long
+const downloadURLTTL = 15 * time.Minute
+
+type attachmentResponse struct {
+	models.Attachment
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+func newAttachmentResponse(a models.Attachment) attachmentResponse {
+	resp := attachmentResponse{Attachment: a, URL: services.SignedDownloadURL(&a, false, downloadURLTTL)}
+	if a.HasThumbnail {
+		resp.ThumbnailURL = services.SignedDownloadURL(&a, true, downloadURLTTL)
+	}
+	return resp
+}
+
+// UploadEquipmentAttachment attaches a file (multipart field "file") to an equipment
+func UploadEquipmentAttachment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var equipment models.Equipment
+	if result := store.ScopeEquipment(database.DB, user).First(&equipment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return
+	}
+
+	uploadAttachment(w, r, services.EntityEquipment, equipment.ID, user)
+}
+
+// UploadRequestAttachment attaches a file (multipart field "file") to a maintenance request
+func UploadRequestAttachment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req models.MaintenanceRequest
+	if result := store.ScopeRequests(database.DB, user).First(&req, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Request not found")
+		return
+	}
+
+	uploadAttachment(w, r, services.EntityRequest, req.ID, user)
+}
+
+func uploadAttachment(w http.ResponseWriter, r *http.Request, entityType string, entityID uint, user models.User) {
+	maxSize := services.MaxAttachmentSize()
+	// Leave room for the multipart envelope around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload must be a multipart form of at most %d MB", maxSize>>20))
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Missing file field")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds the %d MB limit", maxSize>>20))
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attachment, err := services.SaveAttachment(r.Context(), entityType, entityID, header.Filename, content, user.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newAttachmentResponse(*attachment))
+}
+
+// GetEquipmentAttachments lists the files attached to an equipment
+func GetEquipmentAttachments(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var equipment models.Equipment
+	if result := store.ScopeEquipment(database.DB, user).First(&equipment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return
+	}
+
+	respondAttachments(w, services.EntityEquipment, equipment.ID)
+}
+
+// GetRequestAttachments lists the files attached to a maintenance request
+func GetRequestAttachments(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req models.MaintenanceRequest
+	if result := store.ScopeRequests(database.DB, user).First(&req, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Request not found")
+		return
+	}
+
+	respondAttachments(w, services.EntityRequest, req.ID)
+}
+
+func respondAttachments(w http.ResponseWriter, entityType string, entityID uint) {
+	var attachments []models.Attachment
+	if result := database.DB.Where("entity_type = ? AND entity_id = ?", entityType, entityID).Order("created_at desc").Find(&attachments); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	response := make([]attachmentResponse, 0, len(attachments))
+	for _, a := range attachments {
+		response = append(response, newAttachmentResponse(a))
+	}
+	utils.RespondJSON(w, http.StatusOK, response)
+}
+
+// GetAttachment returns attachment metadata with fresh download URLs
+func GetAttachment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	attachment, ok := findVisibleAttachment(w, r, user)
+	if !ok {
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, newAttachmentResponse(attachment))
+}
+
+// DeleteAttachment removes an attachment; the uploader or a manager can delete
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	attachment, ok := findVisibleAttachment(w, r, user)
+	if !ok {
+		return
+	}
+	if attachment.UploadedByID != user.ID && user.Role != models.RoleManager {
+		utils.RespondError(w, http.StatusForbidden, "You can only delete your own attachments")
+		return
+	}
+
+	if err := services.DeleteAttachment(r.Context(), &attachment); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Attachment deleted"})
+}
+
+// DownloadAttachment streams an attachment. It is a public route: access is
+// granted by the signature of a URL issued to an authorized user.
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	query := r.URL.Query()
+	variant := query.Get("variant")
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if !services.VerifyDownloadSignature(uint(id), variant, expires, query.Get("signature")) {
+		utils.RespondError(w, http.StatusForbidden, "Download link is invalid or expired")
+		return
+	}
+
+	var attachment models.Attachment
+	if result := database.DB.First(&attachment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	thumbnail := variant == "thumbnail"
+	content, err := services.OpenAttachment(r.Context(), &attachment, thumbnail)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.RespondError(w, http.StatusNotFound, "File not found")
+		return
+	} else if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer content.Close()
+
+	if thumbnail {
+		w.Header().Set("Content-Type", "image/jpeg")
+	} else {
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.FileName))
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, content)
+}
+
+// findVisibleAttachment loads the attachment in the URL if the user can see its owner entity
+func findVisibleAttachment(w http.ResponseWriter, r *http.Request, user models.User) (models.Attachment, bool) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var attachment models.Attachment
+	if result := database.DB.First(&attachment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Attachment not found")
+		return attachment, false
+	}
+
+	var err error
+	switch attachment.EntityType {
+	case services.EntityEquipment:
+		err = store.ScopeEquipment(database.DB, user).First(&models.Equipment{}, attachment.EntityID).Error
+	case services.EntityRequest:
+		err = store.ScopeRequests(database.DB, user).First(&models.MaintenanceRequest{}, attachment.EntityID).Error
+	default:
+		err = errors.New("unknown attachment owner")
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Attachment not found")
+		return attachment, false
+	}
+	return attachment, true
+}
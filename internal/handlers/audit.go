@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetRequestHistory returns the audit trail of a maintenance request
+func GetRequestHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req models.MaintenanceRequest
+	if result := store.ScopeRequests(database.DB, user).Unscoped().First(&req, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Request not found")
+		return
+	}
+
+	respondHistory(w, services.EntityRequest, req.ID)
+}
+
+// GetEquipmentHistory returns the audit trail of an equipment
+func GetEquipmentHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var equipment models.Equipment
+	if result := store.ScopeEquipment(database.DB, user).First(&equipment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return
+	}
+
+	respondHistory(w, services.EntityEquipment, equipment.ID)
+}
+
+func respondHistory(w http.ResponseWriter, entityType string, entityID uint) {
+	var entries []models.AuditLog
+	result := database.DB.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email, role")
+	}).Where("entity_type = ? AND entity_id = ?", entityType, entityID).Order("created_at asc, id asc").Find(&entries)
+	if result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, entries)
+}
@@ -5,21 +5,23 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"gearguard/internal/database"
+	"gearguard/internal/middleware"
 	"gearguard/internal/models"
+	"gearguard/internal/policy"
 	"gearguard/internal/services"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // ForgotPassword handles password reset request
-func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email string `json:"email"`
 	}
@@ -31,8 +33,18 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("Password reset requested for email: %s\n", input.Email)
 
-	var user models.User
-	if result := database.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
+	send, ok := h.allowPasswordReset(w, r, input.Email)
+	if !ok {
+		return
+	}
+	if !send {
+		log.Println("Password reset: rate limit reached, no link sent")
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "If this email is registered, you will receive a reset link."})
+		return
+	}
+
+	user, err := h.Users.FindUserByEmail(input.Email)
+	if err != nil {
 		fmt.Printf("User not found for email: %s\n", input.Email)
 		// For security, don't reveal if email exists
 		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "If this email is registered, you will receive a reset link."})
@@ -51,7 +63,7 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	// Save to DB
 	user.PasswordResetToken = token
 	user.PasswordResetAt = time.Now().Add(1 * time.Hour)
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := h.Users.SaveUser(&user); err != nil {
 		fmt.Printf("Error saving token to DB: %v\n", err)
 		utils.RespondError(w, http.StatusInternalServerError, "Error saving token")
 		return
@@ -60,21 +72,16 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("Token generated and saved for user %s\n", user.Email)
 
 	// Send Email
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
-	}
-
-	resetLink := fmt.Sprintf("%s/reset-password/%s", frontendURL, token)
+	resetLink := fmt.Sprintf("%s/reset-password/%s", utils.FrontendURL(), token)
 	body := fmt.Sprintf("<h3>Password Reset Request</h3><p>Click the link below to reset your password:</p><a href='%s'>%s</a><p>This link expires in 1 hour.</p>", resetLink, resetLink)
 
-	go services.SendEmail([]string{user.Email}, "Password Reset - GearGuard", body)
+	h.Mail([]string{user.Email}, "Password Reset - GearGuard", body)
 
 	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "If this email is registered, you will receive a reset link."})
 }
 
 // ResetPassword handles the password update
-func ResetPassword(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Token    string `json:"token"`
 		Password string `json:"password"`
@@ -85,8 +92,8 @@ func ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	if result := database.DB.Where("password_reset_token = ? AND password_reset_at > ?", input.Token, time.Now()).First(&user); result.Error != nil {
+	user, err := h.Users.FindUserByResetToken(input.Token, time.Now())
+	if err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "Invalid or expired token")
 		return
 	}
@@ -101,13 +108,17 @@ func ResetPassword(w http.ResponseWriter, r *http.Request) {
 	user.Password = string(hashedPassword)
 	user.PasswordResetToken = "" // Clear token
 	user.PasswordResetAt = time.Time{}
-	database.DB.Save(&user)
+	h.Users.SaveUser(&user)
+	h.Tokens.RevokeUserSessions(user.ID, time.Now()) // Sign out anyone holding the old password
+	// The password hash is never exposed, so record the reset as an explicit field change
+	h.Audit(user.ID, services.EntityUser, user.ID, models.AuditUpdate,
+		map[string]bool{"password_changed": false}, map[string]bool{"password_changed": true})
 
 	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Password updated successfully"})
 }
 
 // Register creates a new user
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Name     string `json:"name"`
 		Email    string `json:"email"`
@@ -121,6 +132,32 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	role := models.RoleEmployee
+	if input.Role != "" {
+		parsed, err := models.ParseRole(input.Role)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role = parsed
+	}
+
+	// Self-registration only creates team-less Employees; anything else needs a
+	// manager's token, or an invitation
+	var actorID uint
+	if role != models.RoleEmployee || input.TeamID != nil {
+		caller, ok := h.bearerUser(r)
+		if !ok || caller.Role != models.RoleManager {
+			message := "Only managers can register Technician or Manager accounts"
+			if role == models.RoleEmployee {
+				message = "Only managers can assign a team; ask a manager for an invitation"
+			}
+			utils.RespondError(w, http.StatusForbidden, message)
+			return
+		}
+		actorID = caller.ID
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Could not hash password")
@@ -131,22 +168,23 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		Name:     input.Name,
 		Email:    input.Email,
 		Password: string(hashedPassword),
-		Role:     input.Role,
+		Role:     role,
 		TeamID:   input.TeamID,
 	}
 
-	if result := database.DB.Create(&user); result.Error != nil {
+	if err := h.Users.CreateUser(&user); err != nil {
 		// Log the actual error for debugging
-		println("Registration Error:", result.Error.Error())
-		utils.RespondError(w, http.StatusBadRequest, result.Error.Error())
+		println("Registration Error:", err.Error())
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	h.Audit(actorID, services.EntityUser, user.ID, models.AuditCreate, nil, user)
 
 	utils.RespondJSON(w, http.StatusCreated, user)
 }
 
-// Login authenticates a user and returns JWT
-func Login(w http.ResponseWriter, r *http.Request) {
+// Login authenticates a user and starts a session, returning an access and a refresh token
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -157,60 +195,170 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	if result := database.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
+	if wait := h.loginBlocked(r, input.Email); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	user, err := h.Users.FindUserByEmail(input.Email)
+	if err != nil {
+		h.recordLoginFailure(r, input.Email, nil)
 		utils.RespondError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		h.recordLoginFailure(r, input.Email, &user)
 		utils.RespondError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
-
-	// Generate JWT
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &utils.Claims{
-		UserID: user.ID,
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	if user.Disabled {
+		utils.RespondError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+	if user.TOTPEnabled || policy.MFARequired(user.Role) {
+		respondChallenge(w, user)
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(utils.SecretKey)
+	tokens, err := h.issueTokens(user, "", r)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
 		return
 	}
+	h.clearLoginFailures(user.Email)
+	utils.RespondJSON(w, http.StatusOK, tokens)
+}
 
-	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
-		"token":   tokenString,
-		"name":    user.Name,
-		"role":    user.Role,
-		"user_id": user.ID,
-	})
+// userListing reads the filter and list parameters of a listing of role's
+// users: team_id and search, besides paging and sorting
+func userListing(r *http.Request, role models.Role) (listQuery[models.User], store.UserFilter, error) {
+	list, err := parseListQuery(r, store.UserSorting)
+	if err != nil {
+		return list, store.UserFilter{}, err
+	}
+	filter := store.UserFilter{Role: role, Search: r.URL.Query().Get("search"), Page: list.Page}
+	filter.TeamID, err = queryID(r.URL.Query(), "team_id")
+	return list, filter, err
 }
 
 // GetEmployees lists all users with 'Employee' role for assignment
-func GetEmployees(w http.ResponseWriter, r *http.Request) {
-	var employees []models.User
-	// Select only necessary fields to avoid leaking passwords/tokens if any
-	if result := database.DB.Where("role = ?", "Employee").Select("id, name, email").Find(&employees); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+func (h *Handler) GetEmployees(w http.ResponseWriter, r *http.Request) {
+	list, filter, err := userListing(r, models.RoleEmployee)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	users, err := h.Users.ListUsers(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	utils.RespondJSON(w, http.StatusOK, employees)
+	// Keep only necessary fields to avoid leaking passwords/tokens if any
+	employees := make([]models.User, 0, len(users))
+	for _, u := range users {
+		employees = append(employees, models.User{ID: u.ID, Name: u.Name, Email: u.Email})
+	}
+	list.respond(w, employees, func() (int64, error) { return h.Users.CountUsers(filter) })
 }
 
 // GetTechnicians lists all users with 'Technician' role
-func GetTechnicians(w http.ResponseWriter, r *http.Request) {
-	var technicians []models.User
-	// Use ILIKE for case-insensitive role check
-	if result := database.DB.Where("role ILIKE ?", "Technician").Find(&technicians); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+func (h *Handler) GetTechnicians(w http.ResponseWriter, r *http.Request) {
+	list, filter, err := userListing(r, models.RoleTechnician)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	utils.RespondJSON(w, http.StatusOK, technicians)
+	technicians, err := h.Users.ListUsers(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	list.respond(w, technicians, func() (int64, error) { return h.Users.CountUsers(filter) })
+}
+
+// bearerUser loads the active user behind the request's token, on routes that
+// don't require one
+func (h *Handler) bearerUser(r *http.Request) (models.User, bool) {
+	claims, err := middleware.Authenticate(h.Users, h.Tokens, r)
+	if err != nil {
+		return models.User{}, false
+	}
+	user, err := h.Users.GetUser(claims.UserID)
+	if err != nil || user.Disabled {
+		return user, false
+	}
+	return user, true
+}
+
+// sessionUser loads the signed-in user through the store
+func (h *Handler) sessionUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	userID, _ := r.Context().Value(utils.UserIDKey).(uint)
+	user, err := h.Users.GetUser(userID)
+	if err != nil || user.Disabled {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return user, false
+	}
+	return user, true
+}
+
+// staffUser is sessionUser rejecting Employees, see requireStaff
+func (h *Handler) staffUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, ok := h.sessionUser(w, r)
+	if ok && user.Role == models.RoleEmployee {
+		utils.RespondError(w, http.StatusForbidden, "Only managers and technicians can perform this action")
+		return user, false
+	}
+	return user, ok
+}
+
+// managerUser is sessionUser rejecting everyone but Managers, see requireManager
+func (h *Handler) managerUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, ok := h.sessionUser(w, r)
+	if ok && user.Role != models.RoleManager {
+		utils.RespondError(w, http.StatusForbidden, "Only managers can perform this action")
+		return user, false
+	}
+	return user, ok
+}
+
+// currentUser loads the authenticated user from the request context
+func currentUser(r *http.Request) (models.User, bool) {
+	var user models.User
+	userID, ok := r.Context().Value(utils.UserIDKey).(uint)
+	if !ok {
+		return user, false
+	}
+	if result := database.DB.First(&user, userID); result.Error != nil || user.Disabled {
+		return user, false
+	}
+	return user, true
+}
+
+// requireStaff loads the current user and rejects Employees
+func requireStaff(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return user, false
+	}
+	if user.Role == models.RoleEmployee {
+		utils.RespondError(w, http.StatusForbidden, "Only managers and technicians can perform this action")
+		return user, false
+	}
+	return user, true
+}
+
+// requireManager loads the current user and rejects everyone but Managers
+func requireManager(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return user, false
+	}
+	if user.Role != models.RoleManager {
+		utils.RespondError(w, http.StatusForbidden, "Only managers can perform this action")
+		return user, false
+	}
+	return user, true
 }
@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// accessToken starts a session for user directly in the store and signs an access token for it
+func (f *fixture) accessToken(user models.User) string {
+	f.t.Helper()
+	f.sessions++
+	sessionID := fmt.Sprintf("session-%d", f.sessions)
+	f.must(f.store.CreateRefreshToken(&models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: sessionID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	claims := &utils.Claims{
+		UserID:           user.ID,
+		Role:             user.Role,
+		SessionID:        sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}
+	token, err := utils.SignToken(claims)
+	f.must(err)
+	return token
+}
+
+// register posts to Register, authenticated with a token for as when it has an ID
+func (f *fixture) register(as models.User, body map[string]interface{}) *httptest.ResponseRecorder {
+	f.t.Helper()
+	var buf bytes.Buffer
+	f.must(json.NewEncoder(&buf).Encode(body))
+	r := httptest.NewRequest(http.MethodPost, "/api/register", &buf)
+	if as.ID != 0 {
+		r.Header.Set("Authorization", "Bearer "+f.accessToken(as))
+	}
+	w := httptest.NewRecorder()
+	f.h.Register(w, r)
+	return w
+}
+
+func TestRegisterDefaultsToEmployee(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.register(models.User{}, map[string]interface{}{"name": "New Hire", "email": "new@example.com", "password": "secret"})
+	expectStatus(t, w, http.StatusCreated)
+	if user := decode[models.User](t, w); user.Role != models.RoleEmployee {
+		t.Fatalf("expected Employee, got %q", user.Role)
+	}
+}
+
+func TestRegisterElevatedRoleNeedsManager(t *testing.T) {
+	f := newFixture(t)
+	body := func(email string) map[string]interface{} {
+		return map[string]interface{}{"name": "Staff", "email": email, "password": "secret", "role": "technician"}
+	}
+
+	expectStatus(t, f.register(models.User{}, body("anon@example.com")), http.StatusForbidden)
+	expectStatus(t, f.register(f.tech1, body("tech@example.com")), http.StatusForbidden)
+
+	w := f.register(f.manager, body("managed@example.com"))
+	expectStatus(t, w, http.StatusCreated)
+	user := decode[models.User](t, w)
+	if user.Role != models.RoleTechnician {
+		t.Fatalf("expected Technician, got %q", user.Role)
+	}
+	if last := f.audit[len(f.audit)-1]; last.ActorID != f.manager.ID {
+		t.Fatalf("expected the manager to be audited as actor, got %d", last.ActorID)
+	}
+}
+
+func TestRegisterTeamNeedsManager(t *testing.T) {
+	f := newFixture(t)
+	body := func(email string) map[string]interface{} {
+		return map[string]interface{}{"name": "Joiner", "email": email, "password": "secret", "team_id": f.team.ID}
+	}
+
+	expectStatus(t, f.register(models.User{}, body("anon@example.com")), http.StatusForbidden)
+	w := f.register(f.manager, body("member@example.com"))
+	expectStatus(t, w, http.StatusCreated)
+	if user := decode[models.User](t, w); user.TeamID == nil || *user.TeamID != f.team.ID {
+		t.Fatalf("expected team %d, got %v", f.team.ID, user.TeamID)
+	}
+}
+
+func TestRegisterRejectsUnknownRole(t *testing.T) {
+	f := newFixture(t)
+	w := f.register(f.manager, map[string]interface{}{"name": "X", "email": "x@example.com", "password": "secret", "role": "Admin"})
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestPolicyGuard(t *testing.T) {
+	f := newFixture(t)
+	guarded := policy.Require(policy.Create, policy.Location, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).ServeHTTP
+
+	expectStatus(t, f.call(guarded, http.MethodPost, "/api/locations", f.employee1, nil, nil), http.StatusForbidden)
+	expectStatus(t, f.call(guarded, http.MethodPost, "/api/locations", f.tech1, nil, nil), http.StatusForbidden)
+	expectStatus(t, f.call(guarded, http.MethodPost, "/api/locations", f.manager, nil, nil), http.StatusNoContent)
+}
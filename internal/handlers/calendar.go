@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/ical"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxCalendarSpan is the widest range GetCalendar answers for
+	maxCalendarSpan = 366 * 24 * time.Hour
+	// Feeds cover scheduled requests from feedPast ago to feedAhead from now
+	feedPast  = 90 * 24 * time.Hour
+	feedAhead = 365 * 24 * time.Hour
+	// feedRefresh is how often calendar apps are asked to poll a feed
+	feedRefresh = time.Hour
+)
+
+// calendarEvent is a scheduled request placed on the calendar
+type calendarEvent struct {
+	Start   time.Time                 `json:"start"`
+	End     time.Time                 `json:"end"` // See MaintenanceRequest.ScheduledEnd
+	Request models.MaintenanceRequest `json:"request"`
+}
+
+// calendarRequests lists the scheduled requests visible to user that are
+// under way at some point in [from, before). Scrapped ones are left out.
+func (h *Handler) calendarRequests(filter store.RequestFilter, user models.User, from, before time.Time) ([]models.MaintenanceRequest, error) {
+	filter.VisibleTo = &user
+	filter.ScheduledBefore = &before
+	filter.EndsAfter = &from
+	filter.ExcludeStatuses = []models.RequestStatus{models.StatusScrap}
+	filter.Page = store.Page{Sort: "scheduled_date"}
+	return h.Requests.ListRequests(filter)
+}
+
+// GetCalendar lists the scheduled requests under way between from and to
+// (dates or RFC 3339 times, to exclusive unless a date), optionally narrowed
+// by type, team_id and technician_id
+func (h *Handler) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from, before, err := queryBounds(q, "from", "to")
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if from == nil || before == nil {
+		utils.RespondError(w, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	if !before.After(*from) {
+		utils.RespondError(w, http.StatusBadRequest, "to must be after from")
+		return
+	}
+	if before.Sub(*from) > maxCalendarSpan {
+		utils.RespondError(w, http.StatusBadRequest, "The range cannot be longer than 366 days")
+		return
+	}
+
+	filter := store.RequestFilter{Type: models.RequestType(q.Get("type"))}
+	var errTeam, errTechnician error
+	filter.TeamID, errTeam = queryID(q, "team_id")
+	filter.TechnicianID, errTechnician = queryID(q, "technician_id")
+	if err := errors.Join(errTeam, errTechnician); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requests, err := h.calendarRequests(filter, user, *from, *before)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	events := make([]calendarEvent, 0, len(requests))
+	for _, req := range requests {
+		events = append(events, calendarEvent{Start: *req.ScheduledDate, End: req.ScheduledEnd(), Request: req})
+	}
+	utils.RespondJSON(w, http.StatusOK, events)
+}
+
+// hashFeedToken is how feed tokens are stored and looked up
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// feedTeamAllowed reports whether user may subscribe to teamID's calendar:
+// Managers to any team's, everyone else to their own team's only
+func feedTeamAllowed(user models.User, teamID uint) bool {
+	return user.Role == models.RoleManager || (user.TeamID != nil && *user.TeamID == teamID)
+}
+
+// CreateCalendarFeed issues a subscription URL for the jobs assigned to the
+// caller, or with team_id for one team's calendar. The URL is only ever
+// returned here.
+func (h *Handler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		TeamID *uint `json:"team_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.TeamID != nil {
+		if _, err := h.Teams.GetTeam(*input.TeamID); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Team not found")
+			return
+		}
+		if !feedTeamAllowed(user, *input.TeamID) {
+			utils.RespondError(w, http.StatusForbidden, "You can only subscribe to your own team's calendar")
+			return
+		}
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	feed := models.CalendarFeed{UserID: user.ID, TeamID: input.TeamID, TokenHash: hashFeedToken(token)}
+	if err := h.Feeds.CreateCalendarFeed(&feed); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityCalendarFeed, feed.ID, models.AuditCreate, nil, feed)
+
+	url := utils.APIURL(r) + "/calendar/feeds/" + token + ".ics"
+	webcal := "webcal://" + strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	utils.RespondJSON(w, http.StatusCreated, map[string]interface{}{"calendar_feed": feed, "url": url, "webcal_url": webcal})
+}
+
+// GetCalendarFeeds lists the caller's feeds, including revoked ones
+func (h *Handler) GetCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	feeds, err := h.Feeds.ListCalendarFeeds(user.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if feeds == nil {
+		feeds = []models.CalendarFeed{}
+	}
+	utils.RespondJSON(w, http.StatusOK, feeds)
+}
+
+// RevokeCalendarFeed stops a feed URL from working. Users revoke their own
+// feeds; Managers can revoke anyone's.
+func (h *Handler) RevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid calendar feed ID")
+		return
+	}
+	feed, err := h.Feeds.GetCalendarFeed(uint(id))
+	if err != nil || (feed.UserID != user.ID && user.Role != models.RoleManager) {
+		utils.RespondError(w, http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+	if feed.RevokedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "Calendar feed was already revoked")
+		return
+	}
+
+	before := feed
+	now := time.Now()
+	if err := h.Feeds.RevokeCalendarFeed(feed.ID, now); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	feed.RevokedAt = &now
+	h.Audit(user.ID, services.EntityCalendarFeed, feed.ID, models.AuditUpdate, before, feed)
+
+	utils.RespondJSON(w, http.StatusOK, feed)
+}
+
+// GetCalendarFeedICS serves a feed to calendar apps as iCalendar. It is
+// public: the token in the path is the credential. What the feed shows is
+// decided at every fetch from its owner's current role and team, so a
+// disabled owner or one who left the team gets nothing.
+func (h *Handler) GetCalendarFeedICS(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.Feeds.FindCalendarFeed(hashFeedToken(mux.Vars(r)["token"]))
+	if err != nil || feed.RevokedAt != nil {
+		utils.RespondError(w, http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+	owner, err := h.Users.GetUser(feed.UserID)
+	if err != nil || owner.Disabled {
+		utils.RespondError(w, http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+
+	name := "GearGuard - " + owner.Name
+	var filter store.RequestFilter
+	if feed.TeamID != nil {
+		team, err := h.Teams.GetTeam(*feed.TeamID)
+		if err != nil || !feedTeamAllowed(owner, team.ID) {
+			utils.RespondError(w, http.StatusNotFound, "Calendar feed not found")
+			return
+		}
+		name = "GearGuard - " + team.Name
+		filter.TeamID = team.ID
+	} else {
+		// A personal feed is the owner's own jobs, not everything they can see
+		filter.TechnicianID = owner.ID
+	}
+
+	now := time.Now()
+	requests, err := h.calendarRequests(filter, owner, now.Add(-feedPast), now.Add(feedAhead))
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	calendar := ical.Calendar{ProdID: "-//GearGuard//Maintenance Calendar//EN", Name: name, Refresh: feedRefresh}
+	for _, req := range requests {
+		calendar.Events = append(calendar.Events, feedEvent(req))
+	}
+	if err := h.Feeds.TouchCalendarFeed(feed.ID, now); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="gearguard.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ical.Encode(calendar, now))
+}
+
+// feedEvent describes a scheduled request to calendar apps
+func feedEvent(req models.MaintenanceRequest) ical.Event {
+	summary := req.Subject
+	if req.Equipment.Name != "" {
+		summary += " (" + req.Equipment.Name + ")"
+	}
+	details := []string{
+		fmt.Sprintf("Request #%d", req.ID),
+		"Type: " + string(req.Type),
+		"Status: " + string(req.Status),
+	}
+	if req.Team.Name != "" {
+		details = append(details, "Team: "+req.Team.Name)
+	}
+	if req.Technician != nil {
+		details = append(details, "Technician: "+req.Technician.Name)
+	}
+	return ical.Event{
+		UID:          fmt.Sprintf("request-%d@gearguard", req.ID),
+		Start:        *req.ScheduledDate,
+		End:          req.ScheduledEnd(),
+		Summary:      summary,
+		Description:  strings.Join(details, "\n"),
+		Location:     req.Equipment.Location,
+		Categories:   []string{string(req.Type)},
+		LastModified: req.UpdatedAt,
+	}
+}
@@ -0,0 +1,232 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+)
+
+// scheduled seeds a request scheduled at start for hours
+func (f *fixture) scheduled(equipment models.Equipment, subject string, start time.Time, hours float64) models.MaintenanceRequest {
+	f.t.Helper()
+	req := models.MaintenanceRequest{
+		Subject:       subject,
+		Type:          models.TypePreventive,
+		Status:        models.StatusNew,
+		EquipmentID:   equipment.ID,
+		TeamID:        equipment.MaintenanceTeamID,
+		TechnicianID:  equipment.DefaultTechnicianID,
+		CreatedByID:   f.manager.ID,
+		ScheduledDate: &start,
+		DurationHours: hours,
+	}
+	f.must(f.store.CreateRequest(&req))
+	return req
+}
+
+type calendarEvent struct {
+	Start   time.Time                 `json:"start"`
+	End     time.Time                 `json:"end"`
+	Request models.MaintenanceRequest `json:"request"`
+}
+
+func TestCalendarRange(t *testing.T) {
+	f := newFixture(t)
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	morning := f.scheduled(f.drill, "Morning service", day.Add(9*time.Hour), 2)
+	overnight := f.scheduled(f.press, "Overnight run", day.Add(-time.Hour), 3)
+	f.scheduled(f.lathe, "Done the day before", day.Add(-4*time.Hour), 0) // Default hour ends at 21:00
+	f.scheduled(f.drill, "Next day", day.AddDate(0, 0, 1), 1)
+	scrapped := f.scheduled(f.drill, "Scrapped", day.Add(12*time.Hour), 1)
+	scrapped.Status = models.StatusScrap
+	f.must(f.store.UpdateRequest(&scrapped, nil, f.manager.ID))
+	f.request(f.drill, f.employee1, models.StatusNew) // Not scheduled
+
+	w := f.call(f.h.GetCalendar, http.MethodGet, "/api/calendar?from=2026-03-10&to=2026-03-10", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	events := decode[[]calendarEvent](t, w)
+	if len(events) != 2 || events[0].Request.ID != overnight.ID || events[1].Request.ID != morning.ID {
+		t.Fatalf("expected the overnight run then the morning service, got %+v", events)
+	}
+	if !events[1].End.Equal(day.Add(11*time.Hour)) || !events[0].End.Equal(day.Add(2*time.Hour)) {
+		t.Fatalf("end times should follow the durations: %v, %v", events[0].End, events[1].End)
+	}
+	if events[1].Request.Equipment.Name != "Drill" {
+		t.Fatalf("events should carry the request with its equipment: %+v", events[1].Request)
+	}
+
+	// Visibility rules apply as on the request list
+	w = f.call(f.h.GetCalendar, http.MethodGet, "/api/calendar?from=2026-03-10&to=2026-03-10", f.tech1, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	if events := decode[[]calendarEvent](t, w); len(events) != 1 || events[0].Request.ID != morning.ID {
+		t.Fatalf("tech1 should only see the drill's service, got %+v", events)
+	}
+
+	for _, query := range []string{"from=2026-03-10", "from=2026-03-10&to=2026-03-09", "from=2026-01-01&to=2027-06-01", "from=tomorrow&to=2026-03-10"} {
+		w := f.call(f.h.GetCalendar, http.MethodGet, "/api/calendar?"+query, f.manager, nil, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+type createdFeed struct {
+	Feed struct {
+		ID     uint  `json:"id"`
+		TeamID *uint `json:"team_id"`
+	} `json:"calendar_feed"`
+	URL       string `json:"url"`
+	WebcalURL string `json:"webcal_url"`
+}
+
+// Token is the credential at the end of the feed URL
+func (c createdFeed) Token() string {
+	return strings.TrimSuffix(c.URL[strings.LastIndex(c.URL, "/")+1:], ".ics")
+}
+
+func (f *fixture) createFeed(as models.User, body map[string]interface{}) createdFeed {
+	f.t.Helper()
+	w := f.call(f.h.CreateCalendarFeed, http.MethodPost, "/api/calendar/feeds", as, body, nil)
+	expectStatus(f.t, w, http.StatusCreated)
+	return decode[createdFeed](f.t, w)
+}
+
+func (f *fixture) fetchFeed(token string) (int, string) {
+	f.t.Helper()
+	w := f.call(f.h.GetCalendarFeedICS, http.MethodGet, "/api/calendar/feeds/"+token+".ics", models.User{}, nil,
+		map[string]string{"token": token})
+	return w.Code, w.Body.String()
+}
+
+func TestCalendarFeedICS(t *testing.T) {
+	f := newFixture(t)
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour).UTC()
+	service := f.scheduled(f.drill, "Lubricate spindle, check belts; replace filter", start, 1.5)
+	drill := f.drill
+	drill.Location = "Hall B, " + strings.Repeat("aisle ", 15)
+	f.must(f.store.SaveEquipment(&drill))
+	press := f.scheduled(f.press, "Press service", start, 1)
+	unassigned := f.scheduled(f.drill, "Inspect guard", start, 1)
+	unassigned.TechnicianID = nil
+	f.must(f.store.UpdateRequest(&unassigned, nil, f.manager.ID))
+
+	feed := f.createFeed(f.tech1, map[string]interface{}{})
+	if !strings.Contains(feed.URL, "/api/calendar/feeds/") || !strings.HasPrefix(feed.WebcalURL, "webcal://") || len(feed.Token()) != 64 {
+		t.Fatalf("unexpected feed URLs: %+v", feed)
+	}
+	stored, err := f.store.GetCalendarFeed(feed.Feed.ID)
+	f.must(err)
+	if stored.TokenHash == "" || stored.TokenHash == feed.Token() {
+		t.Fatal("feed token should be stored hashed")
+	}
+
+	status, body := f.fetchFeed(feed.Token())
+	if status != http.StatusOK {
+		t.Fatalf("expected the feed, got %d: %s", status, body)
+	}
+	if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+		t.Fatalf("not an iCalendar object:\n%s", body)
+	}
+	for _, line := range strings.SplitAfter(body, "\r\n") {
+		if strings.Contains(strings.TrimSuffix(line, "\r\n"), "\n") || len(strings.TrimSuffix(line, "\r\n")) > 75 {
+			t.Fatalf("line is not CRLF-terminated and folded: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(body, "\r\n ", "")
+	for _, want := range []string{
+		fmt.Sprintf("UID:request-%d@gearguard\r\n", service.ID),
+		"DTSTART:" + start.Format("20060102T150405Z"),
+		"DTEND:" + start.Add(90*time.Minute).Format("20060102T150405Z"),
+		`SUMMARY:Lubricate spindle\, check belts\; replace filter (Drill)`,
+		`LOCATION:Hall B\, aisle`,
+		`DESCRIPTION:Request #`,
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("feed is missing %q:\n%s", want, unfolded)
+		}
+	}
+	if strings.Contains(unfolded, fmt.Sprintf("request-%d@", press.ID)) {
+		t.Error("tech1's feed shows a request they can't see")
+	}
+	if strings.Contains(unfolded, fmt.Sprintf("request-%d@", unassigned.ID)) {
+		t.Error("tech1's feed shows a visible request that isn't assigned to them")
+	}
+	if stored, _ := f.store.GetCalendarFeed(feed.Feed.ID); stored.LastFetchedAt == nil {
+		t.Error("expected the fetch to be recorded")
+	}
+
+	// Only the owner (or a Manager) can revoke, once
+	revoke := func(as models.User) int {
+		return f.call(f.h.RevokeCalendarFeed, http.MethodDelete, "/api/calendar/feeds/x", as, nil,
+			map[string]string{"id": fmt.Sprint(feed.Feed.ID)}).Code
+	}
+	if code := revoke(f.tech2); code != http.StatusNotFound {
+		t.Fatalf("expected 404 revoking someone else's feed, got %d", code)
+	}
+	if code := revoke(f.tech1); code != http.StatusOK {
+		t.Fatalf("expected the owner to revoke, got %d", code)
+	}
+	if code := revoke(f.tech1); code != http.StatusConflict {
+		t.Fatalf("expected 409 revoking twice, got %d", code)
+	}
+	if status, _ := f.fetchFeed(feed.Token()); status != http.StatusNotFound {
+		t.Fatalf("revoked feed still served: %d", status)
+	}
+	if status, _ := f.fetchFeed(strings.Repeat("0", 64)); status != http.StatusNotFound {
+		t.Fatalf("unknown token served: %d", status)
+	}
+
+	// API keys can't mint feed URLs, whatever their scopes
+	key := f.createKey(f.manager, "*:*")
+	expectStatus(t, f.withKey(key.Key, policy.Create, policy.CalendarFeed, f.h.CreateCalendarFeed), http.StatusForbidden)
+}
+
+func TestTeamCalendarFeed(t *testing.T) {
+	f := newFixture(t)
+	electrical := models.MaintenanceTeam{Name: "Electrical Team"}
+	f.must(f.store.CreateTeam(&electrical))
+	start := time.Now().Add(24 * time.Hour)
+	mechanical := f.scheduled(f.lathe, "Mechanical job", start, 1)
+	wiring := f.scheduled(f.lathe, "Wiring job", start, 1)
+	wiring.TeamID = electrical.ID
+	f.must(f.store.UpdateRequest(&wiring, nil, f.manager.ID))
+
+	w := f.call(f.h.CreateCalendarFeed, http.MethodPost, "/api/calendar/feeds", f.employee1,
+		map[string]interface{}{"team_id": electrical.ID}, nil)
+	expectStatus(t, w, http.StatusForbidden)
+	w = f.call(f.h.CreateCalendarFeed, http.MethodPost, "/api/calendar/feeds", f.manager,
+		map[string]interface{}{"team_id": 9999}, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	feed := f.createFeed(f.manager, map[string]interface{}{"team_id": f.team.ID})
+	status, body := f.fetchFeed(feed.Token())
+	if status != http.StatusOK || !strings.Contains(body, "X-WR-CALNAME:GearGuard - Mechanical Team") {
+		t.Fatalf("expected the team's calendar, got %d:\n%s", status, body)
+	}
+	if !strings.Contains(body, fmt.Sprintf("request-%d@", mechanical.ID)) || strings.Contains(body, fmt.Sprintf("request-%d@", wiring.ID)) {
+		t.Fatalf("team feed should only show the team's requests:\n%s", body)
+	}
+
+	// Access is rechecked on every fetch
+	member := f.createFeed(f.tech1, map[string]interface{}{"team_id": f.team.ID})
+	if status, _ := f.fetchFeed(member.Token()); status != http.StatusOK {
+		t.Fatalf("expected a member's team feed, got %d", status)
+	}
+	moved := f.tech1
+	moved.TeamID = &electrical.ID
+	f.must(f.store.SaveUser(&moved))
+	if status, _ := f.fetchFeed(member.Token()); status != http.StatusNotFound {
+		t.Fatalf("feed of a team the owner left is still served: %d", status)
+	}
+	disabled := f.manager
+	disabled.Disabled = true
+	f.must(f.store.SaveUser(&disabled))
+	if status, _ := f.fetchFeed(feed.Token()); status != http.StatusNotFound {
+		t.Fatalf("feed of a disabled owner is still served: %d", status)
+	}
+}
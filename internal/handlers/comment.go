@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// GetComments lists the comments of a request visible to the current user
+func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.requestFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	// Requesters (Employees) never see internal work notes
+	comments, err := h.Comments.ListComments(req.ID, user.Role != models.RoleEmployee)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if comments == nil {
+		comments = []models.RequestComment{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, comments)
+}
+
+// CreateComment adds a comment or work note to a request and notifies the
+// requester and technician
+func (h *Handler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.requestFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body     string `json:"body"`
+		Internal bool   `json:"internal"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(input.Body) == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Comment body is required")
+		return
+	}
+	if input.Internal && user.Role == models.RoleEmployee {
+		utils.RespondError(w, http.StatusForbidden, "Only technicians and managers can add internal notes")
+		return
+	}
+
+	comment := models.RequestComment{
+		RequestID: req.ID,
+		AuthorID:  user.ID,
+		Body:      input.Body,
+		Internal:  input.Internal,
+	}
+	if err := h.Comments.CreateComment(&comment); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityComment, comment.ID, models.AuditCreate, nil, comment)
+
+	// Notify requester (unless the note is internal) and technician, never the author
+	var recipients []string
+	if !comment.Internal && req.CreatedByID != user.ID {
+		if creator, err := h.Users.GetUser(req.CreatedByID); err == nil && creator.Email != "" {
+			recipients = append(recipients, creator.Email)
+		}
+	}
+	if req.TechnicianID != nil && *req.TechnicianID != user.ID {
+		if tech, err := h.Users.GetUser(*req.TechnicianID); err == nil {
+			recipients = append(recipients, tech.Email)
+		}
+	}
+	services.SendCommentNotification(recipients, req.Subject, user.Name, comment.Body)
+
+	comment.Author = &models.User{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role}
+	utils.RespondJSON(w, http.StatusCreated, comment)
+}
+
+// UpdateComment edits a comment; only the author can edit
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	comment, ok := h.commentFromVars(w, r)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID {
+		utils.RespondError(w, http.StatusForbidden, "You can only edit your own comments")
+		return
+	}
+
+	var input struct {
+		Body     *string `json:"body"`
+		Internal *bool   `json:"internal"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before := comment
+	if input.Body != nil {
+		if strings.TrimSpace(*input.Body) == "" {
+			utils.RespondError(w, http.StatusBadRequest, "Comment body is required")
+			return
+		}
+		comment.Body = *input.Body
+	}
+	if input.Internal != nil {
+		if *input.Internal && user.Role == models.RoleEmployee {
+			utils.RespondError(w, http.StatusForbidden, "Only technicians and managers can add internal notes")
+			return
+		}
+		comment.Internal = *input.Internal
+	}
+	comment.Edited = true
+
+	if err := h.Comments.SaveComment(&comment); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityComment, comment.ID, models.AuditUpdate, before, comment)
+
+	utils.RespondJSON(w, http.StatusOK, comment)
+}
+
+// DeleteComment removes a comment; authors and managers can delete.
+// The comment stays in the database (soft delete) and its history is kept.
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	comment, ok := h.commentFromVars(w, r)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID && user.Role != models.RoleManager {
+		utils.RespondError(w, http.StatusForbidden, "You can only delete your own comments")
+		return
+	}
+
+	if err := h.Comments.DeleteComment(comment.ID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityComment, comment.ID, models.AuditDelete, comment, nil)
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted"})
+}
+
+// GetCommentHistory returns the edit history of a comment
+func (h *Handler) GetCommentHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	comment, err := h.Comments.GetComment(uint(id), true)
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	req, err := h.Requests.GetRequest(comment.RequestID)
+	if err != nil || !store.RequestVisible(req, req.Equipment, user) ||
+		(comment.Internal && user.Role == models.RoleEmployee) {
+		utils.RespondError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	respondHistory(w, services.EntityComment, comment.ID)
+}
+
+// requestFromVars loads the request in the URL if the user is allowed to see it
+func (h *Handler) requestFromVars(w http.ResponseWriter, r *http.Request, user models.User) (models.MaintenanceRequest, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	req, err := h.Requests.GetRequest(uint(id))
+	if err != nil || !store.RequestVisible(req, req.Equipment, user) {
+		utils.RespondError(w, http.StatusNotFound, "Request not found")
+		return req, false
+	}
+	return req, true
+}
+
+func (h *Handler) commentFromVars(w http.ResponseWriter, r *http.Request) (models.RequestComment, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	comment, err := h.Comments.GetComment(uint(id), false)
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Comment not found")
+		return comment, false
+	}
+	return comment, true
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// AddVendorCost records an external vendor cost on a request
+func AddVendorCost(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireStaff(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := findVisibleRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	var cost models.VendorCost
+	if err := json.NewDecoder(r.Body).Decode(&cost); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if cost.Vendor == "" || cost.Amount <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Vendor and a positive amount are required")
+		return
+	}
+
+	cost.ID = 0
+	cost.RequestID = req.ID
+	cost.RecordedByID = user.ID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&cost).Error; err != nil {
+			return err
+		}
+		return services.RecalculateCosts(tx, req.ID)
+	})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, cost)
+}
+
+// GetVendorCosts lists the vendor costs of a request
+func GetVendorCosts(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	req, ok := findVisibleRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	var costs []models.VendorCost
+	if result := database.DB.Where("request_id = ?", req.ID).Order("created_at").Find(&costs); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, costs)
+}
+
+// DeleteVendorCost removes a vendor cost (managers only)
+func DeleteVendorCost(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireManager(w, r); !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var cost models.VendorCost
+	if result := database.DB.First(&cost, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Cost not found")
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&cost).Error; err != nil {
+			return err
+		}
+		return services.RecalculateCosts(tx, cost.RequestID)
+	})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Cost deleted"})
+}
+
+// GetEquipmentCosts returns the maintenance cost of an equipment with a breakdown
+// by month and request type. ?from and ?to (YYYY-MM-DD) default to the last 12 months.
+func GetEquipmentCosts(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var equipment models.Equipment
+	if result := store.ScopeEquipment(database.DB, user).First(&equipment, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -11, 0)
+	to := now.AddDate(0, 0, 1)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+			return
+		}
+		to = parsed.AddDate(0, 0, 1) // Inclusive
+	}
+
+	monthly, err := services.EquipmentMonthlyCosts(equipment.ID, from, to)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	total := services.CostBreakdown{}
+	byType := map[models.RequestType]services.CostBreakdown{
+		models.TypeCorrective: {},
+		models.TypePreventive: {},
+	}
+	for _, m := range monthly {
+		t := byType[m.Type]
+		t.LaborCost += m.LaborCost
+		t.PartsCost += m.PartsCost
+		t.VendorCost += m.VendorCost
+		t.TotalCost += m.TotalCost
+		byType[m.Type] = t
+
+		total.LaborCost += m.LaborCost
+		total.PartsCost += m.PartsCost
+		total.VendorCost += m.VendorCost
+		total.TotalCost += m.TotalCost
+	}
+	if monthly == nil {
+		monthly = []services.MonthlyCost{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"equipment_id": equipment.ID,
+		"from":         from.Format("2006-01-02"),
+		"to":           to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total":        total,
+		"by_type":      byType,
+		"by_month":     monthly,
+	})
+}
+
+// SetUserLaborRate sets the hourly labor rate of a technician (managers only)
+func SetUserLaborRate(w http.ResponseWriter, r *http.Request) {
+	setLaborRate(w, r, &models.User{}, services.EntityUser)
+}
+
+// SetTeamLaborRate sets the default hourly labor rate of a team (managers only)
+func SetTeamLaborRate(w http.ResponseWriter, r *http.Request) {
+	setLaborRate(w, r, &models.MaintenanceTeam{}, services.EntityTeam)
+}
+
+func setLaborRate(w http.ResponseWriter, r *http.Request, target interface{}, entityType string) {
+	user, ok := requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var input struct {
+		HourlyRate float64 `json:"hourly_rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.HourlyRate < 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Hourly rate cannot be negative")
+		return
+	}
+
+	var previous struct {
+		HourlyRate float64 `json:"hourly_rate"`
+	}
+	if result := database.DB.Model(target).Where("id = ?", id).Select("hourly_rate").Scan(&previous); result.Error != nil || result.RowsAffected == 0 {
+		utils.RespondError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if result := database.DB.Model(target).Where("id = ?", id).Update("hourly_rate", input.HourlyRate); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+	services.RecordAudit(user.ID, entityType, uint(id), models.AuditUpdate, previous, input)
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"id": id, "hourly_rate": input.HourlyRate})
+}
+
+// findVisibleRequest loads the request in the URL if the user is allowed to see it
+func findVisibleRequest(w http.ResponseWriter, r *http.Request, user models.User) (models.MaintenanceRequest, bool) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req models.MaintenanceRequest
+	if result := store.ScopeRequests(database.DB.Preload("CreatedBy").Preload("Technician"), user).First(&req, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Request not found")
+		return req, false
+	}
+	return req, true
+}
@@ -4,8 +4,8 @@ import (
 	"net/http"
 	"time"
 
-	"gearguard/internal/database"
 	"gearguard/internal/models"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
 )
 
@@ -16,28 +16,38 @@ type DashboardStats struct {
 	OverdueRequests   int64   `json:"overdue_requests"`
 	TechnicianCount   int64   `json:"technician_count"`
 	UtilizationRate   float64 `json:"utilization_rate"`
+
+	TotalCost      float64 `json:"total_cost"`
+	CostThisMonth  float64 `json:"cost_this_month"`
+	CorrectiveCost float64 `json:"corrective_cost"`
+	PreventiveCost float64 `json:"preventive_cost"`
 }
 
-func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 	var stats DashboardStats
 
 	// 1. Total Equipment
-	database.DB.Model(&models.Equipment{}).Count(&stats.TotalEquipment)
+	stats.TotalEquipment, _ = h.Equipment.CountEquipment(store.EquipmentFilter{})
 
 	// 2. Critical Equipment (Scrapped/Unusable)
-	database.DB.Model(&models.Equipment{}).Where("is_usable = ?", false).Count(&stats.CriticalEquipment)
+	usable := false
+	stats.CriticalEquipment, _ = h.Equipment.CountEquipment(store.EquipmentFilter{Usable: &usable})
 
 	// 3. Open Requests (New or In Progress)
-	database.DB.Model(&models.MaintenanceRequest{}).Where("status IN ?", []string{"New", "In Progress"}).Count(&stats.OpenRequests)
+	stats.OpenRequests, _ = h.Requests.CountRequests(store.RequestFilter{
+		Statuses: []models.RequestStatus{models.StatusNew, models.StatusInProgress},
+	})
 
 	// 4. Overdue Requests
-	database.DB.Model(&models.MaintenanceRequest{}).
-		Where("scheduled_date < ? AND status NOT IN ?", time.Now(), []string{"Repaired", "Scrap"}).
-		Count(&stats.OverdueRequests)
+	now := time.Now()
+	stats.OverdueRequests, _ = h.Requests.CountRequests(store.RequestFilter{
+		ScheduledBefore: &now,
+		ExcludeStatuses: []models.RequestStatus{models.StatusRepaired, models.StatusScrap},
+	})
 	
 	// 5. Technician Load & Utilization
 	// Count Technicians
-	database.DB.Model(&models.User{}).Where("role = ?", "Technician").Count(&stats.TechnicianCount)
+	stats.TechnicianCount, _ = h.Users.CountUsersByRole(models.RoleTechnician)
 	
 	// Calculate Utilization: (Open Requests / (Technicians * MaxCapacity)) * 100
 	// We assume a theoretical max capacity of 5 active tickets per technician.
@@ -53,5 +63,12 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 		stats.UtilizationRate = 0
 	}
 
+	// 6. Maintenance Costs
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	stats.TotalCost, _ = h.Requests.TotalCost(store.RequestFilter{})
+	stats.CostThisMonth, _ = h.Requests.TotalCost(store.RequestFilter{CreatedSince: &monthStart})
+	stats.CorrectiveCost, _ = h.Requests.TotalCost(store.RequestFilter{Type: models.TypeCorrective})
+	stats.PreventiveCost, _ = h.Requests.TotalCost(store.RequestFilter{Type: models.TypePreventive})
+
 	utils.RespondJSON(w, http.StatusOK, stats)
 }
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"gearguard/internal/handlers"
+	"gearguard/internal/models"
+)
+
+func TestGetDashboardStats(t *testing.T) {
+	f := newFixture(t)
+
+	// The press was scrapped
+	f.press.IsUsable = false
+	f.must(f.store.SaveEquipment(&f.press))
+
+	past := time.Now().Add(-48 * time.Hour)
+	future := time.Now().Add(48 * time.Hour)
+	lastYear := time.Now().AddDate(-1, 0, 0)
+
+	overdue := f.request(f.drill, f.employee1, models.StatusNew)
+	overdue.ScheduledDate = &past
+	overdue.TotalCost = 100
+	f.must(f.store.UpdateRequest(&overdue, nil, f.manager.ID))
+
+	upcoming := f.request(f.drill, f.employee1, models.StatusInProgress)
+	upcoming.ScheduledDate = &future
+	upcoming.Type = models.TypePreventive
+	upcoming.TotalCost = 40
+	f.must(f.store.UpdateRequest(&upcoming, nil, f.manager.ID))
+
+	// Finished work is neither open nor overdue, but its cost counts
+	repairedLongAgo := f.request(f.press, f.employee2, models.StatusRepaired)
+	repairedLongAgo.ScheduledDate = &past
+	repairedLongAgo.CreatedAt = lastYear
+	repairedLongAgo.TotalCost = 250
+	f.must(f.store.UpdateRequest(&repairedLongAgo, nil, f.manager.ID))
+
+	w := f.call(f.h.GetDashboardStats, http.MethodGet, "/api/dashboard/stats", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	stats := decode[handlers.DashboardStats](t, w)
+
+	if stats.TotalEquipment != 3 {
+		t.Errorf("total equipment = %d, want 3", stats.TotalEquipment)
+	}
+	if stats.CriticalEquipment != 1 {
+		t.Errorf("critical equipment = %d, want 1", stats.CriticalEquipment)
+	}
+	if stats.OpenRequests != 2 {
+		t.Errorf("open requests = %d, want 2", stats.OpenRequests)
+	}
+	if stats.OverdueRequests != 1 {
+		t.Errorf("overdue requests = %d, want 1", stats.OverdueRequests)
+	}
+	if stats.TechnicianCount != 3 {
+		t.Errorf("technicians = %d, want 3", stats.TechnicianCount)
+	}
+	// 2 open requests over 3 technicians * 5 tickets of capacity
+	if want := 2.0 / 15.0 * 100; math.Abs(stats.UtilizationRate-want) > 1e-9 {
+		t.Errorf("utilization = %v, want %v", stats.UtilizationRate, want)
+	}
+
+	if stats.TotalCost != 390 {
+		t.Errorf("total cost = %v, want 390", stats.TotalCost)
+	}
+	if stats.CostThisMonth != 140 {
+		t.Errorf("cost this month = %v, want 140", stats.CostThisMonth)
+	}
+	if stats.CorrectiveCost != 350 || stats.PreventiveCost != 40 {
+		t.Errorf("corrective/preventive cost = %v/%v, want 350/40", stats.CorrectiveCost, stats.PreventiveCost)
+	}
+}
+
+func TestGetDashboardStatsUtilizationCapped(t *testing.T) {
+	f := newFixture(t)
+	for i := 0; i < 20; i++ {
+		f.request(f.lathe, f.manager, models.StatusNew)
+	}
+
+	w := f.call(f.h.GetDashboardStats, http.MethodGet, "/api/dashboard/stats", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	if stats := decode[handlers.DashboardStats](t, w); stats.UtilizationRate != 100 {
+		t.Errorf("utilization = %v, want capped at 100", stats.UtilizationRate)
+	}
+}
@@ -2,18 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
-	"gearguard/internal/database"
 	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
 
 	"github.com/gorilla/mux"
 )
 
 // CreateEquipment creates a new equipment record
-func CreateEquipment(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateEquipment(w http.ResponseWriter, r *http.Request) {
 	var equipment models.Equipment
 	if err := json.NewDecoder(r.Body).Decode(&equipment); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, err.Error())
@@ -21,22 +25,43 @@ func CreateEquipment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate MaintenanceTeamID exists
-	var team models.MaintenanceTeam
-	if result := database.DB.First(&team, equipment.MaintenanceTeamID); result.Error != nil {
+	if _, err := h.Teams.GetTeam(equipment.MaintenanceTeamID); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "Invalid Maintenance Team ID")
 		return
 	}
 
-	if result := database.DB.Create(&equipment); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	// Validate the location node and parent assembly, if given
+	if equipment.LocationID != nil {
+		loc, err := h.Locations.GetLocation(*equipment.LocationID)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid Location ID")
+			return
+		}
+		equipment.Location = loc.Name
+	}
+	if equipment.ParentID != nil {
+		if _, err := h.Equipment.GetEquipment(*equipment.ParentID); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid Parent Equipment ID")
+			return
+		}
+	}
+	equipment.LocationNode = nil
+	equipment.Children = nil
+
+	if err := h.Equipment.CreateEquipment(&equipment); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	userID, _ := r.Context().Value(utils.UserIDKey).(uint)
+	h.Audit(userID, services.EntityEquipment, equipment.ID, models.AuditCreate, nil, equipment)
+	h.publishEquipment(models.AuditCreate, models.Equipment{}, equipment)
+
 	utils.RespondJSON(w, http.StatusCreated, equipment)
 }
 
-// GetEquipment lists all equipment
-func GetEquipment(w http.ResponseWriter, r *http.Request) {
+// GetEquipment lists active equipment, with filtering, sorting and paging (see listQuery)
+func (h *Handler) GetEquipment(w http.ResponseWriter, r *http.Request) {
 	// Get User ID from Context
 	userID, ok := r.Context().Value(utils.UserIDKey).(uint)
 	if !ok {
@@ -45,53 +70,419 @@ func GetEquipment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch User to check Role
-	var user models.User
-	if result := database.DB.First(&user, userID); result.Error != nil {
-		println("DEBUG: Equipment fetch - User not found:", userID)
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
 		utils.RespondError(w, http.StatusUnauthorized, "User not found")
 		return
 	}
 
-	println("DEBUG: Equipment fetch for User:", user.Name, "Role:", user.Role)
-
-	query := database.DB.Preload("MaintenanceTeam").Preload("Employee").Preload("DefaultTechnician")
-
-	// Filter: Employees see owned equipment, Technicians see equipment where they are default
-	if user.Role == "Employee" {
-		query = query.Where("employee_id = ?", userID)
-	} else if user.Role == "Technician" {
-		query = query.Where("default_technician_id = ?", userID)
-		println("DEBUG: Filtering equipment for Technician ID:", userID)
+	list, err := parseListQuery(r, store.EquipmentSorting)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	q := r.URL.Query()
 
-	// Search Filter: Name or Department
-	search := r.URL.Query().Get("search")
-	if search != "" {
-		searchTerm := "%" + search + "%"
-		// Use a grouped condition to avoid messing up the EmployeeID filter
-		// (employee_id = X) AND (name LIKE %Y% OR department LIKE %Y%)
-		query = query.Where(database.DB.Where("name ILIKE ?", searchTerm).Or("department ILIKE ?", searchTerm))
+	filter := store.EquipmentFilter{
+		// Filter: Employees see owned equipment, Technicians see equipment where they are default
+		VisibleTo: &user,
+		// Search Filter: Name or Department
+		Search: q.Get("search"),
+		// ?archived=true lists archived equipment instead
+		Archived:   q.Get("archived") == "true",
+		Category:   q.Get("category"),
+		Department: q.Get("department"),
+		Page:       list.Page,
+	}
+	if usable, err := strconv.ParseBool(q.Get("usable")); err == nil {
+		filter.Usable = &usable
+	}
+	var errTeam, errTechnician, errEmployee error
+	filter.TeamID, errTeam = queryID(q, "team_id")
+	filter.TechnicianID, errTechnician = queryID(q, "technician_id")
+	filter.EmployeeID, errEmployee = queryID(q, "employee_id")
+	if err := errors.Join(errTeam, errTechnician, errEmployee); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	var equipment []models.Equipment
-	if result := query.Find(&equipment); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	equipment, err := h.Equipment.ListEquipment(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, equipment)
+	list.respond(w, equipment, func() (int64, error) { return h.Equipment.CountEquipment(filter) })
 }
 
 // GetEquipmentRequests returns all requests for a specific equipment (Smart Button logic)
-func GetEquipmentRequests(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetEquipmentRequests(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid Equipment ID")
+		return
+	}
 
-	var requests []models.MaintenanceRequest
-	if result := database.DB.Where("equipment_id = ?", id).Find(&requests); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	requests, err := h.Requests.ListRequests(store.RequestFilter{EquipmentID: uint(id)})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	
 	utils.RespondJSON(w, http.StatusOK, requests)
 }
+
+// optionalID is an ID in a partial update that tells a missing key apart
+// from an explicit null, which clears the field
+type optionalID struct {
+	Set   bool
+	Value *uint
+}
+
+func (o *optionalID) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// equipmentFromVars loads the equipment named in the route, answering 400/404
+// itself. Equipment the user can't see in listings is not found here either.
+func (h *Handler) equipmentFromVars(w http.ResponseWriter, r *http.Request, user models.User) (models.Equipment, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid Equipment ID")
+		return models.Equipment{}, false
+	}
+	equipment, err := h.Equipment.GetEquipment(uint(id))
+	if err != nil || !store.EquipmentVisible(equipment, user) {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return models.Equipment{}, false
+	}
+	return equipment, true
+}
+
+// respondEquipment answers with the equipment and its team, owner and default technician
+func (h *Handler) respondEquipment(w http.ResponseWriter, status int, equipment models.Equipment) {
+	loaded, err := h.Equipment.ListEquipment(store.EquipmentFilter{ID: equipment.ID, Archived: equipment.ArchivedAt != nil})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(loaded) == 1 {
+		equipment = loaded[0]
+	}
+	utils.RespondJSON(w, status, equipment)
+}
+
+// checkParent rejects a parent that doesn't exist or sits below the equipment
+func (h *Handler) checkParent(equipmentID, parentID uint) error {
+	if equipmentID == parentID {
+		return errors.New("equipment cannot be its own parent")
+	}
+	current := parentID
+	for depth := 0; depth < 100; depth++ {
+		parent, err := h.Equipment.GetEquipment(current)
+		if err != nil {
+			return errors.New("parent equipment not found")
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		if *parent.ParentID == equipmentID {
+			return errors.New("equipment cannot be placed under its own sub-assembly")
+		}
+		current = *parent.ParentID
+	}
+	return errors.New("equipment hierarchy is too deep")
+}
+
+// GetEquipmentByID returns one equipment, archived or not, if the user can see it
+func (h *Handler) GetEquipmentByID(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	h.respondEquipment(w, http.StatusOK, equipment)
+}
+
+// UpdateEquipment changes only the fields present in the body. Owner and
+// default technician are changed with TransferEquipment, which records it.
+func (h *Handler) UpdateEquipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name                *string    `json:"name"`
+		Category            *string    `json:"category"`
+		Department          *string    `json:"department"`
+		SerialNumber        *string    `json:"serial_number"`
+		PurchaseDate        *time.Time `json:"purchase_date"`
+		WarrantyInfo        *string    `json:"warranty_info"`
+		LocationID          optionalID `json:"location_id"`
+		ParentID            optionalID `json:"parent_id"`
+		MaintenanceTeamID   *uint      `json:"maintenance_team_id"`
+		IsUsable            *bool      `json:"is_usable"`
+		EmployeeID          optionalID `json:"employee_id"`
+		DefaultTechnicianID optionalID `json:"default_technician_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if equipment.ArchivedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "Archived equipment must be restored before it can be changed")
+		return
+	}
+	if input.EmployeeID.Set || input.DefaultTechnicianID.Set {
+		utils.RespondError(w, http.StatusBadRequest, "Use the transfer endpoint to change the owner or default technician")
+		return
+	}
+
+	// Usability is set by scrapping; only a manager may override it
+	if input.IsUsable != nil && user.Role != models.RoleManager {
+		utils.RespondError(w, http.StatusForbidden, "Only managers can change whether equipment is usable")
+		return
+	}
+
+	before := equipment
+	var fields []string
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			utils.RespondError(w, http.StatusBadRequest, "Name cannot be empty")
+			return
+		}
+		equipment.Name = name
+		fields = append(fields, "Name")
+	}
+	if input.Category != nil {
+		equipment.Category = *input.Category
+		fields = append(fields, "Category")
+	}
+	if input.Department != nil {
+		equipment.Department = *input.Department
+		fields = append(fields, "Department")
+	}
+	if input.SerialNumber != nil {
+		equipment.SerialNumber = *input.SerialNumber
+		fields = append(fields, "SerialNumber")
+	}
+	if input.PurchaseDate != nil {
+		equipment.PurchaseDate = *input.PurchaseDate
+		fields = append(fields, "PurchaseDate")
+	}
+	if input.WarrantyInfo != nil {
+		equipment.WarrantyInfo = *input.WarrantyInfo
+		fields = append(fields, "WarrantyInfo")
+	}
+	if input.LocationID.Set {
+		fields = append(fields, "LocationID", "Location")
+		equipment.LocationID, equipment.Location = nil, ""
+		if input.LocationID.Value != nil {
+			loc, err := h.Locations.GetLocation(*input.LocationID.Value)
+			if err != nil {
+				utils.RespondError(w, http.StatusBadRequest, "Invalid Location ID")
+				return
+			}
+			equipment.LocationID, equipment.Location = &loc.ID, loc.Name
+		}
+	}
+	if input.ParentID.Set {
+		if input.ParentID.Value != nil {
+			if err := h.checkParent(equipment.ID, *input.ParentID.Value); err != nil {
+				utils.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		equipment.ParentID = input.ParentID.Value
+		fields = append(fields, "ParentID")
+	}
+	if input.MaintenanceTeamID != nil {
+		if _, err := h.Teams.GetTeam(*input.MaintenanceTeamID); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid Maintenance Team ID")
+			return
+		}
+		equipment.MaintenanceTeamID = *input.MaintenanceTeamID
+		fields = append(fields, "MaintenanceTeamID")
+	}
+	if input.IsUsable != nil {
+		equipment.IsUsable = *input.IsUsable
+		fields = append(fields, "IsUsable")
+	}
+
+	if err := h.Equipment.UpdateEquipment(&equipment, fields...); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityEquipment, equipment.ID, models.AuditUpdate, before, equipment)
+	h.publishEquipment(models.AuditUpdate, before, equipment)
+
+	h.respondEquipment(w, http.StatusOK, equipment)
+}
+
+// ArchiveEquipment soft-deletes equipment: it leaves listings and takes no new
+// requests, but keeps its history and can be restored
+func (h *Handler) ArchiveEquipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	if equipment.ArchivedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "Equipment is already archived")
+		return
+	}
+	open, err := h.Requests.CountRequests(store.RequestFilter{
+		EquipmentID:     equipment.ID,
+		ExcludeStatuses: []models.RequestStatus{models.StatusRepaired, models.StatusScrap},
+	})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if open > 0 {
+		utils.RespondError(w, http.StatusConflict, "Equipment has open maintenance requests")
+		return
+	}
+
+	before := equipment
+	now := time.Now()
+	equipment.ArchivedAt = &now
+	if err := h.Equipment.UpdateEquipment(&equipment, "ArchivedAt"); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityEquipment, equipment.ID, models.AuditDelete, before, equipment)
+	h.publishEquipment(models.AuditDelete, before, equipment)
+
+	h.respondEquipment(w, http.StatusOK, equipment)
+}
+
+// RestoreEquipment brings archived equipment back into use
+func (h *Handler) RestoreEquipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	if equipment.ArchivedAt == nil {
+		utils.RespondError(w, http.StatusConflict, "Equipment is not archived")
+		return
+	}
+
+	before := equipment
+	equipment.ArchivedAt = nil
+	if err := h.Equipment.UpdateEquipment(&equipment, "ArchivedAt"); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityEquipment, equipment.ID, models.AuditUpdate, before, equipment)
+	h.publishEquipment(models.AuditUpdate, before, equipment)
+
+	h.respondEquipment(w, http.StatusOK, equipment)
+}
+
+// TransferEquipment hands equipment to a new owner and/or default technician
+// and records the transfer. A field left out keeps its value; null clears it.
+func (h *Handler) TransferEquipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	var input struct {
+		EmployeeID          optionalID `json:"employee_id"`
+		DefaultTechnicianID optionalID `json:"default_technician_id"`
+		Note                string     `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if equipment.ArchivedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "Archived equipment must be restored before it can be transferred")
+		return
+	}
+
+	before := equipment
+	if input.EmployeeID.Set {
+		if id := input.EmployeeID.Value; id != nil {
+			if owner, err := h.Users.GetUser(*id); err != nil || owner.Disabled {
+				utils.RespondError(w, http.StatusBadRequest, "Invalid Employee ID")
+				return
+			}
+		}
+		equipment.EmployeeID = input.EmployeeID.Value
+	}
+	if input.DefaultTechnicianID.Set {
+		if id := input.DefaultTechnicianID.Value; id != nil {
+			tech, err := h.Users.GetUser(*id)
+			if err != nil || tech.Disabled || !tech.Role.IsStaff() {
+				utils.RespondError(w, http.StatusBadRequest, "Default technician must be an active Technician or Manager")
+				return
+			}
+		}
+		equipment.DefaultTechnicianID = input.DefaultTechnicianID.Value
+	}
+	if sameID(equipment.EmployeeID, before.EmployeeID) && sameID(equipment.DefaultTechnicianID, before.DefaultTechnicianID) {
+		utils.RespondError(w, http.StatusBadRequest, "Nothing to transfer: owner and default technician are unchanged")
+		return
+	}
+
+	transfer := models.EquipmentTransfer{
+		EquipmentID:      equipment.ID,
+		FromEmployeeID:   before.EmployeeID,
+		ToEmployeeID:     equipment.EmployeeID,
+		FromTechnicianID: before.DefaultTechnicianID,
+		ToTechnicianID:   equipment.DefaultTechnicianID,
+		TransferredByID:  user.ID,
+		Note:             strings.TrimSpace(input.Note),
+	}
+	if err := h.Equipment.TransferEquipment(&equipment, &transfer); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityEquipment, equipment.ID, models.AuditUpdate, before, equipment)
+	h.publishEquipment(models.AuditUpdate, before, equipment)
+
+	utils.RespondJSON(w, http.StatusCreated, transfer)
+}
+
+// GetEquipmentTransfers lists an equipment's transfers, newest first
+func (h *Handler) GetEquipmentTransfers(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	transfers, err := h.Equipment.ListEquipmentTransfers(equipment.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if transfers == nil {
+		transfers = []models.EquipmentTransfer{}
+	}
+	utils.RespondJSON(w, http.StatusOK, transfers)
+}
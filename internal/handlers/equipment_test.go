@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gearguard/internal/models"
+)
+
+func (f *fixture) equipmentVars(e models.Equipment) (string, map[string]string) {
+	id := fmt.Sprint(e.ID)
+	return "/api/equipment/" + id, map[string]string{"id": id}
+}
+
+func TestGetEquipmentByIDRespectsVisibility(t *testing.T) {
+	f := newFixture(t)
+	target, vars := f.equipmentVars(f.drill)
+
+	w := f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.employee1, nil, vars)
+	expectStatus(t, w, http.StatusOK)
+	got := decode[models.Equipment](t, w)
+	if got.ID != f.drill.ID || got.Employee == nil || got.Employee.ID != f.employee1.ID || got.MaintenanceTeam.ID != f.team.ID {
+		t.Fatalf("expected the drill with its owner and team, got %+v", got)
+	}
+
+	// Other employees and technicians get the same answer as for a missing ID
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.employee2, nil, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.tech2, nil, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.manager, nil, vars), http.StatusOK)
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, "/api/equipment/999", f.manager, nil, map[string]string{"id": "999"}), http.StatusNotFound)
+}
+
+func TestUpdateEquipmentIsPartial(t *testing.T) {
+	f := newFixture(t)
+	loc := models.Location{Name: "Hall A", Kind: models.LocationArea}
+	f.store.CreateLocation(&loc)
+	target, vars := f.equipmentVars(f.drill)
+
+	w := f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.tech1,
+		map[string]interface{}{"serial_number": "SN-1", "location_id": loc.ID, "parent_id": f.lathe.ID}, vars)
+	expectStatus(t, w, http.StatusOK)
+	updated, err := f.store.GetEquipment(f.drill.ID)
+	f.must(err)
+	if updated.SerialNumber != "SN-1" || updated.Location != "Hall A" || updated.ParentID == nil || *updated.ParentID != f.lathe.ID {
+		t.Fatalf("fields in the body were not applied: %+v", updated)
+	}
+	if updated.Name != "Drill" || updated.Department != "Production" || !updated.IsUsable {
+		t.Fatalf("fields left out of the body changed: %+v", updated)
+	}
+
+	// null clears, and the lathe can't then become a child of its own child
+	expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.tech1, map[string]interface{}{"location_id": nil}, vars), http.StatusOK)
+	updated, _ = f.store.GetEquipment(f.drill.ID)
+	if updated.LocationID != nil || updated.Location != "" || updated.ParentID == nil {
+		t.Fatalf("expected only the location to be cleared: %+v", updated)
+	}
+	latheTarget, latheVars := f.equipmentVars(f.lathe)
+	w = f.call(f.h.UpdateEquipment, http.MethodPatch, latheTarget, f.manager, map[string]interface{}{"parent_id": f.drill.ID}, latheVars)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	for _, body := range []map[string]interface{}{{"name": " "}, {"maintenance_team_id": 999}, {"employee_id": f.employee2.ID}} {
+		expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.manager, body, vars), http.StatusBadRequest)
+	}
+	// Technicians only edit the equipment they maintain
+	expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.tech2, map[string]interface{}{"name": "Mine"}, vars), http.StatusNotFound)
+}
+
+func TestUpdateEquipmentUsabilityIsForManagers(t *testing.T) {
+	f := newFixture(t)
+	target, vars := f.equipmentVars(f.drill)
+	f.drill.IsUsable = false
+	f.must(f.store.SaveEquipment(&f.drill))
+
+	w := f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.tech1, map[string]interface{}{"is_usable": true}, vars)
+	expectStatus(t, w, http.StatusForbidden)
+	expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.tech1, map[string]interface{}{"serial_number": "SN-2"}, vars), http.StatusOK)
+	if drill, _ := f.store.GetEquipment(f.drill.ID); drill.IsUsable || drill.SerialNumber != "SN-2" {
+		t.Fatalf("expected other changes to leave the drill scrapped, got %+v", drill)
+	}
+
+	expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.manager, map[string]interface{}{"is_usable": true}, vars), http.StatusOK)
+	if drill, _ := f.store.GetEquipment(f.drill.ID); !drill.IsUsable {
+		t.Fatal("expected a manager to be able to put the drill back in use")
+	}
+}
+
+func TestArchiveAndRestoreEquipment(t *testing.T) {
+	f := newFixture(t)
+	target, vars := f.equipmentVars(f.press)
+	open := f.request(f.press, f.employee2, models.StatusInProgress)
+
+	expectStatus(t, f.call(f.h.ArchiveEquipment, http.MethodDelete, target, f.manager, nil, vars), http.StatusConflict)
+	open.Status = models.StatusRepaired
+	f.must(f.store.UpdateRequest(&open, nil, f.manager.ID))
+
+	expectStatus(t, f.call(f.h.ArchiveEquipment, http.MethodDelete, target, f.manager, nil, vars), http.StatusOK)
+	listed := decode[[]models.Equipment](t, f.call(f.h.GetEquipment, http.MethodGet, "/api/equipment", f.manager, nil, nil))
+	if len(listed) != 2 {
+		t.Fatalf("expected the press to leave the listing, got %d items", len(listed))
+	}
+	archived := decode[[]models.Equipment](t, f.call(f.h.GetEquipment, http.MethodGet, "/api/equipment?archived=true", f.manager, nil, nil))
+	if len(archived) != 1 || archived[0].ID != f.press.ID || archived[0].ArchivedAt == nil {
+		t.Fatalf("expected only the press among archived equipment, got %+v", archived)
+	}
+
+	// Archived equipment can still be viewed but not changed or reported on
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.employee2, nil, vars), http.StatusOK)
+	expectStatus(t, f.call(f.h.UpdateEquipment, http.MethodPatch, target, f.manager, map[string]interface{}{"name": "Old press"}, vars), http.StatusConflict)
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.employee2,
+		map[string]interface{}{"subject": "Leak", "type": models.TypeCorrective, "equipment_id": f.press.ID}, nil)
+	expectStatus(t, w, http.StatusConflict)
+	expectStatus(t, f.call(f.h.ArchiveEquipment, http.MethodDelete, target, f.manager, nil, vars), http.StatusConflict)
+
+	expectStatus(t, f.call(f.h.RestoreEquipment, http.MethodPost, target+"/restore", f.manager, nil, vars), http.StatusOK)
+	restored, _ := f.store.GetEquipment(f.press.ID)
+	if restored.ArchivedAt != nil {
+		t.Fatal("expected the press to be restored")
+	}
+	expectStatus(t, f.call(f.h.RestoreEquipment, http.MethodPost, target+"/restore", f.manager, nil, vars), http.StatusConflict)
+}
+
+func TestTransferEquipment(t *testing.T) {
+	f := newFixture(t)
+	target, vars := f.equipmentVars(f.drill)
+
+	w := f.call(f.h.TransferEquipment, http.MethodPost, target+"/transfer", f.manager,
+		map[string]interface{}{"employee_id": f.employee2.ID, "note": "Moved to line 2"}, vars)
+	expectStatus(t, w, http.StatusCreated)
+	transfer := decode[models.EquipmentTransfer](t, w)
+	if *transfer.FromEmployeeID != f.employee1.ID || *transfer.ToEmployeeID != f.employee2.ID ||
+		*transfer.FromTechnicianID != f.tech1.ID || *transfer.ToTechnicianID != f.tech1.ID || transfer.TransferredByID != f.manager.ID {
+		t.Fatalf("unexpected transfer record: %+v", transfer)
+	}
+
+	// The drill now shows up for its new owner only
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.employee1, nil, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.GetEquipmentByID, http.MethodGet, target, f.employee2, nil, vars), http.StatusOK)
+
+	w = f.call(f.h.TransferEquipment, http.MethodPost, target+"/transfer", f.manager,
+		map[string]interface{}{"default_technician_id": nil}, vars)
+	expectStatus(t, w, http.StatusCreated)
+	drill, _ := f.store.GetEquipment(f.drill.ID)
+	if drill.DefaultTechnicianID != nil || drill.EmployeeID == nil || *drill.EmployeeID != f.employee2.ID {
+		t.Fatalf("expected only the technician to be cleared: %+v", drill)
+	}
+
+	for _, body := range []map[string]interface{}{
+		{},
+		{"employee_id": f.employee2.ID},
+		{"employee_id": 999},
+		{"default_technician_id": f.employee1.ID},
+	} {
+		expectStatus(t, f.call(f.h.TransferEquipment, http.MethodPost, target+"/transfer", f.manager, body, vars), http.StatusBadRequest)
+	}
+
+	history := decode[[]models.EquipmentTransfer](t, f.call(f.h.GetEquipmentTransfers, http.MethodGet, target+"/transfers", f.employee2, nil, vars))
+	if len(history) != 2 || history[0].ToTechnicianID != nil || history[1].Note != "Moved to line 2" {
+		t.Fatalf("expected both transfers, newest first: %+v", history)
+	}
+	expectStatus(t, f.call(f.h.GetEquipmentTransfers, http.MethodGet, target+"/transfers", f.employee1, nil, vars), http.StatusNotFound)
+}
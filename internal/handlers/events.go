@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+)
+
+const (
+	// eventBacklog is how many recent events are kept for clients resuming a stream
+	eventBacklog = 1000
+	// eventHeartbeat is how often an idle stream is pinged, which also rechecks
+	// that its user may still receive events
+	eventHeartbeat = 25 * time.Second
+	// eventRetry is how long clients should wait before reconnecting, in milliseconds
+	eventRetry = 3000
+)
+
+// publishRequest streams a change to req to the users who may see it
+func (h *Handler) publishRequest(action string, req models.MaintenanceRequest) {
+	equipment := req.Equipment
+	if equipment.ID != req.EquipmentID {
+		equipment, _ = h.Equipment.GetEquipment(req.EquipmentID)
+	}
+	h.Events.Publish("request."+action, req, func(user models.User) bool {
+		return store.RequestVisible(req, equipment, user)
+	})
+}
+
+// publishEquipment streams a change to equipment to the users who could see
+// it before or can see it after, so those losing sight of it learn about it
+func (h *Handler) publishEquipment(action string, before, after models.Equipment) {
+	h.Events.Publish("equipment."+action, after, func(user models.User) bool {
+		return store.EquipmentVisible(before, user) || store.EquipmentVisible(after, user)
+	})
+}
+
+// writeEvent writes one event in the text/event-stream format; id may be empty
+func writeEvent(w http.ResponseWriter, id, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload)
+	return err
+}
+
+// streamAllowed rechecks an open stream against server-side state, as
+// middleware.Auth does for every other request: the session must still be
+// active and the user unchanged
+func (h *Handler) streamAllowed(r *http.Request, user models.User) bool {
+	if sessionID, ok := r.Context().Value(utils.SessionKey).(string); ok {
+		if active, err := h.Tokens.SessionActive(sessionID, time.Now()); err != nil || !active {
+			return false
+		}
+	}
+	current, err := h.Users.GetUser(user.ID)
+	return err == nil && !current.Disabled && current.Role == user.Role
+}
+
+// StreamEvents streams changes to the requests and equipment the caller may
+// see as Server-Sent Events ("request.create", "equipment.update", ...). A
+// client reconnecting with Last-Event-ID (or ?last_event_id=) first receives
+// what it missed, or a "reset" event when that is no longer known and it
+// should reload instead. Events are only shared within one server process, so
+// the stream needs the API to run as a single replica.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, missed, complete := h.Events.Subscribe(user, lastEventID)
+	defer sub.Close()
+
+	// The server's write timeout is meant for ordinary responses, not streams
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	if !complete {
+		writeEvent(w, "", "reset", struct{}{})
+	}
+	for _, e := range missed {
+		writeEvent(w, e.ID, e.Type, e.Data)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, open := <-sub.Events:
+			if !open {
+				return // Fell behind; the client resumes from its last event
+			}
+			if err := writeEvent(w, e.ID, e.Type, e.Data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if !h.streamAllowed(r, user) {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
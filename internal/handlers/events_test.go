@@ -0,0 +1,200 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gearguard/internal/events"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+)
+
+type streamedEvent struct {
+	ID, Type string
+	Data     map[string]interface{}
+}
+
+type eventStream struct {
+	t      *testing.T
+	lines  chan string
+	cancel context.CancelFunc
+}
+
+// stream opens the event stream as a user, resuming after lastEventID when
+// given, and returns once the server is sending
+func (f *fixture) stream(as models.User, lastEventID string) *eventStream {
+	f.t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), utils.UserIDKey, as.ID)
+		f.h.StreamEvents(w, r.WithContext(context.WithValue(ctx, utils.RoleKey, as.Role)))
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	f.t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	f.must(err)
+	if lastEventID != "" {
+		r.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	f.must(err)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		f.t.Fatalf("expected an event stream, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	s := &eventStream{t: f.t, lines: make(chan string), cancel: cancel}
+	go func() {
+		defer close(s.lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			s.lines <- scanner.Text()
+		}
+	}()
+	if line := s.line(); !strings.HasPrefix(line, "retry: ") {
+		f.t.Fatalf("expected the stream to open with a retry interval, got %q", line)
+	}
+	return s
+}
+
+func (s *eventStream) line() string {
+	s.t.Helper()
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			s.t.Fatal("stream ended")
+		}
+		return line
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("timed out waiting for the stream")
+	}
+	return ""
+}
+
+// next returns the next event, skipping comments and other fields
+func (s *eventStream) next() streamedEvent {
+	s.t.Helper()
+	var e streamedEvent
+	for {
+		line := s.line()
+		switch {
+		case line == "" && e.Type != "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.Type = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.Data); err != nil {
+				s.t.Fatalf("event data is not JSON: %q", line)
+			}
+		}
+	}
+}
+
+func (f *fixture) createRequestAs(as models.User, equipment models.Equipment, subject string) {
+	f.t.Helper()
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", as,
+		map[string]interface{}{"subject": subject, "type": models.TypeCorrective, "equipment_id": equipment.ID}, nil)
+	expectStatus(f.t, w, http.StatusCreated)
+}
+
+func TestEventsFollowVisibility(t *testing.T) {
+	f := newFixture(t)
+	manager := f.stream(f.manager, "")
+	tech1 := f.stream(f.tech1, "")
+	tech2 := f.stream(f.tech2, "")
+
+	f.createRequestAs(f.employee1, f.drill, "Drill jams")
+	f.createRequestAs(f.employee2, f.press, "Press leaks")
+
+	for _, subject := range []string{"Drill jams", "Press leaks"} {
+		if e := manager.next(); e.Type != "request.create" || e.Data["subject"] != subject || e.ID == "" {
+			t.Fatalf("manager expected the creation of %q, got %+v", subject, e)
+		}
+	}
+	if e := tech1.next(); e.Data["subject"] != "Drill jams" {
+		t.Fatalf("tech1 expected the drill request, got %+v", e)
+	}
+	// tech2 never saw the drill request: the press one comes first
+	if e := tech2.next(); e.Data["subject"] != "Press leaks" {
+		t.Fatalf("tech2 expected only the press request, got %+v", e)
+	}
+
+	// A change taking equipment out of someone's sight still reaches them
+	employee1 := f.stream(f.employee1, "")
+	w := f.call(f.h.TransferEquipment, http.MethodPost, "/api/equipment/x/transfer", f.manager,
+		map[string]interface{}{"employee_id": f.employee2.ID}, map[string]string{"id": fmt.Sprint(f.drill.ID)})
+	expectStatus(t, w, http.StatusCreated)
+	if e := employee1.next(); e.Type != "equipment.update" || e.Data["employee_id"] != float64(f.employee2.ID) {
+		t.Fatalf("previous owner expected the transfer, got %+v", e)
+	}
+}
+
+func TestEventsReplayOnReconnect(t *testing.T) {
+	f := newFixture(t)
+	first := f.stream(f.manager, "")
+	f.createRequestAs(f.employee1, f.drill, "One")
+	seen := first.next()
+	first.cancel()
+
+	// Missed while disconnected, one of them not visible to tech1
+	f.createRequestAs(f.employee2, f.press, "Two")
+	f.createRequestAs(f.employee1, f.drill, "Three")
+
+	resumed := f.stream(f.manager, seen.ID)
+	if e := resumed.next(); e.Data["subject"] != "Two" {
+		t.Fatalf("expected replay to start after the last event seen, got %+v", e)
+	}
+	if e := resumed.next(); e.Data["subject"] != "Three" {
+		t.Fatalf("expected the second missed event, got %+v", e)
+	}
+	if e := f.stream(f.tech1, seen.ID).next(); e.Data["subject"] != "Three" {
+		t.Fatalf("replay should be filtered like live events, got %+v", e)
+	}
+
+	// An ID from before a restart, or older than what is kept, asks for a reload
+	if e := f.stream(f.manager, "0badcafe-1").next(); e.Type != "reset" {
+		t.Fatalf("expected a reset for a foreign event ID, got %+v", e)
+	}
+	f.h.Events = events.NewHub(1)
+	f.createRequestAs(f.employee1, f.drill, "Four")
+	f.createRequestAs(f.employee1, f.drill, "Five")
+	live := f.stream(f.manager, "")
+	f.createRequestAs(f.employee1, f.drill, "Six")
+	six := live.next()
+	if e := f.stream(f.manager, strings.Replace(six.ID, "-3", "-1", 1)).next(); e.Type != "reset" {
+		t.Fatalf("expected a reset once missed events are dropped, got %+v", e)
+	}
+}
+
+func TestEventsForGeneratedRequests(t *testing.T) {
+	f := newFixture(t)
+	tech2 := f.stream(f.tech2, "")
+
+	meter, _ := f.overheatRule()
+	w := f.call(f.h.PostMeterReading, http.MethodPost, "/api/meters/x/readings", f.employee2,
+		map[string]interface{}{"value": 500}, map[string]string{"id": fmt.Sprint(meter.ID)})
+	expectStatus(t, w, http.StatusCreated)
+	if e := tech2.next(); e.Type != "request.create" || e.Data["subject"] != "Overheat" {
+		t.Fatalf("expected the request opened by the meter rule, got %+v", e)
+	}
+
+	// The schedule generator announces through the same path
+	scheduled := models.MaintenanceRequest{Subject: "Lubricate", Type: models.TypePreventive, CreatedByID: f.manager.ID}
+	services.PrepareRequest(&scheduled, f.press)
+	f.must(f.store.CreateRequest(&scheduled))
+	f.h.AnnounceRequest(scheduled, f.press)
+	if e := tech2.next(); e.Type != "request.create" || e.Data["subject"] != "Lubricate" {
+		t.Fatalf("expected the scheduled request, got %+v", e)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"gearguard/internal/events"
+	"gearguard/internal/oidc"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/webhooks"
+)
+
+// Handler serves the core endpoints (auth, teams, equipment, locations,
+// requests and their comments, schedules, meters and the dashboard) on top of
+// injected stores, so they can run without a database.
+type Handler struct {
+	Equipment   store.EquipmentStore
+	Locations   store.LocationStore
+	Requests    store.RequestStore
+	Comments    store.CommentStore
+	Schedules   store.ScheduleStore
+	Meters      store.MeterStore
+	Users       store.UserStore
+	Teams       store.TeamStore
+	Tokens      store.TokenStore
+	Recovery    store.RecoveryCodeStore
+	Throttle    store.ThrottleStore
+	Invitations store.InvitationStore
+	APIKeys     store.APIKeyStore
+	Feeds       store.CalendarFeedStore
+
+	// OIDC is the single sign-on provider, nil when single sign-on is off
+	OIDC *oidc.Provider
+	// Events streams changes to requests and equipment to connected clients
+	Events *events.Hub
+	// Webhooks records and sends webhook deliveries, and holds the webhooks
+	Webhooks *webhooks.Dispatcher
+
+	// Audit records a change to an entity; defaults to services.RecordAudit
+	Audit func(actorID uint, entityType string, entityID uint, action string, before, after interface{})
+	// Mail sends an email; defaults to services.SendEmail in the background
+	Mail func(to []string, subject, body string)
+}
+
+// New builds a Handler backed by a single store implementation
+func New(s store.Store) *Handler {
+	return &Handler{
+		Equipment:   s,
+		Locations:   s,
+		Requests:    s,
+		Comments:    s,
+		Schedules:   s,
+		Meters:      s,
+		Users:       s,
+		Teams:       s,
+		Tokens:      s,
+		Recovery:    s,
+		Throttle:    s,
+		Invitations: s,
+		APIKeys:     s,
+		Feeds:       s,
+		Events:      events.NewHub(eventBacklog),
+		Webhooks:    webhooks.NewDispatcher(s),
+		Audit:       services.RecordAudit,
+		Mail: func(to []string, subject, body string) {
+			go services.SendEmail(to, subject, body)
+		},
+	}
+}
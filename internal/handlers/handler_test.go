@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gearguard/internal/handlers"
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type sentMail struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+type auditEntry struct {
+	ActorID    uint
+	EntityType string
+	EntityID   uint
+	Action     string
+}
+
+// fixture is a small plant: two technicians each responsible for one machine
+// owned by an employee, plus a lathe nobody owns or maintains by default.
+type fixture struct {
+	t     *testing.T
+	store *store.Memory
+	h     *handlers.Handler
+	audit []auditEntry
+	mail  []sentMail
+
+	sessions int // Sessions started by accessToken
+
+	team                  models.MaintenanceTeam
+	manager, tech1, tech2 models.User
+	employee1, employee2  models.User
+	idleTech              models.User
+	drill, press, lathe   models.Equipment
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{t: t, store: store.NewMemory()}
+	f.h = handlers.New(f.store)
+	f.h.Audit = func(actorID uint, entityType string, entityID uint, action string, before, after interface{}) {
+		f.audit = append(f.audit, auditEntry{actorID, entityType, entityID, action})
+	}
+	f.h.Mail = func(to []string, subject, body string) {
+		f.mail = append(f.mail, sentMail{to, subject, body})
+	}
+
+	f.team = models.MaintenanceTeam{Name: "Mechanical Team"}
+	f.must(f.store.CreateTeam(&f.team))
+
+	f.manager = f.user("Maya Manager", models.RoleManager)
+	f.tech1 = f.user("Tom Tech", models.RoleTechnician)
+	f.tech2 = f.user("Tina Tech", models.RoleTechnician)
+	f.idleTech = f.user("Ian Idle", models.RoleTechnician)
+	f.employee1 = f.user("Eve Employee", models.RoleEmployee)
+	f.employee2 = f.user("Ed Employee", models.RoleEmployee)
+
+	f.drill = f.equipment("Drill", &f.employee1.ID, &f.tech1.ID)
+	f.press = f.equipment("Press", &f.employee2.ID, &f.tech2.ID)
+	f.lathe = f.equipment("Lathe", nil, nil)
+	return f
+}
+
+func (f *fixture) must(err error) {
+	f.t.Helper()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) user(name string, role models.Role) models.User {
+	u := models.User{Name: name, Email: name + "@example.com", Role: role, TeamID: &f.team.ID}
+	f.must(f.store.CreateUser(&u))
+	return u
+}
+
+func (f *fixture) equipment(name string, owner, technician *uint) models.Equipment {
+	e := models.Equipment{
+		Name:                name,
+		Department:          "Production",
+		MaintenanceTeamID:   f.team.ID,
+		EmployeeID:          owner,
+		DefaultTechnicianID: technician,
+		IsUsable:            true,
+	}
+	f.must(f.store.CreateEquipment(&e))
+	return e
+}
+
+// request seeds a request directly in the store
+func (f *fixture) request(equipment models.Equipment, createdBy models.User, status models.RequestStatus) models.MaintenanceRequest {
+	req := models.MaintenanceRequest{
+		Subject:      equipment.Name + " issue",
+		Type:         models.TypeCorrective,
+		Status:       status,
+		EquipmentID:  equipment.ID,
+		TeamID:       equipment.MaintenanceTeamID,
+		TechnicianID: equipment.DefaultTechnicianID,
+		CreatedByID:  createdBy.ID,
+	}
+	f.must(f.store.CreateRequest(&req))
+	return req
+}
+
+// call invokes a handler as the given user, with optional route variables
+func (f *fixture) call(handler http.HandlerFunc, method, target string, as models.User, body interface{}, vars map[string]string) *httptest.ResponseRecorder {
+	f.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		f.must(json.NewEncoder(&buf).Encode(body))
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	if as.ID != 0 {
+		ctx := context.WithValue(r.Context(), utils.UserIDKey, as.ID)
+		r = r.WithContext(context.WithValue(ctx, utils.RoleKey, as.Role))
+	}
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
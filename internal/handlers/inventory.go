@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type partResponse struct {
+	models.Part
+	TotalQuantity float64 `json:"total_quantity"`
+	LowStock      bool    `json:"low_stock"`
+}
+
+func newPartResponse(part models.Part) partResponse {
+	total := 0.0
+	for _, s := range part.Stock {
+		total += s.Quantity
+	}
+	return partResponse{Part: part, TotalQuantity: total, LowStock: part.ReorderPoint > 0 && total < part.ReorderPoint}
+}
+
+// CreatePart adds a spare part to the catalogue
+func CreatePart(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireStaff(w, r); !ok {
+		return
+	}
+
+	var part models.Part
+	if err := json.NewDecoder(r.Body).Decode(&part); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if part.PartNumber == "" || part.Name == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Part number and name are required")
+		return
+	}
+
+	part.ID = 0
+	part.Stock = nil
+	part.LowStockAlerted = false
+	if result := database.DB.Create(&part); result.Error != nil {
+		utils.RespondError(w, http.StatusBadRequest, result.Error.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, newPartResponse(part))
+}
+
+// GetParts lists parts with their stock; ?low_stock=true returns only parts below their reorder point
+func GetParts(w http.ResponseWriter, r *http.Request) {
+	query := database.DB.Preload("Stock")
+
+	if search := r.URL.Query().Get("search"); search != "" {
+		searchTerm := "%" + search + "%"
+		query = query.Where(database.DB.Where("name ILIKE ?", searchTerm).Or("part_number ILIKE ?", searchTerm))
+	}
+
+	var parts []models.Part
+	if result := query.Order("part_number").Find(&parts); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	lowStockOnly := r.URL.Query().Get("low_stock") == "true"
+	response := make([]partResponse, 0, len(parts))
+	for _, part := range parts {
+		p := newPartResponse(part)
+		if lowStockOnly && !p.LowStock {
+			continue
+		}
+		response = append(response, p)
+	}
+
+	utils.RespondJSON(w, http.StatusOK, response)
+}
+
+// GetPart returns a part with its stock per location
+func GetPart(w http.ResponseWriter, r *http.Request) {
+	part, ok := findPart(w, r)
+	if !ok {
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, newPartResponse(part))
+}
+
+// UpdatePart edits catalogue data of a part (not its stock)
+func UpdatePart(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireStaff(w, r); !ok {
+		return
+	}
+
+	part, ok := findPart(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name         *string  `json:"name"`
+		Description  *string  `json:"description"`
+		Unit         *string  `json:"unit"`
+		UnitCost     *float64 `json:"unit_cost"`
+		ReorderPoint *float64 `json:"reorder_point"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Name != nil {
+		part.Name = *input.Name
+	}
+	if input.Description != nil {
+		part.Description = *input.Description
+	}
+	if input.Unit != nil {
+		part.Unit = *input.Unit
+	}
+	if input.UnitCost != nil {
+		part.UnitCost = *input.UnitCost
+	}
+	if input.ReorderPoint != nil {
+		part.ReorderPoint = *input.ReorderPoint
+	}
+
+	if result := database.DB.Omit("Stock").Save(&part); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+	services.CheckLowStock(part.ID)
+
+	utils.RespondJSON(w, http.StatusOK, newPartResponse(part))
+}
+
+// ReceiveStock adds delivered quantity of a part at a location
+func ReceiveStock(w http.ResponseWriter, r *http.Request) {
+	moveStock(w, r, models.MovementReceive)
+}
+
+// AdjustStock corrects the quantity of a part at a location (stocktake, damage...)
+func AdjustStock(w http.ResponseWriter, r *http.Request) {
+	moveStock(w, r, models.MovementAdjust)
+}
+
+// IssueStock takes a part out of stock, optionally against a maintenance request
+func IssueStock(w http.ResponseWriter, r *http.Request) {
+	moveStock(w, r, models.MovementIssue)
+}
+
+func moveStock(w http.ResponseWriter, r *http.Request, kind string) {
+	user, ok := requireStaff(w, r)
+	if !ok {
+		return
+	}
+
+	part, ok := findPart(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Location  string  `json:"location"`
+		Quantity  float64 `json:"quantity"` // Positive for receive/issue, signed for adjust
+		RequestID *uint   `json:"request_id"`
+		Note      string  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Location == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Location is required")
+		return
+	}
+	if input.Quantity == 0 || (kind != models.MovementAdjust && input.Quantity < 0) {
+		utils.RespondError(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+
+	delta := input.Quantity
+	if kind == models.MovementIssue {
+		delta = -input.Quantity
+	}
+
+	if input.RequestID != nil {
+		var req models.MaintenanceRequest
+		if result := database.DB.First(&req, *input.RequestID); result.Error != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid Request ID")
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if kind == models.MovementIssue && input.RequestID != nil {
+			_, err := services.ConsumeParts(tx, *input.RequestID, []models.PartUsage{{PartID: part.ID, Location: input.Location, Quantity: input.Quantity}}, user.ID)
+			if err != nil {
+				return err
+			}
+			return services.RecalculateCosts(tx, *input.RequestID)
+		}
+		return services.MoveStock(tx, part.ID, input.Location, delta, kind, nil, input.Note, user.ID)
+	})
+	if errors.Is(err, services.ErrInsufficientStock) {
+		utils.RespondError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	services.CheckLowStock(part.ID)
+
+	part.Stock = nil
+	database.DB.Preload("Stock").First(&part, part.ID)
+	utils.RespondJSON(w, http.StatusOK, newPartResponse(part))
+}
+
+// GetStockMovements lists the stock ledger of a part, newest first
+func GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var movements []models.StockMovement
+	if result := database.DB.Where("part_id = ?", id).Order("created_at desc").Limit(500).Find(&movements); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, movements)
+}
+
+// GetRequestParts lists the parts consumed by a maintenance request
+func GetRequestParts(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	req, ok := findVisibleRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	var usages []models.PartUsage
+	if result := database.DB.Preload("Part").Where("request_id = ?", req.ID).Find(&usages); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, usages)
+}
+
+func findPart(w http.ResponseWriter, r *http.Request) (models.Part, bool) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var part models.Part
+	if result := database.DB.Preload("Stock").First(&part, id); result.Error != nil {
+		utils.RespondError(w, http.StatusNotFound, "Part not found")
+		return part, false
+	}
+	return part, true
+}
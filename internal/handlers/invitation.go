@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// InvitationTTL is how long an invitation link stays valid after it was (re)sent
+const InvitationTTL = 7 * 24 * time.Hour
+
+// invitationView adds the derived status to an invitation in responses
+type invitationView struct {
+	models.Invitation
+	Status string `json:"status"`
+}
+
+func viewInvitation(invitation models.Invitation, now time.Time) invitationView {
+	return invitationView{Invitation: invitation, Status: invitation.Status(now)}
+}
+
+// renewInvitation gives the invitation a fresh nonce and expiry, so links sent
+// before stop working
+func renewInvitation(invitation *models.Invitation, now time.Time) error {
+	nonce, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+	invitation.Nonce = nonce
+	invitation.SentAt = now
+	invitation.ExpiresAt = now.Add(InvitationTTL)
+	return nil
+}
+
+// mailInvitation emails the signed acceptance link
+func (h *Handler) mailInvitation(invitation models.Invitation, inviter models.User) error {
+	token, err := utils.SignToken(&utils.InvitationClaims{
+		InvitationID: invitation.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        invitation.Nonce,
+			IssuedAt:  jwt.NewNumericDate(invitation.SentAt),
+			ExpiresAt: jwt.NewNumericDate(invitation.ExpiresAt),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/invite/%s", utils.FrontendURL(), token)
+	body := fmt.Sprintf("<h3>You're invited to GearGuard</h3><p>%s invited you to join as a %s.</p><p>Click the link below to set your password and sign in:</p><a href='%s'>%s</a><p>This link expires on %s.</p>",
+		html.EscapeString(inviter.Name), invitation.Role, link, link, invitation.ExpiresAt.Format("Jan 2, 2006 15:04 MST"))
+	h.Mail([]string{invitation.Email}, "Invitation - GearGuard", body)
+	return nil
+}
+
+// openInvitation resolves the invitation behind a link token, if it can still
+// be accepted
+func (h *Handler) openInvitation(token string) (models.Invitation, bool) {
+	claims := &utils.InvitationClaims{}
+	if err := utils.ParseToken(token, claims); err != nil {
+		return models.Invitation{}, false
+	}
+	invitation, err := h.Invitations.GetInvitation(claims.InvitationID)
+	if err != nil || invitation.Status(time.Now()) != models.InvitationPending {
+		return invitation, false
+	}
+	// A resend rotates the nonce, retiring every earlier link
+	if subtle.ConstantTimeCompare([]byte(claims.ID), []byte(invitation.Nonce)) != 1 {
+		return invitation, false
+	}
+	return invitation, true
+}
+
+// invitationFromVars loads the invitation named in the route, answering 400/404 itself
+func (h *Handler) invitationFromVars(w http.ResponseWriter, r *http.Request) (models.Invitation, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid invitation ID")
+		return models.Invitation{}, false
+	}
+	invitation, err := h.Invitations.GetInvitation(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Invitation not found")
+		return invitation, false
+	}
+	return invitation, true
+}
+
+// CreateInvitation invites someone by email with a role and team chosen by the manager
+func (h *Handler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Email  string `json:"email"`
+		Name   string `json:"name"`
+		Role   string `json:"role"`
+		TeamID *uint  `json:"team_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	email := strings.TrimSpace(input.Email)
+	if !strings.Contains(email, "@") {
+		utils.RespondError(w, http.StatusBadRequest, "A valid email is required")
+		return
+	}
+	role := models.RoleEmployee
+	if input.Role != "" {
+		parsed, err := models.ParseRole(input.Role)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		role = parsed
+	}
+	if input.TeamID != nil {
+		if _, err := h.Teams.GetTeam(*input.TeamID); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Team not found")
+			return
+		}
+	}
+	if _, err := h.Users.FindUserByEmail(email); err == nil {
+		utils.RespondError(w, http.StatusConflict, "A user with this email already exists")
+		return
+	}
+	if _, err := h.Invitations.FindOpenInvitation(email); err == nil {
+		utils.RespondError(w, http.StatusConflict, "This email already has an open invitation; resend it instead")
+		return
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:       email,
+		Name:        strings.TrimSpace(input.Name),
+		Role:        role,
+		TeamID:      input.TeamID,
+		InvitedByID: manager.ID,
+	}
+	if err := renewInvitation(&invitation, now); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	if err := h.Invitations.CreateInvitation(&invitation); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.mailInvitation(invitation, manager); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	h.Audit(manager.ID, services.EntityInvitation, invitation.ID, models.AuditCreate, nil, invitation)
+
+	utils.RespondJSON(w, http.StatusCreated, viewInvitation(invitation, now))
+}
+
+// GetInvitations lists invitations that were neither accepted nor revoked,
+// including expired ones that can be resent
+func (h *Handler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.Invitations.ListOpenInvitations()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now()
+	views := make([]invitationView, 0, len(invitations))
+	for _, invitation := range invitations {
+		views = append(views, viewInvitation(invitation, now))
+	}
+	utils.RespondJSON(w, http.StatusOK, views)
+}
+
+// ResendInvitation emails a fresh link and restarts the expiry; earlier links stop working
+func (h *Handler) ResendInvitation(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	invitation, ok := h.invitationFromVars(w, r)
+	if !ok {
+		return
+	}
+	if !invitation.Open() {
+		utils.RespondError(w, http.StatusConflict, "Invitation was already "+invitation.Status(time.Now()))
+		return
+	}
+
+	before := invitation
+	now := time.Now()
+	if err := renewInvitation(&invitation, now); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	if err := h.Invitations.SaveInvitation(&invitation); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.mailInvitation(invitation, manager); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	h.Audit(manager.ID, services.EntityInvitation, invitation.ID, models.AuditUpdate, before, invitation)
+
+	utils.RespondJSON(w, http.StatusOK, viewInvitation(invitation, now))
+}
+
+// RevokeInvitation withdraws an invitation so its link can no longer be accepted
+func (h *Handler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	invitation, ok := h.invitationFromVars(w, r)
+	if !ok {
+		return
+	}
+	if !invitation.Open() {
+		utils.RespondError(w, http.StatusConflict, "Invitation was already "+invitation.Status(time.Now()))
+		return
+	}
+
+	before := invitation
+	now := time.Now()
+	if err := h.Invitations.RevokeInvitation(&invitation, now); err != nil {
+		if errors.Is(err, store.ErrInvitationClosed) {
+			// Accepted or revoked since it was loaded
+			utils.RespondError(w, http.StatusConflict, "Invitation is no longer open")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(manager.ID, services.EntityInvitation, invitation.ID, models.AuditUpdate, before, invitation)
+
+	utils.RespondJSON(w, http.StatusOK, viewInvitation(invitation, now))
+}
+
+// GetInvitationByToken shows who an invitation link is for, before it is accepted
+func (h *Handler) GetInvitationByToken(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := h.openInvitation(r.URL.Query().Get("token"))
+	if !ok {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid or expired invitation")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, viewInvitation(invitation, time.Now()))
+}
+
+// AcceptInvitation creates the invited account with the password the invitee
+// chooses. Role, team and email come from the invitation.
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, ok := h.openInvitation(input.Token)
+	if !ok {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid or expired invitation")
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = invitation.Name
+	}
+	if name == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if len(input.Password) < 6 {
+		utils.RespondError(w, http.StatusBadRequest, "Password must be at least 6 characters")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not hash password")
+		return
+	}
+	user := models.User{
+		Name:     name,
+		Email:    invitation.Email,
+		Password: string(hashedPassword),
+		Role:     invitation.Role,
+		TeamID:   invitation.TeamID,
+	}
+
+	before := invitation
+	err = h.Invitations.AcceptInvitation(&invitation, &user, time.Now())
+	if errors.Is(err, store.ErrInvitationClosed) {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid or expired invitation")
+		return
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusConflict, "Could not create account: "+err.Error())
+		return
+	}
+	// The inviting manager chose the role and team, so the account is theirs to answer for
+	h.Audit(invitation.InvitedByID, services.EntityUser, user.ID, models.AuditCreate, nil, user)
+	h.Audit(user.ID, services.EntityInvitation, invitation.ID, models.AuditUpdate, before, invitation)
+
+	utils.RespondJSON(w, http.StatusCreated, user)
+}
@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+)
+
+var invitationLink = regexp.MustCompile(`/invite/([^'"]+)`)
+
+func (f *fixture) invite(body map[string]interface{}) *httptest.ResponseRecorder {
+	return f.call(f.h.CreateInvitation, http.MethodPost, "/api/invitations", f.manager, body, nil)
+}
+
+// lastInvitationToken is the token from the most recent invitation email
+func (f *fixture) lastInvitationToken() string {
+	f.t.Helper()
+	if len(f.mail) == 0 {
+		f.t.Fatal("no invitation email sent")
+	}
+	match := invitationLink.FindStringSubmatch(f.mail[len(f.mail)-1].Body)
+	if match == nil {
+		f.t.Fatalf("no invitation link in %q", f.mail[len(f.mail)-1].Body)
+	}
+	return match[1]
+}
+
+func (f *fixture) accept(token, password string) *httptest.ResponseRecorder {
+	return f.call(f.h.AcceptInvitation, http.MethodPost, "/api/invitations/accept", models.User{}, map[string]string{"token": token, "password": password}, nil)
+}
+
+func TestInvitationOnboardsWithRoleAndTeam(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.invite(map[string]interface{}{"email": "nina@example.com", "name": "Nina New", "role": "Technician", "team_id": f.team.ID})
+	expectStatus(t, w, http.StatusCreated)
+	invitation := decode[map[string]interface{}](t, w)
+	if invitation["status"] != models.InvitationPending {
+		t.Fatalf("expected a pending invitation, got %v", invitation["status"])
+	}
+	if len(f.mail) != 1 || f.mail[0].To[0] != "nina@example.com" {
+		t.Fatalf("expected one invitation email, got %+v", f.mail)
+	}
+	token := f.lastInvitationToken()
+
+	w = f.call(f.h.GetInvitationByToken, http.MethodGet, "/api/invitations/accept?token="+url.QueryEscape(token), models.User{}, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	expectStatus(t, f.accept(token, "short"), http.StatusBadRequest)
+	w = f.accept(token, "secret")
+	expectStatus(t, w, http.StatusCreated)
+	user := decode[models.User](t, w)
+	if user.Role != models.RoleTechnician || user.TeamID == nil || *user.TeamID != f.team.ID || user.Name != "Nina New" {
+		t.Fatalf("account does not match the invitation: %+v", user)
+	}
+	expectStatus(t, f.attemptLogin("nina@example.com", "secret"), http.StatusOK)
+
+	// The link works once
+	expectStatus(t, f.accept(token, "another"), http.StatusBadRequest)
+	w = f.call(f.h.GetInvitations, http.MethodGet, "/api/invitations", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	if open := decode[[]map[string]interface{}](t, w); len(open) != 0 {
+		t.Fatalf("accepted invitation still listed: %v", open)
+	}
+}
+
+func TestResendRetiresEarlierLinks(t *testing.T) {
+	f := newFixture(t)
+	w := f.invite(map[string]interface{}{"email": "late@example.com", "name": "Late Joiner"})
+	expectStatus(t, w, http.StatusCreated)
+	id := strconv.Itoa(int(decode[models.Invitation](t, w).ID))
+	first := f.lastInvitationToken()
+
+	w = f.call(f.h.ResendInvitation, http.MethodPost, "/api/invitations/"+id+"/resend", f.manager, nil, map[string]string{"id": id})
+	expectStatus(t, w, http.StatusOK)
+	second := f.lastInvitationToken()
+	if first == second {
+		t.Fatal("resend reused the old link")
+	}
+
+	expectStatus(t, f.accept(first, "secret"), http.StatusBadRequest)
+	w = f.accept(second, "secret")
+	expectStatus(t, w, http.StatusCreated)
+	if user := decode[models.User](t, w); user.Role != models.RoleEmployee {
+		t.Fatalf("expected the default Employee role, got %q", user.Role)
+	}
+}
+
+func TestRevokedInvitationCannotBeAccepted(t *testing.T) {
+	f := newFixture(t)
+	w := f.invite(map[string]interface{}{"email": "gone@example.com", "name": "Gone"})
+	expectStatus(t, w, http.StatusCreated)
+	id := strconv.Itoa(int(decode[models.Invitation](t, w).ID))
+	token := f.lastInvitationToken()
+
+	w = f.call(f.h.RevokeInvitation, http.MethodDelete, "/api/invitations/"+id, f.manager, nil, map[string]string{"id": id})
+	expectStatus(t, w, http.StatusOK)
+	expectStatus(t, f.accept(token, "secret"), http.StatusBadRequest)
+
+	w = f.call(f.h.ResendInvitation, http.MethodPost, "/api/invitations/"+id+"/resend", f.manager, nil, map[string]string{"id": id})
+	expectStatus(t, w, http.StatusConflict)
+	if _, err := f.store.FindUserByEmail("gone@example.com"); err == nil {
+		t.Fatal("revoked invitation created an account")
+	}
+}
+
+func TestInvitationConflicts(t *testing.T) {
+	f := newFixture(t)
+
+	expectStatus(t, f.invite(map[string]interface{}{"email": f.tech1.Email}), http.StatusConflict)
+	expectStatus(t, f.invite(map[string]interface{}{"email": "twice@example.com", "name": "Twice"}), http.StatusCreated)
+	expectStatus(t, f.invite(map[string]interface{}{"email": "twice@example.com", "name": "Twice"}), http.StatusConflict)
+	expectStatus(t, f.invite(map[string]interface{}{"email": "x@example.com", "role": "Admin"}), http.StatusBadRequest)
+	expectStatus(t, f.invite(map[string]interface{}{"email": "y@example.com", "team_id": 9999}), http.StatusBadRequest)
+}
+
+// staleInvitations hands out invitations as they were before being accepted,
+// like a revocation that loaded one just before the invitee accepted it
+type staleInvitations struct {
+	store.InvitationStore
+}
+
+func (s staleInvitations) GetInvitation(id uint) (models.Invitation, error) {
+	invitation, err := s.InvitationStore.GetInvitation(id)
+	invitation.AcceptedAt, invitation.UserID = nil, nil
+	return invitation, err
+}
+
+func TestRevokeLosesToAcceptance(t *testing.T) {
+	f := newFixture(t)
+	w := f.invite(map[string]interface{}{"email": "quick@example.com", "name": "Quick"})
+	expectStatus(t, w, http.StatusCreated)
+	invitation := decode[models.Invitation](t, w)
+	id := strconv.Itoa(int(invitation.ID))
+	expectStatus(t, f.accept(f.lastInvitationToken(), "secret"), http.StatusCreated)
+
+	f.h.Invitations = staleInvitations{f.store}
+	audited := len(f.audit)
+	w = f.call(f.h.RevokeInvitation, http.MethodDelete, "/api/invitations/"+id, f.manager, nil, map[string]string{"id": id})
+	expectStatus(t, w, http.StatusConflict)
+	if stored, _ := f.store.GetInvitation(invitation.ID); stored.AcceptedAt == nil || stored.RevokedAt != nil {
+		t.Fatalf("expected the invitation to stay accepted, got %+v", stored)
+	}
+	if len(f.audit) != audited {
+		t.Fatal("a refused revocation must not be audited")
+	}
+}
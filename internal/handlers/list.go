@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+)
+
+// List endpoints share these query parameters:
+//
+//	limit   rows per page, defaultPageSize unless given, at most maxPageSize
+//	cursor  next_cursor from the previous page
+//	sort    a sort field of the listing, prefixed with "-" for descending
+//	count   "true" to include the total number of matches
+//
+// With limit, cursor or count the response is a listPage. Without them it is
+// every match as a bare array, as these endpoints have always answered, up to
+// the endpoint's cap if it sets one (see capUnpaged).
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	// maxUnpagedRequests caps an unpaged request listing
+	maxUnpagedRequests = 1000
+)
+
+// listPage is one page of a listing
+type listPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // Absent on the last page
+	Total      *int64 `json:"total,omitempty"`       // Only when count=true
+}
+
+// listQuery is the parsed paging and sorting parameters of a list request
+type listQuery[T any] struct {
+	Page    store.Page // Asks for one row more than the page holds, to tell whether another follows
+	sorting store.Sorting[T]
+	paged   bool
+	count   bool
+	limit   int
+}
+
+func parseListQuery[T any](r *http.Request, sorting store.Sorting[T]) (listQuery[T], error) {
+	q := r.URL.Query()
+	lq := listQuery[T]{sorting: sorting, count: q.Get("count") == "true"}
+	lq.paged = q.Has("limit") || q.Has("cursor") || lq.count
+
+	if sort := q.Get("sort"); sort != "" {
+		lq.Page.Desc = strings.HasPrefix(sort, "-")
+		lq.Page.Sort = strings.TrimPrefix(sort, "-")
+		if _, ok := sorting.Fields[lq.Page.Sort]; !ok {
+			return lq, fmt.Errorf("sort must be one of %s, prefixed with - for descending", strings.Join(sorting.Names(), ", "))
+		}
+	}
+	if !lq.paged {
+		return lq, nil
+	}
+
+	lq.limit = defaultPageSize
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return lq, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		lq.limit = limit
+	}
+	lq.Page.Limit = lq.limit + 1
+	if raw := q.Get("cursor"); raw != "" {
+		cursor, err := sorting.ParseCursor(raw, lq.Page)
+		if err != nil {
+			return lq, errors.New("Invalid cursor; it must be used with the sort it was issued for")
+		}
+		lq.Page.After = cursor
+	}
+	return lq, nil
+}
+
+// capUnpaged limits a listing asked for without paging to max rows. It is
+// still answered as a bare array; when rows were left out, the cursor to page
+// on from is sent in the X-Next-Cursor header.
+func (lq *listQuery[T]) capUnpaged(max int) {
+	if lq.paged {
+		return
+	}
+	lq.limit = max
+	lq.Page.Limit = max + 1
+}
+
+// respond writes rows as the list request asked. count is only called when
+// the total was asked for.
+func (lq listQuery[T]) respond(w http.ResponseWriter, rows []T, count func() (int64, error)) {
+	if !lq.paged {
+		if lq.limit > 0 && len(rows) > lq.limit {
+			rows = rows[:lq.limit]
+			w.Header().Set("X-Next-Cursor", lq.sorting.Cursor(lq.Page, rows[lq.limit-1]))
+		}
+		utils.RespondJSON(w, http.StatusOK, rows)
+		return
+	}
+	page := listPage[T]{Items: rows}
+	if len(rows) > lq.limit {
+		page.Items = rows[:lq.limit]
+		page.NextCursor = lq.sorting.Cursor(lq.Page, page.Items[lq.limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if lq.count {
+		total, err := count()
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		page.Total = &total
+	}
+	utils.RespondJSON(w, http.StatusOK, page)
+}
+
+// queryID reads an optional ID parameter; 0 when absent
+func queryID(q url.Values, key string) (uint, error) {
+	raw := q.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("Invalid %s", key)
+	}
+	return uint(id), nil
+}
+
+// queryValues reads a multi-value parameter, given repeated or comma-separated
+func queryValues(q url.Values, key string) []string {
+	var values []string
+	for _, raw := range q[key] {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// queryRange reads a <prefix>_from/<prefix>_to pair of optional bounds, each a
+// date (2006-01-02) or an RFC 3339 time. The upper bound is exclusive, except
+// that a plain date includes that whole day.
+func queryRange(q url.Values, prefix string) (from, before *time.Time, err error) {
+	return queryBounds(q, prefix+"_from", prefix+"_to")
+}
+
+// queryBounds reads bounds like queryRange from the given parameters
+func queryBounds(q url.Values, fromKey, toKey string) (from, before *time.Time, err error) {
+	parse := func(key string, endOfDay bool) (*time.Time, error) {
+		raw := q.Get(key)
+		if raw == "" {
+			return nil, nil
+		}
+		if day, err := time.Parse("2006-01-02", raw); err == nil {
+			if endOfDay {
+				day = day.AddDate(0, 0, 1)
+			}
+			return &day, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 time", key)
+		}
+		return &t, nil
+	}
+	if from, err = parse(fromKey, false); err != nil {
+		return nil, nil, err
+	}
+	if before, err = parse(toKey, true); err != nil {
+		return nil, nil, err
+	}
+	return from, before, nil
+}
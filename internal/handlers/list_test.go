@@ -0,0 +1,187 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+type requestPage struct {
+	Items      []models.MaintenanceRequest `json:"items"`
+	NextCursor string                      `json:"next_cursor"`
+	Total      *int64                      `json:"total"`
+}
+
+// seedRequests adds requests with the given subjects on the drill, created a
+// day apart starting on 2025-01-01
+func (f *fixture) seedRequests(subjects ...string) []models.MaintenanceRequest {
+	var requests []models.MaintenanceRequest
+	for i, subject := range subjects {
+		req := models.MaintenanceRequest{
+			Subject:     subject,
+			Type:        models.TypeCorrective,
+			Status:      models.StatusNew,
+			EquipmentID: f.drill.ID,
+			TeamID:      f.team.ID,
+			CreatedByID: f.employee1.ID,
+			CreatedAt:   time.Date(2025, 1, 1+i, 12, 0, 0, 0, time.UTC),
+		}
+		f.must(f.store.CreateRequest(&req))
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+func TestRequestPagesFollowCursor(t *testing.T) {
+	f := newFixture(t)
+	f.seedRequests("b", "d", "a", "c", "b")
+
+	var subjects []string
+	query := url.Values{"limit": {"2"}, "sort": {"-subject"}, "count": {"true"}}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor never ran out")
+		}
+		w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests?"+query.Encode(), f.manager, nil, nil)
+		expectStatus(t, w, http.StatusOK)
+		page := decode[requestPage](t, w)
+		if page.Total == nil || *page.Total != 5 {
+			t.Fatalf("expected a total of 5, got %v", page.Total)
+		}
+		for _, req := range page.Items {
+			subjects = append(subjects, req.Subject)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if got := len(subjects); got != 5 || subjects[0] != "d" || subjects[1] != "c" || subjects[2] != "b" || subjects[3] != "b" || subjects[4] != "a" {
+		t.Fatalf("pages should list every request once, by subject descending: %v", subjects)
+	}
+
+	// A cursor only works with the sort it came from
+	query.Set("sort", "subject")
+	expectStatus(t, f.call(f.h.GetRequests, http.MethodGet, "/api/requests?"+query.Encode(), f.manager, nil, nil), http.StatusBadRequest)
+	for _, bad := range []string{"sort=password", "limit=0", "limit=1000", "cursor=garbage"} {
+		expectStatus(t, f.call(f.h.GetRequests, http.MethodGet, "/api/requests?"+bad, f.manager, nil, nil), http.StatusBadRequest)
+	}
+
+	// Without paging parameters the answer is still a bare array
+	all := decode[[]models.MaintenanceRequest](t, f.call(f.h.GetRequests, http.MethodGet, "/api/requests?sort=created_at", f.manager, nil, nil))
+	if len(all) != 5 || all[0].Subject != "b" || all[4].Subject != "b" || all[1].Subject != "d" {
+		t.Fatalf("expected all requests by creation date, got %d", len(all))
+	}
+}
+
+func TestRequestListFilters(t *testing.T) {
+	f := newFixture(t)
+	seeded := f.seedRequests("jan 1", "jan 2", "jan 3", "jan 4")
+	seeded[1].Status = models.StatusInProgress
+	seeded[1].TechnicianID = &f.tech2.ID
+	f.must(f.store.UpdateRequest(&seeded[1], nil, f.manager.ID))
+	seeded[2].Status = models.StatusRepaired
+	f.must(f.store.UpdateRequest(&seeded[2], nil, f.manager.ID))
+	press := f.request(f.press, f.employee2, models.StatusNew)
+
+	cases := []struct {
+		query string
+		want  []uint
+	}{
+		{"status=New,In+Progress&equipment_id=" + fmt.Sprint(f.drill.ID), []uint{seeded[0].ID, seeded[1].ID, seeded[3].ID}},
+		{"status=Repaired&status=In+Progress", []uint{seeded[1].ID, seeded[2].ID}},
+		{"technician_id=" + fmt.Sprint(f.tech2.ID), []uint{seeded[1].ID, press.ID}},
+		{"created_from=2025-01-02&created_to=2025-01-03", []uint{seeded[1].ID, seeded[2].ID}},
+		{"created_to=2025-01-02T12:00:00Z", []uint{seeded[0].ID}},
+		{"team_id=" + fmt.Sprint(f.team.ID) + "&equipment_id=" + fmt.Sprint(f.press.ID), []uint{press.ID}},
+	}
+	for _, c := range cases {
+		w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests?"+c.query, f.manager, nil, nil)
+		expectStatus(t, w, http.StatusOK)
+		assertRequestIDs(t, decode[[]models.MaintenanceRequest](t, w), c.want)
+	}
+
+	// Filters narrow the role scope, never widen it
+	w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests?equipment_id="+fmt.Sprint(f.drill.ID), f.employee2, nil, nil)
+	assertRequestIDs(t, decode[[]models.MaintenanceRequest](t, w), nil)
+	for _, bad := range []string{"technician_id=abc", "created_from=yesterday"} {
+		expectStatus(t, f.call(f.h.GetRequests, http.MethodGet, "/api/requests?"+bad, f.manager, nil, nil), http.StatusBadRequest)
+	}
+}
+
+func TestEquipmentTeamAndUserListings(t *testing.T) {
+	f := newFixture(t)
+
+	type equipmentPage struct {
+		Items      []models.Equipment `json:"items"`
+		NextCursor string             `json:"next_cursor"`
+	}
+	w := f.call(f.h.GetEquipment, http.MethodGet, "/api/equipment?limit=2&sort=name", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	first := decode[equipmentPage](t, w)
+	if len(first.Items) != 2 || first.Items[0].Name != "Drill" || first.Items[1].Name != "Lathe" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	w = f.call(f.h.GetEquipment, http.MethodGet, "/api/equipment?limit=2&sort=name&cursor="+first.NextCursor, f.manager, nil, nil)
+	second := decode[equipmentPage](t, w)
+	if len(second.Items) != 1 || second.Items[0].Name != "Press" || second.NextCursor != "" {
+		t.Fatalf("unexpected last page: %+v", second)
+	}
+
+	byTech := decode[[]models.Equipment](t, f.call(f.h.GetEquipment, http.MethodGet, "/api/equipment?technician_id="+fmt.Sprint(f.tech2.ID), f.manager, nil, nil))
+	if len(byTech) != 1 || byTech[0].ID != f.press.ID {
+		t.Fatalf("expected only the press for tech2, got %+v", byTech)
+	}
+
+	other := models.MaintenanceTeam{Name: "Electrical Team"}
+	f.must(f.store.CreateTeam(&other))
+	teams := decode[[]models.MaintenanceTeam](t, f.call(f.h.GetTeams, http.MethodGet, "/api/teams?search=electr", f.manager, nil, nil))
+	if len(teams) != 1 || teams[0].ID != other.ID {
+		t.Fatalf("expected the electrical team, got %+v", teams)
+	}
+
+	type userPage struct {
+		Items []models.User `json:"items"`
+		Total int64         `json:"total"`
+	}
+	w = f.call(f.h.GetTechnicians, http.MethodGet, "/api/users/technicians?sort=-name&count=true&limit=1", f.manager, nil, nil)
+	techs := decode[userPage](t, w)
+	if techs.Total != 3 || len(techs.Items) != 1 || techs.Items[0].ID != f.tech1.ID {
+		t.Fatalf("expected Tom first of 3 technicians, got %+v", techs)
+	}
+	employees := decode[[]models.User](t, f.call(f.h.GetEmployees, http.MethodGet, "/api/users/employees?search=eve", f.manager, nil, nil))
+	if len(employees) != 1 || employees[0].ID != f.employee1.ID {
+		t.Fatalf("expected only Eve, got %+v", employees)
+	}
+}
+
+func TestUnpagedRequestListingIsCapped(t *testing.T) {
+	f := newFixture(t)
+	subjects := make([]string, 1001)
+	for i := range subjects {
+		subjects[i] = fmt.Sprintf("r%04d", i)
+	}
+	f.seedRequests(subjects...)
+
+	w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	cursor := w.Header().Get("X-Next-Cursor")
+	if all := decode[[]models.MaintenanceRequest](t, w); len(all) != 1000 || cursor == "" {
+		t.Fatalf("expected the first 1000 requests and a cursor, got %d and %q", len(all), cursor)
+	}
+	w = f.call(f.h.GetRequests, http.MethodGet, "/api/requests?cursor="+url.QueryEscape(cursor), f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	if rest := decode[requestPage](t, w); len(rest.Items) != 1 || rest.NextCursor != "" {
+		t.Fatalf("expected the cursor to page on to the last request, got %+v", rest)
+	}
+
+	w = f.call(f.h.GetRequests, http.MethodGet, "/api/requests?status=Repaired", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	if w.Header().Get("X-Next-Cursor") != "" {
+		t.Fatal("expected no cursor when everything fits")
+	}
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateLocation adds a node to the location tree (managers only)
+func (h *Handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.managerUser(w, r); !ok {
+		return
+	}
+
+	var loc models.Location
+	if err := json.NewDecoder(r.Body).Decode(&loc); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	loc.ID = 0
+	loc.Children = nil
+
+	if strings.TrimSpace(loc.Name) == "" {
+		utils.RespondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	parent, err := h.parentLocation(loc.ParentID)
+	if err == nil {
+		err = services.CheckLocationParent(loc.Kind, parent)
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.Locations.CreateLocation(&loc); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, loc)
+}
+
+// GetLocations lists locations; ?tree=true nests them under their parents
+func (h *Handler) GetLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.Locations.ListLocations(store.LocationFilter{})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("tree") == "true" {
+		tree := services.BuildLocationTree(locations)
+		if tree == nil {
+			tree = []models.Location{}
+		}
+		utils.RespondJSON(w, http.StatusOK, tree)
+		return
+	}
+	if locations == nil {
+		locations = []models.Location{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, locations)
+}
+
+// GetLocation returns a location with its direct children and the equipment placed in it
+func (h *Handler) GetLocation(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	loc, ok := h.locationFromVars(w, r)
+	if !ok {
+		return
+	}
+
+	children, err := h.Locations.ListLocations(store.LocationFilter{ParentID: loc.ID})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	loc.Children = children
+
+	equipment, err := h.Equipment.ListEquipment(store.EquipmentFilter{VisibleTo: &user, LocationID: loc.ID})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if equipment == nil {
+		equipment = []models.Equipment{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"location":  loc,
+		"equipment": equipment,
+	})
+}
+
+// UpdateLocation renames or moves a location (managers only)
+func (h *Handler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.managerUser(w, r); !ok {
+		return
+	}
+
+	loc, ok := h.locationFromVars(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name     *string `json:"name"`
+		ParentID *uint   `json:"parent_id"`
+		Move     bool    `json:"move"` // Set to apply parent_id (null moves a Site to the top)
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Name != nil {
+		if err := h.Locations.RenameLocation(loc.ID, *input.Name); err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		loc.Name = *input.Name
+	}
+	if input.Move || input.ParentID != nil {
+		parent, err := h.parentLocation(input.ParentID)
+		if err == nil {
+			err = services.CheckLocationMove(loc, parent)
+		}
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.Locations.MoveLocation(&loc, parent); err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, loc)
+}
+
+// DeleteLocation removes an empty location (managers only)
+func (h *Handler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.managerUser(w, r); !ok {
+		return
+	}
+
+	loc, ok := h.locationFromVars(w, r)
+	if !ok {
+		return
+	}
+
+	children, err := h.Locations.ListLocations(store.LocationFilter{ParentID: loc.ID})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Archived equipment keeps its place, so it counts too
+	var equipment int64
+	for _, archived := range []bool{false, true} {
+		n, err := h.Equipment.CountEquipment(store.EquipmentFilter{LocationID: loc.ID, Archived: archived})
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		equipment += n
+	}
+	if len(children) > 0 || equipment > 0 {
+		utils.RespondError(w, http.StatusConflict, "Location still has child locations or equipment")
+		return
+	}
+
+	if err := h.Locations.DeleteLocation(loc.ID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Location deleted"})
+}
+
+// GetLocationRollup sums open requests and costs of the equipment under a
+// location, and its sub-assemblies, that the user can see
+func (h *Handler) GetLocationRollup(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	loc, err := h.Locations.GetLocation(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Location not found")
+		return
+	}
+
+	located, err := h.Equipment.ListEquipment(store.EquipmentFilter{VisibleTo: &user, LocationPath: loc.Path})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.respondRollup(w, located, user)
+}
+
+// ImportLegacyLocations converts free-text equipment locations into location nodes
+// (managers only). ?dry_run=true previews the result without writing anything.
+func (h *Handler) ImportLegacyLocations(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.managerUser(w, r); !ok {
+		return
+	}
+
+	var input struct {
+		SiteName string              `json:"site_name"`
+		Kind     models.LocationKind `json:"kind"`
+		Mapping  map[string]string   `json:"mapping"` // Raw value -> canonical name
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.SiteName == "" {
+		input.SiteName = "Main Site"
+	}
+	if input.Kind == "" {
+		input.Kind = models.LocationBuilding
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	groups, err := h.Locations.ImportLegacyLocations(input.SiteName, input.Kind, input.Mapping, dryRun)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if groups == nil {
+		groups = []services.LegacyLocationGroup{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run":   dryRun,
+		"site_name": input.SiteName,
+		"locations": groups,
+	})
+}
+
+// SetEquipmentParent attaches an equipment to a parent assembly, or detaches it with null
+func (h *Handler) SetEquipmentParent(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleEmployee {
+		utils.RespondError(w, http.StatusForbidden, "Only managers and technicians can perform this action")
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ParentID *uint `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.ParentID != nil {
+		if err := h.checkEquipmentParent(equipment.ID, *input.ParentID, user); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	before := equipment
+	equipment.ParentID = input.ParentID
+	if err := h.Equipment.UpdateEquipment(&equipment, "ParentID"); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityEquipment, equipment.ID, models.AuditUpdate, before, equipment)
+	h.publishEquipment(models.AuditUpdate, before, equipment)
+
+	h.respondEquipment(w, http.StatusOK, equipment)
+}
+
+// GetEquipmentChildren returns the sub-assemblies of an equipment the user can see, as a tree
+func (h *Handler) GetEquipmentChildren(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	all, err := h.withSubAssemblies([]models.Equipment{equipment}, user)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	children := map[uint][]models.Equipment{}
+	for _, e := range all {
+		if e.ParentID != nil {
+			children[*e.ParentID] = append(children[*e.ParentID], e)
+		}
+	}
+	var attach func(nodes []models.Equipment) []models.Equipment
+	attach = func(nodes []models.Equipment) []models.Equipment {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	tree := attach(children[equipment.ID])
+	if tree == nil {
+		tree = []models.Equipment{}
+	}
+	utils.RespondJSON(w, http.StatusOK, tree)
+}
+
+// GetEquipmentRollup sums open requests and costs of an equipment and the
+// sub-assemblies of it the user can see
+func (h *Handler) GetEquipmentRollup(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	h.respondRollup(w, []models.Equipment{equipment}, user)
+}
+
+// checkEquipmentParent refuses parents the user can't see and parents that
+// would make the assembly hierarchy a cycle
+func (h *Handler) checkEquipmentParent(equipmentID, parentID uint, user models.User) error {
+	if equipmentID == parentID {
+		return fmt.Errorf("equipment cannot be its own parent")
+	}
+	parent, err := h.Equipment.GetEquipment(parentID)
+	if err != nil || !store.EquipmentVisible(parent, user) {
+		return fmt.Errorf("parent equipment not found")
+	}
+	for depth := 0; depth < 100; depth++ {
+		if parent.ParentID == nil {
+			return nil
+		}
+		if *parent.ParentID == equipmentID {
+			return fmt.Errorf("equipment cannot be placed under its own sub-assembly")
+		}
+		if parent, err = h.Equipment.GetEquipment(*parent.ParentID); err != nil {
+			return fmt.Errorf("parent equipment not found")
+		}
+	}
+	return fmt.Errorf("equipment hierarchy is too deep")
+}
+
+// withSubAssemblies adds to roots all of their sub-assemblies the user can see
+func (h *Handler) withSubAssemblies(roots []models.Equipment, user models.User) ([]models.Equipment, error) {
+	seen := map[uint]bool{}
+	var all []models.Equipment
+	frontier := roots
+	for len(frontier) > 0 {
+		var ids []uint
+		for _, e := range frontier {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				all = append(all, e)
+				ids = append(ids, e.ID)
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		var err error
+		if frontier, err = h.Equipment.ListEquipment(store.EquipmentFilter{VisibleTo: &user, ParentIDs: ids}); err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
+}
+
+// respondRollup answers with the open requests and costs of roots and their
+// sub-assemblies the user can see
+func (h *Handler) respondRollup(w http.ResponseWriter, roots []models.Equipment, user models.User) {
+	equipment, err := h.withSubAssemblies(roots, user)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rollup := services.Rollup{EquipmentCount: len(equipment)}
+	if len(equipment) > 0 {
+		ids := make([]uint, len(equipment))
+		for i, e := range equipment {
+			ids[i] = e.ID
+		}
+		rollup.OpenRequests, err = h.Requests.CountRequests(store.RequestFilter{
+			EquipmentIDs:    ids,
+			ExcludeStatuses: []models.RequestStatus{models.StatusRepaired, models.StatusScrap},
+		})
+		if err == nil {
+			rollup.Costs, err = h.Requests.CostTotals(store.RequestFilter{EquipmentIDs: ids})
+		}
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	utils.RespondJSON(w, http.StatusOK, rollup)
+}
+
+func (h *Handler) locationFromVars(w http.ResponseWriter, r *http.Request) (models.Location, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	loc, err := h.Locations.GetLocation(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Location not found")
+		return loc, false
+	}
+	return loc, true
+}
+
+// parentLocation loads the parent a location is placed under, nil for the top of the tree
+func (h *Handler) parentLocation(id *uint) (*models.Location, error) {
+	if id == nil {
+		return nil, nil
+	}
+	parent, err := h.Locations.GetLocation(*id)
+	if err != nil {
+		return nil, fmt.Errorf("parent location not found")
+	}
+	return &parent, nil
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+)
+
+// assemblies puts the machines in a hall of a site and gives the drill a
+// chuck (maintained like the drill) whose bit belongs to another employee
+func (f *fixture) assemblies() (site models.Location, chuck, bit models.Equipment) {
+	site = models.Location{Name: "Main Site", Kind: models.LocationSite}
+	f.store.CreateLocation(&site)
+	hall := models.Location{Name: "Hall A", Kind: models.LocationBuilding, ParentID: &site.ID}
+	f.store.CreateLocation(&hall)
+	for _, e := range []*models.Equipment{&f.drill, &f.press} {
+		e.LocationID = &hall.ID
+		f.must(f.store.SaveEquipment(e))
+	}
+	f.lathe.LocationID = &site.ID
+	f.must(f.store.SaveEquipment(&f.lathe))
+
+	chuck = f.equipment("Chuck", &f.employee1.ID, &f.tech1.ID)
+	bit = f.equipment("Bit", &f.employee2.ID, &f.tech2.ID)
+	for _, link := range []struct{ child, parent models.Equipment }{{chuck, f.drill}, {bit, chuck}} {
+		target, vars := f.equipmentVars(link.child)
+		w := f.call(f.h.SetEquipmentParent, http.MethodPut, target+"/parent", f.manager, map[string]uint{"parent_id": link.parent.ID}, vars)
+		expectStatus(f.t, w, http.StatusOK)
+	}
+
+	for _, e := range []models.Equipment{f.drill, chuck, bit, f.press} {
+		req := models.MaintenanceRequest{Subject: e.Name + " repair", Type: models.TypeCorrective, Status: models.StatusNew,
+			EquipmentID: e.ID, TeamID: f.team.ID, CreatedByID: f.manager.ID, VendorCost: 10, TotalCost: 10}
+		f.must(f.store.CreateRequest(&req))
+	}
+	return site, chuck, bit
+}
+
+func TestLocationRollupCoversOnlyVisibleEquipment(t *testing.T) {
+	f := newFixture(t)
+	site, _, _ := f.assemblies()
+	vars := map[string]string{"id": fmt.Sprint(site.ID)}
+	rollup := func(as models.User) services.Rollup {
+		t.Helper()
+		w := f.call(f.h.GetLocationRollup, http.MethodGet, "/api/locations/"+vars["id"]+"/rollup", as, nil, vars)
+		expectStatus(t, w, http.StatusOK)
+		return decode[services.Rollup](t, w)
+	}
+
+	if got := rollup(f.manager); got.EquipmentCount != 5 || got.OpenRequests != 4 || got.Costs.TotalCost != 40 {
+		t.Fatalf("expected the whole site for a manager, got %+v", got)
+	}
+	// The drill and its chuck, but not the press nor the bit owned by someone else
+	if got := rollup(f.employee1); got.EquipmentCount != 2 || got.OpenRequests != 2 || got.Costs.VendorCost != 20 {
+		t.Fatalf("expected only the employee's own equipment, got %+v", got)
+	}
+	if got := rollup(f.idleTech); got.EquipmentCount != 0 || got.OpenRequests != 0 || got.Costs.TotalCost != 0 {
+		t.Fatalf("expected nothing for a technician maintaining nothing, got %+v", got)
+	}
+
+	missing := map[string]string{"id": "999"}
+	expectStatus(t, f.call(f.h.GetLocationRollup, http.MethodGet, "/api/locations/999/rollup", f.manager, nil, missing), http.StatusNotFound)
+}
+
+func TestEquipmentAssembliesRespectVisibility(t *testing.T) {
+	f := newFixture(t)
+	_, chuck, bit := f.assemblies()
+	target, vars := f.equipmentVars(f.drill)
+
+	w := f.call(f.h.GetEquipmentChildren, http.MethodGet, target+"/children", f.manager, nil, vars)
+	expectStatus(t, w, http.StatusOK)
+	tree := decode[[]models.Equipment](t, w)
+	if len(tree) != 1 || tree[0].ID != chuck.ID || len(tree[0].Children) != 1 || tree[0].Children[0].ID != bit.ID {
+		t.Fatalf("expected drill > chuck > bit, got %+v", tree)
+	}
+	w = f.call(f.h.GetEquipmentChildren, http.MethodGet, target+"/children", f.employee1, nil, vars)
+	expectStatus(t, w, http.StatusOK)
+	if tree := decode[[]models.Equipment](t, w); len(tree) != 1 || len(tree[0].Children) != 0 {
+		t.Fatalf("expected the bit to be hidden from the drill's owner, got %+v", tree)
+	}
+
+	w = f.call(f.h.GetEquipmentRollup, http.MethodGet, target+"/rollup", f.employee1, nil, vars)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[services.Rollup](t, w); got.EquipmentCount != 2 || got.Costs.TotalCost != 20 {
+		t.Fatalf("expected the drill and chuck only, got %+v", got)
+	}
+
+	// Equipment the caller can't see is answered like a missing one
+	for _, handler := range []http.HandlerFunc{f.h.GetEquipmentChildren, f.h.GetEquipmentRollup} {
+		expectStatus(t, f.call(handler, http.MethodGet, target, f.employee2, nil, vars), http.StatusNotFound)
+		expectStatus(t, f.call(handler, http.MethodGet, target, f.tech2, nil, vars), http.StatusNotFound)
+	}
+}
+
+func TestSetEquipmentParent(t *testing.T) {
+	f := newFixture(t)
+	_, chuck, _ := f.assemblies()
+	target, vars := f.equipmentVars(f.drill)
+	set := func(as models.User, parent uint) int {
+		t.Helper()
+		return f.call(f.h.SetEquipmentParent, http.MethodPut, target+"/parent", as, map[string]uint{"parent_id": parent}, vars).Code
+	}
+
+	if code := set(f.employee1, f.lathe.ID); code != http.StatusForbidden {
+		t.Fatalf("expected employees to be refused, got %d", code)
+	}
+	if code := set(f.tech2, f.press.ID); code != http.StatusNotFound {
+		t.Fatalf("expected equipment the technician doesn't maintain to be hidden, got %d", code)
+	}
+	if code := set(f.tech1, f.press.ID); code != http.StatusBadRequest {
+		t.Fatalf("expected a parent the technician can't see to be refused, got %d", code)
+	}
+	if code := set(f.manager, chuck.ID); code != http.StatusBadRequest {
+		t.Fatalf("expected a cycle to be refused, got %d", code)
+	}
+	if code := set(f.manager, f.drill.ID); code != http.StatusBadRequest {
+		t.Fatalf("expected equipment to be refused as its own parent, got %d", code)
+	}
+
+	w := f.call(f.h.SetEquipmentParent, http.MethodPut, target+"/parent", f.manager, map[string]interface{}{"parent_id": nil}, vars)
+	expectStatus(t, w, http.StatusOK)
+	if got, _ := f.store.GetEquipment(f.drill.ID); got.ParentID != nil {
+		t.Fatalf("expected the drill to be detached, got parent %v", *got.ParentID)
+	}
+}
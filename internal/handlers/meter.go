@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type readingInput struct {
+	MeterID uint       `json:"meter_id"`
+	Value   float64    `json:"value"`
+	ReadAt  *time.Time `json:"read_at"`
+}
+
+type readingResult struct {
+	MeterID        uint                        `json:"meter_id"`
+	CurrentReading float64                     `json:"current_reading"`
+	OpenedRequests []models.MaintenanceRequest `json:"opened_requests"`
+	Error          string                      `json:"error,omitempty"`
+}
+
+// visibleMeter loads a meter on equipment the user may see, and that equipment
+func (h *Handler) visibleMeter(user models.User, id uint) (models.Meter, models.Equipment, bool) {
+	meter, err := h.Meters.GetMeter(id)
+	if err != nil {
+		return meter, models.Equipment{}, false
+	}
+	equipment, err := h.Equipment.GetEquipment(meter.EquipmentID)
+	return meter, equipment, err == nil && store.EquipmentVisible(equipment, user)
+}
+
+// CreateMeter defines a new usage meter on an equipment
+func (h *Handler) CreateMeter(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	var meter models.Meter
+	if err := json.NewDecoder(r.Body).Decode(&meter); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if meter.Name == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Meter name is required")
+		return
+	}
+
+	meter.ID = 0
+	meter.EquipmentID = equipment.ID
+	meter.Rules = nil
+	if err := h.Meters.CreateMeter(&meter); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, meter)
+}
+
+// GetEquipmentMeters lists the meters (and their rules) of an equipment
+func (h *Handler) GetEquipmentMeters(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	equipment, ok := h.equipmentFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	meters, err := h.Meters.ListMeters(equipment.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if meters == nil {
+		meters = []models.Meter{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, meters)
+}
+
+// PostMeterReading records a single reading for a meter
+func (h *Handler) PostMeterReading(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	meterID, _ := strconv.Atoi(vars["id"])
+
+	var input readingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	input.MeterID = uint(meterID)
+
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	result, status := h.recordReading(input, user)
+	if result.Error != "" {
+		utils.RespondError(w, status, result.Error)
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, result)
+}
+
+// PostMeterReadings records a batch of readings, e.g. from a PLC gateway.
+// Each reading is processed independently and reported in the response.
+func (h *Handler) PostMeterReadings(w http.ResponseWriter, r *http.Request) {
+	var inputs []readingInput
+	if err := json.NewDecoder(r.Body).Decode(&inputs); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	results := make([]readingResult, 0, len(inputs))
+	for _, input := range inputs {
+		result, _ := h.recordReading(input, user)
+		results = append(results, result)
+	}
+
+	utils.RespondJSON(w, http.StatusOK, results)
+}
+
+// recordReading records a reading as user, who must be able to see the
+// meter's equipment, and announces the requests it opens
+func (h *Handler) recordReading(input readingInput, user models.User) (readingResult, int) {
+	result := readingResult{MeterID: input.MeterID, OpenedRequests: []models.MaintenanceRequest{}}
+
+	meter, equipment, ok := h.visibleMeter(user, input.MeterID)
+	if !ok {
+		result.Error = "Meter not found"
+		return result, http.StatusNotFound
+	}
+
+	reading := models.MeterReading{Value: input.Value, ReadAt: time.Now(), RecordedByID: user.ID}
+	if input.ReadAt != nil {
+		reading.ReadAt = *input.ReadAt
+	}
+
+	opened, err := h.Meters.RecordMeterReading(&meter, &reading)
+	if err != nil {
+		result.Error = err.Error()
+		return result, http.StatusInternalServerError
+	}
+	for _, req := range opened {
+		h.AnnounceRequest(req, equipment)
+	}
+	if opened != nil {
+		result.OpenedRequests = opened
+	}
+	result.CurrentReading = meter.CurrentReading
+	return result, http.StatusCreated
+}
+
+// GetMeterReadings lists the reading history of a meter, newest first
+func (h *Handler) GetMeterReadings(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	meterID, _ := strconv.Atoi(vars["id"])
+
+	if _, _, ok := h.visibleMeter(user, uint(meterID)); !ok {
+		utils.RespondError(w, http.StatusNotFound, "Meter not found")
+		return
+	}
+
+	readings, err := h.Meters.ListMeterReadings(uint(meterID), 500)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if readings == nil {
+		readings = []models.MeterReading{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, readings)
+}
+
+// CreateMeterRule adds a usage-triggered maintenance rule to a meter
+func (h *Handler) CreateMeterRule(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.staffUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	meterID, _ := strconv.Atoi(vars["id"])
+
+	meter, _, ok := h.visibleMeter(user, uint(meterID))
+	if !ok {
+		utils.RespondError(w, http.StatusNotFound, "Meter not found")
+		return
+	}
+
+	var input struct {
+		models.MeterRule
+		BaselineReading *float64 `json:"baseline_reading"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule := input.MeterRule
+	if err := services.ValidateMeterRule(&rule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule.ID = 0
+	rule.MeterID = meter.ID
+	rule.Active = true
+	rule.CreatedByID = user.ID
+	// "Every 500 hours" counts from the current reading unless a baseline is given
+	rule.BaselineReading = meter.CurrentReading
+	if input.BaselineReading != nil {
+		rule.BaselineReading = *input.BaselineReading
+	}
+	rule.Tripped = rule.Kind == models.RuleThreshold && meter.CurrentReading > rule.Threshold
+
+	if err := h.Meters.CreateMeterRule(&rule); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, rule)
+}
+
+// DeleteMeterRule removes a meter rule
+func (h *Handler) DeleteMeterRule(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.staffUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	rule, err := h.Meters.GetMeterRule(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Meter rule not found")
+		return
+	}
+	if _, _, ok := h.visibleMeter(user, rule.MeterID); !ok {
+		utils.RespondError(w, http.StatusNotFound, "Meter rule not found")
+		return
+	}
+
+	if err := h.Meters.DeleteMeterRule(rule.ID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Meter rule deleted"})
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+)
+
+// overheatRule puts a temperature meter on the press with a rule opening a
+// request above 100
+func (f *fixture) overheatRule() (models.Meter, models.MeterRule) {
+	meter := models.Meter{EquipmentID: f.press.ID, Name: "Temperature", Unit: "C"}
+	f.must(f.store.CreateMeter(&meter))
+	rule := models.MeterRule{MeterID: meter.ID, Kind: models.RuleThreshold, Subject: "Overheat", Threshold: 100, Active: true, CreatedByID: f.manager.ID}
+	f.must(f.store.CreateMeterRule(&rule))
+	return meter, rule
+}
+
+func TestMeterReadingsNeedVisibleEquipment(t *testing.T) {
+	f := newFixture(t)
+	meter, _ := f.overheatRule()
+	vars := map[string]string{"id": fmt.Sprint(meter.ID)}
+	target := "/api/meters/" + vars["id"] + "/readings"
+
+	w := f.call(f.h.PostMeterReading, http.MethodPost, target, f.employee1, map[string]interface{}{"value": 500}, vars)
+	expectStatus(t, w, http.StatusNotFound)
+	w = f.call(f.h.PostMeterReadings, http.MethodPost, "/api/meters/readings", f.employee1, []map[string]interface{}{{"meter_id": meter.ID, "value": 500}}, nil)
+	expectStatus(t, w, http.StatusOK)
+	if results := decode[[]map[string]interface{}](t, w); results[0]["error"] != "Meter not found" {
+		t.Fatalf("expected the batch reading to be refused, got %v", results)
+	}
+	expectStatus(t, f.call(f.h.GetMeterReadings, http.MethodGet, target, f.employee1, nil, vars), http.StatusNotFound)
+
+	if got, _ := f.store.GetMeter(meter.ID); got.CurrentReading != 0 || len(f.audit) != 0 {
+		t.Fatalf("refused readings must not move the meter or open requests: %g, %v", got.CurrentReading, f.audit)
+	}
+
+	w = f.call(f.h.PostMeterReading, http.MethodPost, target, f.employee2, map[string]interface{}{"value": 500}, vars)
+	expectStatus(t, w, http.StatusCreated)
+	result := decode[map[string]interface{}](t, w)
+	if opened := result["opened_requests"].([]interface{}); len(opened) != 1 || result["current_reading"] != 500.0 {
+		t.Fatalf("expected the owner's reading to trip the rule, got %v", result)
+	}
+	if len(f.audit) != 1 || f.audit[0].EntityType != services.EntityRequest || f.audit[0].Action != models.AuditCreate {
+		t.Fatalf("expected the opened request to be recorded, got %v", f.audit)
+	}
+	w = f.call(f.h.GetMeterReadings, http.MethodGet, target, f.employee2, nil, vars)
+	expectStatus(t, w, http.StatusOK)
+	if readings := decode[[]models.MeterReading](t, w); len(readings) != 1 || readings[0].Value != 500 {
+		t.Fatalf("expected the one reading, got %+v", readings)
+	}
+}
+
+func TestMeterRulesPauseOnArchivedEquipment(t *testing.T) {
+	f := newFixture(t)
+	meter, rule := f.overheatRule()
+	vars := map[string]string{"id": fmt.Sprint(meter.ID)}
+	now := time.Now()
+	f.press.ArchivedAt = &now
+	f.must(f.store.SaveEquipment(&f.press))
+
+	w := f.call(f.h.PostMeterReading, http.MethodPost, "/api/meters/"+vars["id"]+"/readings", f.manager, map[string]interface{}{"value": 500}, vars)
+	expectStatus(t, w, http.StatusCreated)
+	if result := decode[map[string]interface{}](t, w); len(result["opened_requests"].([]interface{})) != 0 {
+		t.Fatalf("expected archived equipment to get no request, got %v", result)
+	}
+	if got, _ := f.store.GetMeterRule(rule.ID); got.Tripped {
+		t.Fatal("expected the rule to stay armed while the equipment is archived")
+	}
+}
+
+func TestDeleteMeterRule(t *testing.T) {
+	f := newFixture(t)
+	_, rule := f.overheatRule()
+	vars := map[string]string{"id": fmt.Sprint(rule.ID)}
+	target := "/api/meter-rules/" + vars["id"]
+
+	expectStatus(t, f.call(f.h.DeleteMeterRule, http.MethodDelete, target, f.employee2, nil, vars), http.StatusForbidden)
+	expectStatus(t, f.call(f.h.DeleteMeterRule, http.MethodDelete, target, f.tech1, nil, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.DeleteMeterRule, http.MethodDelete, target, f.tech2, nil, vars), http.StatusOK)
+	expectStatus(t, f.call(f.h.DeleteMeterRule, http.MethodDelete, target, f.tech2, nil, vars), http.StatusNotFound)
+}
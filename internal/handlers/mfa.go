@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+	"gearguard/internal/services"
+	"gearguard/internal/totp"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+const (
+	totpIssuer = "GearGuard"
+
+	// Challenge purposes: verify an enrolled second factor, or enroll one first
+	// because the user's role requires it
+	challengeMFA       = "mfa"
+	challengeMFAEnroll = "mfa_enroll"
+
+	// ChallengeTTL is how long a user has to complete the second login step
+	ChallengeTTL = 5 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+// respondChallenge answers a correct password for a user who still needs a
+// second factor with a short-lived challenge token instead of a session
+func respondChallenge(w http.ResponseWriter, user models.User) {
+	purpose := challengeMFA
+	if !user.TOTPEnabled {
+		purpose = challengeMFAEnroll
+	}
+	now := time.Now()
+	challenge, err := utils.SignToken(&utils.ChallengeClaims{
+		UserID:  user.ID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTTL)),
+		},
+	})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"mfa_required":            purpose == challengeMFA,
+		"mfa_enrollment_required": purpose == challengeMFAEnroll,
+		"challenge_token":         challenge,
+	})
+}
+
+// challengeUser resolves the user behind a challenge token issued for purpose
+func (h *Handler) challengeUser(token, purpose string) (models.User, bool) {
+	claims := &utils.ChallengeClaims{}
+	if err := utils.ParseToken(token, claims); err != nil || claims.Purpose != purpose {
+		return models.User{}, false
+	}
+	user, err := h.Users.GetUser(claims.UserID)
+	if err != nil || user.Disabled {
+		return user, false
+	}
+	return user, true
+}
+
+// checkTOTP validates a code for an enrolled or enrolling user, rejecting
+// codes at or before the last accepted time step
+func (h *Handler) checkTOTP(user *models.User, code string) bool {
+	if user.TOTPSecret == "" {
+		return false
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+	user.TOTPLastStep = step
+	return h.Users.SaveUser(user) == nil
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code
+func (h *Handler) checkSecondFactor(user *models.User, code, recoveryCode string) bool {
+	if recoveryCode != "" {
+		used, err := h.Recovery.UseRecoveryCode(user.ID, hashRecoveryCode(recoveryCode), time.Now())
+		if used {
+			h.Audit(user.ID, services.EntityUser, user.ID, models.AuditUpdate, nil, map[string]bool{"recovery_code_used": true})
+		}
+		return err == nil && used
+	}
+	return h.checkTOTP(user, code)
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashRefreshToken(code)
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns the new ones
+func (h *Handler) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := randomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := h.Recovery.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// startEnrollment gives the user a fresh secret to add to their authenticator
+func (h *Handler) startEnrollment(w http.ResponseWriter, user models.User) {
+	if user.TOTPEnabled {
+		utils.RespondError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate secret")
+		return
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := h.Users.SaveUser(&user); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, totpIssuer, user.Email),
+	})
+}
+
+// confirmEnrollment enables TOTP once the user proves their authenticator works,
+// returning the recovery codes (shown only this once)
+func (h *Handler) confirmEnrollment(user *models.User, code string) ([]string, int, string) {
+	if user.TOTPEnabled {
+		return nil, http.StatusConflict, "Two-factor authentication is already enabled"
+	}
+	if user.TOTPSecret == "" {
+		return nil, http.StatusConflict, "Start enrollment first"
+	}
+	if !h.checkTOTP(user, code) {
+		return nil, http.StatusBadRequest, "Invalid verification code"
+	}
+
+	codes, err := h.newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+	user.TOTPEnabled = true
+	if err := h.Users.SaveUser(user); err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+	h.Audit(user.ID, services.EntityUser, user.ID, models.AuditUpdate,
+		map[string]bool{"totp_enabled": false}, map[string]bool{"totp_enabled": true})
+	return codes, 0, ""
+}
+
+// VerifyLoginMFA completes a login with a TOTP or recovery code
+func (h *Handler) VerifyLoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, ok := h.challengeUser(input.ChallengeToken, challengeMFA)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+	// Second-factor guesses count towards the same lockout as passwords
+	if wait := h.loginBlocked(r, user.Email); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+	if !h.checkSecondFactor(&user, input.Code, input.RecoveryCode) {
+		h.recordLoginFailure(r, user.Email, &user)
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid verification code")
+		return
+	}
+
+	tokens, err := h.issueTokens(user, "", r)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	h.clearLoginFailures(user.Email)
+	utils.RespondJSON(w, http.StatusOK, tokens)
+}
+
+// StartLoginEnrollment begins TOTP enrollment for a user whose role requires it
+// and who therefore can't sign in yet
+func (h *Handler) StartLoginEnrollment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.challengeUser(input.ChallengeToken, challengeMFAEnroll)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+	h.startEnrollment(w, user)
+}
+
+// ConfirmLoginEnrollment finishes enrollment started at login and signs the user in
+func (h *Handler) ConfirmLoginEnrollment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.challengeUser(input.ChallengeToken, challengeMFAEnroll)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	codes, status, message := h.confirmEnrollment(&user, input.Code)
+	if status != 0 {
+		utils.RespondError(w, status, message)
+		return
+	}
+	tokens, err := h.issueTokens(user, "", r)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	tokens["recovery_codes"] = codes
+	utils.RespondJSON(w, http.StatusOK, tokens)
+}
+
+// StartTOTPEnrollment begins TOTP enrollment for the signed-in user
+func (h *Handler) StartTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	h.startEnrollment(w, user)
+}
+
+// ConfirmTOTPEnrollment enables TOTP for the signed-in user
+func (h *Handler) ConfirmTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	codes, status, message := h.confirmEnrollment(&user, input.Code)
+	if status != 0 {
+		utils.RespondError(w, status, message)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// DisableTOTP turns two-factor authentication off, unless the user's role requires it
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	if policy.MFARequired(user.Role) {
+		utils.RespondError(w, http.StatusForbidden, "Two-factor authentication is required for your role")
+		return
+	}
+	if !user.TOTPEnabled {
+		utils.RespondError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+	if !h.checkSecondFactor(&user, input.Code, input.RecoveryCode) {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid verification code")
+		return
+	}
+
+	if err := h.clearTOTP(&user); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityUser, user.ID, models.AuditUpdate,
+		map[string]bool{"totp_enabled": true}, map[string]bool{"totp_enabled": false})
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the signed-in user's recovery codes
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		utils.RespondError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+	if !h.checkTOTP(&user, input.Code) {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid verification code")
+		return
+	}
+
+	codes, err := h.newRecoveryCodes(user.ID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// ResetUserMFA lets a manager clear another user's second factor (lost phone
+// and recovery codes). The user's sessions end and they enroll again on next login.
+func (h *Handler) ResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	user, err := h.Users.GetUser(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	wasEnabled := user.TOTPEnabled
+	if err := h.clearTOTP(&user); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Tokens.RevokeUserSessions(user.ID, time.Now())
+	h.Audit(manager.ID, services.EntityUser, user.ID, models.AuditUpdate,
+		map[string]bool{"totp_enabled": wasEnabled}, map[string]bool{"totp_enabled": false})
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication reset"})
+}
+
+// clearTOTP removes the user's secret and recovery codes
+func (h *Handler) clearTOTP(user *models.User) error {
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	if err := h.Users.SaveUser(user); err != nil {
+		return err
+	}
+	return h.Recovery.ReplaceRecoveryCodes(user.ID, nil)
+}
@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/policy"
+	"gearguard/internal/totp"
+)
+
+type challenge struct {
+	MFARequired           bool   `json:"mfa_required"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required"`
+	ChallengeToken        string `json:"challenge_token"`
+}
+
+type enrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// code returns the TOTP code for secret, offset by steps from now
+func code(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(time.Now())+steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// passwordLogin logs in with a password and returns the challenge, if any
+func (f *fixture) passwordLogin(user models.User) challenge {
+	f.t.Helper()
+	f.login(user)
+	w := f.call(f.h.Login, http.MethodPost, "/api/login", models.User{}, map[string]string{"email": user.Email, "password": "secret"}, nil)
+	expectStatus(f.t, w, http.StatusOK)
+	return decode[challenge](f.t, w)
+}
+
+// enroll turns TOTP on for user through the signed-in endpoints
+func (f *fixture) enroll(user models.User) (string, []string) {
+	f.t.Helper()
+	w := f.call(f.h.StartTOTPEnrollment, http.MethodPost, "/api/mfa/totp", user, nil, nil)
+	expectStatus(f.t, w, http.StatusOK)
+	secret := decode[enrollment](f.t, w).Secret
+
+	w = f.call(f.h.ConfirmTOTPEnrollment, http.MethodPost, "/api/mfa/totp/confirm", user, map[string]string{"code": code(f.t, secret, 0)}, nil)
+	expectStatus(f.t, w, http.StatusOK)
+	return secret, decode[struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}](f.t, w).RecoveryCodes
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	f := newFixture(t)
+	secret, _ := f.enroll(f.manager)
+
+	c := f.passwordLogin(f.manager)
+	if !c.MFARequired || c.ChallengeToken == "" {
+		t.Fatalf("expected a TOTP challenge, got %+v", c)
+	}
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		return f.call(f.h.VerifyLoginMFA, http.MethodPost, "/api/login/mfa", models.User{}, map[string]string{"challenge_token": c.ChallengeToken, "code": code}, nil)
+	}
+	expectStatus(t, verify("000000"), http.StatusUnauthorized)
+
+	// The enrollment code was for the current step, so the login uses the next one
+	next := code(t, secret, 1)
+	w := verify(next)
+	expectStatus(t, w, http.StatusOK)
+	if err := f.authenticate(decode[tokenPair](t, w).Token); err != nil {
+		t.Fatalf("token from a 2FA login rejected: %v", err)
+	}
+	expectStatus(t, verify(next), http.StatusUnauthorized) // Replay
+
+	// A challenge token is not an access token
+	if err := f.authenticate(c.ChallengeToken); err == nil {
+		t.Fatal("challenge token accepted as an access token")
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	f := newFixture(t)
+	_, codes := f.enroll(f.tech1)
+	if len(codes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(codes))
+	}
+
+	c := f.passwordLogin(f.tech1)
+	useCode := func() *httptest.ResponseRecorder {
+		return f.call(f.h.VerifyLoginMFA, http.MethodPost, "/api/login/mfa", models.User{}, map[string]string{"challenge_token": c.ChallengeToken, "recovery_code": codes[0]}, nil)
+	}
+	expectStatus(t, useCode(), http.StatusOK)
+	expectStatus(t, useCode(), http.StatusUnauthorized)
+}
+
+func TestRequiredMFAForcesEnrollment(t *testing.T) {
+	policy.SetMFARoles(models.RoleManager)
+	t.Cleanup(func() { policy.SetMFARoles() })
+	f := newFixture(t)
+
+	c := f.passwordLogin(f.manager)
+	if !c.MFAEnrollmentRequired {
+		t.Fatalf("expected enrollment to be required, got %+v", c)
+	}
+	// An enrollment challenge can't be used to skip the second factor
+	w := f.call(f.h.VerifyLoginMFA, http.MethodPost, "/api/login/mfa", models.User{}, map[string]string{"challenge_token": c.ChallengeToken, "code": "123456"}, nil)
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	w = f.call(f.h.StartLoginEnrollment, http.MethodPost, "/api/login/mfa/enroll", models.User{}, map[string]string{"challenge_token": c.ChallengeToken}, nil)
+	expectStatus(t, w, http.StatusOK)
+	secret := decode[enrollment](t, w).Secret
+
+	w = f.call(f.h.ConfirmLoginEnrollment, http.MethodPost, "/api/login/mfa/enroll/confirm", models.User{},
+		map[string]string{"challenge_token": c.ChallengeToken, "code": code(t, secret, 0)}, nil)
+	expectStatus(t, w, http.StatusOK)
+	if err := f.authenticate(decode[tokenPair](t, w).Token); err != nil {
+		t.Fatalf("token from enrollment rejected: %v", err)
+	}
+
+	manager, _ := f.store.GetUser(f.manager.ID)
+	w = f.call(f.h.DisableTOTP, http.MethodDelete, "/api/mfa/totp", manager, map[string]string{"code": code(t, secret, 1)}, nil)
+	expectStatus(t, w, http.StatusForbidden)
+}
+
+func TestManagerResetsMFA(t *testing.T) {
+	f := newFixture(t)
+	f.enroll(f.employee1)
+	token := f.accessToken(f.employee1)
+
+	w := f.call(f.h.ResetUserMFA, http.MethodPost, "/api/users/x/mfa/reset", f.manager, nil, map[string]string{"id": fmt.Sprint(f.employee1.ID)})
+	expectStatus(t, w, http.StatusOK)
+	if err := f.authenticate(token); err == nil {
+		t.Fatal("expected sessions to end with the reset")
+	}
+
+	user, _ := f.store.GetUser(f.employee1.ID)
+	if user.TOTPEnabled || user.TOTPSecret != "" {
+		t.Fatal("expected TOTP to be cleared")
+	}
+	if c := f.passwordLogin(f.employee1); c.MFARequired || c.ChallengeToken != "" {
+		t.Fatalf("expected a plain login after reset, got %+v", c)
+	}
+}
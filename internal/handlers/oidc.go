@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/oidc"
+	"gearguard/internal/policy"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCFlowTTL is how long a user has to finish signing in at the identity provider
+const OIDCFlowTTL = 10 * time.Minute
+
+// errIdentityMismatch means the email belongs to an account linked to another identity
+var errIdentityMismatch = errors.New("account linked to a different identity")
+
+// errEmailUnverified means the identity isn't linked yet and its email can't be trusted to find or create an account
+var errEmailUnverified = errors.New("email not verified by the identity provider")
+
+// ssoEnabled answers 404 itself when single sign-on isn't configured
+func (h *Handler) ssoEnabled(w http.ResponseWriter) bool {
+	if h.OIDC == nil {
+		utils.RespondError(w, http.StatusNotFound, "Single sign-on is not configured")
+		return false
+	}
+	return true
+}
+
+// GetOIDCConfig tells the login page whether to offer single sign-on
+func (h *Handler) GetOIDCConfig(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"enabled": false})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"enabled": true, "name": h.OIDC.Name})
+}
+
+// StartOIDCLogin begins a sign-in at the identity provider. The browser goes
+// to authorization_url and keeps flow_token until the provider sends it back.
+func (h *Handler) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoEnabled(w) {
+		return
+	}
+	state, errState := oidc.RandomString(16)
+	nonce, errNonce := oidc.RandomString(16)
+	verifier, challenge, errPKCE := oidc.NewPKCE()
+	if errState != nil || errNonce != nil || errPKCE != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	authURL, err := h.OIDC.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		utils.RespondError(w, http.StatusBadGateway, "Identity provider is unavailable")
+		return
+	}
+	now := time.Now()
+	flow, err := utils.SignToken(&utils.OIDCFlowClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCFlowTTL)),
+		},
+	})
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"authorization_url": authURL, "flow_token": flow})
+}
+
+// FinishOIDCLogin redeems the provider's authorization code, finds or creates
+// the matching user and signs them in like Login does
+func (h *Handler) FinishOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoEnabled(w) {
+		return
+	}
+	var input struct {
+		Code      string `json:"code"`
+		State     string `json:"state"`
+		FlowToken string `json:"flow_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	flow := &utils.OIDCFlowClaims{}
+	if err := utils.ParseToken(input.FlowToken, flow); err != nil || flow.State == "" ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(input.State)) != 1 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid or expired sign-in attempt")
+		return
+	}
+
+	identity, err := h.OIDC.Exchange(r.Context(), input.Code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		utils.RespondError(w, http.StatusUnauthorized, "Single sign-on failed")
+		return
+	}
+	user, err := h.ssoUser(identity)
+	if errors.Is(err, errEmailUnverified) {
+		utils.RespondError(w, http.StatusForbidden, "The identity provider did not confirm an email address")
+		return
+	}
+	if errors.Is(err, errIdentityMismatch) {
+		utils.RespondError(w, http.StatusConflict, "This account is linked to a different single sign-on identity")
+		return
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.Disabled {
+		utils.RespondError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+	// The provider's own second factor isn't visible to us, so the same rules apply
+	if user.TOTPEnabled || policy.MFARequired(user.Role) {
+		respondChallenge(w, user)
+		return
+	}
+
+	tokens, err := h.issueTokens(user, "", r)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, tokens)
+}
+
+// ssoUser returns the user for identity: the one already linked to its
+// subject, else the one with its email (linking it), else a new one. Role and
+// team follow the provider's groups whenever mappings are configured.
+func (h *Handler) ssoUser(identity oidc.Identity) (models.User, error) {
+	user, err := h.Users.FindUserByOIDCSubject(identity.Subject)
+	if err != nil {
+		// Accounts are only matched or created by an email the provider vouches for
+		if identity.Email == "" || !identity.EmailVerified {
+			return user, errEmailUnverified
+		}
+		user, err = h.Users.FindUserByEmail(identity.Email)
+		if err == nil && user.OIDCSubject != "" {
+			return user, errIdentityMismatch
+		}
+	}
+	created := err != nil
+	if created {
+		// No password: the account can only sign in through the provider until
+		// someone sets one with a reset
+		user = models.User{Email: identity.Email, Role: models.RoleEmployee}
+	}
+	before := user
+
+	user.OIDCSubject = identity.Subject
+	if user.Name == "" {
+		user.Name = identity.Name
+	}
+	if user.Name == "" {
+		user.Name = identity.Email
+	}
+	if identity.HasGroups && len(h.OIDC.RoleGroups) > 0 {
+		role, ok := h.OIDC.RoleFor(identity.Groups)
+		if !ok {
+			role = models.RoleEmployee
+		}
+		user.Role = role
+	}
+	if identity.HasGroups && len(h.OIDC.TeamGroups) > 0 {
+		user.TeamID = nil
+		if name, ok := h.OIDC.TeamFor(identity.Groups); ok {
+			team, err := h.Teams.FindTeamByName(name)
+			if err != nil {
+				log.Printf("OIDC: team %q mapped from groups does not exist", name)
+				user.TeamID = before.TeamID
+			} else {
+				user.TeamID = &team.ID
+			}
+		}
+	}
+
+	if created {
+		if err := h.Users.CreateUser(&user); err != nil {
+			return user, err
+		}
+		h.Audit(0, services.EntityUser, user.ID, models.AuditCreate, nil, user)
+		return user, nil
+	}
+	if user.OIDCSubject != before.OIDCSubject || user.Name != before.Name || user.Role != before.Role || !sameID(user.TeamID, before.TeamID) {
+		if err := h.Users.SaveUser(&user); err != nil {
+			return user, err
+		}
+		h.Audit(0, services.EntityUser, user.ID, models.AuditUpdate, before, user)
+	}
+	return user, nil
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
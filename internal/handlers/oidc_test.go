@@ -0,0 +1,177 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gearguard/internal/models"
+	"gearguard/internal/oidc"
+	"gearguard/internal/oidc/oidctest"
+)
+
+// withSSO points the handler at a mock identity provider
+func (f *fixture) withSSO(users ...oidctest.User) *oidctest.Server {
+	f.t.Helper()
+	idp, err := oidctest.NewServer("gearguard", "s3cret", users...)
+	f.must(err)
+	f.t.Cleanup(idp.Close)
+	f.h.OIDC = oidc.NewProvider(oidc.Config{
+		Issuer:       idp.URL,
+		ClientID:     "gearguard",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://app.test/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+		RoleGroups:   map[string]models.Role{"gg-managers": models.RoleManager, "gg-techs": models.RoleTechnician},
+		TeamGroups:   []oidc.TeamGroup{{Group: "gg-mechanical", Team: "Mechanical Team"}},
+	})
+	return idp
+}
+
+// ssoRedirect starts a sign-in and has the provider log in as email, returning
+// the callback query and the flow token
+func (f *fixture) ssoRedirect(email string) (url.Values, string) {
+	f.t.Helper()
+	w := f.call(f.h.StartOIDCLogin, http.MethodGet, "/api/oidc/login", models.User{}, nil, nil)
+	expectStatus(f.t, w, http.StatusOK)
+	start := decode[map[string]string](f.t, w)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(start["authorization_url"] + "&login_hint=" + url.QueryEscape(email))
+	f.must(err)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		f.t.Fatalf("provider did not redirect: %s", resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	f.must(err)
+	return location.Query(), start["flow_token"]
+}
+
+func (f *fixture) finishSSO(query url.Values, flowToken string) *httptest.ResponseRecorder {
+	return f.call(f.h.FinishOIDCLogin, http.MethodPost, "/api/oidc/callback", models.User{},
+		map[string]string{"code": query.Get("code"), "state": query.Get("state"), "flow_token": flowToken}, nil)
+}
+
+func (f *fixture) sso(email string) *httptest.ResponseRecorder {
+	f.t.Helper()
+	query, flow := f.ssoRedirect(email)
+	return f.finishSSO(query, flow)
+}
+
+func TestSSOProvisionsUserFromGroups(t *testing.T) {
+	f := newFixture(t)
+	idp := f.withSSO(oidctest.User{Subject: "idp-42", Email: "sam@example.com", Name: "Sam Sso", Groups: []string{"gg-techs", "gg-mechanical"}})
+
+	w := f.sso("sam@example.com")
+	expectStatus(t, w, http.StatusOK)
+	tokens := decode[tokenPair](t, w)
+	if err := f.authenticate(tokens.Token); err != nil {
+		t.Fatalf("SSO access token rejected: %v", err)
+	}
+
+	user, err := f.store.FindUserByEmail("sam@example.com")
+	f.must(err)
+	if user.Role != models.RoleTechnician || user.TeamID == nil || *user.TeamID != f.team.ID || user.Name != "Sam Sso" {
+		t.Fatalf("provisioned user does not match the identity: %+v", user)
+	}
+	if user.Password != "" {
+		t.Fatal("provisioned user should have no password")
+	}
+
+	// Group changes at the provider follow on the next sign-in, to the same account
+	idp.Users[0].Groups = []string{"gg-managers"}
+	expectStatus(t, f.sso("sam@example.com"), http.StatusOK)
+	again, err := f.store.GetUser(user.ID)
+	f.must(err)
+	if again.Role != models.RoleManager || again.TeamID != nil {
+		t.Fatalf("expected a team-less Manager after regrouping, got %+v", again)
+	}
+	if managers, _ := f.store.CountUsersByRole(models.RoleManager); managers != 2 {
+		t.Fatalf("expected the existing account to be updated, found %d managers", managers)
+	}
+}
+
+func TestSSOLinksExistingAccountByEmail(t *testing.T) {
+	f := newFixture(t)
+	idp := f.withSSO(oidctest.User{Subject: "idp-tom", Email: f.tech1.Email, Name: "Thomas", Groups: []string{"gg-techs"}})
+
+	expectStatus(t, f.sso(f.tech1.Email), http.StatusOK)
+	linked, err := f.store.FindUserByOIDCSubject("idp-tom")
+	f.must(err)
+	if linked.ID != f.tech1.ID || linked.Name != f.tech1.Name {
+		t.Fatalf("expected tech1 to be linked unchanged, got %+v", linked)
+	}
+
+	// Another identity claiming the same email can't take the account over
+	idp.Users = append(idp.Users, oidctest.User{Subject: "idp-imposter", Email: f.tech1.Email})
+	query, flow := f.ssoRedirect("idp-imposter")
+	expectStatus(t, f.finishSSO(query, flow), http.StatusConflict)
+}
+
+func TestSSOLinksByEmailOnlyWhenVerified(t *testing.T) {
+	f := newFixture(t)
+	idp := f.withSSO(
+		oidctest.User{Subject: "idp-missing", Email: f.tech1.Email, EmailVerified: oidctest.OmitClaim},
+		oidctest.User{Subject: "idp-false", Email: "new@example.com", EmailVerified: false},
+		oidctest.User{Subject: "idp-string-false", Email: f.tech2.Email, EmailVerified: "false"},
+	)
+
+	// No claim, or anything but true, is not a verified email: no account is
+	// linked nor created from it
+	for _, subject := range []string{"idp-missing", "idp-false", "idp-string-false"} {
+		query, flow := f.ssoRedirect(subject)
+		expectStatus(t, f.finishSSO(query, flow), http.StatusForbidden)
+		if _, err := f.store.FindUserByOIDCSubject(subject); err == nil {
+			t.Fatalf("%s: expected no account to be linked", subject)
+		}
+	}
+	if _, err := f.store.FindUserByEmail("new@example.com"); err == nil {
+		t.Fatal("expected no account to be created for an unverified email")
+	}
+
+	// Some providers send the claim as a string
+	idp.Users[0].EmailVerified = "true"
+	query, flow := f.ssoRedirect("idp-missing")
+	expectStatus(t, f.finishSSO(query, flow), http.StatusOK)
+	linked, err := f.store.FindUserByOIDCSubject("idp-missing")
+	f.must(err)
+	if linked.ID != f.tech1.ID {
+		t.Fatalf("expected tech1 to be linked, got %+v", linked)
+	}
+
+	// Once linked, the subject alone identifies the account
+	idp.Users[0].EmailVerified = oidctest.OmitClaim
+	query, flow = f.ssoRedirect("idp-missing")
+	expectStatus(t, f.finishSSO(query, flow), http.StatusOK)
+}
+
+func TestSSORejectsForgedOrReplayedCallbacks(t *testing.T) {
+	f := newFixture(t)
+	f.withSSO(oidctest.User{Subject: "idp-1", Email: "one@example.com", Name: "One"})
+
+	query, flow := f.ssoRedirect("one@example.com")
+	forged := url.Values{"code": {query.Get("code")}, "state": {"attacker-state"}}
+	expectStatus(t, f.finishSSO(forged, flow), http.StatusBadRequest)
+
+	// A flow token from another attempt doesn't match this state either
+	_, otherFlow := f.ssoRedirect("one@example.com")
+	expectStatus(t, f.finishSSO(query, otherFlow), http.StatusBadRequest)
+
+	expectStatus(t, f.finishSSO(query, flow), http.StatusOK)
+	expectStatus(t, f.finishSSO(query, flow), http.StatusUnauthorized)
+}
+
+func TestSSODisabledUserAndNoProvider(t *testing.T) {
+	f := newFixture(t)
+	w := f.call(f.h.StartOIDCLogin, http.MethodGet, "/api/oidc/login", models.User{}, nil, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	f.withSSO(oidctest.User{Subject: "idp-ed", Email: f.employee2.Email})
+	disabled := f.employee2
+	disabled.Disabled = true
+	f.must(f.store.SaveUser(&disabled))
+	expectStatus(t, f.sso(f.employee2.Email), http.StatusForbidden)
+}
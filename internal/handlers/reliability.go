@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+)
+
+// GetReliability reports MTBF, MTTR and availability grouped by
+// ?group_by=equipment|category|department|team over ?from/?to (YYYY-MM-DD,
+// default last 90 days). ?limit returns only the N least available groups.
+func GetReliability(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = services.GroupByEquipment
+	case services.GroupByEquipment, services.GroupByCategory, services.GroupByDepartment, services.GroupByTeam:
+	default:
+		utils.RespondError(w, http.StatusBadRequest, "group_by must be equipment, category, department or team")
+		return
+	}
+
+	to := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -90)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+			return
+		}
+		to = parsed.AddDate(0, 0, 1) // Inclusive
+	}
+	if !from.Before(to) {
+		utils.RespondError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	var equipment []models.Equipment
+	if result := store.ScopeEquipment(database.DB, user).Find(&equipment); result.Error != nil {
+		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+
+	equipmentIDs := make([]uint, 0, len(equipment))
+	for _, e := range equipment {
+		equipmentIDs = append(equipmentIDs, e.ID)
+	}
+
+	var requests []models.MaintenanceRequest
+	if len(equipmentIDs) > 0 {
+		result := database.DB.
+			Where("type = ? AND equipment_id IN ? AND created_at >= ? AND created_at < ?", models.TypeCorrective, equipmentIDs, from, to).
+			Find(&requests)
+		if result.Error != nil {
+			utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+			return
+		}
+	}
+
+	var teams []models.MaintenanceTeam
+	database.DB.Find(&teams)
+	teamNames := map[uint]string{}
+	for _, t := range teams {
+		teamNames[t.ID] = t.Name
+	}
+
+	// Metrics cover elapsed time only; a period ending in the future stops at now
+	end := to
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+
+	stats := services.ComputeReliability(equipment, teamNames, requests, groupBy, from, end)
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit < len(stats) {
+		stats = stats[:limit]
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"group_by": groupBy,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"groups":   stats,
+	})
+}
@@ -2,25 +2,43 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
-	"gearguard/internal/database"
 	"gearguard/internal/models"
 	"gearguard/internal/services"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
 
 	"github.com/gorilla/mux"
 )
 
+// requestInput is what clients may set when opening a request; everything
+// else (status, team, costs, parts, ...) is derived or recorded later
+type requestInput struct {
+	Subject       string             `json:"subject"`
+	Type          models.RequestType `json:"type"`
+	EquipmentID   uint               `json:"equipment_id"`
+	ScheduledDate *time.Time         `json:"scheduled_date"`
+	DurationHours float64            `json:"duration_hours"`
+}
+
 // CreateRequest creates a new maintenance request with auto-fill logic
-func CreateRequest(w http.ResponseWriter, r *http.Request) {
-	var req models.MaintenanceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+func (h *Handler) CreateRequest(w http.ResponseWriter, r *http.Request) {
+	var input requestInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	req := models.MaintenanceRequest{
+		Subject:       input.Subject,
+		Type:          input.Type,
+		EquipmentID:   input.EquipmentID,
+		ScheduledDate: input.ScheduledDate,
+		DurationHours: input.DurationHours,
+	}
 
 	// Get User ID from Context
 	userID, ok := r.Context().Value(utils.UserIDKey).(uint)
@@ -29,11 +47,15 @@ func CreateRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch Equipment to Auto-Fill Team
-	var equipment models.Equipment
-	if result := database.DB.First(&equipment, req.EquipmentID); result.Error != nil {
+	equipment, err := h.Equipment.GetEquipment(req.EquipmentID)
+	if err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "Invalid Equipment ID")
 		return
 	}
+	if equipment.ArchivedAt != nil {
+		utils.RespondError(w, http.StatusConflict, "Equipment is archived")
+		return
+	}
 
 	// BUSINESS RULE: Breakdown (Corrective) requests can only be made by the assigned Employee
 	if req.Type == models.TypeCorrective {
@@ -52,56 +74,54 @@ func CreateRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Auto-Fill Logic: Assign Team from Equipment
-	req.TeamID = equipment.MaintenanceTeamID
-	req.Status = models.StatusNew // Default status
-
-	// Auto-Assign Technician if default exists on equipment
-	if equipment.DefaultTechnicianID != nil {
-		req.TechnicianID = equipment.DefaultTechnicianID
-	}
-
-	if result := database.DB.Create(&req); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	services.PrepareRequest(&req, equipment)
+	if err := h.Requests.CreateRequest(&req); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.AnnounceRequest(req, equipment)
+
+	utils.RespondJSON(w, http.StatusCreated, req)
+}
+
+// AnnounceRequest records the creation of a saved request, notifies its
+// creator and technician, streams it to connected clients and queues its
+// webhooks, whether it was reported, opened by a meter rule or generated from
+// a schedule
+func (h *Handler) AnnounceRequest(req models.MaintenanceRequest, equipment models.Equipment) {
+	h.Audit(req.CreatedByID, services.EntityRequest, req.ID, models.AuditCreate, nil, req)
 
 	// --- Email Notification Logic ---
-	// 1. Fetch Creator Email
-	var creator models.User
-	if req.CreatedByID != 0 {
-		database.DB.First(&creator, req.CreatedByID)
+	var creatorEmail, techEmail string
+	if creator, err := h.Users.GetUser(req.CreatedByID); err == nil {
+		creatorEmail = creator.Email
 	}
-
-	// 2. Fetch Technician Email (if assigned)
-	var techEmail string
 	if req.TechnicianID != nil {
-		var tech models.User
-		database.DB.First(&tech, *req.TechnicianID)
-		techEmail = tech.Email
+		if tech, err := h.Users.GetUser(*req.TechnicianID); err == nil {
+			techEmail = tech.Email
+		}
 	}
+	services.SendNewRequestNotification(techEmail, creatorEmail, req.Subject, equipment.Name)
 
-	// 3. Send Emails (Async)
-	services.SendNewRequestNotification(techEmail, creator.Email, req.Subject, equipment.Name)
-
-	utils.RespondJSON(w, http.StatusCreated, req)
+	h.publishRequest(models.AuditCreate, req)
+	h.Webhooks.Enqueue(models.WebhookRequestCreated, req)
 }
 
 // UpdateRequest updates a request and handles Scrap logic
-func UpdateRequest(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateRequest(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
 	// Get User from Context
 	userID, _ := r.Context().Value(utils.UserIDKey).(uint)
-	var user models.User
-	database.DB.First(&user, userID)
+	user, _ := h.Users.GetUser(userID)
 
-	var req models.MaintenanceRequest
-	if result := database.DB.Preload("Equipment").First(&req, id); result.Error != nil {
+	req, err := h.Requests.GetRequest(uint(id))
+	if err != nil || !store.RequestVisible(req, req.Equipment, user) {
 		utils.RespondError(w, http.StatusNotFound, "Request not found")
 		return
 	}
+	before := req
 
 	// Decode update payload
 	var updateData models.MaintenanceRequest
@@ -112,19 +132,20 @@ func UpdateRequest(w http.ResponseWriter, r *http.Request) {
 
 	// BUSINESS LOGIC: Self-assignment if moving from New to In Progress
 	if req.Status == models.StatusNew && updateData.Status == models.StatusInProgress {
-		if req.TechnicianID == nil && user.Role == "Technician" {
+		if req.TechnicianID == nil && user.Role == models.RoleTechnician {
 			// Technician is picking up an unassigned ticket
 			req.TechnicianID = &userID
 		}
 	}
 
 	// RBAC: Only assigned technician or Manager can update status beyond "New"
-	if user.Role == "Technician" && req.TechnicianID != nil && *req.TechnicianID != userID {
+	if user.Role == models.RoleTechnician && req.TechnicianID != nil && *req.TechnicianID != userID {
 		utils.RespondError(w, http.StatusForbidden, "You can only update requests assigned to you")
 		return
 	}
 
 	// Apply updates
+	previousStatus := req.Status
 	if updateData.Status != "" {
 		req.Status = updateData.Status
 	}
@@ -138,24 +159,60 @@ func UpdateRequest(w http.ResponseWriter, r *http.Request) {
 		req.ScheduledDate = updateData.ScheduledDate
 	}
 	
-	// Scrap Logic: If moving to Scrap, mark equipment as unusable
-	if req.Status == models.StatusScrap {
-		req.Equipment.IsUsable = false
-		if result := database.DB.Save(&req.Equipment); result.Error == nil {
-			println("DEBUG: Equipment", req.Equipment.ID, "marked as SCRAPPED (Unusable)")
+	// WORKFLOW: Only transitions defined in the workflow are allowed
+	if err := services.CurrentWorkflow.CheckTransition(previousStatus, &req, user.Role); err != nil {
+		utils.RespondJSON(w, err.Status, err)
+		return
+	}
+
+	// Track when the repair was completed (for reliability metrics); reopening clears it
+	if req.Status != previousStatus {
+		if req.Status == models.StatusRepaired {
+			now := time.Now()
+			req.RepairedAt = &now
+		} else if previousStatus == models.StatusRepaired {
+			req.RepairedAt = nil
 		}
 	}
 
-	if result := database.DB.Save(&req); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	// INVENTORY: Parts consumed are recorded when the request moves to Repaired
+	if len(updateData.PartsUsed) > 0 && (req.Status != models.StatusRepaired || previousStatus == models.StatusRepaired) {
+		utils.RespondError(w, http.StatusUnprocessableEntity, "Parts can only be recorded when moving a request to Repaired")
 		return
 	}
 
+	equipmentBefore := req.Equipment
+
+	// Scrap Logic: moving to Scrap marks the equipment unusable along with the request
+	err = h.Requests.UpdateRequest(&req, updateData.PartsUsed, userID)
+	if errors.Is(err, services.ErrInsufficientStock) {
+		utils.RespondError(w, http.StatusConflict, err.Error())
+		return
+	} else if errors.Is(err, services.ErrInvalidPartUsage) {
+		utils.RespondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if req.Status == models.StatusScrap && equipmentBefore.IsUsable {
+		equipment := equipmentBefore
+		equipment.IsUsable = false
+		h.Audit(userID, services.EntityEquipment, equipment.ID, models.AuditUpdate, equipmentBefore, equipment)
+		h.publishEquipment(models.AuditUpdate, equipmentBefore, equipment)
+		h.Webhooks.Enqueue(models.WebhookEquipmentScrapped, map[string]interface{}{"equipment": equipment, "request_id": req.ID})
+	}
+	h.Audit(userID, services.EntityRequest, req.ID, models.AuditUpdate, before, req)
+	h.publishRequest(models.AuditUpdate, req)
+	if req.Status != previousStatus {
+		h.Webhooks.Enqueue(models.WebhookRequestStatusChanged, map[string]interface{}{"request": req, "previous_status": previousStatus})
+	}
+
 	utils.RespondJSON(w, http.StatusOK, req)
 }
 
-// GetRequests retrieves requests with optional filtering
-func GetRequests(w http.ResponseWriter, r *http.Request) {
+// GetRequests retrieves requests with optional filtering, sorting and paging (see listQuery)
+func (h *Handler) GetRequests(w http.ResponseWriter, r *http.Request) {
 	// Get User ID from Context
 	userID, ok := r.Context().Value(utils.UserIDKey).(uint)
 	if !ok {
@@ -164,71 +221,69 @@ func GetRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch User to check Role
-	var user models.User
-	if result := database.DB.First(&user, userID); result.Error != nil {
-		println("DEBUG: User not found for ID:", userID)
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
 		utils.RespondError(w, http.StatusUnauthorized, "User not found")
 		return
 	}
 
-	println("DEBUG: Request fetch for User:", user.Name, "Role:", user.Role, "ID:", user.ID)
-
-	query := database.DB.Preload("Equipment").Preload("Team").Preload("Technician")
+	list, err := parseListQuery(r, store.RequestSorting)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	list.capUnpaged(maxUnpagedRequests)
+	q := r.URL.Query()
 
 	// ROLE BASED ACCESS CONTROL
-	if user.Role == "Employee" {
-		// Employees see requests they created OR requests for equipment they own
-		var equipmentIDs []uint
-		database.DB.Model(&models.Equipment{}).Where("employee_id = ?", userID).Pluck("id", &equipmentIDs)
-		
-		if len(equipmentIDs) > 0 {
-			query = query.Where("created_by_id = ? OR equipment_id IN ?", userID, equipmentIDs)
-		} else {
-			query = query.Where("created_by_id = ?", userID)
-		}
-	} else if user.Role == "Technician" {
-		// Technicians see ONLY requests for equipment where they are the Default Technician
-		var equipmentIDs []uint
-		database.DB.Model(&models.Equipment{}).Where("default_technician_id = ?", userID).Pluck("id", &equipmentIDs)
-		
-		if len(equipmentIDs) > 0 {
-			query = query.Where("equipment_id IN ?", equipmentIDs)
-		} else {
-			// If no equipment assigned, they see nothing
-			query = query.Where("1 = 0")
-		}
-	}
-	// Managers see ALL requests (no filter added)
+	filter := store.RequestFilter{VisibleTo: &user, Page: list.Page}
 
-	// Filter by Status (Kanban columns)
-	status := r.URL.Query().Get("status")
-	if status != "" {
-		query = query.Where("status = ?", status)
+	// Filter by Status (Kanban columns), one or several
+	for _, status := range queryValues(q, "status") {
+		filter.Statuses = append(filter.Statuses, models.RequestStatus(status))
 	}
 
 	// Filter by Type (e.g., Preventive for Calendar)
-	reqType := r.URL.Query().Get("type")
-	if reqType != "" {
-		query = query.Where("type = ?", reqType)
+	filter.Type = models.RequestType(q.Get("type"))
+
+	// Filter by technician, team and equipment
+	var errTechnician, errTeam, errEquipment error
+	filter.TechnicianID, errTechnician = queryID(q, "technician_id")
+	filter.TeamID, errTeam = queryID(q, "team_id")
+	filter.EquipmentID, errEquipment = queryID(q, "equipment_id")
+	if err := errors.Join(errTechnician, errTeam, errEquipment); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Filter by creation and schedule date ranges
+	filter.CreatedSince, filter.CreatedBefore, err = queryRange(q, "created")
+	if err == nil {
+		filter.ScheduledFrom, filter.ScheduledBefore, err = queryRange(q, "scheduled")
+	}
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 	
 	// Filter by Date (Calendar View) - simplified for "on this date"
-	dateStr := r.URL.Query().Get("date")
+	dateStr := q.Get("date")
 	if dateStr != "" {
 		// Assuming dateStr is YYYY-MM-DD
 		parsedDate, err := time.Parse("2006-01-02", dateStr)
 		if err == nil {
 			// Find requests scheduled for this day (ignoring time)
 			nextDay := parsedDate.Add(24 * time.Hour)
-			query = query.Where("scheduled_date >= ? AND scheduled_date < ?", parsedDate, nextDay)
+			filter.ScheduledFrom = &parsedDate
+			filter.ScheduledBefore = &nextDay
 		}
 	}
 
-	var requests []models.MaintenanceRequest
-	if result := query.Find(&requests); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	requests, err := h.Requests.ListRequests(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, requests)
+	list.respond(w, requests, func() (int64, error) { return h.Requests.CountRequests(filter) })
 }
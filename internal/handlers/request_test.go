@@ -0,0 +1,375 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+)
+
+func TestCreateRequestAutoFillsFromEquipment(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.employee1, map[string]interface{}{
+		"subject":      "Drill is smoking",
+		"type":         models.TypeCorrective,
+		"status":       models.StatusRepaired, // Ignored: new requests always start in the initial state
+		"equipment_id": f.drill.ID,
+	}, nil)
+	expectStatus(t, w, http.StatusCreated)
+
+	req := decode[models.MaintenanceRequest](t, w)
+	if req.ID == 0 {
+		t.Fatal("expected the request to be stored")
+	}
+	if req.Status != models.StatusNew {
+		t.Errorf("status = %q, want %q", req.Status, models.StatusNew)
+	}
+	if req.TeamID != f.team.ID {
+		t.Errorf("team = %d, want %d", req.TeamID, f.team.ID)
+	}
+	if req.TechnicianID == nil || *req.TechnicianID != f.tech1.ID {
+		t.Errorf("technician = %v, want %d", req.TechnicianID, f.tech1.ID)
+	}
+	if req.CreatedByID != f.employee1.ID {
+		t.Errorf("created by = %d, want %d", req.CreatedByID, f.employee1.ID)
+	}
+
+	stored, err := f.store.GetRequest(req.ID)
+	if err != nil || stored.Subject != "Drill is smoking" {
+		t.Fatalf("stored request = %+v, %v", stored, err)
+	}
+	if len(f.audit) != 1 || f.audit[0].Action != models.AuditCreate || f.audit[0].EntityID != req.ID {
+		t.Errorf("audit = %+v, want one create of request %d", f.audit, req.ID)
+	}
+}
+
+func TestCreateRequestCorrectiveOnlyByOwner(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.employee2, map[string]interface{}{
+		"subject":      "Not my drill",
+		"type":         models.TypeCorrective,
+		"equipment_id": f.drill.ID,
+	}, nil)
+	expectStatus(t, w, http.StatusForbidden)
+
+	// Preventive work and unowned equipment are open to anyone
+	w = f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.manager, map[string]interface{}{
+		"subject":      "Quarterly check",
+		"type":         models.TypePreventive,
+		"equipment_id": f.drill.ID,
+	}, nil)
+	expectStatus(t, w, http.StatusCreated)
+
+	w = f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.employee2, map[string]interface{}{
+		"subject":      "Lathe rattles",
+		"type":         models.TypeCorrective,
+		"equipment_id": f.lathe.ID,
+	}, nil)
+	expectStatus(t, w, http.StatusCreated)
+	if req := decode[models.MaintenanceRequest](t, w); req.TechnicianID != nil {
+		t.Errorf("technician = %d, want none for equipment without a default", *req.TechnicianID)
+	}
+}
+
+func TestCreateRequestRejectsUnknownEquipment(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.manager, map[string]interface{}{
+		"subject":      "Ghost",
+		"type":         models.TypePreventive,
+		"equipment_id": 999,
+	}, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func updateVars(req models.MaintenanceRequest) map[string]string {
+	return map[string]string{"id": fmt.Sprint(req.ID)}
+}
+
+func TestCreateRequestIgnoresRecordedFields(t *testing.T) {
+	f := newFixture(t)
+
+	// Parts are only consumed from stock when a request is repaired
+	w := f.call(f.h.CreateRequest, http.MethodPost, "/api/requests", f.manager, map[string]interface{}{
+		"subject":      "Free parts",
+		"type":         models.TypePreventive,
+		"equipment_id": f.lathe.ID,
+		"parts_used":   []map[string]interface{}{{"part_id": 1, "quantity": 5, "unit_cost": 0.01}},
+		// Costs are computed from labor, parts and vendor costs
+		"labor_rate":  1000,
+		"labor_cost":  5000,
+		"parts_cost":  5000,
+		"vendor_cost": 5000,
+		"total_cost":  15000,
+		// Reliability metrics and generated requests depend on these
+		"repaired_at":   "2020-01-01T00:00:00Z",
+		"schedule_id":   1,
+		"meter_rule_id": 1,
+	}, nil)
+	expectStatus(t, w, http.StatusCreated)
+
+	req := decode[models.MaintenanceRequest](t, w)
+	stored, err := f.store.GetRequest(req.ID)
+	f.must(err)
+	if len(req.PartsUsed) != 0 || len(stored.PartsUsed) != 0 {
+		t.Errorf("parts used = %+v, want none", stored.PartsUsed)
+	}
+	if stored.LaborRate != 0 || stored.LaborCost != 0 || stored.PartsCost != 0 || stored.VendorCost != 0 || stored.TotalCost != 0 {
+		t.Errorf("costs = %+v, want none recorded yet", stored)
+	}
+	if stored.RepairedAt != nil || stored.ScheduleID != nil || stored.MeterRuleID != nil {
+		t.Errorf("repaired at %v, schedule %v, meter rule %v; want none", stored.RepairedAt, stored.ScheduleID, stored.MeterRuleID)
+	}
+}
+
+func TestUpdateRequestTechnicianSelfAssigns(t *testing.T) {
+	f := newFixture(t)
+	req := f.request(f.press, f.employee2, models.StatusNew)
+	req.TechnicianID = nil
+	f.must(f.store.UpdateRequest(&req, nil, f.manager.ID))
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech2,
+		map[string]interface{}{"status": models.StatusInProgress}, updateVars(req))
+	expectStatus(t, w, http.StatusOK)
+
+	updated := decode[models.MaintenanceRequest](t, w)
+	if updated.Status != models.StatusInProgress {
+		t.Errorf("status = %q, want %q", updated.Status, models.StatusInProgress)
+	}
+	if updated.TechnicianID == nil || *updated.TechnicianID != f.tech2.ID {
+		t.Errorf("technician = %v, want %d", updated.TechnicianID, f.tech2.ID)
+	}
+	if len(f.audit) != 1 || f.audit[0].Action != models.AuditUpdate {
+		t.Errorf("audit = %+v, want one update", f.audit)
+	}
+}
+
+func TestUpdateRequestOnlyAssignedTechnician(t *testing.T) {
+	f := newFixture(t)
+	// On the press, which Tina maintains, but handed to Tom
+	req := f.request(f.press, f.employee2, models.StatusNew)
+	req.TechnicianID = &f.tech1.ID
+	f.must(f.store.UpdateRequest(&req, nil, f.manager.ID))
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech2,
+		map[string]interface{}{"status": models.StatusInProgress}, updateVars(req))
+	expectStatus(t, w, http.StatusForbidden)
+
+	stored, _ := f.store.GetRequest(req.ID)
+	if stored.Status != models.StatusNew {
+		t.Errorf("status = %q, want the request untouched", stored.Status)
+	}
+}
+
+func TestUpdateRequestNeedsVisibleRequest(t *testing.T) {
+	f := newFixture(t)
+	drill := f.request(f.drill, f.employee1, models.StatusNew)
+	lathe := f.request(f.lathe, f.manager, models.StatusNew)
+	update := map[string]interface{}{"status": models.StatusInProgress}
+
+	// Requests the caller can't see are answered like missing ones
+	for _, tc := range []struct {
+		as  models.User
+		req models.MaintenanceRequest
+	}{{f.employee2, drill}, {f.tech2, drill}, {f.tech1, lathe}, {f.employee1, lathe}} {
+		w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", tc.as, update, updateVars(tc.req))
+		expectStatus(t, w, http.StatusNotFound)
+	}
+	for _, req := range []models.MaintenanceRequest{drill, lathe} {
+		if stored, _ := f.store.GetRequest(req.ID); stored.Status != models.StatusNew {
+			t.Fatalf("expected request %d to be untouched, got %s", req.ID, stored.Status)
+		}
+	}
+	if len(f.audit) != 0 {
+		t.Errorf("audit = %+v, want nothing recorded", f.audit)
+	}
+}
+
+func TestUpdateRequestEnforcesWorkflow(t *testing.T) {
+	f := newFixture(t)
+	req := f.request(f.drill, f.employee1, models.StatusNew)
+
+	// New -> Repaired is not a transition
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech1,
+		map[string]interface{}{"status": models.StatusRepaired}, updateVars(req))
+	expectStatus(t, w, http.StatusConflict)
+	if e := decode[services.TransitionError](t, w); len(e.Allowed) == 0 {
+		t.Errorf("expected the allowed states to be listed, got %+v", e)
+	}
+
+	// Employees may not move requests
+	w = f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.employee1,
+		map[string]interface{}{"status": models.StatusInProgress}, updateVars(req))
+	expectStatus(t, w, http.StatusConflict)
+
+	// Repaired requires the duration
+	req = f.request(f.drill, f.employee1, models.StatusInProgress)
+	w = f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/2", f.tech1,
+		map[string]interface{}{"status": models.StatusRepaired}, updateVars(req))
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	w = f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/2", f.tech1,
+		map[string]interface{}{"status": models.StatusRepaired, "duration_hours": 1.5}, updateVars(req))
+	expectStatus(t, w, http.StatusOK)
+	if updated := decode[models.MaintenanceRequest](t, w); updated.RepairedAt == nil || updated.DurationHours != 1.5 {
+		t.Errorf("expected repair time and duration to be recorded, got %+v", updated)
+	}
+}
+
+func TestUpdateRequestPartsOnlyWhenRepairing(t *testing.T) {
+	f := newFixture(t)
+	req := f.request(f.drill, f.employee1, models.StatusNew)
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech1, map[string]interface{}{
+		"status":     models.StatusInProgress,
+		"parts_used": []map[string]interface{}{{"part_id": 1, "location": "Main", "quantity": 2}},
+	}, updateVars(req))
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
+
+func TestUpdateRequestScrapMarksEquipmentUnusable(t *testing.T) {
+	f := newFixture(t)
+	req := f.request(f.press, f.employee2, models.StatusInProgress)
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech2,
+		map[string]interface{}{"status": models.StatusScrap}, updateVars(req))
+	expectStatus(t, w, http.StatusOK)
+
+	press, _ := f.store.GetEquipment(f.press.ID)
+	if press.IsUsable {
+		t.Error("expected scrapped equipment to be unusable")
+	}
+	var equipmentAudited bool
+	for _, a := range f.audit {
+		if a.EntityType == services.EntityEquipment && a.EntityID == f.press.ID {
+			equipmentAudited = true
+		}
+	}
+	if !equipmentAudited {
+		t.Errorf("audit = %+v, want the equipment change recorded", f.audit)
+	}
+}
+
+// failingRequests is a request store whose updates fail, as on a lost connection
+type failingRequests struct{ store.RequestStore }
+
+func (failingRequests) UpdateRequest(*models.MaintenanceRequest, []models.PartUsage, uint) error {
+	return errors.New("connection reset")
+}
+
+func TestUpdateRequestFailedScrapLeavesEquipmentAlone(t *testing.T) {
+	f := newFixture(t)
+	req := f.request(f.press, f.employee2, models.StatusInProgress)
+	hook := f.createWebhook(newReceiver(t, http.StatusOK).URL, models.WebhookEquipmentScrapped)
+	f.audit = nil
+	f.h.Requests = failingRequests{f.store}
+	sub, _, _ := f.h.Events.Subscribe(f.manager, "")
+	defer sub.Close()
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/1", f.tech2,
+		map[string]interface{}{"status": models.StatusScrap}, updateVars(req))
+	expectStatus(t, w, http.StatusInternalServerError)
+
+	if press, _ := f.store.GetEquipment(f.press.ID); !press.IsUsable {
+		t.Error("expected the equipment to stay usable when the request isn't saved")
+	}
+	if len(f.audit) != 0 {
+		t.Errorf("audit = %+v, want nothing recorded", f.audit)
+	}
+	select {
+	case e := <-sub.Events:
+		t.Errorf("expected nothing published, got %s", e.Type)
+	default:
+	}
+	if log := f.deliveries(hook.Webhook.ID); len(log) != 0 {
+		t.Errorf("expected no webhook delivery, got %+v", log)
+	}
+}
+
+func TestUpdateRequestNotFound(t *testing.T) {
+	f := newFixture(t)
+
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/42", f.manager,
+		map[string]interface{}{"status": models.StatusInProgress}, map[string]string{"id": "42"})
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestGetRequestsRoleFiltering(t *testing.T) {
+	f := newFixture(t)
+	drillByOwner := f.request(f.drill, f.employee1, models.StatusNew)
+	pressByOwner := f.request(f.press, f.employee2, models.StatusNew)
+	latheByEmployee1 := f.request(f.lathe, f.employee1, models.StatusInProgress)
+	drillByManager := f.request(f.drill, f.manager, models.StatusInProgress)
+
+	cases := []struct {
+		name string
+		as   models.User
+		want []uint
+	}{
+		{"manager sees everything", f.manager, []uint{drillByOwner.ID, pressByOwner.ID, latheByEmployee1.ID, drillByManager.ID}},
+		{"employee sees created and owned", f.employee1, []uint{drillByOwner.ID, latheByEmployee1.ID, drillByManager.ID}},
+		{"other employee", f.employee2, []uint{pressByOwner.ID}},
+		{"technician sees default equipment", f.tech1, []uint{drillByOwner.ID, drillByManager.ID}},
+		{"technician without equipment", f.idleTech, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests", c.as, nil, nil)
+			expectStatus(t, w, http.StatusOK)
+			assertRequestIDs(t, decode[[]models.MaintenanceRequest](t, w), c.want)
+		})
+	}
+
+	t.Run("status filter keeps role scope", func(t *testing.T) {
+		w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests?status=In+Progress", f.employee1, nil, nil)
+		expectStatus(t, w, http.StatusOK)
+		assertRequestIDs(t, decode[[]models.MaintenanceRequest](t, w), []uint{latheByEmployee1.ID, drillByManager.ID})
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests", models.User{}, nil, nil)
+		expectStatus(t, w, http.StatusUnauthorized)
+	})
+}
+
+func TestGetRequestsByScheduledDate(t *testing.T) {
+	f := newFixture(t)
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{-time.Hour, 9 * time.Hour, 25 * time.Hour} {
+		req := f.request(f.drill, f.manager, models.StatusNew)
+		scheduled := day.Add(offset)
+		req.ScheduledDate = &scheduled
+		f.must(f.store.UpdateRequest(&req, nil, f.manager.ID))
+	}
+
+	w := f.call(f.h.GetRequests, http.MethodGet, "/api/requests?date=2025-03-14", f.manager, nil, nil)
+	expectStatus(t, w, http.StatusOK)
+	requests := decode[[]models.MaintenanceRequest](t, w)
+	if len(requests) != 1 || !requests[0].ScheduledDate.Equal(day.Add(9*time.Hour)) {
+		t.Errorf("requests = %+v, want only the one scheduled on 2025-03-14", requests)
+	}
+}
+
+func assertRequestIDs(t *testing.T, requests []models.MaintenanceRequest, want []uint) {
+	t.Helper()
+	got := []uint{}
+	for _, r := range requests {
+		got = append(got, r.ID)
+	}
+	if want == nil {
+		want = []uint{}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("request IDs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// scheduleFromVars loads the schedule in the URL, answering 404 when it doesn't
+// exist or its equipment isn't visible to user
+func (h *Handler) scheduleFromVars(w http.ResponseWriter, r *http.Request, user models.User) (models.MaintenanceSchedule, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	schedule, err := h.Schedules.GetSchedule(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Schedule not found")
+		return schedule, false
+	}
+	equipment, err := h.Equipment.GetEquipment(schedule.EquipmentID)
+	if err != nil || !store.EquipmentVisible(equipment, user) {
+		utils.RespondError(w, http.StatusNotFound, "Schedule not found")
+		return schedule, false
+	}
+	return schedule, true
+}
+
+// CreateSchedule creates a recurring preventive maintenance schedule
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.staffUser(w, r)
+	if !ok {
+		return
+	}
+
+	var schedule models.MaintenanceSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if schedule.Interval == 0 {
+		schedule.Interval = 1
+	}
+	if err := services.ValidateSchedule(&schedule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	equipment, err := h.Equipment.GetEquipment(schedule.EquipmentID)
+	if err != nil || !store.EquipmentVisible(equipment, user) {
+		utils.RespondError(w, http.StatusNotFound, "Equipment not found")
+		return
+	}
+
+	schedule.ID = 0
+	schedule.Equipment = models.Equipment{}
+	schedule.Active = true
+	schedule.GeneratedCount = 0
+	schedule.LastOccurrence = nil
+	schedule.CreatedByID = user.ID
+
+	if err := h.Schedules.CreateSchedule(&schedule); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusCreated, schedule)
+}
+
+// GetSchedules lists the schedules of equipment the user can see, optionally
+// filtered by equipment
+func (h *Handler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	filter := store.ScheduleFilter{VisibleTo: &user}
+	if equipmentID := r.URL.Query().Get("equipment_id"); equipmentID != "" {
+		id, err := strconv.ParseUint(equipmentID, 10, 64)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid Equipment ID")
+			return
+		}
+		filter.EquipmentID = uint(id)
+	}
+
+	schedules, err := h.Schedules.ListSchedules(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if schedules == nil {
+		schedules = []models.MaintenanceSchedule{}
+	}
+
+	utils.RespondJSON(w, http.StatusOK, schedules)
+}
+
+// UpdateSchedule changes the recurrence or pauses/resumes a schedule
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.staffUser(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, ok := h.scheduleFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Subject       *string                     `json:"subject"`
+		DurationHours *float64                    `json:"duration_hours"`
+		Frequency     *models.RecurrenceFrequency `json:"frequency"`
+		Interval      *int                        `json:"interval"`
+		Weekdays      *string                     `json:"weekdays"`
+		EndDate       *time.Time                  `json:"end_date"`
+		Count         *int                        `json:"count"`
+		Active        *bool                       `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Apply updates
+	if input.Subject != nil {
+		schedule.Subject = *input.Subject
+	}
+	if input.DurationHours != nil {
+		schedule.DurationHours = *input.DurationHours
+	}
+	if input.Frequency != nil {
+		schedule.Frequency = *input.Frequency
+	}
+	if input.Interval != nil {
+		schedule.Interval = *input.Interval
+	}
+	if input.Weekdays != nil {
+		schedule.Weekdays = *input.Weekdays
+	}
+	if input.EndDate != nil {
+		schedule.EndDate = input.EndDate
+	}
+	if input.Count != nil {
+		schedule.Count = *input.Count
+	}
+	if input.Active != nil {
+		schedule.Active = *input.Active
+	}
+
+	if err := services.ValidateSchedule(&schedule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.Schedules.SaveSchedule(&schedule); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, schedule)
+}
+
+// DeleteSchedule removes a schedule; requests already generated are kept
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.staffUser(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, ok := h.scheduleFromVars(w, r, user)
+	if !ok {
+		return
+	}
+	if err := h.Schedules.DeleteSchedule(schedule.ID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Schedule deleted"})
+}
+
+// GetScheduleOccurrences previews the upcoming occurrences of a schedule
+func (h *Handler) GetScheduleOccurrences(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, ok := h.scheduleFromVars(w, r, user)
+	if !ok {
+		return
+	}
+
+	until := time.Now().AddDate(0, 3, 0)
+	if untilStr := r.URL.Query().Get("until"); untilStr != "" {
+		parsed, err := time.Parse("2006-01-02", untilStr)
+		if err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "until must be YYYY-MM-DD")
+			return
+		}
+		until = parsed
+	}
+
+	utils.RespondJSON(w, http.StatusOK, services.Occurrences(schedule, until))
+}
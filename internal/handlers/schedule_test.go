@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+)
+
+// schedule seeds a daily schedule on equipment
+func (f *fixture) schedule(equipment models.Equipment) models.MaintenanceSchedule {
+	schedule := models.MaintenanceSchedule{Subject: "Lubricate " + equipment.Name, EquipmentID: equipment.ID,
+		Frequency: models.FrequencyDaily, Interval: 1, StartDate: time.Now(), Active: true, CreatedByID: f.manager.ID}
+	f.must(f.store.CreateSchedule(&schedule))
+	return schedule
+}
+
+func TestSchedulesRespectVisibility(t *testing.T) {
+	f := newFixture(t)
+	drill := f.schedule(f.drill)
+	f.schedule(f.press)
+	list := func(as models.User, query string) []models.MaintenanceSchedule {
+		t.Helper()
+		w := f.call(f.h.GetSchedules, http.MethodGet, "/api/schedules"+query, as, nil, nil)
+		expectStatus(t, w, http.StatusOK)
+		return decode[[]models.MaintenanceSchedule](t, w)
+	}
+
+	if got := list(f.manager, ""); len(got) != 2 {
+		t.Fatalf("expected both schedules for a manager, got %d", len(got))
+	}
+	if got := list(f.tech1, ""); len(got) != 1 || got[0].ID != drill.ID || got[0].Equipment.ID != f.drill.ID {
+		t.Fatalf("expected only the drill's schedule with its equipment, got %+v", got)
+	}
+	if got := list(f.tech1, fmt.Sprintf("?equipment_id=%d", f.press.ID)); len(got) != 0 {
+		t.Fatalf("expected filtering by hidden equipment to find nothing, got %+v", got)
+	}
+
+	vars := map[string]string{"id": fmt.Sprint(drill.ID)}
+	target := "/api/schedules/" + vars["id"]
+	expectStatus(t, f.call(f.h.GetScheduleOccurrences, http.MethodGet, target+"/occurrences", f.employee2, nil, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.GetScheduleOccurrences, http.MethodGet, target+"/occurrences", f.employee1, nil, vars), http.StatusOK)
+	expectStatus(t, f.call(f.h.UpdateSchedule, http.MethodPut, target, f.tech2, map[string]bool{"active": false}, vars), http.StatusNotFound)
+	expectStatus(t, f.call(f.h.DeleteSchedule, http.MethodDelete, target, f.tech2, nil, vars), http.StatusNotFound)
+
+	w := f.call(f.h.UpdateSchedule, http.MethodPut, target, f.tech1, map[string]bool{"active": false}, vars)
+	expectStatus(t, w, http.StatusOK)
+	if got, _ := f.store.GetSchedule(drill.ID); got.Active || got.Subject != drill.Subject {
+		t.Fatalf("expected only the schedule to be paused, got %+v", got)
+	}
+	expectStatus(t, f.call(f.h.DeleteSchedule, http.MethodDelete, target, f.tech1, nil, vars), http.StatusOK)
+	if _, err := f.store.GetSchedule(drill.ID); err == nil {
+		t.Fatal("expected the schedule to be deleted")
+	}
+}
+
+func TestCreateScheduleNeedsVisibleEquipment(t *testing.T) {
+	f := newFixture(t)
+	create := func(as models.User, equipment models.Equipment) int {
+		body := map[string]interface{}{"subject": "Lubricate", "equipment_id": equipment.ID, "frequency": models.FrequencyWeekly,
+			"start_date": time.Now().Format(time.RFC3339)}
+		return f.call(f.h.CreateSchedule, http.MethodPost, "/api/schedules", as, body, nil).Code
+	}
+
+	if code := create(f.employee1, f.drill); code != http.StatusForbidden {
+		t.Fatalf("expected employees to be refused, got %d", code)
+	}
+	if code := create(f.tech1, f.press); code != http.StatusNotFound {
+		t.Fatalf("expected equipment the technician doesn't maintain to be hidden, got %d", code)
+	}
+	if code := create(f.tech1, f.drill); code != http.StatusCreated {
+		t.Fatalf("expected the drill's technician to schedule it, got %d", code)
+	}
+	schedules, _ := f.store.ListSchedules(store.ScheduleFilter{})
+	if len(schedules) != 1 || !schedules[0].Active || schedules[0].CreatedByID != f.tech1.ID {
+		t.Fatalf("expected one active schedule by the technician, got %+v", schedules)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// AccessTokenTTL is short so revocation only has to be checked server-side
+	// for the session, not kept in a token blocklist
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL bounds how long a session survives without being used
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashRefreshToken is how refresh tokens are stored and looked up
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken generates the next refresh token of a session, returning the
+// token to hand out and the record to store
+func newRefreshToken(user models.User, sessionID string, r *http.Request, now time.Time) (string, models.RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return token, models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hashRefreshToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		UserAgent: r.UserAgent(),
+	}, nil
+}
+
+// tokenResponse signs an access token for the session and bundles it with the refresh token
+func tokenResponse(user models.User, sessionID, refreshToken string, now time.Time) (map[string]interface{}, error) {
+	claims := &utils.Claims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	accessToken, err := utils.SignToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(AccessTokenTTL.Seconds()),
+		"name":          user.Name,
+		"role":          user.Role,
+		"user_id":       user.ID,
+	}, nil
+}
+
+// issueTokens starts a new session for user and returns its first token pair
+func (h *Handler) issueTokens(user models.User, sessionID string, r *http.Request) (map[string]interface{}, error) {
+	if sessionID == "" {
+		var err error
+		if sessionID, err = randomToken(16); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	refreshToken, record, err := newRefreshToken(user, sessionID, r, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Tokens.CreateRefreshToken(&record); err != nil {
+		return nil, err
+	}
+	return tokenResponse(user, sessionID, refreshToken, now)
+}
+
+// Refresh exchanges a refresh token for a new access token and the next refresh
+// token of the session. Presenting a token that was already exchanged means it
+// leaked, so the whole session is revoked.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	old, err := h.Tokens.FindRefreshToken(hashRefreshToken(input.RefreshToken))
+	if err != nil {
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if old.RevokedAt != nil {
+		h.Tokens.RevokeSession(old.SessionID, now)
+		utils.RespondError(w, http.StatusUnauthorized, "Refresh token has been revoked")
+		return
+	}
+	if !old.ExpiresAt.After(now) {
+		utils.RespondError(w, http.StatusUnauthorized, "Refresh token has expired")
+		return
+	}
+
+	user, err := h.Users.GetUser(old.UserID)
+	if err != nil || user.Disabled {
+		h.Tokens.RevokeSession(old.SessionID, now)
+		utils.RespondError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+
+	refreshToken, next, err := newRefreshToken(user, old.SessionID, r, now)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	if err := h.Tokens.RotateRefreshToken(old, &next, now); err != nil {
+		if errors.Is(err, store.ErrTokenReused) {
+			h.Tokens.RevokeSession(old.SessionID, now)
+			utils.RespondError(w, http.StatusUnauthorized, "Refresh token has been revoked")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tokens, err := tokenResponse(user, old.SessionID, refreshToken, now)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, tokens)
+}
+
+// Logout ends the caller's current session
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, _ := r.Context().Value(utils.SessionKey).(string)
+	if err := h.Tokens.RevokeSession(sessionID, time.Now()); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// LogoutAll ends every session of the caller, on all devices
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(utils.UserIDKey).(uint)
+	if err := h.Tokens.RevokeUserSessions(userID, time.Now()); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Logged out of all sessions"})
+}
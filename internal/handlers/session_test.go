@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gearguard/internal/middleware"
+	"gearguard/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// login sets a password on user and logs in with it; the pair is empty when
+// the login answers with a two-factor challenge instead
+func (f *fixture) login(user models.User) tokenPair {
+	f.t.Helper()
+	f.setPassword(user, "secret")
+	w := f.call(f.h.Login, http.MethodPost, "/api/login", models.User{}, map[string]string{"email": user.Email, "password": "secret"}, nil)
+	expectStatus(f.t, w, http.StatusOK)
+	return decode[tokenPair](f.t, w)
+}
+
+func (f *fixture) setPassword(user models.User, password string) {
+	f.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	f.must(err)
+	user, err = f.store.GetUser(user.ID)
+	f.must(err)
+	user.Password = string(hash)
+	f.must(f.store.SaveUser(&user))
+}
+
+func (f *fixture) refresh(refreshToken string) *httptest.ResponseRecorder {
+	return f.call(f.h.Refresh, http.MethodPost, "/api/refresh", models.User{}, map[string]string{"refresh_token": refreshToken}, nil)
+}
+
+// authenticate runs the middleware checks for an access token
+func (f *fixture) authenticate(token string) error {
+	r := httptest.NewRequest(http.MethodGet, "/api/requests", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	_, err := middleware.Authenticate(f.store, f.store, r)
+	return err
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	f := newFixture(t)
+	first := f.login(f.tech1)
+	if err := f.authenticate(first.Token); err != nil {
+		t.Fatalf("fresh access token rejected: %v", err)
+	}
+
+	w := f.refresh(first.RefreshToken)
+	expectStatus(t, w, http.StatusOK)
+	second := decode[tokenPair](t, w)
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("expected a new refresh token")
+	}
+	if err := f.authenticate(second.Token); err != nil {
+		t.Fatalf("refreshed access token rejected: %v", err)
+	}
+
+	// Replaying the first token looks like theft: the whole session ends
+	expectStatus(t, f.refresh(first.RefreshToken), http.StatusUnauthorized)
+	expectStatus(t, f.refresh(second.RefreshToken), http.StatusUnauthorized)
+	if err := f.authenticate(second.Token); !errors.Is(err, middleware.ErrSessionRevoked) {
+		t.Fatalf("expected the session to be revoked, got %v", err)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	f := newFixture(t)
+	phone, laptop := f.login(f.employee1), f.login(f.employee1)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/logout", nil)
+	r.Header.Set("Authorization", "Bearer "+phone.Token)
+	w := httptest.NewRecorder()
+	middleware.Auth(f.store)(http.HandlerFunc(f.h.Logout)).ServeHTTP(w, r)
+	expectStatus(t, w, http.StatusOK)
+
+	if err := f.authenticate(phone.Token); !errors.Is(err, middleware.ErrSessionRevoked) {
+		t.Fatalf("expected the logged out session to be revoked, got %v", err)
+	}
+	expectStatus(t, f.refresh(phone.RefreshToken), http.StatusUnauthorized)
+	if err := f.authenticate(laptop.Token); err != nil {
+		t.Fatalf("other session should survive a logout: %v", err)
+	}
+
+	expectStatus(t, f.call(f.h.LogoutAll, http.MethodPost, "/api/logout-all", f.employee1, nil, nil), http.StatusOK)
+	if err := f.authenticate(laptop.Token); !errors.Is(err, middleware.ErrSessionRevoked) {
+		t.Fatalf("expected every session to be revoked, got %v", err)
+	}
+}
+
+func TestRoleChangeInvalidatesAccessToken(t *testing.T) {
+	f := newFixture(t)
+	tokens := f.login(f.tech1)
+
+	promoted, _ := f.store.GetUser(f.tech1.ID)
+	promoted.Role = models.RoleManager
+	f.must(f.store.SaveUser(&promoted))
+
+	if err := f.authenticate(tokens.Token); !errors.Is(err, middleware.ErrStaleToken) {
+		t.Fatalf("expected a stale token, got %v", err)
+	}
+	w := f.refresh(tokens.RefreshToken)
+	expectStatus(t, w, http.StatusOK)
+	if err := f.authenticate(decode[tokenPair](t, w).Token); err != nil {
+		t.Fatalf("refreshed token should carry the new role: %v", err)
+	}
+}
@@ -4,34 +4,45 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"gearguard/internal/database"
 	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
 )
 
 // CreateTeam creates a new maintenance team
-func CreateTeam(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateTeam(w http.ResponseWriter, r *http.Request) {
 	var team models.MaintenanceTeam
 	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if result := database.DB.Create(&team); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+	if err := h.Teams.CreateTeam(&team); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	userID, _ := r.Context().Value(utils.UserIDKey).(uint)
+	h.Audit(userID, services.EntityTeam, team.ID, models.AuditCreate, nil, team)
+
 	utils.RespondJSON(w, http.StatusCreated, team)
 }
 
-// GetTeams lists all teams
-func GetTeams(w http.ResponseWriter, r *http.Request) {
-	var teams []models.MaintenanceTeam
-	if result := database.DB.Preload("Members").Find(&teams); result.Error != nil {
-		utils.RespondError(w, http.StatusInternalServerError, result.Error.Error())
+// GetTeams lists teams, with search, sorting and paging (see listQuery)
+func (h *Handler) GetTeams(w http.ResponseWriter, r *http.Request) {
+	list, err := parseListQuery(r, store.TeamSorting)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := store.TeamFilter{Search: r.URL.Query().Get("search"), Page: list.Page}
+
+	teams, err := h.Teams.ListTeams(filter)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.RespondJSON(w, http.StatusOK, teams)
+	list.respond(w, teams, func() (int64, error) { return h.Teams.CountTeams(filter) })
 }
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/utils"
+)
+
+const (
+	// Failed sign-ins (password or second factor) are counted per account and
+	// per client IP within loginWindow. From the second failure on an account
+	// each retry waits twice as long as the last, until it locks outright.
+	loginWindow             = 15 * time.Minute
+	accountLockoutThreshold = 5
+	accountLockout          = 15 * time.Minute
+	ipLockoutThreshold      = 20
+	ipLockout               = 15 * time.Minute
+
+	// Password reset requests allowed per account and per IP within resetWindow
+	resetWindow     = time.Hour
+	resetPerAccount = 3
+	resetPerIP      = 10
+)
+
+func loginAccountKey(email string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(r *http.Request) string {
+	return "login:ip:" + utils.ClientIP(r)
+}
+
+// loginBlocked returns how long the caller must wait before trying to sign in
+// to the account again, or zero
+func (h *Handler) loginBlocked(r *http.Request, email string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{loginAccountKey(email), loginIPKey(r)} {
+		throttle, err := h.Throttle.GetThrottle(key)
+		if err == nil && throttle.BlockedUntil.After(now) && throttle.BlockedUntil.Sub(now) > wait {
+			wait = throttle.BlockedUntil.Sub(now)
+		}
+	}
+	return wait
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	wait = wait.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	utils.RespondError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many attempts, try again in %s", wait))
+}
+
+// recordLoginFailure counts a failed sign-in against the account and the
+// client IP, applying delays and lockouts. user is nil for unknown emails,
+// which are throttled all the same so responses don't reveal who exists.
+func (h *Handler) recordLoginFailure(r *http.Request, email string, user *models.User) {
+	now := time.Now()
+
+	if account, err := h.Throttle.HitThrottle(loginAccountKey(email), now, loginWindow); err == nil {
+		switch {
+		case account.Count >= accountLockoutThreshold:
+			h.Throttle.BlockThrottle(account.Key, now.Add(accountLockout))
+			if user != nil {
+				h.notifyLockout(*user, r)
+			}
+		case account.Count >= 2:
+			h.Throttle.BlockThrottle(account.Key, now.Add(time.Second<<(account.Count-2)))
+		}
+	}
+
+	if ip, err := h.Throttle.HitThrottle(loginIPKey(r), now, loginWindow); err == nil && ip.Count >= ipLockoutThreshold {
+		h.Throttle.BlockThrottle(ip.Key, now.Add(ipLockout))
+	}
+}
+
+// clearLoginFailures forgets an account's failures once it signs in completely
+func (h *Handler) clearLoginFailures(email string) {
+	h.Throttle.ClearThrottle(loginAccountKey(email))
+}
+
+func (h *Handler) notifyLockout(user models.User, r *http.Request) {
+	body := fmt.Sprintf("<h3>Sign-in temporarily locked</h3><p>After %d failed sign-in attempts (the last from %s), sign-in to your GearGuard account is locked for %d minutes.</p><p>If this wasn't you, reset your password once the lock expires.</p>",
+		accountLockoutThreshold, utils.ClientIP(r), int(accountLockout.Minutes()))
+	h.Mail([]string{user.Email}, "Account locked - GearGuard", body)
+}
+
+// allowPasswordReset rate-limits forgot-password requests. Over the IP limit
+// the caller gets a 429; over the per-account limit the request is silently
+// dropped so the response still doesn't reveal whether the email exists.
+func (h *Handler) allowPasswordReset(w http.ResponseWriter, r *http.Request, email string) (send, ok bool) {
+	now := time.Now()
+	ip, err := h.Throttle.HitThrottle("reset:ip:"+utils.ClientIP(r), now, resetWindow)
+	if err == nil && ip.Count > resetPerIP {
+		respondTooManyAttempts(w, ip.WindowStart.Add(resetWindow).Sub(now))
+		return false, false
+	}
+	account, err := h.Throttle.HitThrottle("reset:account:"+strings.ToLower(strings.TrimSpace(email)), now, resetWindow)
+	return err != nil || account.Count <= resetPerAccount, true
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+func (f *fixture) attemptLogin(email, password string) *httptest.ResponseRecorder {
+	return f.call(f.h.Login, http.MethodPost, "/api/login", models.User{}, map[string]string{"email": email, "password": password}, nil)
+}
+
+// waitOutDelay lifts the progressive delay on an account, as if the caller had
+// waited, without forgetting its failures
+func (f *fixture) waitOutDelay(email string) {
+	f.must(f.store.BlockThrottle("login:account:"+strings.ToLower(email), time.Time{}))
+}
+
+func retryAfter(t *testing.T, w *httptest.ResponseRecorder) int {
+	t.Helper()
+	seconds, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("bad Retry-After %q", w.Header().Get("Retry-After"))
+	}
+	return seconds
+}
+
+func TestLoginDelaysAndLocksAccount(t *testing.T) {
+	f := newFixture(t)
+	f.setPassword(f.tech1, "secret")
+	email := f.tech1.Email
+
+	expectStatus(t, f.attemptLogin(email, "wrong"), http.StatusUnauthorized)
+	expectStatus(t, f.attemptLogin(email, "wrong"), http.StatusUnauthorized)
+
+	// The second failure imposes a delay, even for the right password
+	w := f.attemptLogin(email, "secret")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if s := retryAfter(t, w); s != 1 {
+		t.Fatalf("expected a 1s delay, got %ds", s)
+	}
+
+	for i := 3; i <= 4; i++ {
+		f.waitOutDelay(email)
+		expectStatus(t, f.attemptLogin(email, "wrong"), http.StatusUnauthorized)
+	}
+
+	// Five failures lock the account and tell its owner
+	f.waitOutDelay(email)
+	if len(f.mail) != 0 {
+		t.Fatalf("no mail expected before lockout, got %+v", f.mail)
+	}
+	w = f.attemptLogin(email, "wrong")
+	expectStatus(t, w, http.StatusUnauthorized)
+	if len(f.mail) != 1 || f.mail[0].To[0] != email {
+		t.Fatalf("expected a lockout email to %s, got %+v", email, f.mail)
+	}
+
+	w = f.attemptLogin(email, "secret")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if s := retryAfter(t, w); s < 14*60 || s > 15*60 {
+		t.Fatalf("expected a 15 minute lockout, got %ds", s)
+	}
+}
+
+func TestSuccessfulLoginClearsFailures(t *testing.T) {
+	f := newFixture(t)
+	f.setPassword(f.tech1, "secret")
+
+	expectStatus(t, f.attemptLogin(f.tech1.Email, "wrong"), http.StatusUnauthorized)
+	expectStatus(t, f.attemptLogin(f.tech1.Email, "secret"), http.StatusOK)
+
+	throttle, err := f.store.GetThrottle("login:account:" + strings.ToLower(f.tech1.Email))
+	f.must(err)
+	if throttle.Count != 0 {
+		t.Fatalf("failures survived a successful login: %+v", throttle)
+	}
+}
+
+func TestLoginLocksOutNoisyIP(t *testing.T) {
+	f := newFixture(t)
+	f.setPassword(f.tech1, "secret")
+
+	// Spraying unknown accounts from one address eventually blocks it
+	for i := 0; i < 20; i++ {
+		email := "nobody" + strconv.Itoa(i) + "@example.com"
+		expectStatus(t, f.attemptLogin(email, "guess"), http.StatusUnauthorized)
+	}
+	expectStatus(t, f.attemptLogin(f.tech1.Email, "secret"), http.StatusTooManyRequests)
+	if len(f.mail) != 0 {
+		t.Fatalf("unknown accounts must not trigger mail, got %+v", f.mail)
+	}
+}
+
+func TestForgotPasswordRateLimits(t *testing.T) {
+	f := newFixture(t)
+	forgot := func(email string) *httptest.ResponseRecorder {
+		return f.call(f.h.ForgotPassword, http.MethodPost, "/api/forgot-password", models.User{}, map[string]string{"email": email}, nil)
+	}
+
+	// Three reset emails per account per hour, then silently nothing
+	for i := 0; i < 4; i++ {
+		expectStatus(t, forgot(f.tech1.Email), http.StatusOK)
+	}
+	if len(f.mail) != 3 {
+		t.Fatalf("expected 3 reset emails, got %d", len(f.mail))
+	}
+
+	// Ten requests per address per hour, then 429
+	for i := 0; i < 6; i++ {
+		expectStatus(t, forgot(f.employee1.Email), http.StatusOK)
+	}
+	w := forgot(f.employee2.Email)
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if s := retryAfter(t, w); s < 59*60 || s > 60*60 {
+		t.Fatalf("expected to wait out the hour, got %ds", s)
+	}
+}
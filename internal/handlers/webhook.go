@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// webhookDeliveryLimit is how many deliveries GetWebhookDeliveries returns
+	// by default, and maxWebhookDeliveryLimit the most it returns
+	webhookDeliveryLimit    = 50
+	maxWebhookDeliveryLimit = 200
+)
+
+// webhookView adds the subscribed event types to a webhook in responses
+type webhookView struct {
+	models.Webhook
+	EventTypes []string `json:"event_types"`
+}
+
+func viewWebhook(webhook models.Webhook) webhookView {
+	return webhookView{Webhook: webhook, EventTypes: webhook.EventList()}
+}
+
+// webhookURL checks that raw is an absolute http(s) URL
+func webhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("url must be an absolute http or https URL")
+	}
+	return raw, nil
+}
+
+// webhookEvents checks event types against models.WebhookEvents and joins
+// them the way Webhook.Events stores them
+func webhookEvents(eventTypes []string) (string, error) {
+	if len(eventTypes) == 0 {
+		return "", errors.New("At least one event type is required")
+	}
+	var joined []string
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		known := false
+		for _, e := range models.WebhookEvents {
+			known = known || e == eventType
+		}
+		if !known {
+			return "", fmt.Errorf("Unknown event type %q (expected one of %s)", eventType, strings.Join(models.WebhookEvents, ", "))
+		}
+		joined = append(joined, eventType)
+	}
+	return strings.Join(joined, " "), nil
+}
+
+// webhookByID loads the webhook named by the id route variable, responding
+// with an error when it cannot
+func (h *Handler) webhookByID(w http.ResponseWriter, r *http.Request) (models.Webhook, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return models.Webhook{}, false
+	}
+	webhook, err := h.Webhooks.Store.GetWebhook(uint(id))
+	if err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Webhook not found")
+		return webhook, false
+	}
+	return webhook, true
+}
+
+// CreateWebhook subscribes a URL to event types. Deliveries are signed with
+// secret, generated when not given; it is only ever returned here and when
+// rotated.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Secret     string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	target, err := webhookURL(input.URL)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	events, err := webhookEvents(input.EventTypes)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	secret := input.Secret
+	if secret == "" {
+		if secret, err = randomToken(32); err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, "Could not generate secret")
+			return
+		}
+	} else if len(secret) < 16 {
+		utils.RespondError(w, http.StatusBadRequest, "secret must be at least 16 characters")
+		return
+	}
+
+	webhook := models.Webhook{URL: target, Secret: secret, Events: events, Active: true, CreatedByID: user.ID}
+	if err := h.Webhooks.Store.CreateWebhook(&webhook); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityWebhook, webhook.ID, models.AuditCreate, nil, webhook)
+
+	utils.RespondJSON(w, http.StatusCreated, map[string]interface{}{"webhook": viewWebhook(webhook), "secret": secret})
+}
+
+// GetWebhooks lists every webhook, inactive ones included
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.Webhooks.Store.ListWebhooks()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	views := make([]webhookView, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		views = append(views, viewWebhook(webhook))
+	}
+	utils.RespondJSON(w, http.StatusOK, views)
+}
+
+// GetWebhook returns one webhook
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.webhookByID(w, r)
+	if !ok {
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, viewWebhook(webhook))
+}
+
+// UpdateWebhook changes a webhook's URL, event types or active flag, and with
+// rotate_secret replaces its secret, returning the new one. Deactivating it
+// also stops the retries of its pending deliveries.
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	webhook, ok := h.webhookByID(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		URL          *string  `json:"url"`
+		EventTypes   []string `json:"event_types"`
+		Active       *bool    `json:"active"`
+		RotateSecret bool     `json:"rotate_secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before := webhook
+	var err error
+	if input.URL != nil {
+		if webhook.URL, err = webhookURL(*input.URL); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if input.EventTypes != nil {
+		if webhook.Events, err = webhookEvents(input.EventTypes); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	if input.RotateSecret {
+		if webhook.Secret, err = randomToken(32); err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, "Could not generate secret")
+			return
+		}
+	}
+
+	if err := h.Webhooks.Store.SaveWebhook(&webhook); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityWebhook, webhook.ID, models.AuditUpdate, before, webhook)
+
+	if input.RotateSecret {
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"webhook": viewWebhook(webhook), "secret": webhook.Secret})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, viewWebhook(webhook))
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	webhook, ok := h.webhookByID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Webhooks.Store.DeleteWebhook(webhook.ID); err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.Audit(user.ID, services.EntityWebhook, webhook.ID, models.AuditDelete, webhook, nil)
+
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries lists a webhook's latest deliveries, newest first, up
+// to limit (50 by default, at most 200)
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.webhookByID(w, r)
+	if !ok {
+		return
+	}
+	limit := webhookDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxWebhookDeliveryLimit {
+			utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxWebhookDeliveryLimit))
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.Webhooks.Store.ListWebhookDeliveries(webhook.ID, limit)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	utils.RespondJSON(w, http.StatusOK, deliveries)
+}
+
+// RedeliverWebhookDelivery sends a delivery's event again, right away, as a
+// new delivery with the same event ID, and returns it with its outcome
+func (h *Handler) RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.webhookByID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(mux.Vars(r)["delivery"])
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+	delivery, err := h.Webhooks.Store.GetWebhookDelivery(uint(deliveryID))
+	if err != nil || delivery.WebhookID != webhook.ID {
+		utils.RespondError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if !webhook.Active {
+		utils.RespondError(w, http.StatusConflict, "Webhook is inactive")
+		return
+	}
+
+	redelivery, err := h.Webhooks.Redeliver(delivery, time.Now())
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, redelivery)
+}
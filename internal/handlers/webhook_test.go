@@ -0,0 +1,260 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/webhooks"
+)
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local webhook endpoint answering with status
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	received []receivedHook
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	rc := &receiver{status: status}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.received = append(rc.received, receivedHook{r.Header, body})
+		w.WriteHeader(rc.status)
+		fmt.Fprint(w, "ack")
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) answer(status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.status = status
+}
+
+func (rc *receiver) hooks() []receivedHook {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedHook(nil), rc.received...)
+}
+
+type createdWebhook struct {
+	Webhook struct {
+		ID         uint     `json:"id"`
+		EventTypes []string `json:"event_types"`
+	} `json:"webhook"`
+	Secret string `json:"secret"`
+}
+
+func (f *fixture) createWebhook(url string, eventTypes ...string) createdWebhook {
+	f.t.Helper()
+	w := f.call(f.h.CreateWebhook, http.MethodPost, "/api/webhooks", f.manager,
+		map[string]interface{}{"url": url, "event_types": eventTypes}, nil)
+	expectStatus(f.t, w, http.StatusCreated)
+	return decode[createdWebhook](f.t, w)
+}
+
+func (f *fixture) deliveries(webhookID uint) []models.WebhookDelivery {
+	f.t.Helper()
+	w := f.call(f.h.GetWebhookDeliveries, http.MethodGet, "/api/webhooks/x/deliveries", f.manager, nil,
+		map[string]string{"id": fmt.Sprint(webhookID)})
+	expectStatus(f.t, w, http.StatusOK)
+	return decode[[]models.WebhookDelivery](f.t, w)
+}
+
+func TestWebhookDeliveriesAreSigned(t *testing.T) {
+	f := newFixture(t)
+	rc := newReceiver(t, http.StatusOK)
+
+	for _, body := range []map[string]interface{}{
+		{"url": "ftp://example.com/hook", "event_types": []string{models.WebhookRequestCreated}},
+		{"url": rc.URL, "event_types": []string{"request.deleted"}},
+		{"url": rc.URL},
+	} {
+		w := f.call(f.h.CreateWebhook, http.MethodPost, "/api/webhooks", f.manager, body, nil)
+		expectStatus(t, w, http.StatusBadRequest)
+	}
+	hook := f.createWebhook(rc.URL, models.WebhookRequestCreated, models.WebhookEquipmentScrapped)
+	if len(hook.Secret) != 64 || len(hook.Webhook.EventTypes) != 2 {
+		t.Fatalf("expected a generated secret and both event types, got %+v", hook)
+	}
+
+	f.createRequestAs(f.employee1, f.drill, "Drill jams")
+	scrap := f.request(f.press, f.employee2, models.StatusNew)
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/x", f.manager,
+		map[string]interface{}{"status": models.StatusScrap}, updateVars(scrap))
+	expectStatus(t, w, http.StatusOK)
+	if n := f.h.Webhooks.RunDue(time.Now()); n != 2 {
+		t.Fatalf("expected the creation and the scrapping to be due, not the status change: %d", n)
+	}
+
+	received := rc.hooks()
+	for i, eventType := range []string{models.WebhookRequestCreated, models.WebhookEquipmentScrapped} {
+		got := received[i]
+		if got.header.Get(webhooks.HeaderEvent) != eventType {
+			t.Fatalf("delivery %d: expected %s, got %s", i, eventType, got.header.Get(webhooks.HeaderEvent))
+		}
+		if !webhooks.Verify(hook.Secret, got.header.Get(webhooks.HeaderTimestamp), got.body, got.header.Get(webhooks.HeaderSignature)) {
+			t.Fatalf("delivery %d: signature does not verify", i)
+		}
+		var payload webhooks.Payload
+		if err := json.Unmarshal(got.body, &payload); err != nil || payload.Type != eventType || payload.ID != got.header.Get(webhooks.HeaderDelivery) {
+			t.Fatalf("delivery %d: unexpected payload %s", i, got.body)
+		}
+	}
+	if webhooks.Verify("another secret", received[0].header.Get(webhooks.HeaderTimestamp), received[0].body, received[0].header.Get(webhooks.HeaderSignature)) {
+		t.Fatal("signature should not verify with another secret")
+	}
+
+	log := f.deliveries(hook.Webhook.ID)
+	if len(log) != 2 || log[0].EventType != models.WebhookEquipmentScrapped {
+		t.Fatalf("expected both deliveries newest first, got %+v", log)
+	}
+	for _, delivery := range log {
+		if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK || delivery.ResponseBody != "ack" {
+			t.Fatalf("expected a recorded success, got %+v", delivery)
+		}
+	}
+}
+
+func TestWebhookRetriesThenRedelivers(t *testing.T) {
+	f := newFixture(t)
+	f.h.Webhooks.MaxAttempts = 3
+	f.h.Webhooks.BaseDelay = time.Minute
+	rc := newReceiver(t, http.StatusInternalServerError)
+	hook := f.createWebhook(rc.URL, models.WebhookRequestStatusChanged)
+
+	req := f.request(f.lathe, f.employee1, models.StatusNew)
+	w := f.call(f.h.UpdateRequest, http.MethodPut, "/api/requests/x", f.manager,
+		map[string]interface{}{"status": models.StatusInProgress}, updateVars(req))
+	expectStatus(t, w, http.StatusOK)
+
+	now := time.Now()
+	for i, step := range []struct {
+		at      time.Duration
+		due     int
+		status  string
+		backoff time.Duration
+	}{
+		{0, 1, models.DeliveryPending, time.Minute},
+		{30 * time.Second, 0, models.DeliveryPending, time.Minute},
+		{time.Minute, 1, models.DeliveryPending, 2 * time.Minute},
+		{3 * time.Minute, 1, models.DeliveryFailed, 0},
+		{time.Hour, 0, models.DeliveryFailed, 0},
+	} {
+		at := now.Add(step.at)
+		if n := f.h.Webhooks.RunDue(at); n != step.due {
+			t.Fatalf("step %d: expected %d due, got %d", i, step.due, n)
+		}
+		delivery := f.deliveries(hook.Webhook.ID)[0]
+		if delivery.Status != step.status {
+			t.Fatalf("step %d: expected %s, got %+v", i, step.status, delivery)
+		}
+		if step.due == 1 && step.backoff > 0 && !delivery.NextAttemptAt.Equal(at.Add(step.backoff)) {
+			t.Fatalf("step %d: expected a retry in %s, got %v", i, step.backoff, delivery.NextAttemptAt)
+		}
+	}
+	failed := f.deliveries(hook.Webhook.ID)[0]
+	if failed.Attempts != 3 || failed.ResponseStatus != http.StatusInternalServerError || failed.Error == "" || failed.NextAttemptAt != nil {
+		t.Fatalf("expected the failure to be recorded, got %+v", failed)
+	}
+
+	rc.answer(http.StatusNoContent)
+	vars := map[string]string{"id": fmt.Sprint(hook.Webhook.ID), "delivery": fmt.Sprint(failed.ID)}
+	w = f.call(f.h.RedeliverWebhookDelivery, http.MethodPost, "/api/webhooks/x/deliveries/y/redeliver", f.manager, nil, vars)
+	expectStatus(t, w, http.StatusCreated)
+	redelivery := decode[models.WebhookDelivery](t, w)
+	if redelivery.ID == failed.ID || redelivery.EventID != failed.EventID || redelivery.Status != models.DeliverySucceeded {
+		t.Fatalf("expected a successful new delivery of the same event, got %+v", redelivery)
+	}
+	if received := rc.hooks(); len(received) != 4 || received[3].header.Get(webhooks.HeaderDelivery) != failed.EventID {
+		t.Fatalf("expected three attempts and one redelivery, got %d", len(received))
+	}
+
+	// Another webhook's delivery is not found through this one
+	other := f.createWebhook(rc.URL, models.WebhookRequestCreated)
+	vars["id"] = fmt.Sprint(other.Webhook.ID)
+	w = f.call(f.h.RedeliverWebhookDelivery, http.MethodPost, "/api/webhooks/x/deliveries/y/redeliver", f.manager, nil, vars)
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestWebhookReplicasSendEachDeliveryOnce(t *testing.T) {
+	f := newFixture(t)
+	arrived, release := make(chan struct{}, 1), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	hook := f.createWebhook(slow.URL, models.WebhookRequestCreated)
+	f.h.Webhooks.Enqueue(models.WebhookRequestCreated, map[string]string{"subject": "Drill jams"})
+
+	// Another replica shares the store but not the dispatcher
+	replica := webhooks.NewDispatcher(f.store)
+	now := time.Now()
+	done := make(chan int)
+	go func() { done <- f.h.Webhooks.RunDue(now) }()
+	<-arrived
+	if n := replica.RunDue(now); n != 0 {
+		t.Fatalf("expected the delivery being sent to be skipped, got %d", n)
+	}
+	close(release)
+	if n := <-done; n != 1 {
+		t.Fatalf("expected one delivery sent, got %d", n)
+	}
+
+	if delivery := f.deliveries(hook.Webhook.ID)[0]; delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 {
+		t.Fatalf("expected a single successful attempt, got %+v", delivery)
+	}
+	if n := replica.RunDue(now.Add(time.Hour)); n != 0 {
+		t.Fatalf("expected nothing left to send, got %d", n)
+	}
+}
+
+func TestWebhooksForGeneratedRequests(t *testing.T) {
+	f := newFixture(t)
+	rc := newReceiver(t, http.StatusOK)
+	hook := f.createWebhook(rc.URL, models.WebhookRequestCreated)
+
+	meter, _ := f.overheatRule()
+	w := f.call(f.h.PostMeterReading, http.MethodPost, "/api/meters/x/readings", f.employee2,
+		map[string]interface{}{"value": 500}, map[string]string{"id": fmt.Sprint(meter.ID)})
+	expectStatus(t, w, http.StatusCreated)
+
+	// The schedule generator announces through the same path
+	scheduled := models.MaintenanceRequest{Subject: "Lubricate", Type: models.TypePreventive, CreatedByID: f.manager.ID}
+	services.PrepareRequest(&scheduled, f.press)
+	f.must(f.store.CreateRequest(&scheduled))
+	f.h.AnnounceRequest(scheduled, f.press)
+
+	if n := f.h.Webhooks.RunDue(time.Now()); n != 2 {
+		t.Fatalf("expected both generated requests to be delivered, got %d", n)
+	}
+	for i, subject := range []string{"Overheat", "Lubricate"} {
+		var payload struct {
+			Data models.MaintenanceRequest `json:"data"`
+		}
+		if err := json.Unmarshal(rc.hooks()[i].body, &payload); err != nil || payload.Data.ID == 0 || payload.Data.Subject != subject {
+			t.Fatalf("delivery %d: expected the saved %q request, got %s", i, subject, rc.hooks()[i].body)
+		}
+	}
+	if log := f.deliveries(hook.Webhook.ID); len(log) != 2 {
+		t.Fatalf("expected two recorded deliveries, got %d", len(log))
+	}
+}
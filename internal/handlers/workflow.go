@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/utils"
+)
+
+// GetWorkflow exposes the request status workflow (Kanban columns) and the
+// transitions available to the current user from each state
+func GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	wf := services.CurrentWorkflow
+	nextStates := map[models.RequestStatus][]models.RequestStatus{}
+	for _, state := range wf.States {
+		nextStates[state.Name] = wf.NextStates(state.Name, user.Role)
+	}
+
+	utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"initial":     wf.Initial,
+		"states":      wf.States,
+		"transitions": wf.Transitions,
+		"next_states": nextStates,
+	})
+}
@@ -0,0 +1,117 @@
+// Package ical writes iCalendar (RFC 5545) feeds of timed events, as calendar
+// apps subscribe to them.
+package ical
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest a content line may be before it is folded
+const maxLineOctets = 75
+
+// Calendar is a published calendar
+type Calendar struct {
+	ProdID  string        // Identifies the product that wrote the feed
+	Name    string        // Shown by calendar apps as the calendar's name
+	Refresh time.Duration // How often subscribers should poll; not advertised when 0
+	Events  []Event
+}
+
+// Event is a timed event; times are written in UTC
+type Event struct {
+	UID          string // Globally unique and stable across fetches
+	Start, End   time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Categories   []string
+	LastModified time.Time // Not written when zero
+}
+
+// Encode writes c with now as every event's DTSTAMP
+func Encode(c Calendar, now time.Time) []byte {
+	var b bytes.Buffer
+	line := func(name, value string) { writeLine(&b, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", Escape(c.Name))
+	}
+	if c.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", duration(c.Refresh))
+		line("X-PUBLISHED-TTL", duration(c.Refresh))
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", timestamp(now))
+		line("DTSTART", timestamp(e.Start))
+		line("DTEND", timestamp(e.End))
+		line("SUMMARY", Escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", Escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION", Escape(e.Location))
+		}
+		if len(e.Categories) > 0 {
+			escaped := make([]string, len(e.Categories))
+			for i, category := range e.Categories {
+				escaped[i] = Escape(category)
+			}
+			line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		if !e.LastModified.IsZero() {
+			line("LAST-MODIFIED", timestamp(e.LastModified))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+// Escape quotes a TEXT value: backslashes, semicolons, commas and line breaks
+func Escape(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(text)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// duration formats d in whole minutes, the precision feeds need
+func duration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes%60 == 0 {
+		return "PT" + strconv.Itoa(minutes/60) + "H"
+	}
+	return "PT" + strconv.Itoa(minutes) + "M"
+}
+
+// writeLine ends a content line with CRLF, folding it into continuation lines
+// that start with a space so none exceeds maxLineOctets. Folds never split a
+// UTF-8 sequence.
+func writeLine(b *bytes.Buffer, content string) {
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		b.WriteString(content[:cut])
+		b.WriteString("\r\n ")
+		content = content[cut:]
+		limit = maxLineOctets - 1 // The leading space counts
+	}
+	b.WriteString(content)
+	b.WriteString("\r\n")
+}
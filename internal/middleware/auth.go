@@ -2,36 +2,104 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"gearguard/internal/models"
+	"gearguard/internal/store"
 	"gearguard/internal/utils"
+)
 
-	"github.com/golang-jwt/jwt/v5"
+var (
+	// ErrNoToken is returned when the request has no Authorization header
+	ErrNoToken = errors.New("Authorization header missing")
+	// ErrInvalidToken is returned for tokens that are malformed, forged or expired
+	ErrInvalidToken = errors.New("Invalid token")
+	// ErrSessionRevoked is returned once the token's session has been logged out
+	ErrSessionRevoked = errors.New("Session has been revoked")
+	// ErrStaleToken is returned when the user changed since the token was issued
+	// (role change, account disabled); refreshing yields an up-to-date token
+	ErrStaleToken = errors.New("Token is out of date, please refresh it")
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			utils.RespondError(w, http.StatusUnauthorized, "Authorization header missing")
-			return
-		}
+// ParseToken validates the bearer token of the request and returns its claims
+func ParseToken(r *http.Request) (*utils.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, ErrNoToken
+	}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &utils.Claims{}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims := &utils.Claims{}
+	if err := utils.ParseToken(tokenString, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return utils.SecretKey, nil
-		})
+	// Tokens issued before roles were normalized may carry any spelling
+	if role, err := models.ParseRole(string(claims.Role)); err == nil {
+		claims.Role = role
+	}
+	return claims, nil
+}
+
+// Authenticate validates the bearer token and checks it against server-side
+// state, so logouts, disabled accounts and role changes apply immediately
+func Authenticate(users store.UserStore, tokens store.TokenStore, r *http.Request) (*utils.Claims, error) {
+	claims, err := ParseToken(r)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil || !token.Valid {
-			utils.RespondError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
+	active, err := tokens.SessionActive(claims.SessionID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrSessionRevoked
+	}
 
-		// Add UserID to context
-		ctx := context.WithValue(r.Context(), utils.UserIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	user, err := users.GetUser(claims.UserID)
+	if err != nil || user.Disabled || user.Role != claims.Role {
+		return nil, ErrStaleToken
+	}
+	return claims, nil
+}
+
+// Auth returns the middleware guarding protected routes. It accepts access
+// tokens and API keys; requests made with a key carry its scopes for policy.Guard.
+func Auth(s store.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var claims *utils.Claims
+			var scopes []string
+			var err error
+			if key, ok := apiKeyFrom(r); ok {
+				claims, scopes, err = AuthenticateAPIKey(s, s, key)
+			} else {
+				claims, err = Authenticate(s, s, r)
+			}
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !errors.Is(err, ErrNoToken) && !errors.Is(err, ErrInvalidToken) &&
+					!errors.Is(err, ErrSessionRevoked) && !errors.Is(err, ErrStaleToken) &&
+					!errors.Is(err, ErrInvalidAPIKey) && !errors.Is(err, ErrAPIKeyInactive) {
+					status = http.StatusInternalServerError
+				}
+				utils.RespondError(w, status, err.Error())
+				return
+			}
+
+			// Add UserID, Role and session (or API key scopes) to context
+			ctx := context.WithValue(r.Context(), utils.UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, utils.RoleKey, claims.Role)
+			if scopes != nil {
+				ctx = context.WithValue(ctx, utils.ScopesKey, scopes)
+			} else {
+				ctx = context.WithValue(ctx, utils.SessionKey, claims.SessionID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
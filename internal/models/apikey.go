@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets scripts and integrations call the API as their owner, limited
+// to Scopes. The key is shown once at creation; only its SHA-256 hash is
+// stored, with Prefix kept in clear to identify it in listings and logs.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `gorm:"uniqueIndex" json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     string     `json:"-"` // Space-separated "resource:action" entries
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // Never expires when nil
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ScopeList is Scopes split into entries
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Active reports whether the key may be used at now
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
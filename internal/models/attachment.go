@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is a file (photo, manual, service report) attached to an
+// Equipment or a MaintenanceRequest. The content lives in the storage backend.
+type Attachment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EntityType string `gorm:"index:idx_attachment_entity" json:"entity_type"`
+	EntityID   uint   `gorm:"index:idx_attachment_entity" json:"entity_id"`
+
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	HasThumbnail bool   `json:"has_thumbnail"`
+
+	UploadedByID uint `json:"uploaded_by_id"`
+}
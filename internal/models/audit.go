@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditLog is an append-only record of a change to an entity.
+// Changes maps each modified field to its before/after values.
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+	ActorID    *uint           `json:"actor_id"`
+	Actor      *User           `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	EntityType string          `gorm:"index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint            `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Action     string          `json:"action"`
+	Changes    json.RawMessage `gorm:"type:jsonb" json:"changes"`
+}
+
+// FieldChange holds the value of a field before and after a change
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// DefaultDurationHours is how long a scheduled request without a duration
+// is expected to take
+const DefaultDurationHours = 1
+
+// ScheduledEnd is when a scheduled request is expected to be done: its
+// ScheduledDate plus DurationHours, or DefaultDurationHours when unset. The
+// zero time when it isn't scheduled.
+func (r MaintenanceRequest) ScheduledEnd() time.Time {
+	if r.ScheduledDate == nil {
+		return time.Time{}
+	}
+	hours := r.DurationHours
+	if hours <= 0 {
+		hours = DefaultDurationHours
+	}
+	return r.ScheduledDate.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+// CalendarFeed is an iCalendar subscription URL for calendar apps, which
+// can't sign in. Anyone holding the URL sees the scheduled requests its owner
+// sees, all of them or only TeamID's. Only the SHA-256 hash of the token in
+// the URL is stored.
+type CalendarFeed struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	TeamID        *uint      `json:"team_id"` // The owner's whole calendar when nil
+	TokenHash     string     `gorm:"uniqueIndex" json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RequestComment is a comment or work note on a maintenance request.
+// Internal notes are only visible to technicians and managers.
+type RequestComment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	RequestID uint  `gorm:"index" json:"request_id"`
+	AuthorID  uint  `json:"author_id"`
+	Author    *User `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+
+	Body     string `json:"body"`
+	Internal bool   `json:"internal"`
+	Edited   bool   `json:"edited"`
+}
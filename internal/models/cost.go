@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// VendorCost is an external cost (contractor, service call, shipping) charged to a request
+type VendorCost struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	RequestID    uint      `gorm:"index" json:"request_id"`
+	Vendor       string    `json:"vendor"`
+	Description  string    `json:"description"`
+	InvoiceRef   string    `json:"invoice_ref"`
+	Amount       float64   `json:"amount"`
+	RecordedByID uint      `json:"recorded_by_id"`
+}
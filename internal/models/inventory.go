@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stock movement kinds
+const (
+	MovementReceive = "receive"
+	MovementAdjust  = "adjust"
+	MovementIssue   = "issue"
+)
+
+// Part is a spare part kept in inventory
+type Part struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PartNumber  string  `gorm:"uniqueIndex" json:"part_number"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Unit        string  `json:"unit"` // e.g. pcs, litre, m
+	UnitCost    float64 `json:"unit_cost"`
+
+	ReorderPoint    float64 `json:"reorder_point"`     // Alert when total stock falls below this
+	LowStockAlerted bool    `json:"low_stock_alerted"` // Reset once stock is back above the reorder point
+
+	Stock []Stock `gorm:"foreignKey:PartID" json:"stock,omitempty"`
+}
+
+// Stock is the on-hand quantity of a part at one location
+type Stock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	PartID    uint      `gorm:"uniqueIndex:idx_stock_part_location" json:"part_id"`
+	Location  string    `gorm:"uniqueIndex:idx_stock_part_location" json:"location"`
+	Quantity  float64   `json:"quantity"`
+}
+
+// StockMovement is the ledger entry of every stock change
+type StockMovement struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	PartID       uint      `gorm:"index" json:"part_id"`
+	Location     string    `json:"location"`
+	Kind         string    `json:"kind"`
+	Quantity     float64   `json:"quantity"` // Signed change
+	RequestID    *uint     `json:"request_id,omitempty"`
+	Note         string    `json:"note"`
+	RecordedByID uint      `json:"recorded_by_id"`
+}
+
+// PartUsage records parts consumed by a maintenance request
+type PartUsage struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	RequestID    uint      `gorm:"index" json:"request_id"`
+	PartID       uint      `json:"part_id"`
+	Part         *Part     `gorm:"foreignKey:PartID" json:"part,omitempty"`
+	Location     string    `json:"location"`
+	Quantity     float64   `json:"quantity"`
+	UnitCost     float64   `json:"unit_cost"` // Cost at the time of use
+	RecordedByID uint      `json:"recorded_by_id"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Invitation lets a manager onboard someone with a role and team chosen in
+// advance. The emailed link is a signed token naming the invitation and its
+// current Nonce; resending rotates the nonce so older links stop working.
+type Invitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Email       string     `gorm:"index" json:"email"`
+	Name        string     `json:"name"`
+	Role        Role       `json:"role"`
+	TeamID      *uint      `json:"team_id"`
+	Nonce       string     `json:"-"`
+	InvitedByID uint       `json:"invited_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SentAt      time.Time  `json:"sent_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	UserID      *uint      `json:"user_id"` // Account created on acceptance
+}
+
+// Invitation states, derived from the timestamps
+const (
+	InvitationPending  = "pending"
+	InvitationExpired  = "expired"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+)
+
+// Open reports whether the invitation was neither accepted nor revoked; an
+// open invitation may still have expired, in which case it can be resent.
+func (i Invitation) Open() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
+
+// Status is the invitation's state at now
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !i.ExpiresAt.After(now):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Location kinds, from the top of the tree down
+type LocationKind string
+
+const (
+	LocationSite     LocationKind = "Site"
+	LocationBuilding LocationKind = "Building"
+	LocationFloor    LocationKind = "Floor"
+	LocationArea     LocationKind = "Area"
+)
+
+// LocationLevels gives the depth of each kind; a child must sit below its parent
+var LocationLevels = map[LocationKind]int{
+	LocationSite:     0,
+	LocationBuilding: 1,
+	LocationFloor:    2,
+	LocationArea:     3,
+}
+
+// Location is a node of the site -> building -> floor -> area tree.
+// Path holds the IDs from the root ("/1/4/9/") so subtrees can be queried with a prefix match.
+type Location struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name     string       `json:"name"`
+	Kind     LocationKind `json:"kind"`
+	ParentID *uint        `gorm:"index" json:"parent_id"`
+	Path     string       `gorm:"index" json:"path"`
+
+	Children []Location `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+}
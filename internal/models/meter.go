@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Meter rule kinds
+type MeterRuleKind string
+
+const (
+	RuleEvery     MeterRuleKind = "Every"     // Trigger every N units of usage (e.g. every 500 hours)
+	RuleThreshold MeterRuleKind = "Threshold" // Trigger when the reading exceeds a value
+)
+
+// Meter tracks a usage counter on a piece of equipment (run hours, cycles, km...)
+type Meter struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EquipmentID uint   `gorm:"index" json:"equipment_id"`
+	Name        string `json:"name"`
+	Unit        string `json:"unit"`
+
+	CurrentReading float64    `json:"current_reading"`
+	LastReadingAt  *time.Time `json:"last_reading_at"`
+
+	Rules []MeterRule `gorm:"foreignKey:MeterID" json:"rules,omitempty"`
+}
+
+// MeterReading is a single value posted for a meter
+type MeterReading struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	MeterID      uint      `gorm:"index" json:"meter_id"`
+	Value        float64   `json:"value"`
+	ReadAt       time.Time `json:"read_at"`
+	RecordedByID uint      `json:"recorded_by_id"`
+}
+
+// MeterRule opens a Preventive request when a meter crosses its limit
+type MeterRule struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	MeterID       uint          `gorm:"index" json:"meter_id"`
+	Kind          MeterRuleKind `json:"kind"`
+	Subject       string        `json:"subject"`
+	DurationHours float64       `json:"duration_hours"`
+
+	Every           float64 `json:"every"`            // Kind Every: usage between services
+	BaselineReading float64 `json:"baseline_reading"` // Kind Every: reading of the last service
+	Threshold       float64 `json:"threshold"`        // Kind Threshold: limit to exceed
+	Tripped         bool    `json:"tripped"`          // Kind Threshold: re-armed once the reading drops back
+
+	Active      bool `gorm:"default:true" json:"active"`
+	CreatedByID uint `json:"created_by_id"`
+}
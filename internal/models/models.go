@@ -25,16 +25,23 @@ type User struct {
 	Name               string    `json:"name"`
 	Email              string    `gorm:"unique" json:"email"`
 	Password           string    `json:"-"` // Don't expose password hash
-	Role               string    `json:"role"`
+	Role               Role      `json:"role"`
 	TeamID             *uint     `json:"team_id"`
 	PasswordResetToken string    `json:"-"`
 	PasswordResetAt    time.Time `json:"-"`
+	HourlyRate         float64   `json:"hourly_rate"` // Labor cost per hour; falls back to the team rate
+	Disabled           bool      `json:"disabled"`    // Disabled users cannot sign in
+	TOTPSecret         string    `json:"-"`           // Base32 TOTP secret, set when enrollment starts
+	TOTPEnabled        bool      `json:"totp_enabled"` // Login requires a TOTP or recovery code
+	TOTPLastStep       int64     `json:"-"`           // Time step of the last accepted code, so codes can't be replayed
+	OIDCSubject        string    `gorm:"column:oidc_subject" json:"-"` // Subject at the single sign-on provider, once linked
 }
 
 type MaintenanceTeam struct {
-	ID      uint   `gorm:"primaryKey" json:"id"`
-	Name    string `json:"name"`
-	Members []User `gorm:"foreignKey:TeamID" json:"members,omitempty"`
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	Name       string  `json:"name"`
+	HourlyRate float64 `json:"hourly_rate"` // Default labor cost per hour for the team
+	Members    []User  `gorm:"foreignKey:TeamID" json:"members,omitempty"`
 }
 
 type Equipment struct {
@@ -45,7 +52,13 @@ type Equipment struct {
 	SerialNumber      string          `json:"serial_number"`
 	PurchaseDate      time.Time       `json:"purchase_date"`
 	WarrantyInfo      string          `json:"warranty_info"`
-	Location          string          `json:"location"`
+	Location          string          `json:"location"` // Legacy free-text location, superseded by LocationID
+
+	LocationID        *uint           `gorm:"index" json:"location_id"`
+	LocationNode      *Location       `gorm:"foreignKey:LocationID" json:"location_node,omitempty"`
+
+	ParentID          *uint           `gorm:"index" json:"parent_id"` // Assembly this equipment is part of
+	Children          []Equipment     `gorm:"foreignKey:ParentID" json:"children,omitempty"`
 	
 	MaintenanceTeamID uint            `json:"maintenance_team_id"`
 	MaintenanceTeam   MaintenanceTeam `gorm:"foreignKey:MaintenanceTeamID" json:"maintenance_team,omitempty"`
@@ -57,6 +70,7 @@ type Equipment struct {
 	Employee          *User           `gorm:"foreignKey:EmployeeID" json:"employee,omitempty"`
 
 	IsUsable          bool            `gorm:"default:true" json:"is_usable"`
+	ArchivedAt        *time.Time      `gorm:"index" json:"archived_at"` // Archived equipment is left out of listings until restored
 }
 
 type MaintenanceRequest struct {
@@ -83,4 +97,17 @@ type MaintenanceRequest struct {
 	
 	ScheduledDate *time.Time `json:"scheduled_date"`
 	DurationHours float64    `json:"duration_hours"`
+	RepairedAt    *time.Time `json:"repaired_at"` // Set when the request enters Repaired
+
+	ScheduleID    *uint `gorm:"index" json:"schedule_id,omitempty"`   // Set when generated from a MaintenanceSchedule
+	MeterRuleID   *uint `gorm:"index" json:"meter_rule_id,omitempty"` // Set when opened by a MeterRule
+
+	PartsUsed     []PartUsage `gorm:"foreignKey:RequestID" json:"parts_used,omitempty"`
+
+	// Costs, recalculated whenever labor, parts or vendor costs change
+	LaborRate     float64 `json:"labor_rate"`
+	LaborCost     float64 `json:"labor_cost"`
+	PartsCost     float64 `json:"parts_cost"`
+	VendorCost    float64 `json:"vendor_cost"`
+	TotalCost     float64 `json:"total_cost"`
 }
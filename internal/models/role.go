@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is what a user is allowed to do; see the policy package for the rules
+type Role string
+
+const (
+	RoleEmployee   Role = "Employee"
+	RoleTechnician Role = "Technician"
+	RoleManager    Role = "Manager"
+)
+
+// Roles lists every role, least privileged first
+var Roles = []Role{RoleEmployee, RoleTechnician, RoleManager}
+
+// ParseRole maps a role name, in any case, to its Role
+func ParseRole(name string) (Role, error) {
+	for _, r := range Roles {
+		if strings.EqualFold(string(r), strings.TrimSpace(name)) {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("role must be Employee, Technician or Manager")
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	for _, known := range Roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether r is a Technician or Manager
+func (r Role) IsStaff() bool {
+	return r == RoleTechnician || r == RoleManager
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Recurrence frequencies (RRULE FREQ)
+type RecurrenceFrequency string
+
+const (
+	FrequencyDaily   RecurrenceFrequency = "DAILY"
+	FrequencyWeekly  RecurrenceFrequency = "WEEKLY"
+	FrequencyMonthly RecurrenceFrequency = "MONTHLY"
+)
+
+// MaintenanceSchedule describes a recurring Preventive job on a piece of equipment.
+// The generator materializes each occurrence as a MaintenanceRequest.
+type MaintenanceSchedule struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Subject       string  `json:"subject"`
+	DurationHours float64 `json:"duration_hours"`
+
+	EquipmentID uint      `json:"equipment_id"`
+	Equipment   Equipment `gorm:"foreignKey:EquipmentID" json:"equipment,omitempty"`
+
+	// Recurrence: every Interval days/weeks/months, optionally on Weekdays
+	// (comma-separated RRULE BYDAY codes, e.g. "MO,TH"), ending at EndDate or after Count occurrences.
+	Frequency RecurrenceFrequency `json:"frequency"`
+	Interval  int                 `gorm:"default:1" json:"interval"`
+	Weekdays  string              `json:"weekdays"`
+	StartDate time.Time           `json:"start_date"`
+	EndDate   *time.Time          `json:"end_date"`
+	Count     int                 `json:"count"` // 0 = unlimited
+
+	Active         bool       `gorm:"default:true" json:"active"`
+	GeneratedCount int        `json:"generated_count"`
+	LastOccurrence *time.Time `json:"last_occurrence"` // Latest occurrence already materialized
+	CreatedByID    uint       `json:"created_by_id"`
+}
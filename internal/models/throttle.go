@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Throttle counts attempts against a key, such as an account or a client IP,
+// within a fixed window. It lives in the database so every replica sees it.
+type Throttle struct {
+	Key          string    `gorm:"primaryKey" json:"key"`
+	Count        int       `json:"count"`
+	WindowStart  time.Time `json:"window_start"`
+	BlockedUntil time.Time `json:"blocked_until"`
+}
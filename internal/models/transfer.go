@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// EquipmentTransfer records a change of an equipment's owner and/or default
+// technician. Both sides are kept for each, so unchanged ones read the same.
+type EquipmentTransfer struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	EquipmentID      uint      `gorm:"index" json:"equipment_id"`
+	FromEmployeeID   *uint     `json:"from_employee_id"`
+	ToEmployeeID     *uint     `json:"to_employee_id"`
+	FromTechnicianID *uint     `json:"from_technician_id"`
+	ToTechnicianID   *uint     `json:"to_technician_id"`
+	TransferredByID  uint      `json:"transferred_by_id"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"created_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook event types
+const (
+	WebhookRequestCreated       = "request.created"        // A request was opened, e.g. a breakdown reported
+	WebhookRequestStatusChanged = "request.status_changed" // A request moved to another status
+	WebhookEquipmentScrapped    = "equipment.scrapped"     // Equipment was marked unusable by a scrapped request
+)
+
+// WebhookEvents lists every event type a webhook can subscribe to
+var WebhookEvents = []string{WebhookRequestCreated, WebhookRequestStatusChanged, WebhookEquipmentScrapped}
+
+// Webhook posts the events it subscribes to to URL, signed with Secret
+type Webhook struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"` // Kept in clear: deliveries are signed with it
+	Events      string    `json:"-"` // Space-separated event types
+	Active      bool      `json:"active"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EventList is Events split into event types
+func (w Webhook) EventList() []string {
+	return strings.Fields(w.Events)
+}
+
+// Subscribed reports whether the webhook is active and wants eventType
+func (w Webhook) Subscribed(eventType string) bool {
+	if !w.Active {
+		return false
+	}
+	for _, e := range w.EventList() {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"   // Waiting for its first attempt or a retry
+	DeliverySucceeded = "succeeded" // The receiver answered 2xx
+	DeliveryFailed    = "failed"    // Every attempt failed; only a redelivery sends it again
+)
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook, with the
+// outcome of its latest attempt. Redeliveries are new deliveries of the same
+// EventID, so receivers can tell duplicates apart.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"index" json:"webhook_id"`
+	EventID        string     `gorm:"index" json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"` // The JSON body as signed and sent
+	Status         string     `gorm:"index" json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"` // Set while pending
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status"` // 0 when no response was received
+	ResponseBody   string     `json:"response_body"`   // Truncated
+	Error          string     `json:"error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,386 @@
+// Package oidc signs users in through an OpenID Connect identity provider
+// using the authorization code flow with PKCE, and maps the groups the
+// provider reports to GearGuard roles and teams.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes the identity provider and how its groups map onto GearGuard
+type Config struct {
+	Name         string // Shown on the login button
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Empty for a public client relying on PKCE alone
+	RedirectURL  string // The web app's callback page
+	Scopes       []string
+	GroupsClaim  string
+
+	// RoleGroups grants a role to members of a group; the most privileged match wins
+	RoleGroups map[string]models.Role
+	// TeamGroups puts members of a group in the named team; the first match wins
+	TeamGroups []TeamGroup
+}
+
+// TeamGroup maps an identity provider group to a maintenance team by name
+type TeamGroup struct {
+	Group string
+	Team  string
+}
+
+// LoadConfig reads the OIDC_* environment variables. It returns nil when
+// OIDC_ISSUER is unset, meaning single sign-on is off.
+//
+//	OIDC_ROLE_GROUPS="gg-managers:Manager,gg-techs:Technician"
+//	OIDC_TEAM_GROUPS="gg-mechanical:Mechanical Team,gg-electrical:Electricians"
+func LoadConfig() (*Config, error) {
+	issuer := strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/")
+	if issuer == "" {
+		return nil, nil
+	}
+	cfg := &Config{
+		Name:         envOr("OIDC_PROVIDER_NAME", "Single Sign-On"),
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  envOr("OIDC_REDIRECT_URL", utils.FrontendURL()+"/oidc/callback"),
+		Scopes:       strings.Fields(envOr("OIDC_SCOPES", "openid email profile")),
+		GroupsClaim:  envOr("OIDC_GROUPS_CLAIM", "groups"),
+		RoleGroups:   map[string]models.Role{},
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+
+	for _, pair := range splitPairs(os.Getenv("OIDC_ROLE_GROUPS")) {
+		role, err := models.ParseRole(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("OIDC_ROLE_GROUPS %q: %w", pair[0], err)
+		}
+		cfg.RoleGroups[pair[0]] = role
+	}
+	for _, pair := range splitPairs(os.Getenv("OIDC_TEAM_GROUPS")) {
+		cfg.TeamGroups = append(cfg.TeamGroups, TeamGroup{Group: pair[0], Team: pair[1]})
+	}
+	return cfg, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitPairs parses "a:b,c:d" into [[a b] [c d]], skipping malformed entries
+func splitPairs(value string) [][2]string {
+	var pairs [][2]string
+	for _, entry := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(entry, ":")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if ok && key != "" && val != "" {
+			pairs = append(pairs, [2]string{key, val})
+		}
+	}
+	return pairs
+}
+
+// RoleFor is the most privileged role granted by groups, if any group grants one
+func (c *Config) RoleFor(groups []string) (models.Role, bool) {
+	best := -1
+	for _, group := range groups {
+		role, ok := c.RoleGroups[group]
+		if !ok {
+			continue
+		}
+		for rank, r := range models.Roles {
+			if r == role && rank > best {
+				best = rank
+			}
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return models.Roles[best], true
+}
+
+// TeamFor is the name of the team the first mapped group in groups points to
+func (c *Config) TeamFor(groups []string) (string, bool) {
+	for _, mapping := range c.TeamGroups {
+		for _, group := range groups {
+			if group == mapping.Group {
+				return mapping.Team, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Identity is what the provider vouches for in a verified ID token
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool // Only when the token says so
+	Name          string
+	Groups        []string
+	HasGroups     bool // The token carried the groups claim, even if empty
+}
+
+// metadata is the subset of the discovery document the flow needs
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one identity provider. Discovery and signing keys are
+// fetched on first use and cached; keys are refetched when a token names an
+// unknown one, so provider key rotation needs no restart.
+type Provider struct {
+	Config
+	Client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys map[string]*rsa.PublicKey
+}
+
+// NewProvider returns a Provider for cfg
+func NewProvider(cfg Config) *Provider {
+	return &Provider{Config: cfg, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NewPKCE returns a random code verifier and its S256 challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes, base64url encoded
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta metadata
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", meta.Issuer, p.Issuer)
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// AuthCodeURL is where to send the browser to sign in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the returned ID token
+// was issued for this client and login attempt (nonce)
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("token request: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+	return p.verify(ctx, tokens.IDToken, nonce)
+}
+
+func (p *Provider) verify(ctx context.Context, idToken, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("id token: %w", err)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return Identity{}, errors.New("id token: nonce mismatch")
+	}
+
+	identity := Identity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Only an explicit true counts; some providers send it as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if raw, ok := claims[p.GroupsClaim]; ok {
+		identity.HasGroups = true
+		switch groups := raw.(type) {
+		case string:
+			identity.Groups = []string{groups}
+		case []interface{}:
+			for _, g := range groups {
+				if s, ok := g.(string); ok {
+					identity.Groups = append(identity.Groups, s)
+				}
+			}
+		}
+	}
+	if identity.Subject == "" {
+		return Identity{}, errors.New("id token: no subject")
+	}
+	return identity, nil
+}
+
+// key returns the provider's signing key kid, refetching the key set once if
+// it is unknown
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
@@ -0,0 +1,295 @@
+// Package oidctest is a minimal OpenID Connect identity provider for tests
+// and local development. It implements discovery, the authorization code flow
+// with PKCE (S256) and RS256-signed ID tokens, with no real authentication:
+// the user is picked by login_hint, or from a list when it is missing.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-1"
+
+// User is an account known to the mock provider
+type User struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email"`
+	Name    string   `json:"name"`
+	Groups  []string `json:"groups"`
+
+	// EmailVerified is sent as the email_verified claim, true when nil.
+	// OmitClaim leaves the claim out.
+	EmailVerified interface{} `json:"email_verified,omitempty"`
+}
+
+// OmitClaim as a User's EmailVerified leaves the claim out of its ID tokens
+const OmitClaim = "omit"
+
+// authorization is an issued, not yet redeemed, authorization code
+type authorization struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	expires     time.Time
+}
+
+// Provider is the mock identity provider. Serve it with httptest via
+// NewServer, or mount Handler on any server whose base URL is Issuer.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Required at the token endpoint when set
+	Users        []User
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// New returns a provider for issuer with a fresh signing key
+func New(issuer, clientID, clientSecret string, users ...User) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Users:        users,
+		key:          key,
+		codes:        map[string]authorization{},
+	}, nil
+}
+
+// Server is a Provider listening on a local httptest server
+type Server struct {
+	*Provider
+	*httptest.Server
+}
+
+// NewServer starts a provider on a local port; Close it when done
+func NewServer(clientID, clientSecret string, users ...User) (*Server, error) {
+	server := httptest.NewUnstartedServer(nil)
+	provider, err := New("http://"+server.Listener.Addr().String(), clientID, clientSecret, users...)
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	server.Config.Handler = provider.Handler()
+	server.Start()
+	return &Server{Provider: provider, Server: server}, nil
+}
+
+// Handler serves the provider's endpoints
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+func (p *Provider) findUser(hint string) (User, bool) {
+	for _, u := range p.Users {
+		if strings.EqualFold(u.Email, hint) || u.Subject == hint {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != p.ClientID || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := p.findUser(q.Get("login_hint"))
+	if !ok {
+		// No user chosen yet: list them as links that repeat the request with a hint
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<h3>Mock identity provider</h3><p>Sign in as:</p><ul>")
+		for _, u := range p.Users {
+			pick := url.Values{}
+			for k, v := range q {
+				pick[k] = v
+			}
+			pick.Set("login_hint", u.Email)
+			fmt.Fprintf(w, "<li><a href=\"/authorize?%s\">%s</a> (%s)</li>",
+				html.EscapeString(pick.Encode()), html.EscapeString(u.Name), html.EscapeString(strings.Join(u.Groups, ", ")))
+		}
+		fmt.Fprint(w, "</ul>")
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		user:        user,
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	back := url.Values{}
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	sep := "?"
+	if strings.Contains(q.Get("redirect_uri"), "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, q.Get("redirect_uri")+sep+back.Encode(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || (p.ClientSecret != "" && secret != p.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	// Codes are single use, even when redemption fails
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(auth.expires) || auth.clientID != clientID || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, expired or mismatched code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError(w, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	idToken, err := p.IDToken(auth.user, clientID, auth.nonce)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// IDToken signs an ID token for user, as the token endpoint would
+func (p *Provider) IDToken(user User, audience, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":    p.Issuer,
+		"sub":    user.Subject,
+		"aud":    audience,
+		"iat":    now.Unix(),
+		"exp":    now.Add(5 * time.Minute).Unix(),
+		"email":  user.Email,
+		"name":   user.Name,
+		"groups": user.Groups,
+	}
+	switch user.EmailVerified {
+	case nil:
+		claims["email_verified"] = true
+	case OmitClaim:
+	default:
+		claims["email_verified"] = user.EmailVerified
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(p.key)
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
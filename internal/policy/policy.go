@@ -0,0 +1,242 @@
+// Package policy decides which roles may perform which action on which
+// resource. Every protected route is wrapped in a Guard built with Require.
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gearguard/internal/models"
+	"gearguard/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Action is what a route does to its resource
+type Action string
+
+const (
+	Read   Action = "read"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Resource is the kind of record a route works on
+type Resource string
+
+const (
+	Team         Resource = "team"
+	User         Resource = "user"
+	Equipment    Resource = "equipment"
+	Request      Resource = "request"
+	Workflow     Resource = "workflow"
+	History      Resource = "history"
+	Comment      Resource = "comment"
+	Attachment   Resource = "attachment"
+	Part         Resource = "part"
+	Stock        Resource = "stock"
+	Cost         Resource = "cost"
+	Analytics    Resource = "analytics"
+	Location     Resource = "location"
+	Schedule     Resource = "schedule"
+	Meter        Resource = "meter"
+	MeterReading Resource = "meter_reading"
+	MeterRule    Resource = "meter_rule"
+	Dashboard    Resource = "dashboard"
+	Session      Resource = "session"
+	MFA          Resource = "mfa"
+	Invitation   Resource = "invitation"
+	APIKey       Resource = "api_key"
+	Transfer     Resource = "equipment_transfer"
+	Calendar     Resource = "calendar"
+	CalendarFeed Resource = "calendar_feed"
+	Event        Resource = "event"
+	Webhook      Resource = "webhook"
+)
+
+var (
+	everyone = []models.Role{models.RoleEmployee, models.RoleTechnician, models.RoleManager}
+	staff    = []models.Role{models.RoleTechnician, models.RoleManager}
+	managers = []models.Role{models.RoleManager}
+)
+
+// rules lists the roles allowed for each action on each resource. Anything not
+// listed is denied. Handlers still apply record-level checks (ownership,
+// assignment, visibility) on top of these.
+var rules = map[Resource]map[Action][]models.Role{
+	Team:         {Read: everyone, Create: managers, Update: managers},
+	User:         {Read: everyone, Update: managers},
+	Equipment:    {Read: everyone, Create: staff, Update: staff, Delete: managers}, // Delete archives, and restores
+	Transfer:     {Read: everyone, Create: managers},
+	Request:      {Read: everyone, Create: everyone, Update: everyone},
+	Workflow:     {Read: everyone},
+	History:      {Read: everyone},
+	Comment:      {Read: everyone, Create: everyone, Update: everyone, Delete: everyone},
+	Attachment:   {Read: everyone, Create: everyone, Delete: everyone},
+	Part:         {Read: everyone, Create: staff, Update: staff},
+	Stock:        {Read: everyone, Update: staff},
+	Cost:         {Read: everyone, Create: staff, Delete: managers},
+	Analytics:    {Read: everyone},
+	Location:     {Read: everyone, Create: managers, Update: managers, Delete: managers},
+	Schedule:     {Read: everyone, Create: staff, Update: staff, Delete: staff},
+	Meter:        {Read: everyone, Create: staff},
+	MeterReading: {Read: everyone, Create: everyone},
+	MeterRule:    {Create: staff, Delete: staff},
+	Dashboard:    {Read: everyone},
+	Session:      {Delete: everyone},
+	MFA:          {Create: everyone, Delete: everyone},
+	Invitation:   {Read: managers, Create: managers, Update: managers, Delete: managers},
+	APIKey:       {Read: everyone, Create: everyone, Delete: everyone},
+	Calendar:     {Read: everyone},
+	CalendarFeed: {Read: everyone, Create: everyone, Delete: everyone},
+	Event:        {Read: everyone}, // Each stream only carries what its user may see
+	Webhook:      {Read: managers, Create: managers, Update: managers, Delete: managers},
+}
+
+// Allowed reports whether role may perform action on resource
+func Allowed(role models.Role, action Action, resource Resource) bool {
+	for _, r := range rules[resource][action] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Guard serves Next only if the role in the request context is allowed to
+// perform Action on Resource; it must run behind middleware.Auth.
+type Guard struct {
+	Action   Action
+	Resource Resource
+	Next     http.Handler
+}
+
+func (g Guard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	role, _ := r.Context().Value(utils.RoleKey).(models.Role)
+	if !Allowed(role, g.Action, g.Resource) {
+		utils.RespondError(w, http.StatusForbidden, fmt.Sprintf("Role %q cannot %s %s", role, g.Action, g.Resource))
+		return
+	}
+	if scopes, ok := r.Context().Value(utils.ScopesKey).([]string); ok && !ScopeAllows(scopes, g.Action, g.Resource) {
+		utils.RespondError(w, http.StatusForbidden, fmt.Sprintf("API key is not scoped for %s:%s", g.Resource, g.Action))
+		return
+	}
+	g.Next.ServeHTTP(w, r)
+}
+
+// Require guards next with the rule for action on resource. It panics if no
+// rule exists, so a typo in a route table fails at startup.
+func Require(action Action, resource Resource, next http.HandlerFunc) http.Handler {
+	if _, ok := rules[resource][action]; !ok {
+		panic(fmt.Sprintf("policy: no rule for %s %s", action, resource))
+	}
+	return Guard{Action: action, Resource: resource, Next: next}
+}
+
+// VerifyRoutes fails if any route registered on router is not wrapped in a Guard
+func VerifyRoutes(router *mux.Router) error {
+	return router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		handler := route.GetHandler()
+		if handler == nil {
+			return nil // Subrouter prefix, its routes are walked separately
+		}
+		if _, ok := handler.(Guard); !ok {
+			path, _ := route.GetPathTemplate()
+			methods, _ := route.GetMethods()
+			return fmt.Errorf("route %v %s has no policy", methods, path)
+		}
+		return nil
+	})
+}
+
+// unscoped resources can't be reached with an API key whatever its scopes, so
+// a leaked key can't mint more keys or feed URLs, or take over its owner's
+// sessions
+var unscoped = map[Resource]bool{Session: true, MFA: true, APIKey: true, CalendarFeed: true}
+
+// ParseScope splits an API key scope of the form "resource:action", where
+// either side may be "*"
+func ParseScope(scope string) (Resource, Action, error) {
+	resource, action, ok := strings.Cut(strings.TrimSpace(scope), ":")
+	if !ok {
+		return "", "", fmt.Errorf("scope %q must look like resource:action", scope)
+	}
+	if _, known := rules[Resource(resource)]; resource != "*" && (!known || unscoped[Resource(resource)]) {
+		return "", "", fmt.Errorf("scope %q names an unknown or unavailable resource", scope)
+	}
+	switch Action(action) {
+	case Read, Create, Update, Delete, "*":
+	default:
+		return "", "", fmt.Errorf("scope %q names an unknown action", scope)
+	}
+	return Resource(resource), Action(action), nil
+}
+
+// ValidateScopes checks scopes for a key owned by role: each must parse, and
+// exact scopes must be something the role can do at all
+func ValidateScopes(role models.Role, scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		resource, action, err := ParseScope(scope)
+		if err != nil {
+			return err
+		}
+		if resource != "*" && action != "*" && !Allowed(role, action, resource) {
+			return fmt.Errorf("role %q cannot %s %s", role, action, resource)
+		}
+	}
+	return nil
+}
+
+// ScopeAllows reports whether API key scopes cover action on resource
+func ScopeAllows(scopes []string, action Action, resource Resource) bool {
+	if unscoped[resource] {
+		return false
+	}
+	for _, scope := range scopes {
+		r, a, err := ParseScope(scope)
+		if err == nil && (r == "*" || r == resource) && (a == "*" || a == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// mfaRoles are the roles that must sign in with a second factor
+var mfaRoles = map[models.Role]bool{}
+
+// LoadMFARoles reads MFA_REQUIRED_ROLES, a comma-separated list of roles whose
+// users must enroll in two-factor authentication before they can sign in
+func LoadMFARoles() error {
+	var roles []models.Role
+	for _, name := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		role, err := models.ParseRole(name)
+		if err != nil {
+			return fmt.Errorf("MFA_REQUIRED_ROLES: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	SetMFARoles(roles...)
+	return nil
+}
+
+// SetMFARoles replaces the roles that must use two-factor authentication
+func SetMFARoles(roles ...models.Role) {
+	mfaRoles = map[models.Role]bool{}
+	for _, role := range roles {
+		mfaRoles[role] = true
+	}
+}
+
+// MFARequired reports whether users with role must use two-factor authentication
+func MFARequired(role models.Role) bool {
+	return mfaRoles[role]
+}
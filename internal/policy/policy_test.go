@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gearguard/internal/models"
+	"gearguard/internal/utils"
+)
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   models.Role
+		scopes []string
+		err    string // Substring of the error, "" when valid
+	}{
+		{"exact scopes the role has", models.RoleTechnician, []string{"equipment:read", "request:update"}, ""},
+		{"wildcards", models.RoleEmployee, []string{"*:read", "request:*", "*:*"}, ""},
+		{"surrounding spaces", models.RoleManager, []string{" webhook:create "}, ""},
+		{"no scopes", models.RoleManager, nil, "at least one scope"},
+		{"missing action", models.RoleManager, []string{"equipment"}, "resource:action"},
+		{"unknown resource", models.RoleManager, []string{"gadget:read"}, "unknown or unavailable resource"},
+		{"unscoped resource", models.RoleManager, []string{"api_key:create"}, "unknown or unavailable resource"},
+		{"unscoped resource with any action", models.RoleEmployee, []string{"session:*"}, "unknown or unavailable resource"},
+		{"unknown action", models.RoleManager, []string{"equipment:archive"}, "unknown action"},
+		{"beyond the role", models.RoleEmployee, []string{"equipment:read", "equipment:create"}, `role "Employee" cannot create equipment`},
+		{"not allowed to anyone", models.RoleManager, []string{"workflow:update"}, "cannot update workflow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScopes(tt.role, tt.scopes)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("expected %v to be valid, got %v", tt.scopes, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		action   Action
+		resource Resource
+		allowed  bool
+	}{
+		{"exact match", []string{"equipment:read"}, Read, Equipment, true},
+		{"other action", []string{"equipment:read"}, Update, Equipment, false},
+		{"other resource", []string{"equipment:read"}, Read, Request, false},
+		{"any action", []string{"request:*"}, Delete, Request, true},
+		{"any resource", []string{"*:read"}, Read, Stock, true},
+		{"everything", []string{"*:*"}, Create, Part, true},
+		{"one of several", []string{"part:read", "stock:update"}, Update, Stock, true},
+		{"malformed scopes are ignored", []string{"stock", "stock:write"}, Update, Stock, false},
+		{"no scopes", nil, Read, Equipment, false},
+		{"unscoped resources even with everything", []string{"*:*"}, Create, APIKey, false},
+		{"sessions", []string{"*:*"}, Delete, Session, false},
+		{"calendar feeds", []string{"*:*"}, Create, CalendarFeed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeAllows(tt.scopes, tt.action, tt.resource); got != tt.allowed {
+				t.Fatalf("ScopeAllows(%v, %s, %s) = %v, want %v", tt.scopes, tt.action, tt.resource, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestGuardAppliesScopesOnTopOfTheRole(t *testing.T) {
+	guard := Require(Update, Equipment, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	call := func(role models.Role, scopes []string) int {
+		ctx := context.WithValue(context.Background(), utils.RoleKey, role)
+		if scopes != nil {
+			ctx = context.WithValue(ctx, utils.ScopesKey, scopes)
+		}
+		w := httptest.NewRecorder()
+		guard.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/equipment/1", nil).WithContext(ctx))
+		return w.Code
+	}
+
+	if code := call(models.RoleTechnician, nil); code != http.StatusNoContent {
+		t.Fatalf("expected a session to be allowed by role, got %d", code)
+	}
+	if code := call(models.RoleTechnician, []string{"equipment:*"}); code != http.StatusNoContent {
+		t.Fatalf("expected a key scoped for equipment to be allowed, got %d", code)
+	}
+	if code := call(models.RoleTechnician, []string{"equipment:read"}); code != http.StatusForbidden {
+		t.Fatalf("expected a read-only key to be refused, got %d", code)
+	}
+	// A broad scope doesn't lift the owner's role
+	if code := call(models.RoleEmployee, []string{"*:*"}); code != http.StatusForbidden {
+		t.Fatalf("expected an employee's key to be refused, got %d", code)
+	}
+}
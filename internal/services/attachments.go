@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register GIF decoder for thumbnails
+	"image/jpeg"
+	_ "image/png" // Register PNG decoder for thumbnails
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+	"gearguard/internal/storage"
+	"gearguard/internal/utils"
+)
+
+const thumbnailSize = 256
+
+// maxThumbnailPixels is the largest image thumbnailed; decoding allocates for
+// every pixel, whatever the size of the upload
+const maxThumbnailPixels = 40_000_000
+
+// Content types accepted for attachments (as detected from the file content)
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":                true,
+	"image/png":                 true,
+	"image/gif":                 true,
+	"application/pdf":           true,
+	"text/plain; charset=utf-8": true,
+	"application/zip":           true, // Office documents (docx, xlsx) are zip archives
+}
+
+// MaxAttachmentSize is the upload limit in bytes (ATTACHMENT_MAX_MB, default 10)
+func MaxAttachmentSize() int64 {
+	return int64(envInt("ATTACHMENT_MAX_MB", 10)) << 20
+}
+
+// SaveAttachment validates and stores an uploaded file, generating a thumbnail
+// for images, and records it against the given entity.
+func SaveAttachment(ctx context.Context, entityType string, entityID uint, fileName string, content []byte, uploadedByID uint) (*models.Attachment, error) {
+	if int64(len(content)) > MaxAttachmentSize() {
+		return nil, fmt.Errorf("file exceeds the %d MB limit", MaxAttachmentSize()>>20)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	contentType := http.DetectContentType(content)
+	if !allowedAttachmentTypes[contentType] {
+		return nil, fmt.Errorf("file type %s is not allowed", contentType)
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%d/%s%s", strings.ToLower(entityType), entityID, id, strings.ToLower(path.Ext(fileName)))
+
+	if err := storage.Files.Put(ctx, key, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		EntityType:   entityType,
+		EntityID:     entityID,
+		FileName:     path.Base(fileName),
+		ContentType:  contentType,
+		Size:         int64(len(content)),
+		StorageKey:   key,
+		UploadedByID: uploadedByID,
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		if thumb, err := makeThumbnail(content); err == nil {
+			thumbKey := key + ".thumb.jpg"
+			if err := storage.Files.Put(ctx, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err == nil {
+				attachment.ThumbnailKey = thumbKey
+				attachment.HasThumbnail = true
+			}
+		}
+	}
+
+	if err := database.DB.Create(attachment).Error; err != nil {
+		storage.Files.Delete(ctx, key)
+		if attachment.ThumbnailKey != "" {
+			storage.Files.Delete(ctx, attachment.ThumbnailKey)
+		}
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment removes an attachment record and its stored files.
+func DeleteAttachment(ctx context.Context, attachment *models.Attachment) error {
+	if err := database.DB.Delete(attachment).Error; err != nil {
+		return err
+	}
+	storage.Files.Delete(ctx, attachment.StorageKey)
+	if attachment.ThumbnailKey != "" {
+		storage.Files.Delete(ctx, attachment.ThumbnailKey)
+	}
+	return nil
+}
+
+// makeThumbnail scales an image down to fit in thumbnailSize x thumbnailSize (box filter) as JPEG.
+// Images over maxThumbnailPixels are refused before being decoded.
+func makeThumbnail(content []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return nil, fmt.Errorf("image is too large to thumbnail (%dx%d)", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	scale := float64(thumbnailSize) / float64(w)
+	if hs := float64(thumbnailSize) / float64(h); hs < scale {
+		scale = hs
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	tw, th := int(float64(w)*scale), int(float64(h)*scale)
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n > 0 {
+				dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SignedDownloadURL returns a time-limited download path for an attachment that
+// can be used without an Authorization header (e.g. in <img src>).
+func SignedDownloadURL(attachment *models.Attachment, thumbnail bool, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+	variant := "file"
+	if thumbnail {
+		variant = "thumbnail"
+	}
+	q := url.Values{}
+	q.Set("variant", variant)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", downloadSignature(attachment.ID, variant, expires))
+	return fmt.Sprintf("/api/attachments/%d/download?%s", attachment.ID, q.Encode())
+}
+
+// VerifyDownloadSignature checks a signature produced by SignedDownloadURL.
+func VerifyDownloadSignature(attachmentID uint, variant string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	expected := downloadSignature(attachmentID, variant, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func downloadSignature(attachmentID uint, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, utils.ActiveSigningKey())
+	fmt.Fprintf(mac, "attachment:%d:%s:%d", attachmentID, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OpenAttachment returns the stored content of an attachment or its thumbnail.
+func OpenAttachment(ctx context.Context, attachment *models.Attachment, thumbnail bool) (io.ReadCloser, error) {
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			return nil, storage.ErrNotFound
+		}
+		return storage.Files.Get(ctx, attachment.ThumbnailKey)
+	}
+	return storage.Files.Get(ctx, attachment.StorageKey)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMakeThumbnailFitsTheBox(t *testing.T) {
+	thumb, err := makeThumbnail(encodePNG(t, 1024, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != thumbnailSize || b.Dy() != thumbnailSize/2 {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", b.Dx(), b.Dy(), thumbnailSize, thumbnailSize/2)
+	}
+}
+
+func TestMakeThumbnailRefusesHugeImages(t *testing.T) {
+	// A few hundred bytes declaring 50000x50000 pixels: rewrite the IHDR
+	// dimensions of a tiny PNG and fix its checksum
+	content := encodePNG(t, 1, 1)
+	binary.BigEndian.PutUint32(content[16:], 50000)
+	binary.BigEndian.PutUint32(content[20:], 50000)
+	binary.BigEndian.PutUint32(content[29:], crc32.ChecksumIEEE(content[12:29]))
+
+	_, err := makeThumbnail(content)
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected the image to be refused before decoding, got %v", err)
+	}
+}
+
+func TestSaveAttachmentRejectsUnsupportedTypes(t *testing.T) {
+	webp := append([]byte("RIFF\x24\x00\x00\x00WEBPVP8 "), make([]byte, 32)...)
+	for name, content := range map[string][]byte{
+		"photo.webp": webp,
+		"tool.exe":   append([]byte("MZ\x90\x00"), make([]byte, 64)...),
+	} {
+		if _, err := SaveAttachment(context.Background(), "Equipment", 1, name, content, 1); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("%s: expected the type to be refused, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+)
+
+// Audited entity types
+const (
+	EntityRequest      = "MaintenanceRequest"
+	EntityEquipment    = "Equipment"
+	EntityTeam         = "MaintenanceTeam"
+	EntityUser         = "User"
+	EntityComment      = "RequestComment"
+	EntityInvitation   = "Invitation"
+	EntityAPIKey       = "APIKey"
+	EntityCalendarFeed = "CalendarFeed"
+	EntityWebhook      = "Webhook"
+)
+
+// Fields that change on every save and carry no audit value
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// RecordAudit appends an audit entry for an entity. before is nil for creations
+// and after is nil for deletions; only fields that differ are stored. Failures are
+// logged rather than returned so auditing never blocks the change itself.
+func RecordAudit(actorID uint, entityType string, entityID uint, action string, before, after interface{}) {
+	changes := DiffFields(before, after)
+	if action == models.AuditUpdate && len(changes) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("Audit: failed to encode changes for %s %d: %v", entityType, entityID, err)
+		return
+	}
+
+	entry := models.AuditLog{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    data,
+	}
+	if actorID != 0 {
+		entry.ActorID = &actorID
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("Audit: failed to record %s of %s %d: %v", action, entityType, entityID, err)
+	}
+}
+
+// DiffFields compares the JSON representation of two values field by field.
+// Nested objects (preloaded associations) are skipped; their foreign keys are compared instead.
+func DiffFields(before, after interface{}) map[string]models.FieldChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	changes := map[string]models.FieldChange{}
+	for key, value := range afterFields {
+		if old, ok := beforeFields[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes[key] = models.FieldChange{Before: beforeFields[key], After: value}
+		}
+	}
+	for key, old := range beforeFields {
+		if _, ok := afterFields[key]; !ok {
+			changes[key] = models.FieldChange{Before: old, After: nil}
+		}
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fields
+	}
+
+	for key, value := range raw {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		fields[key] = value
+	}
+	return fields
+}
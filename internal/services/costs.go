@@ -0,0 +1,106 @@
+package services
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CostBreakdown sums the cost columns of a set of requests
+type CostBreakdown struct {
+	LaborCost  float64 `json:"labor_cost"`
+	PartsCost  float64 `json:"parts_cost"`
+	VendorCost float64 `json:"vendor_cost"`
+	TotalCost  float64 `json:"total_cost"`
+}
+
+// MonthlyCost is the cost of one request type in one month
+type MonthlyCost struct {
+	Month string             `json:"month"` // YYYY-MM
+	Type  models.RequestType `json:"type"`
+	CostBreakdown
+}
+
+// LaborRate resolves the hourly labor rate for a request: the technician's own
+// rate, else the team rate, else DEFAULT_LABOR_RATE.
+func LaborRate(db *gorm.DB, req *models.MaintenanceRequest) float64 {
+	if req.TechnicianID != nil {
+		var tech models.User
+		if db.First(&tech, *req.TechnicianID).Error == nil && tech.HourlyRate > 0 {
+			return tech.HourlyRate
+		}
+	}
+
+	var team models.MaintenanceTeam
+	if db.First(&team, req.TeamID).Error == nil && team.HourlyRate > 0 {
+		return team.HourlyRate
+	}
+
+	rate, _ := strconv.ParseFloat(os.Getenv("DEFAULT_LABOR_RATE"), 64)
+	return rate
+}
+
+// RecalculateCosts recomputes the labor, parts and vendor totals of a request.
+// The labor rate is frozen once the request is closed so later rate changes
+// don't rewrite history.
+func RecalculateCosts(db *gorm.DB, requestID uint) error {
+	var req models.MaintenanceRequest
+	if err := db.First(&req, requestID).Error; err != nil {
+		return err
+	}
+
+	closed := req.Status == models.StatusRepaired || req.Status == models.StatusScrap
+	rate := req.LaborRate
+	if !closed || rate == 0 {
+		rate = LaborRate(db, &req)
+	}
+
+	var partsCost, vendorCost float64
+	if err := db.Model(&models.PartUsage{}).Where("request_id = ?", requestID).
+		Select("COALESCE(SUM(quantity * unit_cost), 0)").Scan(&partsCost).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.VendorCost{}).Where("request_id = ?", requestID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&vendorCost).Error; err != nil {
+		return err
+	}
+
+	laborCost := rate * req.DurationHours
+	return db.Model(&models.MaintenanceRequest{}).Where("id = ?", requestID).UpdateColumns(map[string]interface{}{
+		"labor_rate":  rate,
+		"labor_cost":  laborCost,
+		"parts_cost":  partsCost,
+		"vendor_cost": vendorCost,
+		"total_cost":  laborCost + partsCost + vendorCost,
+	}).Error
+}
+
+// CostTotals sums request costs, optionally restricted by the given query scope.
+func CostTotals(scope func(*gorm.DB) *gorm.DB) CostBreakdown {
+	var totals CostBreakdown
+	query := database.DB.Model(&models.MaintenanceRequest{})
+	if scope != nil {
+		query = scope(query)
+	}
+	query.Select("COALESCE(SUM(labor_cost), 0) AS labor_cost, COALESCE(SUM(parts_cost), 0) AS parts_cost, " +
+		"COALESCE(SUM(vendor_cost), 0) AS vendor_cost, COALESCE(SUM(total_cost), 0) AS total_cost").Scan(&totals)
+	return totals
+}
+
+// EquipmentMonthlyCosts breaks down the costs of an equipment by month (of request creation) and request type.
+func EquipmentMonthlyCosts(equipmentID uint, from, to time.Time) ([]MonthlyCost, error) {
+	var rows []MonthlyCost
+	err := database.DB.Model(&models.MaintenanceRequest{}).
+		Select("to_char(date_trunc('month', created_at), 'YYYY-MM') AS month, type, "+
+			"SUM(labor_cost) AS labor_cost, SUM(parts_cost) AS parts_cost, SUM(vendor_cost) AS vendor_cost, SUM(total_cost) AS total_cost").
+		Where("equipment_id = ? AND created_at >= ? AND created_at < ?", equipmentID, from, to).
+		Group("month, type").
+		Order("month, type").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"gearguard/internal/database/dbtest"
+	"gearguard/internal/models"
+)
+
+func TestRecalculateCosts(t *testing.T) {
+	db := dbtest.Open(t)
+	t.Setenv("DEFAULT_LABOR_RATE", "20")
+	manager, equipment := seed(t, db)
+	tech := models.User{Name: "Tom Tech", Email: "tom@example.com", Role: models.RoleTechnician, HourlyRate: 50}
+	if err := db.Create(&tech).Error; err != nil {
+		t.Fatal(err)
+	}
+	part := models.Part{PartNumber: "BRG-1", Name: "Bearing", UnitCost: 12.5}
+	db.Create(&part)
+
+	req := models.MaintenanceRequest{Subject: "Overhaul", Type: models.TypeCorrective, EquipmentID: equipment.ID,
+		TeamID: equipment.MaintenanceTeamID, CreatedByID: manager.ID, DurationHours: 2}
+	db.Create(&req)
+	costs := func() models.MaintenanceRequest {
+		t.Helper()
+		if err := RecalculateCosts(db, req.ID); err != nil {
+			t.Fatal(err)
+		}
+		var got models.MaintenanceRequest
+		db.First(&got, req.ID)
+		return got
+	}
+
+	// Without a technician or a team rate, DEFAULT_LABOR_RATE applies
+	if got := costs(); got.LaborRate != 20 || got.LaborCost != 40 || got.TotalCost != 40 {
+		t.Fatalf("expected the default rate, got %+v", got)
+	}
+
+	db.Model(&models.MaintenanceTeam{}).Where("id = ?", equipment.MaintenanceTeamID).Update("hourly_rate", 30)
+	db.Create(&models.PartUsage{RequestID: req.ID, PartID: part.ID, Location: "Main", Quantity: 2, UnitCost: part.UnitCost})
+	db.Create(&models.VendorCost{RequestID: req.ID, Vendor: "Acme", Amount: 100})
+	got := costs()
+	if got.LaborRate != 30 || got.LaborCost != 60 || got.PartsCost != 25 || got.VendorCost != 100 || got.TotalCost != 185 {
+		t.Fatalf("expected the team rate plus parts and vendor costs, got %+v", got)
+	}
+
+	// The technician's own rate wins until the request is closed, then it is frozen
+	db.Model(&req).Update("technician_id", tech.ID)
+	if got := costs(); got.LaborRate != 50 || got.TotalCost != 225 {
+		t.Fatalf("expected the technician's rate, got %+v", got)
+	}
+	db.Model(&req).Update("status", models.StatusRepaired)
+	db.Model(&tech).Update("hourly_rate", 80)
+	if got := costs(); got.LaborRate != 50 || got.LaborCost != 100 {
+		t.Fatalf("expected the rate to stay frozen once repaired, got %+v", got)
+	}
+}
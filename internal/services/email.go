@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"html"
 	"log"
 	"os"
 	"strconv"
@@ -51,3 +52,13 @@ func SendNewRequestNotification(technicianEmail string, userEmail string, subjec
 		go SendEmail([]string{userEmail}, "Request Confirmation: "+subject, body)
 	}
 }
+
+func SendCommentNotification(recipients []string, requestSubject string, authorName string, comment string) {
+	body := fmt.Sprintf("<h3>New Comment</h3><p><b>%s</b> commented on <b>%s</b>:</p><blockquote>%s</blockquote>",
+		html.EscapeString(authorName), html.EscapeString(requestSubject), html.EscapeString(comment))
+	for _, to := range recipients {
+		if to != "" {
+			go SendEmail([]string{to}, "New Comment: "+requestSubject, body)
+		}
+	}
+}
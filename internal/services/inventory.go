@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientStock is returned when an issue would make stock negative
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidPartUsage is returned for parts that don't exist or non-positive quantities
+var ErrInvalidPartUsage = errors.New("invalid part usage")
+
+// MoveStock changes the quantity of a part at a location by delta inside tx and
+// writes the movement to the ledger. Stock can never go below zero.
+func MoveStock(tx *gorm.DB, partID uint, location string, delta float64, kind string, requestID *uint, note string, userID uint) error {
+	var stock models.Stock
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("part_id = ? AND location = ?", partID, location).First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		stock = models.Stock{PartID: partID, Location: location}
+	} else if err != nil {
+		return err
+	}
+
+	if stock.Quantity+delta < 0 {
+		return fmt.Errorf("%w: %g available at %q", ErrInsufficientStock, stock.Quantity, location)
+	}
+	stock.Quantity += delta
+	if err := tx.Save(&stock).Error; err != nil {
+		return err
+	}
+
+	movement := models.StockMovement{
+		PartID:       partID,
+		Location:     location,
+		Kind:         kind,
+		Quantity:     delta,
+		RequestID:    requestID,
+		Note:         note,
+		RecordedByID: userID,
+	}
+	return tx.Create(&movement).Error
+}
+
+// ConsumeParts issues the given parts from stock for a request and records their usage inside tx.
+func ConsumeParts(tx *gorm.DB, requestID uint, parts []models.PartUsage, userID uint) ([]models.PartUsage, error) {
+	var recorded []models.PartUsage
+	for _, usage := range parts {
+		if usage.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity for part %d must be positive", ErrInvalidPartUsage, usage.PartID)
+		}
+
+		var part models.Part
+		if err := tx.First(&part, usage.PartID).Error; err != nil {
+			return nil, fmt.Errorf("%w: part %d not found", ErrInvalidPartUsage, usage.PartID)
+		}
+
+		if err := MoveStock(tx, part.ID, usage.Location, -usage.Quantity, models.MovementIssue, &requestID, "", userID); err != nil {
+			return nil, fmt.Errorf("part %s: %w", part.PartNumber, err)
+		}
+
+		record := models.PartUsage{
+			RequestID:    requestID,
+			PartID:       part.ID,
+			Location:     usage.Location,
+			Quantity:     usage.Quantity,
+			UnitCost:     part.UnitCost,
+			RecordedByID: userID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, record)
+	}
+	return recorded, nil
+}
+
+// TotalStock returns the on-hand quantity of a part across all locations.
+func TotalStock(partID uint) float64 {
+	var total float64
+	database.DB.Model(&models.Stock{}).Where("part_id = ?", partID).Select("COALESCE(SUM(quantity), 0)").Scan(&total)
+	return total
+}
+
+// CheckLowStock emails managers once when a part falls below its reorder point,
+// and re-arms the alert when stock is replenished.
+func CheckLowStock(partID uint) {
+	var part models.Part
+	if err := database.DB.First(&part, partID).Error; err != nil || part.ReorderPoint <= 0 {
+		return
+	}
+
+	total := TotalStock(partID)
+	switch {
+	case total < part.ReorderPoint && !part.LowStockAlerted:
+		database.DB.Model(&part).Update("low_stock_alerted", true)
+
+		var managers []string
+		database.DB.Model(&models.User{}).Where("role = ?", models.RoleManager).Pluck("email", &managers)
+		if len(managers) == 0 {
+			log.Printf("Low stock on part %s but no managers to notify", part.PartNumber)
+			return
+		}
+		body := fmt.Sprintf("<h3>Low Stock Alert</h3><p><b>%s</b> (%s) is down to %g %s, below its reorder point of %g.</p>",
+			html.EscapeString(part.Name), html.EscapeString(part.PartNumber), total, html.EscapeString(part.Unit), part.ReorderPoint)
+		go SendEmail(managers, "Low Stock: "+part.Name, body)
+
+	case total >= part.ReorderPoint && part.LowStockAlerted:
+		database.DB.Model(&part).Update("low_stock_alerted", false)
+	}
+}
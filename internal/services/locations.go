@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Rollup summarizes maintenance for every equipment under a node
+type Rollup struct {
+	EquipmentCount int           `json:"equipment_count"`
+	OpenRequests   int64         `json:"open_requests"`
+	Costs          CostBreakdown `json:"costs"`
+}
+
+// CheckLocationParent validates the position of a node of the given kind under
+// parent, nil for the top of the tree.
+func CheckLocationParent(kind models.LocationKind, parent *models.Location) error {
+	level, ok := models.LocationLevels[kind]
+	if !ok {
+		return fmt.Errorf("kind must be Site, Building, Floor or Area")
+	}
+	if parent == nil {
+		if kind != models.LocationSite {
+			return fmt.Errorf("only a Site can be at the top of the tree")
+		}
+		return nil
+	}
+	if models.LocationLevels[parent.Kind] >= level {
+		return fmt.Errorf("a %s cannot be placed under a %s", kind, parent.Kind)
+	}
+	return nil
+}
+
+// CheckLocationMove validates re-parenting loc under parent, nil for the top of the tree.
+func CheckLocationMove(loc models.Location, parent *models.Location) error {
+	if err := CheckLocationParent(loc.Kind, parent); err != nil {
+		return err
+	}
+	if parent != nil && strings.HasPrefix(parent.Path, loc.Path) {
+		return fmt.Errorf("a location cannot be moved under itself")
+	}
+	return nil
+}
+
+// LocationPath is the path of the node id under parent, nil for the top of the tree.
+func LocationPath(parent *models.Location, id uint) string {
+	if parent == nil {
+		return fmt.Sprintf("/%d/", id)
+	}
+	return fmt.Sprintf("%s%d/", parent.Path, id)
+}
+
+// BuildLocationTree nests a flat list of locations under their parents.
+func BuildLocationTree(locations []models.Location) []models.Location {
+	children := map[uint][]models.Location{}
+	var roots []models.Location
+	ids := map[uint]bool{}
+	for _, l := range locations {
+		ids[l.ID] = true
+	}
+	for _, l := range locations {
+		if l.ParentID == nil || !ids[*l.ParentID] {
+			roots = append(roots, l)
+		} else {
+			children[*l.ParentID] = append(children[*l.ParentID], l)
+		}
+	}
+
+	var attach func(nodes []models.Location) []models.Location
+	attach = func(nodes []models.Location) []models.Location {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
+}
+
+// LegacyLocationGroup is one location node to create from free-text values
+type LegacyLocationGroup struct {
+	Name        string   `json:"name"`
+	Variants    []string `json:"variants"`
+	Equipment   int      `json:"equipment"`
+	LocationID  uint     `json:"location_id,omitempty"`
+	ExistingRef bool     `json:"existing"`
+}
+
+var (
+	nonAlnum      = regexp.MustCompile(`[^a-z0-9]+`)
+	abbreviations = map[string]string{"bldg": "building", "bld": "building", "blg": "building", "flr": "floor", "fl": "floor", "rm": "room"}
+)
+
+// NormalizeLocationKey reduces spelling variants ("Bldg A", "building-a", "BUILDING A")
+// to a single comparison key.
+func NormalizeLocationKey(name string) string {
+	words := strings.Fields(nonAlnum.ReplaceAllString(strings.ToLower(name), " "))
+	for i, w := range words {
+		if full, ok := abbreviations[w]; ok {
+			words[i] = full
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// LegacyLocationCount is a free-text location and how many equipment not yet
+// placed in the tree use it
+type LegacyLocationCount struct {
+	Location string
+	Count    int
+}
+
+// PlanLegacyLocations groups free-text locations, most used first, into the
+// nodes of the given kind to create for them. Variants that normalize to the
+// same key share a node; mapping lets callers force a raw value to a canonical
+// name.
+func PlanLegacyLocations(kind models.LocationKind, rows []LegacyLocationCount, mapping map[string]string) ([]LegacyLocationGroup, error) {
+	if kind == models.LocationSite {
+		return nil, fmt.Errorf("legacy locations must be imported below a site")
+	}
+	if _, ok := models.LocationLevels[kind]; !ok {
+		return nil, fmt.Errorf("kind must be Building, Floor or Area")
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Count > rows[j].Count })
+	groups := map[string]int{}
+	var plan []LegacyLocationGroup
+	for _, row := range rows {
+		name := strings.TrimSpace(row.Location)
+		if mapped, ok := mapping[row.Location]; ok {
+			name = mapped
+		}
+		key := NormalizeLocationKey(name)
+		if key == "" {
+			continue
+		}
+		i, ok := groups[key]
+		if !ok {
+			// Rows are ordered by usage, so the most common spelling names the node
+			i = len(plan)
+			groups[key] = i
+			plan = append(plan, LegacyLocationGroup{Name: name})
+		}
+		plan[i].Variants = append(plan[i].Variants, row.Location)
+		plan[i].Equipment += row.Count
+	}
+	return plan, nil
+}
+
+// ImportLegacyLocations converts the free-text Equipment.Location values into
+// location nodes of the given kind under a site (created if missing), as
+// planned by PlanLegacyLocations. With dryRun nothing is written and the plan
+// is returned.
+func ImportLegacyLocations(db *gorm.DB, siteName string, kind models.LocationKind, mapping map[string]string, dryRun bool) ([]LegacyLocationGroup, error) {
+	var rows []LegacyLocationCount
+	if err := db.Model(&models.Equipment{}).
+		Select("location, COUNT(*) AS count").
+		Where("location <> '' AND location_id IS NULL").
+		Group("location").Order("count DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	plan, err := PlanLegacyLocations(kind, rows, mapping)
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var site models.Location
+		if err := tx.Where("kind = ? AND name = ?", models.LocationSite, siteName).First(&site).Error; err != nil {
+			site = models.Location{Name: siteName, Kind: models.LocationSite}
+			if err := tx.Create(&site).Error; err != nil {
+				return err
+			}
+			site.Path = LocationPath(nil, site.ID)
+			if err := tx.Model(&site).Update("path", site.Path).Error; err != nil {
+				return err
+			}
+		}
+
+		var existing []models.Location
+		tx.Where("parent_id = ? AND kind = ?", site.ID, kind).Find(&existing)
+		existingByKey := map[string]models.Location{}
+		for _, l := range existing {
+			existingByKey[NormalizeLocationKey(l.Name)] = l
+		}
+
+		for i := range plan {
+			g := &plan[i]
+			node, ok := existingByKey[NormalizeLocationKey(g.Name)]
+			if ok {
+				g.ExistingRef = true
+			} else {
+				node = models.Location{Name: g.Name, Kind: kind, ParentID: &site.ID}
+				if err := tx.Create(&node).Error; err != nil {
+					return err
+				}
+				node.Path = LocationPath(&site, node.ID)
+				if err := tx.Model(&node).Update("path", node.Path).Error; err != nil {
+					return err
+				}
+			}
+			g.LocationID = node.ID
+
+			if err := tx.Model(&models.Equipment{}).
+				Where("location IN ? AND location_id IS NULL", g.Variants).
+				Updates(map[string]interface{}{"location_id": node.ID, "location": node.Name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return plan, err
+}
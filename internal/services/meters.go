@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ValidateMeterRule checks the limits of a meter rule for its kind.
+func ValidateMeterRule(rule *models.MeterRule) error {
+	switch rule.Kind {
+	case models.RuleEvery:
+		if rule.Every <= 0 {
+			return fmt.Errorf("every must be greater than 0")
+		}
+	case models.RuleThreshold:
+	default:
+		return fmt.Errorf("kind must be Every or Threshold")
+	}
+	if rule.Subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+	return nil
+}
+
+// RecordReading stores reading and evaluates the meter's rules, opening
+// Preventive requests for every rule the reading triggers unless the equipment
+// is archived. It all happens in one transaction on db with the meter and its
+// rules locked, so concurrent readings for the same meter can't both trip a
+// rule; meter is reloaded there. Announce the opened requests once it returns.
+func RecordReading(db *gorm.DB, meter *models.Meter, reading *models.MeterReading) ([]models.MaintenanceRequest, error) {
+	var opened []models.MaintenanceRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(meter, meter.ID).Error; err != nil {
+			return err
+		}
+
+		reading.MeterID = meter.ID
+		if err := tx.Create(reading).Error; err != nil {
+			return err
+		}
+
+		// Readings can arrive out of order from batch uploads; only the newest one is current
+		if meter.LastReadingAt != nil && reading.ReadAt.Before(*meter.LastReadingAt) {
+			return nil
+		}
+		readAt := reading.ReadAt
+		meter.CurrentReading = reading.Value
+		meter.LastReadingAt = &readAt
+		if err := tx.Model(meter).Select("CurrentReading", "LastReadingAt").Updates(meter).Error; err != nil {
+			return err
+		}
+
+		var equipment models.Equipment
+		if err := tx.First(&equipment, meter.EquipmentID).Error; err != nil {
+			return err
+		}
+		// Rules of archived equipment are paused: they neither advance nor fire
+		if equipment.ArchivedAt != nil {
+			return nil
+		}
+
+		var rules []models.MeterRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("meter_id = ? AND active = ?", meter.ID, true).Order("id").Find(&rules).Error; err != nil {
+			return err
+		}
+
+		for _, rule := range rules {
+			changed, fires := ApplyReading(&rule, reading.Value)
+			if !changed {
+				continue
+			}
+			if err := tx.Model(&rule).Select("BaselineReading", "Tripped").Updates(&rule).Error; err != nil {
+				return err
+			}
+			if !fires {
+				continue
+			}
+
+			req := MeterRuleRequest(rule, *reading)
+			if err := OpenRequest(tx, &req, equipment); err != nil {
+				return err
+			}
+			opened = append(opened, req)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// ApplyReading advances the state of rule for a new reading, reporting whether
+// it changed (and therefore needs saving) and whether the rule fires, opening a
+// request.
+func ApplyReading(rule *models.MeterRule, value float64) (changed, fires bool) {
+	if !evaluateRule(rule, value) {
+		return false, false
+	}
+	return true, ruleFires(*rule, value)
+}
+
+// MeterRuleRequest is the request opened when reading fires rule.
+func MeterRuleRequest(rule models.MeterRule, reading models.MeterReading) models.MaintenanceRequest {
+	readAt := reading.ReadAt
+	return models.MaintenanceRequest{
+		Subject:       rule.Subject,
+		Type:          models.TypePreventive,
+		CreatedByID:   reading.RecordedByID,
+		ScheduledDate: &readAt,
+		DurationHours: rule.DurationHours,
+		MeterRuleID:   &rule.ID,
+	}
+}
+
+// evaluateRule advances the rule state for a new reading and reports whether the
+// state changed (and therefore needs saving).
+func evaluateRule(rule *models.MeterRule, value float64) bool {
+	switch rule.Kind {
+	case models.RuleEvery:
+		if value-rule.BaselineReading < rule.Every {
+			return false
+		}
+		// Keep the baseline aligned to the interval (500, 1000, 1500...) even when
+		// readings skip past several intervals at once
+		steps := math.Floor((value - rule.BaselineReading) / rule.Every)
+		rule.BaselineReading += steps * rule.Every
+		return true
+
+	case models.RuleThreshold:
+		if value > rule.Threshold && !rule.Tripped {
+			rule.Tripped = true
+			return true
+		}
+		if value <= rule.Threshold && rule.Tripped {
+			rule.Tripped = false
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFires reports whether a rule whose state just changed should open a request.
+func ruleFires(rule models.MeterRule, value float64) bool {
+	if rule.Kind == models.RuleThreshold {
+		return value > rule.Threshold
+	}
+	return true
+}
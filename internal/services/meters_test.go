@@ -0,0 +1,70 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gearguard/internal/database/dbtest"
+	"gearguard/internal/models"
+)
+
+func TestRecordReadingTripsRuleOnceUnderConcurrency(t *testing.T) {
+	db := dbtest.Open(t)
+	manager, equipment := seed(t, db)
+	meter := models.Meter{EquipmentID: equipment.ID, Name: "Temperature", Unit: "C"}
+	if err := db.Create(&meter).Error; err != nil {
+		t.Fatal(err)
+	}
+	rule := models.MeterRule{MeterID: meter.ID, Kind: models.RuleThreshold, Subject: "Overheat", Threshold: 100, Active: true, CreatedByID: manager.ID}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Gateways reporting the same overheat at once open a single request
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := models.Meter{ID: meter.ID}
+			reading := models.MeterReading{Value: 150, ReadAt: time.Now().Add(time.Duration(i) * time.Second), RecordedByID: manager.ID}
+			if _, err := RecordReading(db, &m, &reading); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var opened, readings int64
+	db.Model(&models.MaintenanceRequest{}).Where("meter_rule_id = ?", rule.ID).Count(&opened)
+	db.Model(&models.MeterReading{}).Where("meter_id = ?", meter.ID).Count(&readings)
+	if opened != 1 || readings != 5 {
+		t.Fatalf("expected 5 readings to open 1 request, got %d readings and %d requests", readings, opened)
+	}
+}
+
+func TestRecordReadingPausesRulesOfArchivedEquipment(t *testing.T) {
+	db := dbtest.Open(t)
+	manager, equipment := seed(t, db)
+	meter := models.Meter{EquipmentID: equipment.ID, Name: "Hours", Unit: "h"}
+	if err := db.Create(&meter).Error; err != nil {
+		t.Fatal(err)
+	}
+	rule := models.MeterRule{MeterID: meter.ID, Kind: models.RuleEvery, Every: 500, Subject: "Service", Active: true, CreatedByID: manager.ID}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&equipment).Update("archived_at", time.Now())
+
+	opened, err := RecordReading(db, &meter, &models.MeterReading{Value: 600, ReadAt: time.Now(), RecordedByID: manager.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.First(&rule, rule.ID); len(opened) != 0 || rule.BaselineReading != 0 {
+		t.Fatalf("expected the rule to stay put, got %d request(s) and baseline %g", len(opened), rule.BaselineReading)
+	}
+	if meter.CurrentReading != 600 {
+		t.Fatalf("expected the reading to be recorded anyway, got %g", meter.CurrentReading)
+	}
+}
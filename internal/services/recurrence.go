@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseWeekdays converts a comma-separated list of RRULE BYDAY codes ("MO,WE,FR")
+// into weekdays ordered Monday first.
+func ParseWeekdays(codes string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := map[time.Weekday]bool{}
+	for _, code := range strings.Split(codes, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		day, ok := weekdayCodes[code]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", code)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return mondayOffset(days[i]) < mondayOffset(days[j]) })
+	return days, nil
+}
+
+// ValidateSchedule checks that the recurrence rule of a schedule is usable.
+func ValidateSchedule(s *models.MaintenanceSchedule) error {
+	switch s.Frequency {
+	case models.FrequencyDaily, models.FrequencyWeekly, models.FrequencyMonthly:
+	default:
+		return fmt.Errorf("frequency must be one of DAILY, WEEKLY, MONTHLY")
+	}
+	if s.Interval < 1 {
+		return fmt.Errorf("interval must be at least 1")
+	}
+	if s.StartDate.IsZero() {
+		return fmt.Errorf("start_date is required")
+	}
+	if s.EndDate != nil && s.EndDate.Before(s.StartDate) {
+		return fmt.Errorf("end_date must be after start_date")
+	}
+	if s.Count < 0 {
+		return fmt.Errorf("count cannot be negative")
+	}
+	if _, err := ParseWeekdays(s.Weekdays); err != nil {
+		return err
+	}
+	if s.Weekdays != "" && s.Frequency != models.FrequencyWeekly {
+		return fmt.Errorf("weekdays can only be used with WEEKLY frequency")
+	}
+	return nil
+}
+
+// Occurrences returns every occurrence of the schedule from its start date up to
+// and including until, honouring the end date and occurrence count.
+func Occurrences(s models.MaintenanceSchedule, until time.Time) []time.Time {
+	var result []time.Time
+	if s.Interval < 1 {
+		s.Interval = 1
+	}
+	if s.EndDate != nil && s.EndDate.Before(until) {
+		until = *s.EndDate
+	}
+
+	// emit reports whether generation should continue
+	emit := func(t time.Time) bool {
+		if t.After(until) {
+			return false
+		}
+		if s.Count > 0 && len(result) >= s.Count {
+			return false
+		}
+		result = append(result, t)
+		return true
+	}
+
+	start := s.StartDate
+	switch s.Frequency {
+	case models.FrequencyDaily:
+		for t := start; emit(t); t = t.AddDate(0, 0, s.Interval) {
+		}
+
+	case models.FrequencyWeekly:
+		days, _ := ParseWeekdays(s.Weekdays)
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		weekStart := start.AddDate(0, 0, -mondayOffset(start.Weekday()))
+		for ; ; weekStart = weekStart.AddDate(0, 0, 7*s.Interval) {
+			for _, day := range days {
+				t := weekStart.AddDate(0, 0, mondayOffset(day))
+				if t.Before(start) {
+					continue
+				}
+				if !emit(t) {
+					return result
+				}
+			}
+		}
+
+	case models.FrequencyMonthly:
+		for i := 0; ; i++ {
+			if !emit(addMonthsClamped(start, i*s.Interval)) {
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// mondayOffset returns the number of days since Monday.
+func mondayOffset(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// addMonthsClamped adds months to t, clamping the day to the end of the target month
+// so that a schedule starting on the 31st runs on the last day of shorter months.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := firstOfMonth.AddDate(0, months, 0)
+	lastDay := target.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return target.AddDate(0, 0, day-1)
+}
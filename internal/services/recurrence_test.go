@@ -0,0 +1,105 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 9, 0, 0, 0, time.UTC)
+}
+
+func TestOccurrences(t *testing.T) {
+	end := day(2025, time.January, 20)
+	tests := []struct {
+		name     string
+		schedule models.MaintenanceSchedule
+		until    time.Time
+		want     []time.Time
+	}{
+		{
+			name:     "daily every other day",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyDaily, Interval: 2, StartDate: day(2025, time.January, 1)},
+			until:    day(2025, time.January, 7),
+			want:     []time.Time{day(2025, time.January, 1), day(2025, time.January, 3), day(2025, time.January, 5), day(2025, time.January, 7)},
+		},
+		{
+			name:     "daily stops after count",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyDaily, Interval: 1, Count: 2, StartDate: day(2025, time.January, 1)},
+			until:    day(2025, time.February, 1),
+			want:     []time.Time{day(2025, time.January, 1), day(2025, time.January, 2)},
+		},
+		{
+			// 1 January 2025 is a Wednesday: the Monday of that week is skipped
+			name:     "weekly on weekdays",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyWeekly, Interval: 1, Weekdays: "fr,MO,WE", StartDate: day(2025, time.January, 1)},
+			until:    day(2025, time.January, 13),
+			want: []time.Time{
+				day(2025, time.January, 1), day(2025, time.January, 3),
+				day(2025, time.January, 6), day(2025, time.January, 8), day(2025, time.January, 10),
+				day(2025, time.January, 13),
+			},
+		},
+		{
+			name:     "fortnightly on the start weekday until the end date",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyWeekly, Interval: 2, StartDate: day(2025, time.January, 1), EndDate: &end},
+			until:    day(2025, time.March, 1),
+			want:     []time.Time{day(2025, time.January, 1), day(2025, time.January, 15)},
+		},
+		{
+			name:     "monthly from the 31st clamps to shorter months",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: day(2025, time.January, 31)},
+			until:    day(2025, time.May, 1),
+			want:     []time.Time{day(2025, time.January, 31), day(2025, time.February, 28), day(2025, time.March, 31), day(2025, time.April, 30)},
+		},
+		{
+			name:     "nothing before the start date",
+			schedule: models.MaintenanceSchedule{Frequency: models.FrequencyDaily, Interval: 1, StartDate: day(2025, time.January, 10)},
+			until:    day(2025, time.January, 9),
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Occurrences(tt.schedule, tt.until); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		from   time.Time
+		months int
+		want   time.Time
+	}{
+		{day(2025, time.January, 31), 1, day(2025, time.February, 28)},
+		{day(2024, time.January, 31), 1, day(2024, time.February, 29)},
+		{day(2025, time.January, 31), 2, day(2025, time.March, 31)},
+		{day(2025, time.August, 31), 1, day(2025, time.September, 30)},
+		{day(2025, time.November, 30), 3, day(2026, time.February, 28)},
+		{day(2025, time.January, 15), 12, day(2026, time.January, 15)},
+	}
+	for _, tt := range tests {
+		if got := addMonthsClamped(tt.from, tt.months); !got.Equal(tt.want) {
+			t.Errorf("addMonthsClamped(%s, %d) = %s, want %s", tt.from.Format("2006-01-02"), tt.months, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := ParseWeekdays(" su, MO,we,mo ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Weekday{time.Monday, time.Wednesday, time.Sunday}; !reflect.DeepEqual(days, want) {
+		t.Errorf("got %v, want %v", days, want)
+	}
+	if _, err := ParseWeekdays("MO,XX"); err == nil {
+		t.Error("expected an error for an unknown weekday")
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+// Reliability groupings
+const (
+	GroupByEquipment  = "equipment"
+	GroupByCategory   = "category"
+	GroupByDepartment = "department"
+	GroupByTeam       = "team"
+)
+
+// ReliabilityStats are the reliability metrics of one group over a period.
+// Times are in hours; MTBF and MTTR are nil when there is nothing to average.
+type ReliabilityStats struct {
+	Key            string   `json:"key"`
+	Name           string   `json:"name"`
+	EquipmentCount int      `json:"equipment_count"`
+	Failures       int      `json:"failures"`
+	Repaired       int      `json:"repaired"`
+	DowntimeHours  float64  `json:"downtime_hours"`
+	UptimeHours    float64  `json:"uptime_hours"`
+	MTBF           *float64 `json:"mtbf_hours"`
+	MTTR           *float64 `json:"mttr_hours"`
+	Availability   float64  `json:"availability"` // Uptime / (uptime + downtime), 0..1
+}
+
+type reliabilityAccumulator struct {
+	stats      ReliabilityStats
+	repairTime float64
+}
+
+// ComputeReliability derives MTBF, MTTR and availability from Corrective requests
+// created within [from, to), grouped by equipment, category, department or team.
+//
+// A failure is a Corrective request. Downtime runs from its creation until it was
+// repaired (or the end of the period while still open). Repair time is the logged
+// DurationHours, falling back to the downtime when no duration was recorded.
+// Results are ordered from least to most available.
+func ComputeReliability(equipment []models.Equipment, teams map[uint]string, requests []models.MaintenanceRequest, groupBy string, from, to time.Time) []ReliabilityStats {
+	periodHours := to.Sub(from).Hours()
+	if periodHours < 0 {
+		periodHours = 0
+	}
+	groups := map[string]*reliabilityAccumulator{}
+	groupOf := map[uint]string{}
+
+	for _, e := range equipment {
+		key, name := reliabilityGroup(e, teams, groupBy)
+		groupOf[e.ID] = key
+		acc, ok := groups[key]
+		if !ok {
+			acc = &reliabilityAccumulator{stats: ReliabilityStats{Key: key, Name: name}}
+			groups[key] = acc
+		}
+		acc.stats.EquipmentCount++
+	}
+
+	for _, req := range requests {
+		if req.Type != models.TypeCorrective || req.CreatedAt.Before(from) || !req.CreatedAt.Before(to) {
+			continue
+		}
+		acc, ok := groups[groupOf[req.EquipmentID]]
+		if !ok {
+			continue
+		}
+
+		end := to
+		if req.Status == models.StatusRepaired && req.RepairedAt != nil && req.RepairedAt.Before(to) {
+			end = *req.RepairedAt
+		} else if req.Status == models.StatusRepaired && req.RepairedAt == nil && req.UpdatedAt.Before(to) {
+			end = req.UpdatedAt // Repaired before repair timestamps were recorded
+		}
+		downtime := end.Sub(req.CreatedAt).Hours()
+		if downtime < 0 {
+			downtime = 0
+		}
+
+		acc.stats.Failures++
+		acc.stats.DowntimeHours += downtime
+		if req.Status == models.StatusRepaired {
+			acc.stats.Repaired++
+			if req.DurationHours > 0 {
+				acc.repairTime += req.DurationHours
+			} else {
+				acc.repairTime += downtime
+			}
+		}
+	}
+
+	result := make([]ReliabilityStats, 0, len(groups))
+	for _, acc := range groups {
+		s := acc.stats
+		total := periodHours * float64(s.EquipmentCount)
+		if s.DowntimeHours > total {
+			s.DowntimeHours = total
+		}
+		s.UptimeHours = total - s.DowntimeHours
+
+		if s.Failures > 0 {
+			mtbf := s.UptimeHours / float64(s.Failures)
+			s.MTBF = &mtbf
+		}
+		if s.Repaired > 0 {
+			mttr := acc.repairTime / float64(s.Repaired)
+			s.MTTR = &mttr
+		}
+		if total > 0 {
+			s.Availability = s.UptimeHours / total
+		}
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Availability != result[j].Availability {
+			return result[i].Availability < result[j].Availability
+		}
+		return result[i].Failures > result[j].Failures
+	})
+	return result
+}
+
+func reliabilityGroup(e models.Equipment, teams map[uint]string, groupBy string) (string, string) {
+	switch groupBy {
+	case GroupByCategory:
+		return e.Category, e.Category
+	case GroupByDepartment:
+		return e.Department, e.Department
+	case GroupByTeam:
+		return strconv.FormatUint(uint64(e.MaintenanceTeamID), 10), teams[e.MaintenanceTeamID]
+	default:
+		return strconv.FormatUint(uint64(e.ID), 10), e.Name
+	}
+}
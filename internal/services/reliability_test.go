@@ -0,0 +1,128 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"gearguard/internal/models"
+)
+
+func TestComputeReliability(t *testing.T) {
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10) // 240 hours
+	at := func(hours float64) time.Time { return from.Add(time.Duration(hours * float64(time.Hour))) }
+	repaired := func(created, repairedAfter, duration float64) models.MaintenanceRequest {
+		repairedAt := at(created + repairedAfter)
+		return models.MaintenanceRequest{EquipmentID: 1, Type: models.TypeCorrective, Status: models.StatusRepaired,
+			CreatedAt: at(created), RepairedAt: &repairedAt, DurationHours: duration}
+	}
+	hours := func(h float64) *float64 { return &h }
+
+	tests := []struct {
+		name         string
+		requests     []models.MaintenanceRequest
+		failures     int
+		downtime     float64
+		mtbf, mttr   *float64
+		availability float64
+	}{
+		{
+			name: "no failures",
+			requests: []models.MaintenanceRequest{
+				// Preventive work and failures outside the period don't count
+				{EquipmentID: 1, Type: models.TypePreventive, Status: models.StatusNew, CreatedAt: at(24)},
+				repaired(-48, 6, 4),
+			},
+			availability: 1,
+		},
+		{
+			name:         "single failure with logged repair time",
+			requests:     []models.MaintenanceRequest{repaired(24, 6, 4)},
+			failures:     1,
+			downtime:     6,
+			mtbf:         hours(234),
+			mttr:         hours(4),
+			availability: 234.0 / 240,
+		},
+		{
+			name:         "single failure without logged repair time",
+			requests:     []models.MaintenanceRequest{repaired(24, 6, 0)},
+			failures:     1,
+			downtime:     6,
+			mtbf:         hours(234),
+			mttr:         hours(6),
+			availability: 234.0 / 240,
+		},
+		{
+			name: "open repair is down until the end of the period",
+			requests: []models.MaintenanceRequest{
+				{EquipmentID: 1, Type: models.TypeCorrective, Status: models.StatusInProgress, CreatedAt: at(228), DurationHours: 3},
+			},
+			failures:     1,
+			downtime:     12,
+			mtbf:         hours(228),
+			availability: 228.0 / 240,
+		},
+		{
+			name:         "open repair and a repaired one",
+			requests:     []models.MaintenanceRequest{repaired(24, 6, 4), {EquipmentID: 1, Type: models.TypeCorrective, Status: models.StatusNew, CreatedAt: at(228)}},
+			failures:     2,
+			downtime:     18,
+			mtbf:         hours(111),
+			mttr:         hours(4),
+			availability: 222.0 / 240,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := ComputeReliability([]models.Equipment{{ID: 1, Name: "Drill"}}, nil, tt.requests, GroupByEquipment, from, to)
+			if len(stats) != 1 {
+				t.Fatalf("expected one group, got %+v", stats)
+			}
+			s := stats[0]
+			if s.Key != "1" || s.Name != "Drill" || s.EquipmentCount != 1 {
+				t.Errorf("group = %q %q (%d equipment)", s.Key, s.Name, s.EquipmentCount)
+			}
+			if s.Failures != tt.failures || s.DowntimeHours != tt.downtime || s.UptimeHours != 240-tt.downtime {
+				t.Errorf("failures %d, downtime %g, uptime %g; want %d, %g, %g", s.Failures, s.DowntimeHours, s.UptimeHours, tt.failures, tt.downtime, 240-tt.downtime)
+			}
+			if !sameHours(s.MTBF, tt.mtbf) || !sameHours(s.MTTR, tt.mttr) {
+				t.Errorf("MTBF %v, MTTR %v; want %v, %v", deref(s.MTBF), deref(s.MTTR), deref(tt.mtbf), deref(tt.mttr))
+			}
+			if s.Availability != tt.availability {
+				t.Errorf("availability = %g, want %g", s.Availability, tt.availability)
+			}
+		})
+	}
+}
+
+func TestComputeReliabilityGroupsLeastAvailableFirst(t *testing.T) {
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	equipment := []models.Equipment{
+		{ID: 1, Category: "Drills", MaintenanceTeamID: 7},
+		{ID: 2, Category: "Drills", MaintenanceTeamID: 7},
+		{ID: 3, Category: "Presses", MaintenanceTeamID: 7},
+	}
+	requests := []models.MaintenanceRequest{{EquipmentID: 3, Type: models.TypeCorrective, Status: models.StatusNew, CreatedAt: from}}
+
+	stats := ComputeReliability(equipment, nil, requests, GroupByCategory, from, from.AddDate(0, 0, 1))
+	if len(stats) != 2 || stats[0].Key != "Presses" || stats[0].Availability != 0 || stats[1].EquipmentCount != 2 || stats[1].Availability != 1 {
+		t.Fatalf("unexpected grouping %+v", stats)
+	}
+
+	stats = ComputeReliability(equipment, map[uint]string{7: "Mechanical Team"}, requests, GroupByTeam, from, from.AddDate(0, 0, 1))
+	if len(stats) != 1 || stats[0].Name != "Mechanical Team" || stats[0].Availability != 2.0/3 {
+		t.Fatalf("unexpected team grouping %+v", stats)
+	}
+}
+
+func sameHours(a, b *float64) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
+
+func deref(h *float64) interface{} {
+	if h == nil {
+		return nil
+	}
+	return *h
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrEquipmentArchived is returned when opening a request for archived equipment
+var ErrEquipmentArchived = errors.New("equipment is archived")
+
+// PrepareRequest applies the auto-fill shared by every new request: team and
+// default technician are taken from the equipment and the status is reset to the
+// initial workflow state.
+func PrepareRequest(req *models.MaintenanceRequest, equipment models.Equipment) {
+	// Auto-Fill Logic: Assign Team from Equipment
+	req.EquipmentID = equipment.ID
+	req.TeamID = equipment.MaintenanceTeamID
+	req.Status = CurrentWorkflow.Initial // Default status
+
+	// Auto-Assign Technician if default exists on equipment
+	if equipment.DefaultTechnicianID != nil {
+		req.TechnicianID = equipment.DefaultTechnicianID
+	}
+}
+
+// OpenRequest persists a new maintenance request for the given equipment
+// inside tx, applying the same auto-fill used for manually created requests.
+// Announce the request once tx is committed. Archived equipment gets no new
+// requests.
+func OpenRequest(tx *gorm.DB, req *models.MaintenanceRequest, equipment models.Equipment) error {
+	if equipment.ArchivedAt != nil {
+		return ErrEquipmentArchived
+	}
+	PrepareRequest(req, equipment)
+	return tx.Create(req).Error
+}
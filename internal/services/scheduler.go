@@ -0,0 +1,110 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/models"
+)
+
+// StartScheduleGenerator runs the preventive request generator in the background.
+// SCHEDULE_HORIZON_DAYS controls how far ahead requests are created (default 14)
+// and SCHEDULE_INTERVAL_MINUTES how often the generator runs (default 60).
+// Each generated request is passed to announce, as if it had been reported.
+func StartScheduleGenerator(announce Announce) {
+	horizon := time.Duration(envInt("SCHEDULE_HORIZON_DAYS", 14)) * 24 * time.Hour
+	interval := time.Duration(envInt("SCHEDULE_INTERVAL_MINUTES", 60)) * time.Minute
+
+	go func() {
+		for {
+			now := time.Now()
+			GenerateScheduledRequests(now, now.Add(horizon), announce)
+			time.Sleep(interval)
+		}
+	}()
+	log.Printf("Schedule generator started (horizon %s, every %s)", horizon, interval)
+}
+
+// Announce is told about a request once it has been opened and saved
+type Announce func(req models.MaintenanceRequest, equipment models.Equipment)
+
+// scheduleLockKey is the Postgres advisory lock held while generating, so that
+// with several replicas each occurrence is created by exactly one of them
+const scheduleLockKey = 7_243_001_513
+
+// GenerateScheduledRequests materializes every schedule occurrence from the
+// start of now's day up to until that has not been created yet. Occurrences
+// before that, from a start date in the past or while a schedule was paused,
+// are skipped rather than opened as overdue work. A run is skipped while
+// another replica's holds the lock; that one creates the same occurrences.
+func GenerateScheduledRequests(now, until time.Time, announce Announce) {
+	release, acquired, err := database.TryAdvisoryLock(database.DB, scheduleLockKey)
+	if err != nil {
+		log.Println("Schedule generator: failed to take the generator lock:", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer release()
+
+	// Schedules of archived equipment wait until it is restored
+	active := database.DB.Model(&models.Equipment{}).Where("archived_at IS NULL").Select("id")
+	var schedules []models.MaintenanceSchedule
+	if err := database.DB.Preload("Equipment").Where("active = ? AND equipment_id IN (?)", true, active).Find(&schedules).Error; err != nil {
+		log.Println("Schedule generator: failed to load schedules:", err)
+		return
+	}
+
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, schedule := range schedules {
+		created, err := generateForSchedule(&schedule, from, until, announce)
+		if err != nil {
+			log.Printf("Schedule generator: schedule %d: %v", schedule.ID, err)
+		}
+		if created > 0 {
+			log.Printf("Schedule generator: created %d request(s) for schedule %d", created, schedule.ID)
+		}
+	}
+}
+
+func generateForSchedule(schedule *models.MaintenanceSchedule, from, until time.Time, announce Announce) (int, error) {
+	created := 0
+	for _, occurrence := range Occurrences(*schedule, until) {
+		if occurrence.Before(from) || schedule.LastOccurrence != nil && !occurrence.After(*schedule.LastOccurrence) {
+			continue
+		}
+
+		scheduledDate := occurrence
+		req := models.MaintenanceRequest{
+			Subject:       schedule.Subject,
+			Type:          models.TypePreventive,
+			CreatedByID:   schedule.CreatedByID,
+			ScheduledDate: &scheduledDate,
+			DurationHours: schedule.DurationHours,
+			ScheduleID:    &schedule.ID,
+		}
+		if err := OpenRequest(database.DB, &req, schedule.Equipment); err != nil {
+			return created, err
+		}
+		announce(req, schedule.Equipment)
+
+		created++
+		schedule.GeneratedCount++
+		schedule.LastOccurrence = &scheduledDate
+		if err := database.DB.Model(schedule).Select("GeneratedCount", "LastOccurrence").Updates(schedule).Error; err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
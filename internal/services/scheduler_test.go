@@ -0,0 +1,143 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"gearguard/internal/database"
+	"gearguard/internal/database/dbtest"
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// seed creates a manager and a piece of equipment in their team
+func seed(t *testing.T, db *gorm.DB) (models.User, models.Equipment) {
+	t.Helper()
+	team := models.MaintenanceTeam{Name: "Mechanical Team"}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	manager := models.User{Name: "Maya Manager", Email: "maya@example.com", Role: models.RoleManager, TeamID: &team.ID}
+	if err := db.Create(&manager).Error; err != nil {
+		t.Fatal(err)
+	}
+	equipment := models.Equipment{Name: "Drill", MaintenanceTeamID: team.ID, IsUsable: true}
+	if err := db.Create(&equipment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return manager, equipment
+}
+
+// ignore is an Announce for tests that don't look at announcements
+func ignore(models.MaintenanceRequest, models.Equipment) {}
+
+func TestGenerateScheduledRequestsOncePerOccurrence(t *testing.T) {
+	db := dbtest.Open(t)
+	manager, equipment := seed(t, db)
+	start := time.Now().Truncate(time.Hour)
+	schedule := models.MaintenanceSchedule{
+		Subject:     "Lubricate",
+		EquipmentID: equipment.ID,
+		Frequency:   models.FrequencyDaily,
+		Interval:    1,
+		StartDate:   start,
+		Active:      true,
+		CreatedByID: manager.ID,
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	var announced []models.MaintenanceRequest
+	announce := func(req models.MaintenanceRequest, _ models.Equipment) { announced = append(announced, req) }
+	generated := func() int64 {
+		var count int64
+		db.Model(&models.MaintenanceRequest{}).Where("schedule_id = ?", schedule.ID).Count(&count)
+		return count
+	}
+
+	// Another replica is generating: this one leaves it to them
+	release, acquired, err := database.TryAdvisoryLock(db, scheduleLockKey)
+	if err != nil || !acquired {
+		t.Fatalf("expected to take the lock, got %v %v", acquired, err)
+	}
+	GenerateScheduledRequests(start, start.AddDate(0, 0, 2), announce)
+	if n := generated(); n != 0 {
+		t.Fatalf("expected no requests while the lock is held elsewhere, got %d", n)
+	}
+	release()
+
+	GenerateScheduledRequests(start, start.AddDate(0, 0, 2), announce)
+	GenerateScheduledRequests(start, start.AddDate(0, 0, 2), announce)
+	if n := generated(); n != 3 || len(announced) != 3 {
+		t.Fatalf("expected each of the 3 occurrences created and announced once, got %d and %d", n, len(announced))
+	}
+}
+
+func TestGenerateScheduledRequestsSkipsMissedOccurrences(t *testing.T) {
+	db := dbtest.Open(t)
+	manager, equipment := seed(t, db)
+	now := time.Date(2025, time.March, 12, 9, 30, 0, 0, time.UTC)
+	schedule := models.MaintenanceSchedule{
+		Subject:     "Lubricate",
+		EquipmentID: equipment.ID,
+		Frequency:   models.FrequencyDaily,
+		Interval:    1,
+		StartDate:   now.AddDate(0, 0, -10).Truncate(24 * time.Hour),
+		Active:      true,
+		CreatedByID: manager.ID,
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Created ten days late: today's occurrence and the next two, none of the missed ones
+	GenerateScheduledRequests(now, now.AddDate(0, 0, 2), ignore)
+	var dates []time.Time
+	db.Model(&models.MaintenanceRequest{}).Where("schedule_id = ?", schedule.ID).Order("scheduled_date").Pluck("scheduled_date", &dates)
+	today := time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC)
+	if len(dates) != 3 || !dates[0].Equal(today) {
+		t.Fatalf("expected 3 requests from %s, got %v", today, dates)
+	}
+
+	// Paused for a week, then resumed: the paused week isn't backfilled
+	db.Model(&schedule).Update("active", false)
+	GenerateScheduledRequests(now.AddDate(0, 0, 3), now.AddDate(0, 0, 5), ignore)
+	db.Model(&schedule).Update("active", true)
+	later := now.AddDate(0, 0, 7)
+	GenerateScheduledRequests(later, later.AddDate(0, 0, 1), ignore)
+	var count int64
+	db.Model(&models.MaintenanceRequest{}).Where("schedule_id = ? AND scheduled_date < ?", schedule.ID, today.AddDate(0, 0, 7)).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected nothing generated for the paused days, got %d requests before the resume", count)
+	}
+}
+
+func TestGenerateScheduledRequestsSkipsArchivedEquipment(t *testing.T) {
+	db := dbtest.Open(t)
+	manager, equipment := seed(t, db)
+	start := time.Now().Truncate(time.Hour)
+	schedule := models.MaintenanceSchedule{
+		Subject:     "Lubricate",
+		EquipmentID: equipment.ID,
+		Frequency:   models.FrequencyDaily,
+		Interval:    1,
+		StartDate:   start,
+		Active:      true,
+		CreatedByID: manager.ID,
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&equipment).Update("archived_at", start)
+
+	GenerateScheduledRequests(start, start.AddDate(0, 0, 2), ignore)
+	var count int64
+	db.Model(&models.MaintenanceRequest{}).Where("schedule_id = ?", schedule.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no requests for archived equipment, got %d", count)
+	}
+	if err := OpenRequest(db, &models.MaintenanceRequest{Subject: "Lubricate"}, models.Equipment{ID: equipment.ID, ArchivedAt: &start}); err != ErrEquipmentArchived {
+		t.Fatalf("expected archived equipment to be refused, got %v", err)
+	}
+}
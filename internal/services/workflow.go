@@ -0,0 +1,188 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"gearguard/internal/models"
+)
+
+// WorkflowState is a column of the request lifecycle
+type WorkflowState struct {
+	Name     models.RequestStatus `json:"name"`
+	Terminal bool                 `json:"terminal"`
+}
+
+// WorkflowTransition allows moving a request between two states. Roles lists who
+// may perform it; RequiredFields lists request fields that must be set afterwards
+// (duration_hours, technician_id, scheduled_date).
+type WorkflowTransition struct {
+	From           models.RequestStatus `json:"from"`
+	To             models.RequestStatus `json:"to"`
+	Roles          []models.Role        `json:"roles"`
+	RequiredFields []string             `json:"required_fields,omitempty"`
+}
+
+// Workflow is the full request status workflow definition
+type Workflow struct {
+	Initial     models.RequestStatus `json:"initial"`
+	States      []WorkflowState      `json:"states"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// TransitionError explains why a status change was rejected
+type TransitionError struct {
+	Status        int                    `json:"-"`
+	Message       string                 `json:"error"`
+	Allowed       []models.RequestStatus `json:"allowed"`
+	MissingFields []string               `json:"missing_fields,omitempty"`
+}
+
+func (e *TransitionError) Error() string {
+	return e.Message
+}
+
+// DefaultWorkflow mirrors the original four Kanban columns
+var DefaultWorkflow = Workflow{
+	Initial: models.StatusNew,
+	States: []WorkflowState{
+		{Name: models.StatusNew},
+		{Name: models.StatusInProgress},
+		{Name: models.StatusRepaired},
+		{Name: models.StatusScrap, Terminal: true},
+	},
+	Transitions: []WorkflowTransition{
+		{From: models.StatusNew, To: models.StatusInProgress, Roles: []models.Role{models.RoleTechnician, models.RoleManager}},
+		{From: models.StatusNew, To: models.StatusScrap, Roles: []models.Role{models.RoleManager}},
+		{From: models.StatusInProgress, To: models.StatusNew, Roles: []models.Role{models.RoleTechnician, models.RoleManager}},
+		{From: models.StatusInProgress, To: models.StatusRepaired, Roles: []models.Role{models.RoleTechnician, models.RoleManager}, RequiredFields: []string{"duration_hours"}},
+		{From: models.StatusInProgress, To: models.StatusScrap, Roles: []models.Role{models.RoleTechnician, models.RoleManager}},
+		{From: models.StatusRepaired, To: models.StatusInProgress, Roles: []models.Role{models.RoleManager}},
+	},
+}
+
+// CurrentWorkflow is the workflow enforced by the API
+var CurrentWorkflow = DefaultWorkflow
+
+// LoadWorkflow replaces the default workflow with the JSON definition at WORKFLOW_FILE, if set.
+func LoadWorkflow() {
+	path := os.Getenv("WORKFLOW_FILE")
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Failed to read workflow file: ", err)
+	}
+
+	var wf Workflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		log.Fatal("Failed to parse workflow file: ", err)
+	}
+	if err := wf.Validate(); err != nil {
+		log.Fatal("Invalid workflow definition: ", err)
+	}
+
+	CurrentWorkflow = wf
+	log.Printf("Loaded workflow from %s (%d states, %d transitions)", path, len(wf.States), len(wf.Transitions))
+}
+
+// Validate checks that the definition is internally consistent.
+func (wf Workflow) Validate() error {
+	if !wf.HasState(wf.Initial) {
+		return fmt.Errorf("initial state %q is not defined", wf.Initial)
+	}
+	for _, t := range wf.Transitions {
+		if !wf.HasState(t.From) || !wf.HasState(t.To) {
+			return fmt.Errorf("transition %q -> %q references an undefined state", t.From, t.To)
+		}
+		for _, role := range t.Roles {
+			if _, err := models.ParseRole(string(role)); err != nil {
+				return fmt.Errorf("transition %q -> %q allows unknown role %q", t.From, t.To, role)
+			}
+		}
+		for _, field := range t.RequiredFields {
+			if _, ok := requiredFieldChecks[field]; !ok {
+				return fmt.Errorf("transition %q -> %q requires unknown field %q", t.From, t.To, field)
+			}
+		}
+	}
+	return nil
+}
+
+// HasState reports whether status is a state of the workflow.
+func (wf Workflow) HasState(status models.RequestStatus) bool {
+	for _, s := range wf.States {
+		if s.Name == status {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStates lists the states a user with the given role can move a request to from status.
+func (wf Workflow) NextStates(from models.RequestStatus, role models.Role) []models.RequestStatus {
+	next := []models.RequestStatus{}
+	for _, t := range wf.Transitions {
+		if t.From == from && t.allows(role) {
+			next = append(next, t.To)
+		}
+	}
+	return next
+}
+
+// CheckTransition validates moving req (with its updates already applied) from
+// status from to req.Status on behalf of a user with the given role.
+func (wf Workflow) CheckTransition(from models.RequestStatus, req *models.MaintenanceRequest, role models.Role) *TransitionError {
+	to := req.Status
+	if from == to {
+		return nil
+	}
+
+	allowed := wf.NextStates(from, role)
+	if !wf.HasState(to) {
+		return &TransitionError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("Unknown status %q", to), Allowed: allowed}
+	}
+
+	for _, t := range wf.Transitions {
+		if t.From != from || t.To != to {
+			continue
+		}
+		if !t.allows(role) {
+			return &TransitionError{Status: http.StatusConflict, Message: fmt.Sprintf("Role %s cannot move a request from %s to %s", role, from, to), Allowed: allowed}
+		}
+
+		var missing []string
+		for _, field := range t.RequiredFields {
+			if !requiredFieldChecks[field](req) {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			return &TransitionError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("Moving to %s requires: %s", to, strings.Join(missing, ", ")), Allowed: allowed, MissingFields: missing}
+		}
+		return nil
+	}
+
+	return &TransitionError{Status: http.StatusConflict, Message: fmt.Sprintf("Cannot move a request from %s to %s", from, to), Allowed: allowed}
+}
+
+func (t WorkflowTransition) allows(role models.Role) bool {
+	for _, r := range t.Roles {
+		if strings.EqualFold(string(r), string(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+var requiredFieldChecks = map[string]func(*models.MaintenanceRequest) bool{
+	"duration_hours": func(req *models.MaintenanceRequest) bool { return req.DurationHours > 0 },
+	"technician_id":  func(req *models.MaintenanceRequest) bool { return req.TechnicianID != nil },
+	"scheduled_date": func(req *models.MaintenanceRequest) bool { return req.ScheduledDate != nil },
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an S3 stand-in keeping objects in memory. It checks that requests
+// carry a SigV4 authorization for its credentials, and can be told to fail.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]string
+	types   map[string]string
+	fail    bool
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3) {
+	fake := &fakeS3{t: t, objects: map[string]string{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, &S3{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "attachments",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		PathStyle: true,
+		Client:    server.Client(),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	date := time.Now().UTC().Format("20060102")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"+date+"/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") ||
+		r.Header.Get("x-amz-content-sha256") != "UNSIGNED-PAYLOAD" || r.Header.Get("x-amz-date") == "" {
+		f.t.Errorf("unsigned or badly signed request: %q", auth)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/attachments/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "<Error><Code>SlowDown</Code></Error>")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = string(body)
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+	ctx := context.Background()
+	key := "equipment/7/photo of drill.jpg"
+
+	if err := s3.Put(ctx, key, strings.NewReader("jpeg bytes"), 10, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.types[key] != "image/jpeg" {
+		t.Errorf("content type = %q", fake.types[key])
+	}
+
+	body, err := s3.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(body)
+	body.Close()
+	if string(content) != "jpeg bytes" {
+		t.Errorf("got %q back", content)
+	}
+
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing object should succeed, got %v", err)
+	}
+}
+
+func TestS3ReportsErrors(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+	fake.fail = true
+	err := s3.Put(context.Background(), "request/1/log.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "SlowDown") {
+		t.Fatalf("expected the S3 error to be reported, got %v", err)
+	}
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	s3 := &S3{Endpoint: "https://s3.eu-west-1.amazonaws.com/", Bucket: "attachments"}
+	u, err := s3.objectURL("request/1/a b+c.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.String(); got != "https://attachments.s3.eu-west-1.amazonaws.com/request/1/a%20b%2Bc.pdf" {
+		t.Errorf("virtual-hosted URL = %s", got)
+	}
+	s3.PathStyle = true
+	if u, _ = s3.objectURL("k"); u.String() != "https://s3.eu-west-1.amazonaws.com/attachments/k" {
+		t.Errorf("path-style URL = %s", u)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrNotFound is returned by Get when the key does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Backend stores attachment files by key
+type Backend interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Files is the backend used by the API
+var Files Backend
+
+// Init selects the storage backend from STORAGE_BACKEND ("local" by default, or "s3").
+func Init() {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "s3":
+		backend, err := NewS3FromEnv()
+		if err != nil {
+			log.Fatal("Failed to configure S3 storage: ", err)
+		}
+		Files = backend
+		log.Printf("Attachment storage: S3 bucket %s at %s", backend.Bucket, backend.Endpoint)
+	default:
+		root := os.Getenv("STORAGE_PATH")
+		if root == "" {
+			root = "./uploads"
+		}
+		Files = NewLocal(root)
+		log.Printf("Attachment storage: local directory %s", root)
+	}
+}
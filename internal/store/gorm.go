@@ -0,0 +1,837 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Gorm implements Store on top of a GORM connection (Postgres in production)
+type Gorm struct {
+	db *gorm.DB
+}
+
+// NewGorm wraps an open GORM connection
+func NewGorm(db *gorm.DB) *Gorm {
+	return &Gorm{db: db}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ScopeEquipment restricts an equipment query to the equipment the user is allowed to see:
+// Employees see what they own, Technicians what they are the default technician of.
+func ScopeEquipment(query *gorm.DB, user models.User) *gorm.DB {
+	if user.Role == models.RoleEmployee {
+		query = query.Where("employee_id = ?", user.ID)
+	} else if user.Role == models.RoleTechnician {
+		query = query.Where("default_technician_id = ?", user.ID)
+	}
+	return query
+}
+
+// ScopeRequests restricts a request query to the requests the user is allowed to see.
+// Employees see requests they created or for equipment they own; Technicians only
+// requests for equipment where they are the default technician; Managers see everything.
+func ScopeRequests(query *gorm.DB, user models.User) *gorm.DB {
+	equipment := query.Session(&gorm.Session{NewDB: true}).Model(&models.Equipment{}).Select("id")
+	if user.Role == models.RoleEmployee {
+		query = query.Where("created_by_id = ? OR equipment_id IN (?)", user.ID, equipment.Where("employee_id = ?", user.ID))
+	} else if user.Role == models.RoleTechnician {
+		query = query.Where("equipment_id IN (?)", equipment.Where("default_technician_id = ?", user.ID))
+	}
+	return query
+}
+
+func (s *Gorm) equipmentQuery(filter EquipmentFilter) *gorm.DB {
+	query := s.db.Model(&models.Equipment{})
+	if filter.ID != 0 {
+		query = query.Where("id = ?", filter.ID)
+	}
+	if filter.Archived {
+		query = query.Where("archived_at IS NOT NULL")
+	} else {
+		query = query.Where("archived_at IS NULL")
+	}
+	if filter.VisibleTo != nil {
+		query = ScopeEquipment(query, *filter.VisibleTo)
+	}
+	if filter.Search != "" {
+		searchTerm := "%" + filter.Search + "%"
+		// Grouped so the search doesn't loosen the visibility filter
+		query = query.Where(s.db.Where("name ILIKE ?", searchTerm).Or("department ILIKE ?", searchTerm))
+	}
+	if filter.Usable != nil {
+		query = query.Where("is_usable = ?", *filter.Usable)
+	}
+	if filter.TeamID != 0 {
+		query = query.Where("maintenance_team_id = ?", filter.TeamID)
+	}
+	if filter.TechnicianID != 0 {
+		query = query.Where("default_technician_id = ?", filter.TechnicianID)
+	}
+	if filter.EmployeeID != 0 {
+		query = query.Where("employee_id = ?", filter.EmployeeID)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Department != "" {
+		query = query.Where("department = ?", filter.Department)
+	}
+	if filter.LocationID != 0 {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if len(filter.ParentIDs) > 0 {
+		query = query.Where("parent_id IN ?", filter.ParentIDs)
+	}
+	if filter.LocationPath != "" {
+		query = query.Where("location_id IN (?)", s.db.Model(&models.Location{}).Select("id").Where("path LIKE ?", filter.LocationPath+"%"))
+	}
+	return query
+}
+
+func (s *Gorm) GetEquipment(id uint) (models.Equipment, error) {
+	var equipment models.Equipment
+	err := s.db.First(&equipment, id).Error
+	return equipment, notFound(err)
+}
+
+func (s *Gorm) ListEquipment(filter EquipmentFilter) ([]models.Equipment, error) {
+	var equipment []models.Equipment
+	err := EquipmentSorting.Query(s.equipmentQuery(filter), filter.Page).Preload("MaintenanceTeam").Preload("Employee").Preload("DefaultTechnician").Find(&equipment).Error
+	return equipment, err
+}
+
+func (s *Gorm) CountEquipment(filter EquipmentFilter) (int64, error) {
+	var count int64
+	err := s.equipmentQuery(filter).Count(&count).Error
+	return count, err
+}
+
+func (s *Gorm) CreateEquipment(equipment *models.Equipment) error {
+	return s.db.Create(equipment).Error
+}
+
+func (s *Gorm) SaveEquipment(equipment *models.Equipment) error {
+	return s.db.Save(equipment).Error
+}
+
+func (s *Gorm) UpdateEquipment(equipment *models.Equipment, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.db.Model(equipment).Select(fields).Updates(equipment).Error
+}
+
+func (s *Gorm) GetLocation(id uint) (models.Location, error) {
+	var loc models.Location
+	err := s.db.First(&loc, id).Error
+	return loc, notFound(err)
+}
+
+func (s *Gorm) ListLocations(filter LocationFilter) ([]models.Location, error) {
+	query := s.db.Order("path")
+	if filter.ParentID != 0 {
+		query = query.Where("parent_id = ?", filter.ParentID)
+	}
+	var locations []models.Location
+	err := query.Find(&locations).Error
+	return locations, err
+}
+
+func (s *Gorm) CreateLocation(loc *models.Location) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var parent *models.Location
+		if loc.ParentID != nil {
+			parent = &models.Location{}
+			if err := tx.First(parent, *loc.ParentID).Error; err != nil {
+				return notFound(err)
+			}
+		}
+		if err := tx.Create(loc).Error; err != nil {
+			return err
+		}
+		loc.Path = services.LocationPath(parent, loc.ID)
+		return tx.Model(loc).Update("path", loc.Path).Error
+	})
+}
+
+func (s *Gorm) RenameLocation(id uint, name string) error {
+	return s.db.Model(&models.Location{}).Where("id = ?", id).Update("name", name).Error
+}
+
+func (s *Gorm) MoveLocation(loc *models.Location, parent *models.Location) error {
+	var parentID *uint
+	if parent != nil {
+		parentID = &parent.ID
+	}
+	oldPath := loc.Path
+	newPath := services.LocationPath(parent, loc.ID)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(loc).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Location{}).Where("path LIKE ?", oldPath+"%").
+			Update("path", gorm.Expr("? || substr(path, ?)", newPath, len(oldPath)+1)).Error
+	})
+	if err != nil {
+		return err
+	}
+	loc.ParentID = parentID
+	loc.Path = newPath
+	return nil
+}
+
+func (s *Gorm) DeleteLocation(id uint) error {
+	return s.db.Delete(&models.Location{}, id).Error
+}
+
+func (s *Gorm) ImportLegacyLocations(siteName string, kind models.LocationKind, mapping map[string]string, dryRun bool) ([]services.LegacyLocationGroup, error) {
+	return services.ImportLegacyLocations(s.db, siteName, kind, mapping, dryRun)
+}
+
+func (s *Gorm) TransferEquipment(equipment *models.Equipment, transfer *models.EquipmentTransfer) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(equipment).Updates(map[string]interface{}{
+			"employee_id":           equipment.EmployeeID,
+			"default_technician_id": equipment.DefaultTechnicianID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(transfer).Error
+	})
+}
+
+func (s *Gorm) ListEquipmentTransfers(equipmentID uint) ([]models.EquipmentTransfer, error) {
+	var transfers []models.EquipmentTransfer
+	err := s.db.Where("equipment_id = ?", equipmentID).Order("created_at DESC, id DESC").Find(&transfers).Error
+	return transfers, err
+}
+
+func (s *Gorm) requestQuery(filter RequestFilter) *gorm.DB {
+	query := s.db.Model(&models.MaintenanceRequest{})
+	if filter.VisibleTo != nil {
+		query = ScopeRequests(query, *filter.VisibleTo)
+	}
+	if filter.EquipmentID != 0 {
+		query = query.Where("equipment_id = ?", filter.EquipmentID)
+	}
+	if len(filter.EquipmentIDs) > 0 {
+		query = query.Where("equipment_id IN ?", filter.EquipmentIDs)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.ExcludeStatuses) > 0 {
+		query = query.Where("status NOT IN ?", filter.ExcludeStatuses)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ScheduledFrom != nil {
+		query = query.Where("scheduled_date >= ?", *filter.ScheduledFrom)
+	}
+	if filter.ScheduledBefore != nil {
+		query = query.Where("scheduled_date < ?", *filter.ScheduledBefore)
+	}
+	if filter.EndsAfter != nil {
+		query = query.Where("scheduled_date + COALESCE(NULLIF(duration_hours, 0), ?) * interval '1 hour' > ?",
+			models.DefaultDurationHours, *filter.EndsAfter)
+	}
+	if filter.CreatedSince != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedSince)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.TechnicianID != 0 {
+		query = query.Where("technician_id = ?", filter.TechnicianID)
+	}
+	if filter.TeamID != 0 {
+		query = query.Where("team_id = ?", filter.TeamID)
+	}
+	return query
+}
+
+func (s *Gorm) GetRequest(id uint) (models.MaintenanceRequest, error) {
+	var req models.MaintenanceRequest
+	err := s.db.Preload("Equipment").First(&req, id).Error
+	return req, notFound(err)
+}
+
+func (s *Gorm) ListRequests(filter RequestFilter) ([]models.MaintenanceRequest, error) {
+	var requests []models.MaintenanceRequest
+	err := RequestSorting.Query(s.requestQuery(filter), filter.Page).Preload("Equipment").Preload("Team").Preload("Technician").Find(&requests).Error
+	return requests, err
+}
+
+func (s *Gorm) CountRequests(filter RequestFilter) (int64, error) {
+	var count int64
+	err := s.requestQuery(filter).Count(&count).Error
+	return count, err
+}
+
+func (s *Gorm) TotalCost(filter RequestFilter) (float64, error) {
+	var total float64
+	err := s.requestQuery(filter).Select("COALESCE(SUM(total_cost), 0)").Scan(&total).Error
+	return total, err
+}
+
+func (s *Gorm) CostTotals(filter RequestFilter) (services.CostBreakdown, error) {
+	var totals services.CostBreakdown
+	err := s.requestQuery(filter).Select("COALESCE(SUM(labor_cost), 0) AS labor_cost, COALESCE(SUM(parts_cost), 0) AS parts_cost, " +
+		"COALESCE(SUM(vendor_cost), 0) AS vendor_cost, COALESCE(SUM(total_cost), 0) AS total_cost").Scan(&totals).Error
+	return totals, err
+}
+
+func (s *Gorm) CreateRequest(req *models.MaintenanceRequest) error {
+	// Parts are only ever consumed through UpdateRequest, which moves stock
+	return s.db.Omit(clause.Associations).Create(req).Error
+}
+
+func (s *Gorm) UpdateRequest(req *models.MaintenanceRequest, parts []models.PartUsage, actorID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(parts) > 0 {
+			used, err := services.ConsumeParts(tx, req.ID, parts, actorID)
+			if err != nil {
+				return err
+			}
+			req.PartsUsed = used
+		}
+		if err := tx.Omit("PartsUsed").Save(req).Error; err != nil {
+			return err
+		}
+		if req.Status == models.StatusScrap {
+			err := tx.Model(&models.Equipment{}).Where("id = ?", req.EquipmentID).Update("is_usable", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return services.RecalculateCosts(tx, req.ID)
+	})
+	if err != nil {
+		return err
+	}
+	s.db.First(req, req.ID) // Reload computed costs
+
+	// Alert managers if the parts used pushed anything below its reorder point
+	for _, usage := range req.PartsUsed {
+		services.CheckLowStock(usage.PartID)
+	}
+	return nil
+}
+
+func (s *Gorm) GetComment(id uint, deleted bool) (models.RequestComment, error) {
+	query := s.db
+	if deleted {
+		query = query.Unscoped()
+	}
+	var comment models.RequestComment
+	err := query.First(&comment, id).Error
+	return comment, notFound(err)
+}
+
+func (s *Gorm) ListComments(requestID uint, internal bool) ([]models.RequestComment, error) {
+	query := s.db.Preload("Author", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email, role")
+	}).Where("request_id = ?", requestID)
+	if !internal {
+		query = query.Where("internal = ?", false)
+	}
+	var comments []models.RequestComment
+	err := query.Order("created_at asc").Find(&comments).Error
+	return comments, err
+}
+
+func (s *Gorm) CreateComment(comment *models.RequestComment) error {
+	return s.db.Create(comment).Error
+}
+
+func (s *Gorm) SaveComment(comment *models.RequestComment) error {
+	return s.db.Save(comment).Error
+}
+
+func (s *Gorm) DeleteComment(id uint) error {
+	return s.db.Delete(&models.RequestComment{}, id).Error
+}
+
+func (s *Gorm) GetSchedule(id uint) (models.MaintenanceSchedule, error) {
+	var schedule models.MaintenanceSchedule
+	err := s.db.First(&schedule, id).Error
+	return schedule, notFound(err)
+}
+
+func (s *Gorm) ListSchedules(filter ScheduleFilter) ([]models.MaintenanceSchedule, error) {
+	query := s.db.Preload("Equipment").Order("id")
+	if filter.VisibleTo != nil {
+		query = query.Where("equipment_id IN (?)", ScopeEquipment(s.db.Model(&models.Equipment{}), *filter.VisibleTo).Select("id"))
+	}
+	if filter.EquipmentID != 0 {
+		query = query.Where("equipment_id = ?", filter.EquipmentID)
+	}
+	var schedules []models.MaintenanceSchedule
+	err := query.Find(&schedules).Error
+	return schedules, err
+}
+
+func (s *Gorm) CreateSchedule(schedule *models.MaintenanceSchedule) error {
+	return s.db.Create(schedule).Error
+}
+
+func (s *Gorm) SaveSchedule(schedule *models.MaintenanceSchedule) error {
+	return s.db.Omit("Equipment").Save(schedule).Error
+}
+
+func (s *Gorm) DeleteSchedule(id uint) error {
+	return s.db.Delete(&models.MaintenanceSchedule{}, id).Error
+}
+
+func (s *Gorm) GetMeter(id uint) (models.Meter, error) {
+	var meter models.Meter
+	err := s.db.First(&meter, id).Error
+	return meter, notFound(err)
+}
+
+func (s *Gorm) ListMeters(equipmentID uint) ([]models.Meter, error) {
+	var meters []models.Meter
+	err := s.db.Preload("Rules").Where("equipment_id = ?", equipmentID).Order("id").Find(&meters).Error
+	return meters, err
+}
+
+func (s *Gorm) CreateMeter(meter *models.Meter) error {
+	return s.db.Create(meter).Error
+}
+
+func (s *Gorm) RecordMeterReading(meter *models.Meter, reading *models.MeterReading) ([]models.MaintenanceRequest, error) {
+	return services.RecordReading(s.db, meter, reading)
+}
+
+func (s *Gorm) ListMeterReadings(meterID uint, limit int) ([]models.MeterReading, error) {
+	query := s.db.Where("meter_id = ?", meterID).Order("read_at desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var readings []models.MeterReading
+	err := query.Find(&readings).Error
+	return readings, err
+}
+
+func (s *Gorm) GetMeterRule(id uint) (models.MeterRule, error) {
+	var rule models.MeterRule
+	err := s.db.First(&rule, id).Error
+	return rule, notFound(err)
+}
+
+func (s *Gorm) CreateMeterRule(rule *models.MeterRule) error {
+	return s.db.Create(rule).Error
+}
+
+func (s *Gorm) DeleteMeterRule(id uint) error {
+	return s.db.Delete(&models.MeterRule{}, id).Error
+}
+
+func (s *Gorm) GetUser(id uint) (models.User, error) {
+	var user models.User
+	err := s.db.First(&user, id).Error
+	return user, notFound(err)
+}
+
+func (s *Gorm) FindUserByEmail(email string) (models.User, error) {
+	var user models.User
+	err := s.db.Where("email = ?", email).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s *Gorm) FindUserByResetToken(token string, now time.Time) (models.User, error) {
+	var user models.User
+	err := s.db.Where("password_reset_token = ? AND password_reset_at > ?", token, now).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s *Gorm) FindUserByOIDCSubject(subject string) (models.User, error) {
+	var user models.User
+	if subject == "" {
+		return user, ErrNotFound
+	}
+	err := s.db.Where("oidc_subject = ?", subject).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s *Gorm) userQuery(filter UserFilter) *gorm.DB {
+	query := s.db.Model(&models.User{})
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.TeamID != 0 {
+		query = query.Where("team_id = ?", filter.TeamID)
+	}
+	if filter.Search != "" {
+		searchTerm := "%" + filter.Search + "%"
+		query = query.Where(s.db.Where("name ILIKE ?", searchTerm).Or("email ILIKE ?", searchTerm))
+	}
+	return query
+}
+
+func (s *Gorm) ListUsers(filter UserFilter) ([]models.User, error) {
+	var users []models.User
+	err := UserSorting.Query(s.userQuery(filter), filter.Page).Find(&users).Error
+	return users, err
+}
+
+func (s *Gorm) CountUsers(filter UserFilter) (int64, error) {
+	var count int64
+	err := s.userQuery(filter).Count(&count).Error
+	return count, err
+}
+
+func (s *Gorm) CountUsersByRole(role models.Role) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+func (s *Gorm) CreateUser(user *models.User) error {
+	return s.db.Create(user).Error
+}
+
+func (s *Gorm) SaveUser(user *models.User) error {
+	return s.db.Save(user).Error
+}
+
+func (s *Gorm) GetTeam(id uint) (models.MaintenanceTeam, error) {
+	var team models.MaintenanceTeam
+	err := s.db.First(&team, id).Error
+	return team, notFound(err)
+}
+
+func (s *Gorm) FindTeamByName(name string) (models.MaintenanceTeam, error) {
+	var team models.MaintenanceTeam
+	err := s.db.Where("LOWER(name) = LOWER(?)", name).First(&team).Error
+	return team, notFound(err)
+}
+
+func (s *Gorm) teamQuery(filter TeamFilter) *gorm.DB {
+	query := s.db.Model(&models.MaintenanceTeam{})
+	if filter.Search != "" {
+		query = query.Where("name ILIKE ?", "%"+filter.Search+"%")
+	}
+	return query
+}
+
+func (s *Gorm) ListTeams(filter TeamFilter) ([]models.MaintenanceTeam, error) {
+	var teams []models.MaintenanceTeam
+	err := TeamSorting.Query(s.teamQuery(filter), filter.Page).Preload("Members").Find(&teams).Error
+	return teams, err
+}
+
+func (s *Gorm) CountTeams(filter TeamFilter) (int64, error) {
+	var count int64
+	err := s.teamQuery(filter).Count(&count).Error
+	return count, err
+}
+
+func (s *Gorm) CreateTeam(team *models.MaintenanceTeam) error {
+	return s.db.Create(team).Error
+}
+
+func (s *Gorm) CreateRefreshToken(token *models.RefreshToken) error {
+	return s.db.Create(token).Error
+}
+
+func (s *Gorm) FindRefreshToken(hash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := s.db.Where("token_hash = ?", hash).First(&token).Error
+	return token, notFound(err)
+}
+
+func (s *Gorm) RotateRefreshToken(old models.RefreshToken, next *models.RefreshToken, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Conditional on revoked_at so two concurrent refreshes can't both win
+		result := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", old.ID).Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (s *Gorm) RevokeSession(sessionID string, now time.Time) error {
+	return s.db.Model(&models.RefreshToken{}).Where("session_id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", now).Error
+}
+
+func (s *Gorm) RevokeUserSessions(userID uint, now time.Time) error {
+	return s.db.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error
+}
+
+func (s *Gorm) SessionActive(sessionID string, now time.Time) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, now).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *Gorm) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+		codes := make([]models.RecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (s *Gorm) UseRecoveryCode(userID uint, hash string, now time.Time) (bool, error) {
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *Gorm) GetThrottle(key string) (models.Throttle, error) {
+	var throttle models.Throttle
+	err := s.db.Where("key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Throttle{Key: key}, nil
+	}
+	return throttle, err
+}
+
+func (s *Gorm) HitThrottle(key string, now time.Time, window time.Duration) (models.Throttle, error) {
+	// A single upsert, so concurrent attempts on different replicas all count
+	expired := now.Add(-window)
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":        gorm.Expr("CASE WHEN throttles.window_start <= ? THEN 1 ELSE throttles.count + 1 END", expired),
+			"window_start": gorm.Expr("CASE WHEN throttles.window_start <= ? THEN ? ELSE throttles.window_start END", expired, now),
+		}),
+	}).Create(&models.Throttle{Key: key, Count: 1, WindowStart: now}).Error
+	if err != nil {
+		return models.Throttle{}, err
+	}
+	return s.GetThrottle(key)
+}
+
+func (s *Gorm) BlockThrottle(key string, until time.Time) error {
+	return s.db.Model(&models.Throttle{}).Where("key = ?", key).Update("blocked_until", until).Error
+}
+
+func (s *Gorm) ClearThrottle(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.Throttle{}).Error
+}
+
+func (s *Gorm) GetInvitation(id uint) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := s.db.First(&invitation, id).Error
+	return invitation, notFound(err)
+}
+
+func (s *Gorm) ListOpenInvitations() ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := s.db.Where("accepted_at IS NULL AND revoked_at IS NULL").Order("created_at DESC, id DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (s *Gorm) FindOpenInvitation(email string) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := s.db.Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).First(&invitation).Error
+	return invitation, notFound(err)
+}
+
+func (s *Gorm) CreateInvitation(invitation *models.Invitation) error {
+	return s.db.Create(invitation).Error
+}
+
+func (s *Gorm) SaveInvitation(invitation *models.Invitation) error {
+	return s.db.Save(invitation).Error
+}
+
+func (s *Gorm) AcceptInvitation(invitation *models.Invitation, user *models.User, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		// Conditional on the nonce so a resent or revoked link can't race acceptance
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND nonce = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID, invitation.Nonce).
+			Updates(map[string]interface{}{"accepted_at": now, "user_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationClosed
+		}
+		invitation.AcceptedAt = &now
+		invitation.UserID = &user.ID
+		return nil
+	})
+}
+
+func (s *Gorm) RevokeInvitation(invitation *models.Invitation, now time.Time) error {
+	// Conditional so a revocation can't overwrite a concurrent acceptance
+	result := s.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationClosed
+	}
+	invitation.RevokedAt = &now
+	return nil
+}
+
+func (s *Gorm) CreateAPIKey(key *models.APIKey) error {
+	return s.db.Create(key).Error
+}
+
+func (s *Gorm) GetAPIKey(id uint) (models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.First(&key, id).Error
+	return key, notFound(err)
+}
+
+func (s *Gorm) FindAPIKeyByPrefix(prefix string) (models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.Where("prefix = ?", prefix).First(&key).Error
+	return key, notFound(err)
+}
+
+func (s *Gorm) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (s *Gorm) RevokeAPIKey(id uint, now time.Time) error {
+	return s.db.Model(&models.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now).Error
+}
+
+func (s *Gorm) TouchAPIKey(id uint, now time.Time) error {
+	return s.db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", now).Error
+}
+
+func (s *Gorm) CreateCalendarFeed(feed *models.CalendarFeed) error {
+	return s.db.Create(feed).Error
+}
+
+func (s *Gorm) GetCalendarFeed(id uint) (models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := s.db.First(&feed, id).Error
+	return feed, notFound(err)
+}
+
+func (s *Gorm) FindCalendarFeed(tokenHash string) (models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := s.db.Where("token_hash = ?", tokenHash).First(&feed).Error
+	return feed, notFound(err)
+}
+
+func (s *Gorm) ListCalendarFeeds(userID uint) ([]models.CalendarFeed, error) {
+	var feeds []models.CalendarFeed
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&feeds).Error
+	return feeds, err
+}
+
+func (s *Gorm) RevokeCalendarFeed(id uint, now time.Time) error {
+	return s.db.Model(&models.CalendarFeed{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now).Error
+}
+
+func (s *Gorm) TouchCalendarFeed(id uint, now time.Time) error {
+	return s.db.Model(&models.CalendarFeed{}).Where("id = ?", id).Update("last_fetched_at", now).Error
+}
+
+func (s *Gorm) GetWebhook(id uint) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := s.db.First(&webhook, id).Error
+	return webhook, notFound(err)
+}
+
+func (s *Gorm) ListWebhooks() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := s.db.Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (s *Gorm) CreateWebhook(webhook *models.Webhook) error {
+	return s.db.Create(webhook).Error
+}
+
+func (s *Gorm) SaveWebhook(webhook *models.Webhook) error {
+	return s.db.Save(webhook).Error
+}
+
+func (s *Gorm) DeleteWebhook(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Webhook{}, id).Error
+	})
+}
+
+func (s *Gorm) GetWebhookDelivery(id uint) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := s.db.First(&delivery, id).Error
+	return delivery, notFound(err)
+}
+
+func (s *Gorm) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return s.db.Create(delivery).Error
+}
+
+func (s *Gorm) SaveWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return s.db.Save(delivery).Error
+}
+
+func (s *Gorm) ListWebhookDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := s.db.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s *Gorm) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Rows another replica is claiming are skipped rather than waited for
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at, id").Limit(limit).Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		until := now.Add(lease)
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			deliveries[i].NextAttemptAt = &until
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", until).Error
+	})
+	return deliveries, err
+}
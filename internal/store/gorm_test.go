@@ -0,0 +1,238 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gearguard/internal/database/dbtest"
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+	"gearguard/internal/store"
+
+	"gorm.io/gorm"
+)
+
+type gormFixture struct {
+	db        *gorm.DB
+	store     *store.Gorm
+	manager   models.User
+	equipment models.Equipment
+}
+
+func newGormFixture(t *testing.T) gormFixture {
+	t.Helper()
+	db := dbtest.Open(t)
+	f := gormFixture{db: db, store: store.NewGorm(db)}
+	team := models.MaintenanceTeam{Name: "Mechanical Team"}
+	f.must(t, db.Create(&team).Error)
+	f.manager = models.User{Name: "Maya Manager", Email: "maya@example.com", Role: models.RoleManager, TeamID: &team.ID}
+	f.must(t, db.Create(&f.manager).Error)
+	f.equipment = models.Equipment{Name: "Drill", MaintenanceTeamID: team.ID, IsUsable: true}
+	f.must(t, db.Create(&f.equipment).Error)
+	return f
+}
+
+func (f gormFixture) must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// part creates a part with quantity in stock at "Main"
+func (f gormFixture) part(t *testing.T, number string, quantity float64) models.Part {
+	t.Helper()
+	part := models.Part{PartNumber: number, Name: number, Unit: "pcs", UnitCost: 10}
+	f.must(t, f.db.Create(&part).Error)
+	f.must(t, f.db.Create(&models.Stock{PartID: part.ID, Location: "Main", Quantity: quantity}).Error)
+	return part
+}
+
+func (f gormFixture) stock(t *testing.T, part models.Part) float64 {
+	t.Helper()
+	var stock models.Stock
+	f.must(t, f.db.Where("part_id = ? AND location = ?", part.ID, "Main").First(&stock).Error)
+	return stock.Quantity
+}
+
+func (f gormFixture) count(t *testing.T, model interface{}) int64 {
+	t.Helper()
+	var n int64
+	f.must(t, f.db.Model(model).Count(&n).Error)
+	return n
+}
+
+func TestCreateRequestSavesNoParts(t *testing.T) {
+	f := newGormFixture(t)
+	part := f.part(t, "BRG-1", 3)
+
+	req := models.MaintenanceRequest{
+		Subject:     "Bearing noise",
+		Type:        models.TypeCorrective,
+		EquipmentID: f.equipment.ID,
+		TeamID:      f.equipment.MaintenanceTeamID,
+		CreatedByID: f.manager.ID,
+		PartsUsed:   []models.PartUsage{{PartID: part.ID, Location: "Main", Quantity: 2, UnitCost: 0.01}},
+	}
+	f.must(t, f.store.CreateRequest(&req))
+	if n := f.count(t, &models.PartUsage{}); n != 0 {
+		t.Fatalf("expected no part usage without consuming stock, got %d", n)
+	}
+}
+
+func TestUpdateRequestConsumesParts(t *testing.T) {
+	f := newGormFixture(t)
+	bearing := f.part(t, "BRG-1", 3)
+	belt := f.part(t, "BLT-1", 1)
+	req := models.MaintenanceRequest{Subject: "Overhaul", Type: models.TypeCorrective, EquipmentID: f.equipment.ID,
+		TeamID: f.equipment.MaintenanceTeamID, CreatedByID: f.manager.ID}
+	f.must(t, f.store.CreateRequest(&req))
+	req.Status = models.StatusRepaired
+
+	// Not enough belts: nothing is issued, the bearings included
+	err := f.store.UpdateRequest(&req, []models.PartUsage{
+		{PartID: bearing.ID, Location: "Main", Quantity: 2},
+		{PartID: belt.ID, Location: "Main", Quantity: 2},
+	}, f.manager.ID)
+	if !errors.Is(err, services.ErrInsufficientStock) {
+		t.Fatalf("expected insufficient stock, got %v", err)
+	}
+	if f.stock(t, bearing) != 3 || f.stock(t, belt) != 1 || f.count(t, &models.StockMovement{}) != 0 || f.count(t, &models.PartUsage{}) != 0 {
+		t.Fatal("a failed consumption must leave stock, ledger and usage untouched")
+	}
+	stored, err := f.store.GetRequest(req.ID)
+	f.must(t, err)
+	if stored.Status != models.StatusNew {
+		t.Fatalf("the status change must roll back with the parts, got %s", stored.Status)
+	}
+
+	err = f.store.UpdateRequest(&req, []models.PartUsage{{PartID: bearing.ID, Location: "Main", Quantity: 0}}, f.manager.ID)
+	if !errors.Is(err, services.ErrInvalidPartUsage) {
+		t.Fatalf("expected a non-positive quantity to be refused, got %v", err)
+	}
+
+	f.must(t, f.store.UpdateRequest(&req, []models.PartUsage{
+		{PartID: bearing.ID, Location: "Main", Quantity: 2},
+		{PartID: belt.ID, Location: "Main", Quantity: 1},
+	}, f.manager.ID))
+	if f.stock(t, bearing) != 1 || f.stock(t, belt) != 0 || f.count(t, &models.StockMovement{}) != 2 {
+		t.Fatal("expected both parts to be issued and recorded in the ledger")
+	}
+	if req.PartsCost != 30 || req.TotalCost < 30 {
+		t.Fatalf("expected parts to be costed at their unit cost, got %+v", req)
+	}
+}
+
+func TestUpdateRequestScrapsEquipmentWithTheRequest(t *testing.T) {
+	f := newGormFixture(t)
+	belt := f.part(t, "BLT-1", 1)
+	req := models.MaintenanceRequest{Subject: "Cracked frame", Type: models.TypeCorrective, EquipmentID: f.equipment.ID,
+		TeamID: f.equipment.MaintenanceTeamID, CreatedByID: f.manager.ID}
+	f.must(t, f.store.CreateRequest(&req))
+	usable := func() bool {
+		t.Helper()
+		equipment, err := f.store.GetEquipment(f.equipment.ID)
+		f.must(t, err)
+		return equipment.IsUsable
+	}
+
+	req.Status = models.StatusScrap
+	err := f.store.UpdateRequest(&req, []models.PartUsage{{PartID: belt.ID, Location: "Main", Quantity: 2}}, f.manager.ID)
+	if !errors.Is(err, services.ErrInsufficientStock) {
+		t.Fatalf("expected insufficient stock, got %v", err)
+	}
+	if !usable() {
+		t.Fatal("a failed update must leave the equipment usable")
+	}
+
+	f.must(t, f.store.UpdateRequest(&req, nil, f.manager.ID))
+	if usable() {
+		t.Fatal("expected scrapping the request to make the equipment unusable")
+	}
+}
+
+func TestUpdateEquipmentSavesOnlyTheNamedFields(t *testing.T) {
+	f := newGormFixture(t)
+	stale := f.equipment
+	f.must(t, f.db.Model(&models.Equipment{}).Where("id = ?", f.equipment.ID).Update("is_usable", false).Error)
+
+	// Archiving a copy loaded before the equipment was scrapped keeps it scrapped
+	now := time.Now()
+	stale.ArchivedAt = &now
+	stale.Name = "Renamed"
+	f.must(t, f.store.UpdateEquipment(&stale, "ArchivedAt"))
+	equipment, err := f.store.GetEquipment(f.equipment.ID)
+	f.must(t, err)
+	if equipment.ArchivedAt == nil || equipment.IsUsable || equipment.Name != "Drill" {
+		t.Fatalf("expected only archived_at to change, got %+v", equipment)
+	}
+}
+
+func TestClaimDueWebhookDeliveriesOnce(t *testing.T) {
+	f := newGormFixture(t)
+	hook := models.Webhook{URL: "https://example.com/hook", Secret: "s", Events: models.WebhookRequestCreated, Active: true, CreatedByID: f.manager.ID}
+	f.must(t, f.db.Create(&hook).Error)
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		f.must(t, f.store.CreateWebhookDelivery(&models.WebhookDelivery{WebhookID: hook.ID, EventID: fmt.Sprint(i),
+			EventType: models.WebhookRequestCreated, Payload: "{}", Status: models.DeliveryPending, NextAttemptAt: &now}))
+	}
+
+	// Replicas claiming together split the due deliveries between them
+	claimed := make(chan []models.WebhookDelivery, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			due, err := f.store.ClaimDueWebhookDeliveries(now, time.Minute, 8)
+			if err != nil {
+				t.Error(err)
+			}
+			claimed <- due
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+	seen := map[uint]bool{}
+	for due := range claimed {
+		for _, delivery := range due {
+			if seen[delivery.ID] {
+				t.Fatalf("delivery %d was claimed twice", delivery.ID)
+			}
+			seen[delivery.ID] = true
+		}
+	}
+	if len(seen) != 20 {
+		t.Fatalf("expected all 20 deliveries to be claimed, got %d", len(seen))
+	}
+
+	// Until the lease runs out, when they're due again
+	if due, _ := f.store.ClaimDueWebhookDeliveries(now.Add(30*time.Second), time.Minute, 100); len(due) != 0 {
+		t.Fatalf("expected leased deliveries to be skipped, got %d", len(due))
+	}
+	if due, _ := f.store.ClaimDueWebhookDeliveries(now.Add(time.Minute), time.Minute, 100); len(due) != 20 {
+		t.Fatalf("expected deliveries to be claimable once the lease ran out, got %d", len(due))
+	}
+}
+
+func TestRevokeInvitationOnlyWhileOpen(t *testing.T) {
+	f := newGormFixture(t)
+	invitation := models.Invitation{Email: "quick@example.com", Role: models.RoleEmployee, Nonce: "n", InvitedByID: f.manager.ID}
+	f.must(t, f.store.CreateInvitation(&invitation))
+	stale := invitation
+
+	user := models.User{Name: "Quick", Email: "quick@example.com", Role: models.RoleEmployee}
+	f.must(t, f.store.AcceptInvitation(&invitation, &user, time.Now()))
+	if err := f.store.RevokeInvitation(&stale, time.Now()); !errors.Is(err, store.ErrInvitationClosed) {
+		t.Fatalf("expected an accepted invitation to stay accepted, got %v", err)
+	}
+	stored, err := f.store.GetInvitation(invitation.ID)
+	f.must(t, err)
+	if stored.AcceptedAt == nil || stored.RevokedAt != nil {
+		t.Fatalf("expected the acceptance to stand, got %+v", stored)
+	}
+}
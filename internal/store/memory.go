@@ -0,0 +1,1490 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+)
+
+// Memory implements Store in process memory. It is meant for tests: associations
+// are resolved on read, parts are recorded on requests without touching stock, and
+// costs are kept as they are set on the request.
+type Memory struct {
+	mu        sync.Mutex
+	nextID    uint
+	equipment map[uint]models.Equipment
+	locations map[uint]models.Location
+	requests  map[uint]models.MaintenanceRequest
+	users     map[uint]models.User
+	teams     map[uint]models.MaintenanceTeam
+	tokens    map[uint]models.RefreshToken
+	recovery  map[uint]models.RecoveryCode
+	throttles map[string]models.Throttle
+	invites   map[uint]models.Invitation
+	apiKeys   map[uint]models.APIKey
+	transfers map[uint]models.EquipmentTransfer
+	feeds     map[uint]models.CalendarFeed
+	webhooks  map[uint]models.Webhook
+	delivered map[uint]models.WebhookDelivery
+	comments  map[uint]models.RequestComment
+	schedules map[uint]models.MaintenanceSchedule
+	meters    map[uint]models.Meter
+	readings  map[uint]models.MeterReading
+	rules     map[uint]models.MeterRule
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		equipment: map[uint]models.Equipment{},
+		locations: map[uint]models.Location{},
+		requests:  map[uint]models.MaintenanceRequest{},
+		users:     map[uint]models.User{},
+		teams:     map[uint]models.MaintenanceTeam{},
+		tokens:    map[uint]models.RefreshToken{},
+		recovery:  map[uint]models.RecoveryCode{},
+		throttles: map[string]models.Throttle{},
+		invites:   map[uint]models.Invitation{},
+		apiKeys:   map[uint]models.APIKey{},
+		transfers: map[uint]models.EquipmentTransfer{},
+		feeds:     map[uint]models.CalendarFeed{},
+		webhooks:  map[uint]models.Webhook{},
+		delivered: map[uint]models.WebhookDelivery{},
+		comments:  map[uint]models.RequestComment{},
+		schedules: map[uint]models.MaintenanceSchedule{},
+		meters:    map[uint]models.Meter{},
+		readings:  map[uint]models.MeterReading{},
+		rules:     map[uint]models.MeterRule{},
+	}
+}
+
+func (s *Memory) id() uint {
+	s.nextID++
+	return s.nextID
+}
+
+func sortedIDs[T any](m map[uint]T) []uint {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func hasID(ids []uint, id uint) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func hasStatus(statuses []models.RequestStatus, status models.RequestStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Memory) equipmentMatches(e models.Equipment, filter EquipmentFilter) bool {
+	if filter.ID != 0 && e.ID != filter.ID {
+		return false
+	}
+	if (e.ArchivedAt != nil) != filter.Archived {
+		return false
+	}
+	if filter.VisibleTo != nil && !EquipmentVisible(e, *filter.VisibleTo) {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		if !strings.Contains(strings.ToLower(e.Name), search) && !strings.Contains(strings.ToLower(e.Department), search) {
+			return false
+		}
+	}
+	if filter.Usable != nil && e.IsUsable != *filter.Usable {
+		return false
+	}
+	if filter.TeamID != 0 && e.MaintenanceTeamID != filter.TeamID {
+		return false
+	}
+	if filter.TechnicianID != 0 && (e.DefaultTechnicianID == nil || *e.DefaultTechnicianID != filter.TechnicianID) {
+		return false
+	}
+	if filter.EmployeeID != 0 && (e.EmployeeID == nil || *e.EmployeeID != filter.EmployeeID) {
+		return false
+	}
+	if filter.Category != "" && e.Category != filter.Category {
+		return false
+	}
+	if filter.Department != "" && e.Department != filter.Department {
+		return false
+	}
+	if filter.LocationID != 0 && (e.LocationID == nil || *e.LocationID != filter.LocationID) {
+		return false
+	}
+	if len(filter.ParentIDs) > 0 && (e.ParentID == nil || !hasID(filter.ParentIDs, *e.ParentID)) {
+		return false
+	}
+	if filter.LocationPath != "" && (e.LocationID == nil || !strings.HasPrefix(s.locations[*e.LocationID].Path, filter.LocationPath)) {
+		return false
+	}
+	return true
+}
+
+func (s *Memory) userRef(id *uint) *models.User {
+	if id == nil {
+		return nil
+	}
+	if user, ok := s.users[*id]; ok {
+		return &user
+	}
+	return nil
+}
+
+func (s *Memory) GetEquipment(id uint) (models.Equipment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	equipment, ok := s.equipment[id]
+	if !ok {
+		return equipment, ErrNotFound
+	}
+	return equipment, nil
+}
+
+func (s *Memory) ListEquipment(filter EquipmentFilter) ([]models.Equipment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []models.Equipment
+	for _, id := range sortedIDs(s.equipment) {
+		e := s.equipment[id]
+		if !s.equipmentMatches(e, filter) {
+			continue
+		}
+		e.MaintenanceTeam = s.teams[e.MaintenanceTeamID]
+		e.Employee = s.userRef(e.EmployeeID)
+		e.DefaultTechnician = s.userRef(e.DefaultTechnicianID)
+		result = append(result, e)
+	}
+	return EquipmentSorting.Apply(result, filter.Page), nil
+}
+
+func (s *Memory) CountEquipment(filter EquipmentFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, e := range s.equipment {
+		if s.equipmentMatches(e, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) CreateEquipment(equipment *models.Equipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	equipment.ID = s.id()
+	s.equipment[equipment.ID] = *equipment
+	return nil
+}
+
+func (s *Memory) SaveEquipment(equipment *models.Equipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if equipment.ID == 0 {
+		equipment.ID = s.id()
+	}
+	s.equipment[equipment.ID] = *equipment
+	return nil
+}
+
+func (s *Memory) UpdateEquipment(equipment *models.Equipment, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.equipment[equipment.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	from, to := reflect.ValueOf(equipment).Elem(), reflect.ValueOf(&stored).Elem()
+	for _, field := range fields {
+		to.FieldByName(field).Set(from.FieldByName(field))
+	}
+	s.equipment[equipment.ID] = stored
+	return nil
+}
+
+func (s *Memory) GetLocation(id uint) (models.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.locations[id]
+	if !ok {
+		return loc, ErrNotFound
+	}
+	return loc, nil
+}
+
+func (s *Memory) ListLocations(filter LocationFilter) ([]models.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var locations []models.Location
+	for _, loc := range s.locations {
+		if filter.ParentID != 0 && (loc.ParentID == nil || *loc.ParentID != filter.ParentID) {
+			continue
+		}
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Path < locations[j].Path })
+	return locations, nil
+}
+
+func (s *Memory) CreateLocation(loc *models.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createLocation(loc)
+}
+
+func (s *Memory) createLocation(loc *models.Location) error {
+	var parent *models.Location
+	if loc.ParentID != nil {
+		stored, ok := s.locations[*loc.ParentID]
+		if !ok {
+			return ErrNotFound
+		}
+		parent = &stored
+	}
+	loc.ID = s.id()
+	loc.CreatedAt = time.Now()
+	loc.UpdatedAt = loc.CreatedAt
+	loc.Path = services.LocationPath(parent, loc.ID)
+	s.locations[loc.ID] = *loc
+	return nil
+}
+
+func (s *Memory) RenameLocation(id uint, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.locations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	loc.Name = name
+	s.locations[id] = loc
+	return nil
+}
+
+func (s *Memory) MoveLocation(loc *models.Location, parent *models.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.locations[loc.ID]; !ok {
+		return ErrNotFound
+	}
+	oldPath := loc.Path
+	newPath := services.LocationPath(parent, loc.ID)
+	for id, l := range s.locations {
+		if strings.HasPrefix(l.Path, oldPath) {
+			l.Path = newPath + strings.TrimPrefix(l.Path, oldPath)
+			s.locations[id] = l
+		}
+	}
+	stored := s.locations[loc.ID]
+	stored.ParentID = nil
+	if parent != nil {
+		stored.ParentID = &parent.ID
+	}
+	s.locations[loc.ID] = stored
+	loc.ParentID, loc.Path = stored.ParentID, stored.Path
+	return nil
+}
+
+func (s *Memory) DeleteLocation(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locations, id)
+	return nil
+}
+
+func (s *Memory) ImportLegacyLocations(siteName string, kind models.LocationKind, mapping map[string]string, dryRun bool) ([]services.LegacyLocationGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int{}
+	for _, e := range s.equipment {
+		if e.Location != "" && e.LocationID == nil {
+			counts[e.Location]++
+		}
+	}
+	var rows []services.LegacyLocationCount
+	for location, count := range counts {
+		rows = append(rows, services.LegacyLocationCount{Location: location, Count: count})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Location < rows[j].Location })
+
+	plan, err := services.PlanLegacyLocations(kind, rows, mapping)
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	var site models.Location
+	for _, id := range sortedIDs(s.locations) {
+		if l := s.locations[id]; l.Kind == models.LocationSite && l.Name == siteName {
+			site = l
+			break
+		}
+	}
+	if site.ID == 0 {
+		site = models.Location{Name: siteName, Kind: models.LocationSite}
+		if err := s.createLocation(&site); err != nil {
+			return nil, err
+		}
+	}
+	existing := map[string]models.Location{}
+	for _, l := range s.locations {
+		if l.ParentID != nil && *l.ParentID == site.ID && l.Kind == kind {
+			existing[services.NormalizeLocationKey(l.Name)] = l
+		}
+	}
+
+	for i := range plan {
+		g := &plan[i]
+		node, ok := existing[services.NormalizeLocationKey(g.Name)]
+		if ok {
+			g.ExistingRef = true
+		} else {
+			node = models.Location{Name: g.Name, Kind: kind, ParentID: &site.ID}
+			if err := s.createLocation(&node); err != nil {
+				return nil, err
+			}
+		}
+		g.LocationID = node.ID
+		for id, e := range s.equipment {
+			if e.LocationID == nil && slices.Contains(g.Variants, e.Location) {
+				e.LocationID, e.Location = &node.ID, node.Name
+				s.equipment[id] = e
+			}
+		}
+	}
+	return plan, nil
+}
+
+func (s *Memory) TransferEquipment(equipment *models.Equipment, transfer *models.EquipmentTransfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.equipment[equipment.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.EmployeeID = equipment.EmployeeID
+	stored.DefaultTechnicianID = equipment.DefaultTechnicianID
+	s.equipment[equipment.ID] = stored
+	transfer.ID = s.id()
+	transfer.CreatedAt = time.Now()
+	s.transfers[transfer.ID] = *transfer
+	return nil
+}
+
+func (s *Memory) ListEquipmentTransfers(equipmentID uint) ([]models.EquipmentTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []models.EquipmentTransfer
+	ids := sortedIDs(s.transfers)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if transfer := s.transfers[ids[i]]; transfer.EquipmentID == equipmentID {
+			result = append(result, transfer)
+		}
+	}
+	return result, nil
+}
+
+func (s *Memory) requestMatches(req models.MaintenanceRequest, filter RequestFilter) bool {
+	if filter.VisibleTo != nil && !RequestVisible(req, s.equipment[req.EquipmentID], *filter.VisibleTo) {
+		return false
+	}
+	if filter.EquipmentID != 0 && req.EquipmentID != filter.EquipmentID {
+		return false
+	}
+	if len(filter.EquipmentIDs) > 0 && !hasID(filter.EquipmentIDs, req.EquipmentID) {
+		return false
+	}
+	if filter.Status != "" && req.Status != filter.Status {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !hasStatus(filter.Statuses, req.Status) {
+		return false
+	}
+	if len(filter.ExcludeStatuses) > 0 && hasStatus(filter.ExcludeStatuses, req.Status) {
+		return false
+	}
+	if filter.Type != "" && req.Type != filter.Type {
+		return false
+	}
+	// Like SQL comparisons, an unscheduled request never matches a date bound
+	if filter.ScheduledFrom != nil && (req.ScheduledDate == nil || req.ScheduledDate.Before(*filter.ScheduledFrom)) {
+		return false
+	}
+	if filter.ScheduledBefore != nil && (req.ScheduledDate == nil || !req.ScheduledDate.Before(*filter.ScheduledBefore)) {
+		return false
+	}
+	if filter.EndsAfter != nil && (req.ScheduledDate == nil || !req.ScheduledEnd().After(*filter.EndsAfter)) {
+		return false
+	}
+	if filter.CreatedSince != nil && req.CreatedAt.Before(*filter.CreatedSince) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !req.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.TechnicianID != 0 && (req.TechnicianID == nil || *req.TechnicianID != filter.TechnicianID) {
+		return false
+	}
+	if filter.TeamID != 0 && req.TeamID != filter.TeamID {
+		return false
+	}
+	return true
+}
+
+// stripRequest drops loaded associations so they're resolved fresh on read
+func stripRequest(req models.MaintenanceRequest) models.MaintenanceRequest {
+	req.Equipment = models.Equipment{}
+	req.Team = models.MaintenanceTeam{}
+	req.Technician = nil
+	req.CreatedBy = models.User{}
+	return req
+}
+
+func (s *Memory) GetRequest(id uint) (models.MaintenanceRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := s.requests[id]
+	if !ok {
+		return req, ErrNotFound
+	}
+	req.Equipment = s.equipment[req.EquipmentID]
+	return req, nil
+}
+
+func (s *Memory) ListRequests(filter RequestFilter) ([]models.MaintenanceRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []models.MaintenanceRequest
+	for _, id := range sortedIDs(s.requests) {
+		req := s.requests[id]
+		if !s.requestMatches(req, filter) {
+			continue
+		}
+		req.Equipment = s.equipment[req.EquipmentID]
+		req.Team = s.teams[req.TeamID]
+		req.Technician = s.userRef(req.TechnicianID)
+		result = append(result, req)
+	}
+	return RequestSorting.Apply(result, filter.Page), nil
+}
+
+func (s *Memory) CountRequests(filter RequestFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, req := range s.requests {
+		if s.requestMatches(req, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) TotalCost(filter RequestFilter) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total float64
+	for _, req := range s.requests {
+		if s.requestMatches(req, filter) {
+			total += req.TotalCost
+		}
+	}
+	return total, nil
+}
+
+func (s *Memory) CostTotals(filter RequestFilter) (services.CostBreakdown, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var totals services.CostBreakdown
+	for _, req := range s.requests {
+		if s.requestMatches(req, filter) {
+			totals.LaborCost += req.LaborCost
+			totals.PartsCost += req.PartsCost
+			totals.VendorCost += req.VendorCost
+			totals.TotalCost += req.TotalCost
+		}
+	}
+	return totals, nil
+}
+
+func (s *Memory) CreateRequest(req *models.MaintenanceRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createRequest(req)
+}
+
+func (s *Memory) createRequest(req *models.MaintenanceRequest) error {
+	if _, ok := s.equipment[req.EquipmentID]; !ok {
+		return fmt.Errorf("equipment %d does not exist", req.EquipmentID)
+	}
+	now := time.Now()
+	req.ID = s.id()
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = now
+	}
+	req.UpdatedAt = now
+	if req.Status == "" {
+		req.Status = models.StatusNew
+	}
+	s.requests[req.ID] = stripRequest(*req)
+	return nil
+}
+
+func (s *Memory) UpdateRequest(req *models.MaintenanceRequest, parts []models.PartUsage, actorID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.requests[req.ID]; !ok {
+		return ErrNotFound
+	}
+	for _, usage := range parts {
+		usage.ID = s.id()
+		usage.RequestID = req.ID
+		usage.RecordedByID = actorID
+		req.PartsUsed = append(req.PartsUsed, usage)
+	}
+	req.UpdatedAt = time.Now()
+	s.requests[req.ID] = stripRequest(*req)
+	if equipment, ok := s.equipment[req.EquipmentID]; ok && req.Status == models.StatusScrap {
+		equipment.IsUsable = false
+		s.equipment[equipment.ID] = equipment
+	}
+	return nil
+}
+
+func (s *Memory) GetComment(id uint, deleted bool) (models.RequestComment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, ok := s.comments[id]
+	if !ok || (comment.DeletedAt.Valid && !deleted) {
+		return models.RequestComment{}, ErrNotFound
+	}
+	return comment, nil
+}
+
+func (s *Memory) ListComments(requestID uint, internal bool) ([]models.RequestComment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var comments []models.RequestComment
+	for _, id := range sortedIDs(s.comments) {
+		comment := s.comments[id]
+		if comment.RequestID != requestID || comment.DeletedAt.Valid || (comment.Internal && !internal) {
+			continue
+		}
+		if author, ok := s.users[comment.AuthorID]; ok {
+			comment.Author = &models.User{ID: author.ID, Name: author.Name, Email: author.Email, Role: author.Role}
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+func (s *Memory) CreateComment(comment *models.RequestComment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment.ID = s.id()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	s.comments[comment.ID] = *comment
+	return nil
+}
+
+func (s *Memory) SaveComment(comment *models.RequestComment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.comments[comment.ID]; !ok {
+		return ErrNotFound
+	}
+	comment.UpdatedAt = time.Now()
+	stored := *comment
+	stored.Author = nil
+	s.comments[comment.ID] = stored
+	return nil
+}
+
+func (s *Memory) DeleteComment(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if comment, ok := s.comments[id]; ok {
+		comment.DeletedAt.Time, comment.DeletedAt.Valid = time.Now(), true
+		s.comments[id] = comment
+	}
+	return nil
+}
+
+func (s *Memory) GetSchedule(id uint) (models.MaintenanceSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return schedule, ErrNotFound
+	}
+	return schedule, nil
+}
+
+func (s *Memory) ListSchedules(filter ScheduleFilter) ([]models.MaintenanceSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var schedules []models.MaintenanceSchedule
+	for _, id := range sortedIDs(s.schedules) {
+		schedule := s.schedules[id]
+		equipment := s.equipment[schedule.EquipmentID]
+		if filter.VisibleTo != nil && !EquipmentVisible(equipment, *filter.VisibleTo) {
+			continue
+		}
+		if filter.EquipmentID != 0 && schedule.EquipmentID != filter.EquipmentID {
+			continue
+		}
+		schedule.Equipment = equipment
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func (s *Memory) CreateSchedule(schedule *models.MaintenanceSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule.ID = s.id()
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+	s.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (s *Memory) SaveSchedule(schedule *models.MaintenanceSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[schedule.ID]; !ok {
+		return ErrNotFound
+	}
+	schedule.UpdatedAt = time.Now()
+	stored := *schedule
+	stored.Equipment = models.Equipment{}
+	s.schedules[schedule.ID] = stored
+	return nil
+}
+
+func (s *Memory) DeleteSchedule(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules, id)
+	return nil
+}
+
+func (s *Memory) GetMeter(id uint) (models.Meter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meter, ok := s.meters[id]
+	if !ok {
+		return meter, ErrNotFound
+	}
+	return meter, nil
+}
+
+func (s *Memory) ListMeters(equipmentID uint) ([]models.Meter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var meters []models.Meter
+	for _, id := range sortedIDs(s.meters) {
+		meter := s.meters[id]
+		if meter.EquipmentID != equipmentID {
+			continue
+		}
+		for _, ruleID := range sortedIDs(s.rules) {
+			if rule := s.rules[ruleID]; rule.MeterID == meter.ID {
+				meter.Rules = append(meter.Rules, rule)
+			}
+		}
+		meters = append(meters, meter)
+	}
+	return meters, nil
+}
+
+func (s *Memory) CreateMeter(meter *models.Meter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meter.ID = s.id()
+	meter.CreatedAt = time.Now()
+	s.meters[meter.ID] = *meter
+	return nil
+}
+
+func (s *Memory) RecordMeterReading(meter *models.Meter, reading *models.MeterReading) ([]models.MaintenanceRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.meters[meter.ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	*meter = stored
+
+	reading.ID = s.id()
+	reading.MeterID = meter.ID
+	reading.CreatedAt = time.Now()
+	s.readings[reading.ID] = *reading
+
+	if meter.LastReadingAt != nil && reading.ReadAt.Before(*meter.LastReadingAt) {
+		return nil, nil
+	}
+	readAt := reading.ReadAt
+	meter.CurrentReading = reading.Value
+	meter.LastReadingAt = &readAt
+	s.meters[meter.ID] = *meter
+
+	equipment := s.equipment[meter.EquipmentID]
+	if equipment.ArchivedAt != nil {
+		return nil, nil
+	}
+	var opened []models.MaintenanceRequest
+	for _, id := range sortedIDs(s.rules) {
+		rule := s.rules[id]
+		if rule.MeterID != meter.ID || !rule.Active {
+			continue
+		}
+		changed, fires := services.ApplyReading(&rule, reading.Value)
+		if !changed {
+			continue
+		}
+		s.rules[id] = rule
+		if !fires {
+			continue
+		}
+		req := services.MeterRuleRequest(rule, *reading)
+		services.PrepareRequest(&req, equipment)
+		if err := s.createRequest(&req); err != nil {
+			return nil, err
+		}
+		opened = append(opened, req)
+	}
+	return opened, nil
+}
+
+func (s *Memory) ListMeterReadings(meterID uint, limit int) ([]models.MeterReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var readings []models.MeterReading
+	for _, reading := range s.readings {
+		if reading.MeterID == meterID {
+			readings = append(readings, reading)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].ReadAt.After(readings[j].ReadAt) })
+	if limit > 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+func (s *Memory) GetMeterRule(id uint) (models.MeterRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule, ok := s.rules[id]
+	if !ok {
+		return rule, ErrNotFound
+	}
+	return rule, nil
+}
+
+func (s *Memory) CreateMeterRule(rule *models.MeterRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.ID = s.id()
+	rule.CreatedAt = time.Now()
+	s.rules[rule.ID] = *rule
+	return nil
+}
+
+func (s *Memory) DeleteMeterRule(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *Memory) GetUser(id uint) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return user, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *Memory) FindUserByEmail(email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range sortedIDs(s.users) {
+		if s.users[id].Email == email {
+			return s.users[id], nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *Memory) FindUserByResetToken(token string, now time.Time) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range sortedIDs(s.users) {
+		user := s.users[id]
+		if token != "" && user.PasswordResetToken == token && user.PasswordResetAt.After(now) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *Memory) FindUserByOIDCSubject(subject string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range sortedIDs(s.users) {
+		if subject != "" && s.users[id].OIDCSubject == subject {
+			return s.users[id], nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func userMatches(user models.User, filter UserFilter) bool {
+	if filter.Role != "" && user.Role != filter.Role {
+		return false
+	}
+	if filter.TeamID != 0 && (user.TeamID == nil || *user.TeamID != filter.TeamID) {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		if !strings.Contains(strings.ToLower(user.Name), search) && !strings.Contains(strings.ToLower(user.Email), search) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Memory) ListUsers(filter UserFilter) ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []models.User
+	for _, id := range sortedIDs(s.users) {
+		if userMatches(s.users[id], filter) {
+			users = append(users, s.users[id])
+		}
+	}
+	return UserSorting.Apply(users, filter.Page), nil
+}
+
+func (s *Memory) CountUsers(filter UserFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, user := range s.users {
+		if userMatches(user, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) CountUsersByRole(role models.Role) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, user := range s.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) CreateUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return fmt.Errorf("duplicate key value violates unique constraint on email")
+		}
+	}
+	user.ID = s.id()
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *Memory) SaveUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user.ID == 0 {
+		user.ID = s.id()
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *Memory) GetTeam(id uint) (models.MaintenanceTeam, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team, ok := s.teams[id]
+	if !ok {
+		return team, ErrNotFound
+	}
+	return team, nil
+}
+
+func (s *Memory) FindTeamByName(name string) (models.MaintenanceTeam, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range sortedIDs(s.teams) {
+		if strings.EqualFold(s.teams[id].Name, name) {
+			return s.teams[id], nil
+		}
+	}
+	return models.MaintenanceTeam{}, ErrNotFound
+}
+
+func teamMatches(team models.MaintenanceTeam, filter TeamFilter) bool {
+	return filter.Search == "" || strings.Contains(strings.ToLower(team.Name), strings.ToLower(filter.Search))
+}
+
+func (s *Memory) ListTeams(filter TeamFilter) ([]models.MaintenanceTeam, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var teams []models.MaintenanceTeam
+	for _, id := range sortedIDs(s.teams) {
+		team := s.teams[id]
+		if !teamMatches(team, filter) {
+			continue
+		}
+		team.Members = nil
+		for _, userID := range sortedIDs(s.users) {
+			if user := s.users[userID]; user.TeamID != nil && *user.TeamID == team.ID {
+				team.Members = append(team.Members, user)
+			}
+		}
+		teams = append(teams, team)
+	}
+	return TeamSorting.Apply(teams, filter.Page), nil
+}
+
+func (s *Memory) CountTeams(filter TeamFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, team := range s.teams {
+		if teamMatches(team, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) CreateTeam(team *models.MaintenanceTeam) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	team.ID = s.id()
+	team.Members = nil
+	s.teams[team.ID] = *team
+	return nil
+}
+
+func (s *Memory) CreateRefreshToken(token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createRefreshToken(token)
+}
+
+func (s *Memory) createRefreshToken(token *models.RefreshToken) error {
+	for _, t := range s.tokens {
+		if t.TokenHash == token.TokenHash {
+			return fmt.Errorf("duplicate refresh token")
+		}
+	}
+	token.ID = s.id()
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	s.tokens[token.ID] = *token
+	return nil
+}
+
+func (s *Memory) FindRefreshToken(hash string) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.TokenHash == hash {
+			return t, nil
+		}
+	}
+	return models.RefreshToken{}, ErrNotFound
+}
+
+func (s *Memory) RotateRefreshToken(old models.RefreshToken, next *models.RefreshToken, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.tokens[old.ID]
+	if !ok || current.RevokedAt != nil {
+		return ErrTokenReused
+	}
+	current.RevokedAt = &now
+	s.tokens[old.ID] = current
+	return s.createRefreshToken(next)
+}
+
+func (s *Memory) RevokeSession(sessionID string, now time.Time) error {
+	return s.revokeTokens(now, func(t models.RefreshToken) bool { return t.SessionID == sessionID })
+}
+
+func (s *Memory) RevokeUserSessions(userID uint, now time.Time) error {
+	return s.revokeTokens(now, func(t models.RefreshToken) bool { return t.UserID == userID })
+}
+
+func (s *Memory) revokeTokens(now time.Time, match func(models.RefreshToken) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.tokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+			s.tokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (s *Memory) SessionActive(sessionID string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.SessionID == sessionID && t.RevokedAt == nil && t.ExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Memory) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, code := range s.recovery {
+		if code.UserID == userID {
+			delete(s.recovery, id)
+		}
+	}
+	for _, hash := range hashes {
+		code := models.RecoveryCode{ID: s.id(), UserID: userID, CodeHash: hash, CreatedAt: time.Now()}
+		s.recovery[code.ID] = code
+	}
+	return nil
+}
+
+func (s *Memory) UseRecoveryCode(userID uint, hash string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, code := range s.recovery {
+		if code.UserID == userID && code.CodeHash == hash && code.UsedAt == nil {
+			code.UsedAt = &now
+			s.recovery[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Memory) GetThrottle(key string) (models.Throttle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if throttle, ok := s.throttles[key]; ok {
+		return throttle, nil
+	}
+	return models.Throttle{Key: key}, nil
+}
+
+func (s *Memory) HitThrottle(key string, now time.Time, window time.Duration) (models.Throttle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	throttle, ok := s.throttles[key]
+	if !ok || !throttle.WindowStart.After(now.Add(-window)) {
+		throttle.Key, throttle.Count, throttle.WindowStart = key, 0, now
+	}
+	throttle.Count++
+	s.throttles[key] = throttle
+	return throttle, nil
+}
+
+func (s *Memory) BlockThrottle(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if throttle, ok := s.throttles[key]; ok {
+		throttle.BlockedUntil = until
+		s.throttles[key] = throttle
+	}
+	return nil
+}
+
+func (s *Memory) ClearThrottle(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.throttles, key)
+	return nil
+}
+
+func (s *Memory) GetInvitation(id uint) (models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invitation, ok := s.invites[id]
+	if !ok {
+		return invitation, ErrNotFound
+	}
+	return invitation, nil
+}
+
+func (s *Memory) ListOpenInvitations() ([]models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var invitations []models.Invitation
+	ids := sortedIDs(s.invites)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if invitation := s.invites[ids[i]]; invitation.Open() {
+			invitations = append(invitations, invitation)
+		}
+	}
+	return invitations, nil
+}
+
+func (s *Memory) FindOpenInvitation(email string) (models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range sortedIDs(s.invites) {
+		if invitation := s.invites[id]; invitation.Email == email && invitation.Open() {
+			return invitation, nil
+		}
+	}
+	return models.Invitation{}, ErrNotFound
+}
+
+func (s *Memory) CreateInvitation(invitation *models.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invitation.ID = s.id()
+	invitation.CreatedAt = time.Now()
+	s.invites[invitation.ID] = *invitation
+	return nil
+}
+
+func (s *Memory) SaveInvitation(invitation *models.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.invites[invitation.ID]; !ok {
+		return ErrNotFound
+	}
+	s.invites[invitation.ID] = *invitation
+	return nil
+}
+
+func (s *Memory) AcceptInvitation(invitation *models.Invitation, user *models.User, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.invites[invitation.ID]
+	if !ok || !current.Open() || current.Nonce != invitation.Nonce {
+		return ErrInvitationClosed
+	}
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return fmt.Errorf("duplicate key value violates unique constraint on email")
+		}
+	}
+	user.ID = s.id()
+	s.users[user.ID] = *user
+	current.AcceptedAt = &now
+	current.UserID = &user.ID
+	s.invites[current.ID] = current
+	*invitation = current
+	return nil
+}
+
+func (s *Memory) RevokeInvitation(invitation *models.Invitation, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.invites[invitation.ID]
+	if !ok || current.AcceptedAt != nil || current.RevokedAt != nil {
+		return ErrInvitationClosed
+	}
+	current.RevokedAt = &now
+	s.invites[current.ID] = current
+	invitation.RevokedAt = &now
+	return nil
+}
+
+func (s *Memory) CreateAPIKey(key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.apiKeys {
+		if existing.Prefix == key.Prefix {
+			return fmt.Errorf("duplicate key value violates unique constraint on prefix")
+		}
+	}
+	key.ID = s.id()
+	key.CreatedAt = time.Now()
+	s.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (s *Memory) GetAPIKey(id uint) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return key, ErrNotFound
+	}
+	return key, nil
+}
+
+func (s *Memory) FindAPIKeyByPrefix(prefix string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrNotFound
+}
+
+func (s *Memory) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []models.APIKey
+	ids := sortedIDs(s.apiKeys)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if key := s.apiKeys[ids[i]]; key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *Memory) RevokeAPIKey(id uint, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.apiKeys[id]; ok && key.RevokedAt == nil {
+		key.RevokedAt = &now
+		s.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (s *Memory) TouchAPIKey(id uint, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &now
+		s.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (s *Memory) CreateCalendarFeed(feed *models.CalendarFeed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.feeds {
+		if existing.TokenHash == feed.TokenHash {
+			return fmt.Errorf("duplicate key value violates unique constraint on token_hash")
+		}
+	}
+	feed.ID = s.id()
+	feed.CreatedAt = time.Now()
+	s.feeds[feed.ID] = *feed
+	return nil
+}
+
+func (s *Memory) GetCalendarFeed(id uint) (models.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[id]
+	if !ok {
+		return feed, ErrNotFound
+	}
+	return feed, nil
+}
+
+func (s *Memory) FindCalendarFeed(tokenHash string) (models.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, feed := range s.feeds {
+		if feed.TokenHash == tokenHash {
+			return feed, nil
+		}
+	}
+	return models.CalendarFeed{}, ErrNotFound
+}
+
+func (s *Memory) ListCalendarFeeds(userID uint) ([]models.CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var feeds []models.CalendarFeed
+	ids := sortedIDs(s.feeds)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if feed := s.feeds[ids[i]]; feed.UserID == userID {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+func (s *Memory) RevokeCalendarFeed(id uint, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[id]; ok && feed.RevokedAt == nil {
+		feed.RevokedAt = &now
+		s.feeds[id] = feed
+	}
+	return nil
+}
+
+func (s *Memory) TouchCalendarFeed(id uint, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[id]; ok {
+		feed.LastFetchedAt = &now
+		s.feeds[id] = feed
+	}
+	return nil
+}
+
+func (s *Memory) GetWebhook(id uint) (models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return webhook, ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (s *Memory) ListWebhooks() ([]models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var webhooks []models.Webhook
+	for _, id := range sortedIDs(s.webhooks) {
+		webhooks = append(webhooks, s.webhooks[id])
+	}
+	return webhooks, nil
+}
+
+func (s *Memory) CreateWebhook(webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhook.ID = s.id()
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	s.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (s *Memory) SaveWebhook(webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhooks[webhook.ID]; !ok {
+		return ErrNotFound
+	}
+	webhook.UpdatedAt = time.Now()
+	s.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (s *Memory) DeleteWebhook(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.webhooks, id)
+	for deliveryID, delivery := range s.delivered {
+		if delivery.WebhookID == id {
+			delete(s.delivered, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (s *Memory) GetWebhookDelivery(id uint) (models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery, ok := s.delivered[id]
+	if !ok {
+		return delivery, ErrNotFound
+	}
+	return delivery, nil
+}
+
+func (s *Memory) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery.ID = s.id()
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+	s.delivered[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *Memory) SaveWebhookDelivery(delivery *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.delivered[delivery.ID]; !ok {
+		return ErrNotFound
+	}
+	delivery.UpdatedAt = time.Now()
+	s.delivered[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *Memory) ListWebhookDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []models.WebhookDelivery
+	ids := sortedIDs(s.delivered)
+	for i := len(ids) - 1; i >= 0 && (limit <= 0 || len(deliveries) < limit); i-- {
+		if delivery := s.delivered[ids[i]]; delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *Memory) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []models.WebhookDelivery
+	for _, id := range sortedIDs(s.delivered) {
+		delivery := s.delivered[id]
+		if delivery.Status == models.DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	until := now.Add(lease)
+	for i := range due {
+		due[i].NextAttemptAt = &until
+		s.delivered[due[i].ID] = due[i]
+	}
+	return due, nil
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gearguard/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed or were issued
+// for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects one page of a listing in a stable order: rows are sorted by
+// Sort, then ID, and start right after the row the cursor points at. The zero
+// Page lists everything by ID, as listings always have.
+type Page struct {
+	Limit int    // Rows to return; 0 for all of them
+	Sort  string // One of the listing's sort fields; "" sorts by ID only
+	Desc  bool
+	After *Cursor // Position of the last row of the previous page
+}
+
+// Cursor is a row's position in a sort order
+type Cursor struct {
+	Sort  string      `json:"s,omitempty"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v,omitempty"`
+	ID    uint        `json:"id"`
+}
+
+// SortField is something a listing can be sorted by
+type SortField[T any] struct {
+	Column string              // SQL expression; NULLs must be coalesced so keyset comparisons hold
+	Value  func(T) interface{} // The same value read off a row: a string, float64 or time.Time
+}
+
+// Sorting describes how rows of one kind can be ordered and paged
+type Sorting[T any] struct {
+	ID     func(T) uint
+	Fields map[string]SortField[T]
+}
+
+// Names lists the sort fields, for error messages
+func (s Sorting[T]) Names() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Sorting[T]) value(page Page, row T) interface{} {
+	if field, ok := s.Fields[page.Sort]; ok {
+		return field.Value(row)
+	}
+	return nil
+}
+
+// Cursor encodes the position of row in page's order
+func (s Sorting[T]) Cursor(page Page, row T) string {
+	data, _ := json.Marshal(Cursor{Sort: page.Sort, Desc: page.Desc, Value: s.value(page, row), ID: s.ID(row)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor from Cursor, which must have been made for
+// the same sort order as page
+func (s Sorting[T]) ParseCursor(raw string, page Page) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != page.Sort || cursor.Desc != page.Desc {
+		return nil, ErrInvalidCursor
+	}
+
+	field, ok := s.Fields[page.Sort]
+	if !ok {
+		cursor.Value = nil
+		return &cursor, nil
+	}
+	// JSON loses the value's type; the field's zero value tells what it was
+	var zero T
+	switch field.Value(zero).(type) {
+	case time.Time:
+		text, _ := cursor.Value.(string)
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Value = t
+	case float64:
+		if _, ok := cursor.Value.(float64); !ok {
+			return nil, ErrInvalidCursor
+		}
+	case string:
+		if _, ok := cursor.Value.(string); !ok {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+// compare orders a row against a position, ascending
+func (s Sorting[T]) compare(page Page, row T, value interface{}, id uint) int {
+	if c := compareValues(s.value(page, row), value); c != 0 {
+		return c
+	}
+	return cmp.Compare(s.ID(row), id)
+}
+
+// Apply sorts and pages rows in memory the way Query does in SQL
+func (s Sorting[T]) Apply(rows []T, page Page) []T {
+	direction := 1
+	if page.Desc {
+		direction = -1
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return direction*s.compare(page, rows[i], s.value(page, rows[j]), s.ID(rows[j])) < 0
+	})
+	if page.After != nil {
+		start := sort.Search(len(rows), func(i int) bool {
+			return direction*s.compare(page, rows[i], page.After.Value, page.After.ID) > 0
+		})
+		rows = rows[start:]
+	}
+	if page.Limit > 0 && len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+	}
+	return rows
+}
+
+// Query orders query by page's sort, then ID, and restricts it to the page
+func (s Sorting[T]) Query(query *gorm.DB, page Page) *gorm.DB {
+	direction, after := "ASC", ">"
+	if page.Desc {
+		direction, after = "DESC", "<"
+	}
+	field, sorted := s.Fields[page.Sort]
+	if page.After != nil {
+		if sorted {
+			query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", field.Column, after, field.Column, after),
+				page.After.Value, page.After.Value, page.After.ID)
+		} else {
+			query = query.Where(fmt.Sprintf("id %s ?", after), page.After.ID)
+		}
+	}
+	if sorted {
+		query = query.Order(field.Column + " " + direction)
+	}
+	query = query.Order("id " + direction)
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	return query
+}
+
+// Columns are nullable, so sort columns treat NULL like the Go zero value
+func textColumn(name string) string   { return "COALESCE(" + name + ", '')" }
+func timeColumn(name string) string   { return "COALESCE(" + name + ", '0001-01-01 00:00:00+00')" }
+func numberColumn(name string) string { return "COALESCE(" + name + ", 0)" }
+
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// RequestSorting lists the sort fields of maintenance requests
+var RequestSorting = Sorting[models.MaintenanceRequest]{
+	ID: func(r models.MaintenanceRequest) uint { return r.ID },
+	Fields: map[string]SortField[models.MaintenanceRequest]{
+		"created_at":     {timeColumn("created_at"), func(r models.MaintenanceRequest) interface{} { return r.CreatedAt }},
+		"updated_at":     {timeColumn("updated_at"), func(r models.MaintenanceRequest) interface{} { return r.UpdatedAt }},
+		"scheduled_date": {timeColumn("scheduled_date"), func(r models.MaintenanceRequest) interface{} { return optionalTime(r.ScheduledDate) }},
+		"subject":        {textColumn("subject"), func(r models.MaintenanceRequest) interface{} { return r.Subject }},
+		"status":         {textColumn("status"), func(r models.MaintenanceRequest) interface{} { return string(r.Status) }},
+		"total_cost":     {numberColumn("total_cost"), func(r models.MaintenanceRequest) interface{} { return r.TotalCost }},
+	},
+}
+
+// EquipmentSorting lists the sort fields of equipment
+var EquipmentSorting = Sorting[models.Equipment]{
+	ID: func(e models.Equipment) uint { return e.ID },
+	Fields: map[string]SortField[models.Equipment]{
+		"name":          {textColumn("name"), func(e models.Equipment) interface{} { return e.Name }},
+		"category":      {textColumn("category"), func(e models.Equipment) interface{} { return e.Category }},
+		"department":    {textColumn("department"), func(e models.Equipment) interface{} { return e.Department }},
+		"serial_number": {textColumn("serial_number"), func(e models.Equipment) interface{} { return e.SerialNumber }},
+		"purchase_date": {timeColumn("purchase_date"), func(e models.Equipment) interface{} { return e.PurchaseDate }},
+	},
+}
+
+// TeamSorting lists the sort fields of maintenance teams
+var TeamSorting = Sorting[models.MaintenanceTeam]{
+	ID: func(t models.MaintenanceTeam) uint { return t.ID },
+	Fields: map[string]SortField[models.MaintenanceTeam]{
+		"name":        {textColumn("name"), func(t models.MaintenanceTeam) interface{} { return t.Name }},
+		"hourly_rate": {numberColumn("hourly_rate"), func(t models.MaintenanceTeam) interface{} { return t.HourlyRate }},
+	},
+}
+
+// UserSorting lists the sort fields of users
+var UserSorting = Sorting[models.User]{
+	ID: func(u models.User) uint { return u.ID },
+	Fields: map[string]SortField[models.User]{
+		"name":  {textColumn("name"), func(u models.User) interface{} { return u.Name }},
+		"email": {textColumn("email"), func(u models.User) interface{} { return u.Email }},
+	},
+}
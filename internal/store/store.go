@@ -0,0 +1,310 @@
+// Package store defines the persistence interfaces used by the HTTP handlers,
+// with a GORM implementation for production and an in-memory one for tests.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/services"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrTokenReused is returned when rotating a refresh token that was already used
+var ErrTokenReused = errors.New("refresh token already used")
+
+// ErrInvitationClosed is returned when accepting an invitation that was
+// accepted, revoked or resent in the meantime
+var ErrInvitationClosed = errors.New("invitation is no longer open")
+
+// EquipmentFilter narrows equipment listings and counts
+type EquipmentFilter struct {
+	ID           uint
+	VisibleTo    *models.User // Only equipment this user is allowed to see
+	Search       string       // Name or department contains (case-insensitive)
+	Usable       *bool
+	Archived     bool // Archived equipment instead of active equipment
+	TeamID       uint // Maintenance team
+	TechnicianID uint // Default technician
+	EmployeeID   uint // Owner
+	Category     string
+	Department   string
+	LocationID   uint   // Placed directly at this location
+	ParentIDs    []uint // Sub-assemblies of any of these
+	LocationPath string // At this location or anywhere below it, see models.Location.Path
+	Page         Page   // Listings only
+}
+
+// EquipmentVisible reports whether user may see e: Employees see what they
+// own, Technicians what they are the default technician of, Managers everything
+func EquipmentVisible(e models.Equipment, user models.User) bool {
+	switch user.Role {
+	case models.RoleEmployee:
+		return e.EmployeeID != nil && *e.EmployeeID == user.ID
+	case models.RoleTechnician:
+		return e.DefaultTechnicianID != nil && *e.DefaultTechnicianID == user.ID
+	}
+	return true
+}
+
+// RequestVisible reports whether user may see req, whose equipment is
+// equipment: Employees see requests they created or for equipment they own,
+// Technicians those for equipment they are the default technician of,
+// Managers everything
+func RequestVisible(req models.MaintenanceRequest, equipment models.Equipment, user models.User) bool {
+	switch user.Role {
+	case models.RoleEmployee:
+		return req.CreatedByID == user.ID || (equipment.EmployeeID != nil && *equipment.EmployeeID == user.ID)
+	case models.RoleTechnician:
+		return equipment.DefaultTechnicianID != nil && *equipment.DefaultTechnicianID == user.ID
+	}
+	return true
+}
+
+// RequestFilter narrows request listings, counts and cost totals
+type RequestFilter struct {
+	VisibleTo       *models.User // Only requests this user is allowed to see
+	EquipmentID     uint
+	EquipmentIDs    []uint // EquipmentID is one of these
+	Status          models.RequestStatus
+	Statuses        []models.RequestStatus // Status is one of these
+	ExcludeStatuses []models.RequestStatus // Status is none of these
+	Type            models.RequestType
+	ScheduledFrom   *time.Time // Scheduled on or after
+	ScheduledBefore *time.Time // Scheduled strictly before
+	EndsAfter       *time.Time // Scheduled to end strictly after, see MaintenanceRequest.ScheduledEnd
+	CreatedSince    *time.Time
+	CreatedBefore   *time.Time // Created strictly before
+	TechnicianID    uint
+	TeamID          uint
+	Page            Page // Listings only
+}
+
+// TeamFilter narrows team listings and counts
+type TeamFilter struct {
+	Search string // Name contains (case-insensitive)
+	Page   Page   // Listings only
+}
+
+// ScheduleFilter narrows schedule listings
+type ScheduleFilter struct {
+	VisibleTo   *models.User // Only schedules of equipment this user is allowed to see
+	EquipmentID uint
+}
+
+// LocationFilter narrows location listings
+type LocationFilter struct {
+	ParentID uint // Direct children of this location
+}
+
+// UserFilter narrows user listings and counts
+type UserFilter struct {
+	Role   models.Role
+	TeamID uint
+	Search string // Name or email contains (case-insensitive)
+	Page   Page   // Listings only
+}
+
+// EquipmentStore persists equipment
+type EquipmentStore interface {
+	GetEquipment(id uint) (models.Equipment, error)
+	ListEquipment(filter EquipmentFilter) ([]models.Equipment, error) // With team, owner and default technician
+	CountEquipment(filter EquipmentFilter) (int64, error)
+	CreateEquipment(equipment *models.Equipment) error
+	SaveEquipment(equipment *models.Equipment) error
+	// UpdateEquipment saves only the named fields of equipment (e.g.
+	// "ArchivedAt"), leaving columns changed concurrently elsewhere alone
+	UpdateEquipment(equipment *models.Equipment, fields ...string) error
+	TransferEquipment(equipment *models.Equipment, transfer *models.EquipmentTransfer) error // Saves both together
+	ListEquipmentTransfers(equipmentID uint) ([]models.EquipmentTransfer, error)             // Newest first
+}
+
+// LocationStore persists the location tree
+type LocationStore interface {
+	GetLocation(id uint) (models.Location, error)
+	ListLocations(filter LocationFilter) ([]models.Location, error) // Ordered by path
+	CreateLocation(loc *models.Location) error                      // Sets its path below its parent
+	RenameLocation(id uint, name string) error
+	// MoveLocation re-parents loc under parent (nil for the top of the tree),
+	// rewriting the path of its whole subtree
+	MoveLocation(loc *models.Location, parent *models.Location) error
+	DeleteLocation(id uint) error
+	// ImportLegacyLocations places equipment by its free-text location, see
+	// services.ImportLegacyLocations
+	ImportLegacyLocations(siteName string, kind models.LocationKind, mapping map[string]string, dryRun bool) ([]services.LegacyLocationGroup, error)
+}
+
+// RequestStore persists maintenance requests
+type RequestStore interface {
+	GetRequest(id uint) (models.MaintenanceRequest, error)                  // With equipment
+	ListRequests(filter RequestFilter) ([]models.MaintenanceRequest, error) // With equipment, team and technician
+	CountRequests(filter RequestFilter) (int64, error)
+	TotalCost(filter RequestFilter) (float64, error)
+	CostTotals(filter RequestFilter) (services.CostBreakdown, error)
+	CreateRequest(req *models.MaintenanceRequest) error
+	// UpdateRequest saves a request, consuming the given parts from stock,
+	// marking its equipment unusable if it is scrapped and recalculating its
+	// costs, all or nothing, then reloads it.
+	UpdateRequest(req *models.MaintenanceRequest, parts []models.PartUsage, actorID uint) error
+}
+
+// CommentStore persists request comments
+type CommentStore interface {
+	GetComment(id uint, deleted bool) (models.RequestComment, error)             // deleted also finds deleted comments
+	ListComments(requestID uint, internal bool) ([]models.RequestComment, error) // With author, oldest first; internal includes internal notes
+	CreateComment(comment *models.RequestComment) error
+	SaveComment(comment *models.RequestComment) error
+	DeleteComment(id uint) error // Kept with its history, see models.RequestComment
+}
+
+// ScheduleStore persists preventive maintenance schedules
+type ScheduleStore interface {
+	GetSchedule(id uint) (models.MaintenanceSchedule, error)
+	ListSchedules(filter ScheduleFilter) ([]models.MaintenanceSchedule, error) // With equipment
+	CreateSchedule(schedule *models.MaintenanceSchedule) error
+	SaveSchedule(schedule *models.MaintenanceSchedule) error
+	DeleteSchedule(id uint) error // Requests already generated are kept
+}
+
+// MeterStore persists usage meters, their readings and rules
+type MeterStore interface {
+	GetMeter(id uint) (models.Meter, error)
+	ListMeters(equipmentID uint) ([]models.Meter, error) // With rules
+	CreateMeter(meter *models.Meter) error
+	// RecordMeterReading stores reading and opens requests for the rules it
+	// fires, atomically, see services.RecordReading; meter is reloaded
+	RecordMeterReading(meter *models.Meter, reading *models.MeterReading) ([]models.MaintenanceRequest, error)
+	ListMeterReadings(meterID uint, limit int) ([]models.MeterReading, error) // Newest first
+	GetMeterRule(id uint) (models.MeterRule, error)
+	CreateMeterRule(rule *models.MeterRule) error
+	DeleteMeterRule(id uint) error
+}
+
+// UserStore persists users
+type UserStore interface {
+	GetUser(id uint) (models.User, error)
+	FindUserByEmail(email string) (models.User, error)
+	FindUserByResetToken(token string, now time.Time) (models.User, error) // Unexpired tokens only
+	FindUserByOIDCSubject(subject string) (models.User, error)
+	ListUsers(filter UserFilter) ([]models.User, error)
+	CountUsers(filter UserFilter) (int64, error)
+	CountUsersByRole(role models.Role) (int64, error)
+	CreateUser(user *models.User) error
+	SaveUser(user *models.User) error
+}
+
+// TeamStore persists maintenance teams
+type TeamStore interface {
+	GetTeam(id uint) (models.MaintenanceTeam, error)
+	FindTeamByName(name string) (models.MaintenanceTeam, error)    // Case-insensitive
+	ListTeams(filter TeamFilter) ([]models.MaintenanceTeam, error) // With members
+	CountTeams(filter TeamFilter) (int64, error)
+	CreateTeam(team *models.MaintenanceTeam) error
+}
+
+// TokenStore persists refresh tokens. A session is the chain of tokens rotated
+// from one login; it is active while its latest token is unrevoked and unexpired.
+type TokenStore interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	FindRefreshToken(hash string) (models.RefreshToken, error)
+	// RotateRefreshToken revokes old and stores next in its place, atomically.
+	// It returns ErrTokenReused if old was revoked in the meantime.
+	RotateRefreshToken(old models.RefreshToken, next *models.RefreshToken, now time.Time) error
+	RevokeSession(sessionID string, now time.Time) error
+	RevokeUserSessions(userID uint, now time.Time) error
+	SessionActive(sessionID string, now time.Time) (bool, error)
+}
+
+// RecoveryCodeStore persists hashed two-factor recovery codes
+type RecoveryCodeStore interface {
+	// ReplaceRecoveryCodes discards the user's codes and stores hashes instead
+	// (none to just discard them).
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	// UseRecoveryCode marks an unused code as used, reporting whether one matched
+	UseRecoveryCode(userID uint, hash string, now time.Time) (bool, error)
+}
+
+// ThrottleStore tracks attempts per key (account, client IP) for rate limiting
+type ThrottleStore interface {
+	GetThrottle(key string) (models.Throttle, error) // Zero value for unknown keys
+	// HitThrottle counts one attempt against key, starting a new window when the
+	// current one is older than window, and returns the updated state.
+	HitThrottle(key string, now time.Time, window time.Duration) (models.Throttle, error)
+	BlockThrottle(key string, until time.Time) error
+	ClearThrottle(key string) error
+}
+
+// InvitationStore persists invitations
+type InvitationStore interface {
+	GetInvitation(id uint) (models.Invitation, error)
+	ListOpenInvitations() ([]models.Invitation, error)          // Neither accepted nor revoked, newest first
+	FindOpenInvitation(email string) (models.Invitation, error) // ErrNotFound if none
+	CreateInvitation(invitation *models.Invitation) error
+	SaveInvitation(invitation *models.Invitation) error
+	// AcceptInvitation creates user and marks the invitation accepted by it,
+	// atomically. It returns ErrInvitationClosed if the invitation is no longer
+	// open or its nonce changed.
+	AcceptInvitation(invitation *models.Invitation, user *models.User, now time.Time) error
+	// RevokeInvitation marks the invitation revoked at now if it is still
+	// open, and returns ErrInvitationClosed otherwise
+	RevokeInvitation(invitation *models.Invitation, now time.Time) error
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	CreateAPIKey(key *models.APIKey) error
+	GetAPIKey(id uint) (models.APIKey, error)
+	FindAPIKeyByPrefix(prefix string) (models.APIKey, error)
+	ListAPIKeys(userID uint) ([]models.APIKey, error) // Including revoked and expired ones, newest first
+	RevokeAPIKey(id uint, now time.Time) error
+	TouchAPIKey(id uint, now time.Time) error // Records a use
+}
+
+// CalendarFeedStore persists calendar subscription feeds
+type CalendarFeedStore interface {
+	CreateCalendarFeed(feed *models.CalendarFeed) error
+	GetCalendarFeed(id uint) (models.CalendarFeed, error)
+	FindCalendarFeed(tokenHash string) (models.CalendarFeed, error)
+	ListCalendarFeeds(userID uint) ([]models.CalendarFeed, error) // Including revoked ones, newest first
+	RevokeCalendarFeed(id uint, now time.Time) error
+	TouchCalendarFeed(id uint, now time.Time) error // Records a fetch
+}
+
+// WebhookStore persists webhooks and their delivery log
+type WebhookStore interface {
+	GetWebhook(id uint) (models.Webhook, error)
+	ListWebhooks() ([]models.Webhook, error) // Oldest first
+	CreateWebhook(webhook *models.Webhook) error
+	SaveWebhook(webhook *models.Webhook) error
+	DeleteWebhook(id uint) error // Along with its deliveries
+	GetWebhookDelivery(id uint) (models.WebhookDelivery, error)
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) error
+	SaveWebhookDelivery(delivery *models.WebhookDelivery) error
+	ListWebhookDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error) // Newest first
+	// ClaimDueWebhookDeliveries takes pending deliveries whose next attempt is
+	// due at now, soonest first, and pushes their next attempt to now+lease in
+	// the same step, so dispatchers sharing the store never take the same one
+	ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+}
+
+// Store bundles every store; both implementations satisfy it
+type Store interface {
+	EquipmentStore
+	LocationStore
+	RequestStore
+	CommentStore
+	ScheduleStore
+	MeterStore
+	UserStore
+	TeamStore
+	TokenStore
+	RecoveryCodeStore
+	ThrottleStore
+	InvitationStore
+	APIKeyStore
+	CalendarFeedStore
+	WebhookStore
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// devSigningKey is only used when no key is configured, so local setups keep working
+const devSigningKey = "super_secret_key_change_in_prod"
+
+// signingKeys holds every HMAC key tokens may be verified with, by kid. Tokens are
+// signed with activeKeyID; older keys stay listed until their tokens have expired.
+var (
+	signingKeys = map[string][]byte{"dev": []byte(devSigningKey)}
+	activeKeyID = "dev"
+)
+
+// LoadSigningKeys reads the JWT keys from the environment:
+//
+//	JWT_KEYS="2026-10:secret,2026-04:older-secret"  keys by kid, to rotate
+//	JWT_ACTIVE_KEY=2026-10                          kid to sign with (default: the first)
+//	JWT_SECRET=secret                               single key, kid "default"
+//
+// With none of them set it falls back to a development key and logs a warning.
+func LoadSigningKeys() error {
+	keys := map[string][]byte{}
+	var order []string
+
+	if raw := os.Getenv("JWT_KEYS"); raw != "" {
+		for _, entry := range strings.Split(raw, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || kid == "" || secret == "" {
+				return fmt.Errorf("JWT_KEYS entries must look like kid:secret")
+			}
+			if _, dup := keys[kid]; dup {
+				return fmt.Errorf("JWT_KEYS lists kid %q twice", kid)
+			}
+			keys[kid] = []byte(secret)
+			order = append(order, kid)
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys["default"] = []byte(secret)
+		order = append(order, "default")
+	}
+
+	if len(keys) == 0 {
+		log.Println("WARNING: JWT_KEYS/JWT_SECRET not set, signing tokens with the development key")
+		return nil
+	}
+
+	active := os.Getenv("JWT_ACTIVE_KEY")
+	if active == "" {
+		active = order[0]
+	}
+	if _, ok := keys[active]; !ok {
+		return fmt.Errorf("JWT_ACTIVE_KEY %q is not in JWT_KEYS", active)
+	}
+
+	signingKeys, activeKeyID = keys, active
+	return nil
+}
+
+// ActiveSigningKey is the secret currently used to sign, also used for other HMACs
+func ActiveSigningKey() []byte {
+	return signingKeys[activeKeyID]
+}
+
+// SignToken signs claims with the active key, naming it in the kid header
+func SignToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = activeKeyID
+	return token.SignedString(signingKeys[activeKeyID])
+}
+
+// ParseToken verifies tokenString with the key named by its kid header and fills claims
+func ParseToken(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ClientIP returns the address of the client. Behind a reverse proxy set
+// TRUST_PROXY_HEADERS=true to use the first X-Forwarded-For entry instead of
+// the proxy's own address.
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// FrontendURL is the web app's base URL, where links in emails and redirects point
+func FrontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:3000"
+}
+
+// APIURL is the API's public base URL, for links handed to other apps:
+// PUBLIC_API_URL, or else the address the request came in on
+func APIURL(r *http.Request) string {
+	if url := os.Getenv("PUBLIC_API_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = proto
+		}
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host + "/api"
+}
@@ -4,15 +4,41 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"gearguard/internal/models"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var SecretKey = []byte("super_secret_key_change_in_prod")
-
 // Claims struct
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    uint        `json:"user_id"`
+	Role      models.Role `json:"role"`
+	SessionID string      `json:"sid"` // Refresh token session the access token belongs to
+	jwt.RegisteredClaims
+}
+
+// ChallengeClaims identify a user who passed the password check but still has
+// to complete a step (Purpose) before receiving real tokens
+type ChallengeClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// InvitationClaims are carried by the link in an invitation email. The
+// registered ID must match the invitation's current nonce.
+type InvitationClaims struct {
+	InvitationID uint `json:"invitation_id"`
+	jwt.RegisteredClaims
+}
+
+// OIDCFlowClaims carry the secrets of a single sign-on attempt from its start
+// to the callback, so any replica can finish it. The browser keeps the token;
+// only State is ever sent to the identity provider.
+type OIDCFlowClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
 	jwt.RegisteredClaims
 }
 
@@ -20,6 +46,16 @@ type ContextKey string
 
 const UserIDKey ContextKey = "userID"
 
+// RoleKey carries the caller's models.Role from the token claims
+const RoleKey ContextKey = "role"
+
+// SessionKey carries the refresh token session of the caller's access token
+const SessionKey ContextKey = "session"
+
+// ScopesKey carries the scopes of the API key a request was made with; it is
+// absent for requests made with an access token
+const ScopesKey ContextKey = "scopes"
+
 func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
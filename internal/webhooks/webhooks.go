@@ -0,0 +1,277 @@
+// Package webhooks posts events to the URLs subscribed to them. Every delivery
+// is recorded before it is sent, signed with the webhook's secret, and retried
+// with exponential backoff until the receiver accepts it or attempts run out.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gearguard/internal/models"
+	"gearguard/internal/store"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-GearGuard-Event"     // The event type
+	HeaderDelivery  = "X-GearGuard-Delivery"  // The event ID, shared by redeliveries
+	HeaderTimestamp = "X-GearGuard-Timestamp" // Unix seconds, part of the signed content
+	HeaderSignature = "X-GearGuard-Signature" // "sha256=<hex>", see Sign
+)
+
+const (
+	// responseLimit is how much of a receiver's response body is kept
+	responseLimit = 1024
+	// dueBatch is how many due deliveries are attempted per run
+	dueBatch = 100
+)
+
+// Payload is the JSON body posted to receivers
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Sign returns the signature of a delivery: the hex HMAC-SHA256, keyed with
+// the webhook secret, of the timestamp header, a dot and the raw body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is Sign's for the same content, in
+// constant time; receivers written in Go can use it as is
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher records and sends deliveries
+type Dispatcher struct {
+	Store  store.WebhookStore
+	Client *http.Client
+
+	// MaxAttempts is how many times a delivery is tried before it is failed
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each one after
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between retries
+	MaxDelay time.Duration
+	// Lease is how long claimed deliveries are left to this dispatcher before
+	// another replica may take them over, should this one stop mid-batch. It
+	// must outlast sending a whole batch to receivers that time out.
+	Lease time.Duration
+
+	wake chan struct{}
+}
+
+// NewDispatcher returns a dispatcher retrying up to 8 times over about an hour
+// and a half, giving up on receivers that take more than 10 seconds
+func NewDispatcher(s store.WebhookStore) *Dispatcher {
+	return &Dispatcher{
+		Store:       s,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		Lease:       30 * time.Minute,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Start sends due deliveries in the background, as soon as events are
+// enqueued and every interval for retries
+func (d *Dispatcher) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			}
+			d.RunDue(time.Now())
+		}
+	}()
+	log.Printf("Webhook dispatcher started (retries checked every %s)", interval)
+}
+
+// Enqueue records a pending delivery of an event to every active webhook
+// subscribed to eventType; Start's loop sends them. Failures are logged: an
+// event that cannot be recorded must not fail the change that raised it.
+func (d *Dispatcher) Enqueue(eventType string, data interface{}) {
+	webhooks, err := d.Store.ListWebhooks()
+	if err != nil {
+		log.Printf("Webhooks: failed to load webhooks for %s: %v", eventType, err)
+		return
+	}
+
+	now := time.Now()
+	payload := Payload{ID: newEventID(), Type: eventType, CreatedAt: now, Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Webhooks: failed to encode %s: %v", eventType, err)
+		return
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(eventType) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       payload.ID,
+			EventType:     eventType,
+			Payload:       string(body),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := d.Store.CreateWebhookDelivery(&delivery); err != nil {
+			log.Printf("Webhooks: failed to queue %s for webhook %d: %v", eventType, webhook.ID, err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default: // Already woken
+		}
+	}
+}
+
+// Redeliver records a new delivery of the same event as delivery and sends
+// it right away, whatever became of the original
+func (d *Dispatcher) Redeliver(delivery models.WebhookDelivery, now time.Time) (models.WebhookDelivery, error) {
+	redelivery := models.WebhookDelivery{
+		WebhookID: delivery.WebhookID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Status:    models.DeliveryPending,
+	}
+	if err := d.Store.CreateWebhookDelivery(&redelivery); err != nil {
+		return redelivery, err
+	}
+	err := d.Attempt(&redelivery, now)
+	return redelivery, err
+}
+
+// RunDue attempts the pending deliveries due at now and returns how many were
+// attempted. Deliveries are claimed first, so replicas running it together
+// never send the same one twice.
+func (d *Dispatcher) RunDue(now time.Time) int {
+	due, err := d.Store.ClaimDueWebhookDeliveries(now, d.Lease, dueBatch)
+	if err != nil {
+		log.Println("Webhooks: failed to load due deliveries:", err)
+		return 0
+	}
+	for i := range due {
+		if err := d.Attempt(&due[i], now); err != nil {
+			log.Printf("Webhooks: delivery %d: %v", due[i].ID, err)
+		}
+	}
+	return len(due)
+}
+
+// Attempt sends delivery once and saves the outcome: succeeded on a 2xx
+// response, otherwise pending with the next attempt backed off, or failed once
+// MaxAttempts is reached or the webhook is gone or inactive. The error is
+// only about saving the outcome.
+func (d *Dispatcher) Attempt(delivery *models.WebhookDelivery, now time.Time) error {
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	webhook, err := d.Store.GetWebhook(delivery.WebhookID)
+	switch {
+	case err != nil:
+		d.fail(delivery, "Webhook not found")
+	case !webhook.Active:
+		d.fail(delivery, "Webhook is inactive")
+	default:
+		d.send(webhook, delivery, now)
+	}
+	return d.Store.SaveWebhookDelivery(delivery)
+}
+
+func (d *Dispatcher) send(webhook models.Webhook, delivery *models.WebhookDelivery, now time.Time) {
+	body := []byte(delivery.Payload)
+	r, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		d.fail(delivery, err.Error())
+		return
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", "GearGuard-Webhooks")
+	r.Header.Set(HeaderEvent, delivery.EventType)
+	r.Header.Set(HeaderDelivery, delivery.EventID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(r)
+	if err != nil {
+		d.retry(delivery, now, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = string(response)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+		return
+	}
+	d.retry(delivery, now, fmt.Sprintf("Receiver answered %s", resp.Status))
+}
+
+// retry schedules the next attempt, doubling the wait each time, or fails the
+// delivery when it has had all its attempts
+func (d *Dispatcher) retry(delivery *models.WebhookDelivery, now time.Time, reason string) {
+	if delivery.Attempts >= d.MaxAttempts {
+		d.fail(delivery, reason)
+		return
+	}
+	delay := d.BaseDelay
+	for i := 1; i < delivery.Attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxDelay {
+		delay = d.MaxDelay
+	}
+	next := now.Add(delay)
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = &next
+	delivery.Error = reason
+}
+
+func (d *Dispatcher) fail(delivery *models.WebhookDelivery, reason string) {
+	delivery.Status = models.DeliveryFailed
+	delivery.NextAttemptAt = nil
+	delivery.Error = reason
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}